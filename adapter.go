@@ -0,0 +1,127 @@
+package req
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// RequestAdapter adapts the serialized request (HTTP/1.1 wire format,
+// including the body) right before it's sent, e.g. hands it to an ICAP or
+// DLP scanner. It returns the modified serialized request, or nil if the
+// request is not modified, or an error to veto the request.
+type RequestAdapter func(ctx context.Context, rawRequest []byte) (adapted []byte, err error)
+
+// readRequestBody reads the body of the request from GetBody so that
+// req.Body is untouched, or buffers the streaming body (without GetBody)
+// and rebuilds req.Body from the buffer, so that the whole body is always
+// handed to the adapter.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	if req.Body == nil || req.Body == NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	setRequestBody(req, body)
+	return body, nil
+}
+
+// setRequestBody replaces the body of the request with body.
+func setRequestBody(req *http.Request, body []byte) {
+	req.ContentLength = int64(len(body))
+	req.TransferEncoding = nil
+	req.Body, req.GetBody = NoBody, nil
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+}
+
+// serializeRequest serializes the request with the body in HTTP/1.1 wire
+// format.
+func serializeRequest(req *http.Request, body []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\nHost: %s\r\n", valueOrDefault(req.Method, http.MethodGet), req.URL.RequestURI(), req.Host)
+	header := req.Header.Clone()
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+	if len(body) > 0 {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// adaptRequest passes the serialized request to the adapter, and applies
+// the modifications to req.
+func adaptRequest(req *http.Request, adapter RequestAdapter) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return fmt.Errorf("failed to read request body for the adapter: %w", err)
+	}
+	adapted, err := adapter(req.Context(), serializeRequest(req, body))
+	if err != nil {
+		return fmt.Errorf("request is vetoed by the adapter: %w", err)
+	}
+	if adapted == nil {
+		return nil
+	}
+	ar, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(adapted)))
+	if err != nil {
+		return fmt.Errorf("failed to parse the adapted request: %w", err)
+	}
+	body, err = io.ReadAll(ar.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the adapted request body: %w", err)
+	}
+	u := *req.URL
+	u.Path, u.RawPath, u.RawQuery = ar.URL.Path, ar.URL.RawPath, ar.URL.RawQuery
+	ar.Header.Del("Content-Length")
+	ar.Header.Del("Transfer-Encoding")
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	req.Method = ar.Method
+	req.URL = &u
+	req.Host = ar.Host
+	req.Header = ar.Header
+	setRequestBody(req, body)
+	return nil
+}
+
+// SetRequestAdapter set the adapter which is handed the fully serialized
+// request right before it's sent (after the request middlewares, before
+// the AWS signing), so that an external service (e.g. an ICAP or DLP
+// scanner) can inspect it, and modify or veto it before it leaves the
+// host. The upload callback is not called if the body is modified. For
+// example:
+//
+//	client.SetRequestAdapter(func(ctx context.Context, raw []byte) ([]byte, error) {
+//		if bytes.Contains(raw, []byte("CONFIDENTIAL")) {
+//			return nil, errors.New("confidential data is not allowed")
+//		}
+//		return nil, nil // not modified
+//	})
+func (c *Client) SetRequestAdapter(adapter RequestAdapter) *Client {
+	c.requestAdapter = adapter
+	return c
+}
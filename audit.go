@@ -0,0 +1,151 @@
+package req
+
+import (
+	"encoding/json"
+	"io"
+	urlpkg "net/url"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3/internal/rotate"
+)
+
+// AuditRecord is the structured record which is appended to the audit log
+// for each request attempt.
+type AuditRecord struct {
+	Time          time.Time         `json:"time"`
+	Principal     string            `json:"principal,omitempty"`
+	Method        string            `json:"method"`
+	Operation     string            `json:"operation"`
+	URL           string            `json:"url"`
+	Status        int               `json:"status,omitempty"`
+	BytesSent     int64             `json:"bytes_sent"`
+	BytesReceived int64             `json:"bytes_received"`
+	Duration      time.Duration     `json:"duration"`
+	Error         string            `json:"error,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// AuditOptions controls the audit log behavior.
+type AuditOptions struct {
+	// Output is the writer which records are appended to as JSON lines,
+	// it's ignored if Filename is set.
+	Output io.Writer
+	// Filename is the file which records are appended to as JSON lines.
+	Filename string
+	// MaxSize is the max size in bytes of the file before it's rotated,
+	// 0 means never rotate.
+	MaxSize int64
+	// MaxBackups is the max number of rotated files to retain, 0 means
+	// retain all of them.
+	MaxBackups int
+	// Principal returns who sends the request, e.g. the user or service
+	// account which the request is fired on behalf of.
+	Principal func(req *Request) string
+	// RedactURL returns the url written in the record, the default one
+	// removes the password and the values of query parameters.
+	RedactURL func(u *urlpkg.URL) string
+}
+
+type auditLogger struct {
+	mu     sync.Mutex
+	output io.Writer
+	opt    AuditOptions
+}
+
+func newAuditLogger(opt *AuditOptions) (*auditLogger, error) {
+	l := &auditLogger{opt: *opt, output: opt.Output}
+	if opt.Filename != "" {
+		w, err := rotate.New(opt.Filename, opt.MaxSize, opt.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		l.output = w
+	}
+	if l.output == nil {
+		l.output = io.Discard
+	}
+	if l.opt.RedactURL == nil {
+		l.opt.RedactURL = redactURL
+	}
+	return l, nil
+}
+
+func (l *auditLogger) close() error {
+	if c, ok := l.output.(io.Closer); ok && l.opt.Filename != "" {
+		return c.Close()
+	}
+	return nil
+}
+
+func (l *auditLogger) record(r *Request, resp *Response) error {
+	record := &AuditRecord{
+		Time:      r.StartTime,
+		Method:    r.Method,
+		Labels:    r.GetLabels(),
+		Operation: r.OperationName(),
+	}
+	if l.opt.Principal != nil {
+		record.Principal = l.opt.Principal(r)
+	}
+	if r.URL != nil {
+		record.URL = l.opt.RedactURL(r.URL)
+	}
+	record.BytesSent = requestBytesSent(r)
+	if resp.Response != nil {
+		record.Status = resp.StatusCode
+		record.BytesReceived = responseBytesReceived(resp)
+	}
+	if !r.StartTime.IsZero() {
+		record.Duration = time.Since(r.StartTime)
+	}
+	if resp.Err != nil {
+		record.Error = resp.Err.Error()
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.output.Write(b)
+	return err
+}
+
+// requestBytesSent returns the size of the request body.
+func requestBytesSent(r *Request) int64 {
+	if r.RawRequest != nil && r.RawRequest.ContentLength > 0 {
+		return r.RawRequest.ContentLength
+	}
+	return int64(len(r.Body))
+}
+
+// responseBytesReceived returns the size of the response body which is read
+// automatically, or the Content-Length if it's not read yet.
+func responseBytesReceived(resp *Response) int64 {
+	if resp.body != nil {
+		return int64(len(resp.body))
+	}
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
+
+// redactURL removes the password and the values of query parameters.
+func redactURL(u *urlpkg.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	ru := *u
+	query := ru.Query()
+	for k, vs := range query {
+		for i := range vs {
+			vs[i] = redactedValue
+		}
+		query[k] = vs
+	}
+	ru.RawQuery = query.Encode()
+	return ru.Redacted()
+}
@@ -0,0 +1,124 @@
+package req
+
+import (
+	"io"
+	"strings"
+)
+
+// AutoReadPolicy controls how the response body is read automatically
+// (see Client.SetAutoReadPolicy), so that the memory can be bounded when
+// the servers return many or large error bodies.
+type AutoReadPolicy struct {
+	// MaxSuccessBodySize is the max size in bytes of the body read
+	// automatically if the status code is less than 400, the rest is
+	// discarded, 0 means no limit.
+	MaxSuccessBodySize int64
+	// MaxErrorBodySize is the max size in bytes of the body read
+	// automatically if the status code is 400 or above (4xx and 5xx), the
+	// rest is discarded, 0 means no limit.
+	MaxErrorBodySize int64
+	// SkipContentTypes are the content types (prefix matched, e.g.
+	// "image/" or "application/octet-stream") whose body is never read
+	// automatically, which must be read and closed by the caller like
+	// Client.DisableAutoReadResponse, and it's not unmarshalled into the
+	// result or error set by Request.SetSuccessResult or SetErrorResult.
+	SkipContentTypes []string
+	// MaxConcurrentReads is the max number of the bodies read automatically
+	// at the same time, the others wait for their turn (or the cancellation
+	// of the request), which smooths the memory spikes when many responses
+	// of the fan-out requests arrive at once, 0 means no limit. The limit is
+	// per client, the cloned and derived clients have their own.
+	MaxConcurrentReads int
+}
+
+func (p *AutoReadPolicy) skip(resp *Response) bool {
+	if p == nil || len(p.SkipContentTypes) == 0 {
+		return false
+	}
+	contentType := strings.ToLower(resp.GetContentType())
+	for _, t := range p.SkipContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *AutoReadPolicy) maxBodySize(statusCode int) int64 {
+	if p == nil {
+		return 0
+	}
+	if statusCode >= 400 {
+		return p.MaxErrorBodySize
+	}
+	return p.MaxSuccessBodySize
+}
+
+// limitedBody reads at most n bytes from the body, and marks the response
+// truncated if there is more.
+type limitedBody struct {
+	io.ReadCloser
+	n    int64
+	resp *Response
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.n <= 0 {
+		var buf [1]byte
+		if n, _ := b.ReadCloser.Read(buf[:]); n > 0 {
+			b.resp.bodyTruncated = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err = b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	return
+}
+
+// autoReadResponse reads the response body automatically with the policy.
+func (c *Client) autoReadResponse(resp *Response) {
+	if sem := c.autoReadSem; sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-resp.Request.Context().Done():
+			resp.Body.Close()
+			resp.Err = resp.Request.Context().Err()
+			return
+		}
+	}
+	if limit := c.autoReadPolicy.maxBodySize(resp.StatusCode); limit > 0 {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, n: limit, resp: resp}
+	}
+	resp.ToBytes()
+}
+
+// SetAutoReadPolicy set the policy of reading the response body
+// automatically by the status class and content type, e.g. the error
+// bodies are capped at a few KB and the binary bodies are never read, which
+// bounds the memory on error storms. The truncated body is not unmarshalled
+// into the result or error (see Response.IsBodyTruncated), and the nil
+// policy reads all bodies fully (the default). For example:
+//
+//	client.SetAutoReadPolicy(&req.AutoReadPolicy{
+//		MaxErrorBodySize:   4 * 1024,
+//		SkipContentTypes:   []string{"application/octet-stream", "video/"},
+//		MaxConcurrentReads: 64,
+//	})
+func (c *Client) SetAutoReadPolicy(policy *AutoReadPolicy) *Client {
+	c.autoReadPolicy = policy
+	c.autoReadSem = nil
+	if policy != nil && policy.MaxConcurrentReads > 0 {
+		c.autoReadSem = make(chan struct{}, policy.MaxConcurrentReads)
+	}
+	return c
+}
+
+// IsBodyTruncated returns true if the response body is truncated when it's
+// read automatically, see Client.SetAutoReadPolicy.
+func (r *Response) IsBodyTruncated() bool {
+	return r.bodyTruncated
+}
@@ -0,0 +1,249 @@
+package req
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the rate of events, which allows bursts of up to
+// burst events.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available or the ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// NextPageFromLinkHeader returns the url of the next page in the Link
+// header (rel="next", RFC 8288) of the response, which is used by GitHub
+// and many other APIs, the empty url is returned if it's the last page.
+func NextPageFromLinkHeader(resp *Response) (string, error) {
+	target := findLink(resp.Header, "next")
+	if target == "" {
+		return "", nil
+	}
+	u, err := resp.Request.URL.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// findLink returns the target of the first link with the rel in the Link
+// header, or empty if not found.
+func findLink(h http.Header, rel string) string {
+	for _, v := range h.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(r, rel) {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// BulkFetchOptions is the options of Client.NewBulkFetcher.
+type BulkFetchOptions struct {
+	// Rate is the max number of requests per second, 0 means no limit.
+	Rate float64
+	// Burst is the max number of requests sent at once without waiting
+	// for the Rate, default is 1.
+	Burst int
+	// NextPage returns the url of the next page from the response, or
+	// empty url if it's the last page, default is NextPageFromLinkHeader.
+	NextPage func(resp *Response) (string, error)
+	// CheckpointFile is the file which the url of the next page is saved
+	// to after each page is handled, the fetch is resumed from it if it
+	// exists, and it's removed after the last page is handled.
+	CheckpointFile string
+}
+
+// BulkFetcher walks the paginated endpoint at the rate, and checkpoints
+// the progress so that it can be resumed after crashes, which is the
+// common shape of sync jobs. Use Client.NewBulkFetcher to create it.
+type BulkFetcher struct {
+	client   *Client
+	startURL string
+	opts     BulkFetchOptions
+	limiter  *tokenBucket
+}
+
+// NewBulkFetcher creates a BulkFetcher which fetches the pages starting
+// from the url. For example:
+//
+//	fetcher := client.NewBulkFetcher("https://api.github.com/orgs/golang/repos?per_page=100", &req.BulkFetchOptions{
+//		Rate:           5,
+//		CheckpointFile: "repos.checkpoint",
+//	})
+//	err := fetcher.Run(ctx, func(resp *req.Response) error {
+//		var repos []*Repo
+//		if err := resp.Unmarshal(&repos); err != nil {
+//			return err
+//		}
+//		return save(repos)
+//	})
+func (c *Client) NewBulkFetcher(url string, opts ...*BulkFetchOptions) *BulkFetcher {
+	f := &BulkFetcher{
+		client:   c,
+		startURL: url,
+	}
+	if len(opts) > 0 && opts[0] != nil {
+		f.opts = *opts[0]
+	}
+	if f.opts.NextPage == nil {
+		f.opts.NextPage = NextPageFromLinkHeader
+	}
+	if f.opts.Rate > 0 {
+		f.limiter = newTokenBucket(f.opts.Rate, f.opts.Burst)
+	}
+	return f
+}
+
+func (f *BulkFetcher) loadCheckpoint() (string, error) {
+	if f.opts.CheckpointFile == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(f.opts.CheckpointFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// saveCheckpoint saves the url of the next page atomically, the
+// checkpoint is removed if it's the last page.
+func (f *BulkFetcher) saveCheckpoint(next string) error {
+	if f.opts.CheckpointFile == "" {
+		return nil
+	}
+	if next == "" {
+		err := os.Remove(f.opts.CheckpointFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.opts.CheckpointFile), filepath.Base(f.opts.CheckpointFile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.WriteString(next); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.opts.CheckpointFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Run fetches the pages one by one from the checkpoint (or the start url
+// if there is no checkpoint), and calls handle with the response of each
+// page, until the last page is handled, or an error occurs, e.g. the
+// request fails, the status code is not 2xx (*StatusError is returned), or
+// handle returns an error, in which case the checkpoint is kept so that
+// the failed page is fetched again in the next run.
+func (f *BulkFetcher) Run(ctx context.Context, handle func(resp *Response) error) error {
+	url, err := f.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		url = f.startURL
+	}
+	for url != "" {
+		if f.limiter != nil {
+			if err = f.limiter.wait(ctx); err != nil {
+				return err
+			}
+		}
+		resp, err := f.client.R().SetContext(ctx).Get(url)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode < http.StatusOK || resp.StatusCode > 299 {
+			return &StatusError{
+				StatusCode: resp.StatusCode,
+				Status:     resp.Status,
+				Body:       resp.Bytes(),
+			}
+		}
+		if err = handle(resp); err != nil {
+			return err
+		}
+		if url, err = f.opts.NextPage(resp); err != nil {
+			return err
+		}
+		if err = f.saveCheckpoint(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
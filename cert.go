@@ -0,0 +1,96 @@
+package req
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader reloads the client certificate from the cert and key file
+// when they change, the modification time of files is checked on each TLS
+// handshake which requests the client certificate.
+type certReloader struct {
+	certFile, keyFile string
+	log               Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime [2]time.Time
+}
+
+func fileModTime(name string) (time.Time, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// load reloads the certificate if the files change, the last loaded
+// certificate is kept if the reload fails, e.g. the files are being
+// rotated and the cert doesn't match the key yet.
+func (r *certReloader) load() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	certModTime, err := fileModTime(r.certFile)
+	if err != nil {
+		return r.loaded(err)
+	}
+	keyModTime, err := fileModTime(r.keyFile)
+	if err != nil {
+		return r.loaded(err)
+	}
+	modTime := [2]time.Time{certModTime, keyModTime}
+	if r.cert != nil && modTime == r.modTime {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return r.loaded(err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+func (r *certReloader) loaded(err error) (*tls.Certificate, error) {
+	if r.cert == nil {
+		return nil, err
+	}
+	r.log.Warnf("failed to reload client cert, use the last loaded one: %v", err)
+	return r.cert, nil
+}
+
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+// SetCertGetter set the function which returns the client certificate
+// when it's requested by the server in the TLS handshake, so that the
+// certificate can be rotated without recreating the client, e.g. obtained
+// from the SPIFFE Workload API or Vault. It takes precedence over the
+// certificates set by SetCerts and SetCertFromFile.
+func (c *Client) SetCertGetter(getter func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) *Client {
+	c.GetTLSClientConfig().GetClientCertificate = getter
+	return c
+}
+
+// SetCertFromFileWithReload is like SetCertFromFile, but the cert and key
+// file are reloaded when they change (the modification time is checked in
+// the TLS handshake which requests the client certificate), which is used
+// in mTLS setups where the certificate is rotated frequently. The last
+// loaded certificate is used if the reload fails. Note the new
+// certificate is used by the new connections only.
+func (c *Client) SetCertFromFileWithReload(certFile, keyFile string) *Client {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      c.log,
+	}
+	if _, err := r.load(); err != nil {
+		c.log.Errorf("failed to load client cert: %v", err)
+		return c
+	}
+	return c.SetCertGetter(r.GetClientCertificate)
+}
@@ -0,0 +1,115 @@
+package req
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ChecksumError is returned if the checksum of the downloaded content
+// doesn't match the expected one set by SetDownloadChecksum.
+type ChecksumError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("req: %s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+func isChecksumError(err error) bool {
+	var e *ChecksumError
+	return errors.As(err, &e)
+}
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type downloadChecksum struct {
+	algo     string
+	expected string
+	newHash  func() hash.Hash
+}
+
+// SetDownloadChecksum set the expected checksum (hex encoded) of the
+// downloaded content, supported algorithms are "sha256", "sha512" and
+// "md5". The content is hashed while streaming to the output (set by
+// SetOutputFile or SetOutput), and the request fails with *ChecksumError
+// if the checksum doesn't match, the output file is removed in that case.
+func (r *Request) SetDownloadChecksum(algo, expectedHex string) *Request {
+	algo = strings.ToLower(algo)
+	newHash, ok := checksumAlgorithms[algo]
+	if !ok {
+		r.appendError(fmt.Errorf("unsupported checksum algorithm %q", algo))
+		return r
+	}
+	expected := strings.ToLower(expectedHex)
+	if b, err := hex.DecodeString(expected); err != nil || len(b) != newHash().Size() {
+		r.appendError(fmt.Errorf("invalid %s checksum %q", algo, expectedHex))
+		return r
+	}
+	r.downloadChecksum = &downloadChecksum{
+		algo:     algo,
+		expected: expected,
+		newHash:  newHash,
+	}
+	return r
+}
+
+func (cs *downloadChecksum) verify(h hash.Hash) error {
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != cs.expected {
+		return &ChecksumError{
+			Algorithm: cs.algo,
+			Expected:  cs.expected,
+			Actual:    actual,
+		}
+	}
+	return nil
+}
+
+// partHash returns the hash of the part file which the body is written to,
+// the existing content is hashed first if the download is resumed.
+func (cs *downloadChecksum) partHash(resp *Response, part string) (hash.Hash, error) {
+	h := cs.newHash()
+	if resp.Request.resumeOffset > 0 && resp.StatusCode == http.StatusPartialContent {
+		f, err := os.Open(part)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if _, err = io.Copy(h, f); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// verifyFile verifies the checksum of the whole file, it's used if the
+// content is not streamed sequentially, e.g. segmented download.
+func (cs *downloadChecksum) verifyFile(name string) error {
+	if cs == nil {
+		return nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := cs.newHash()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	return cs.verify(h)
+}
@@ -1,22 +1,44 @@
 package req
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"crypto/md5"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/http/httptrace"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/imroc/req/v3/internal/header"
 	"github.com/imroc/req/v3/internal/tests"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/dns/dnsmessage"
 	"golang.org/x/net/publicsuffix"
 )
 
@@ -84,6 +106,918 @@ func TestSetTLSHandshakeTimeout(t *testing.T) {
 	tests.AssertEqual(t, timeout, c.TLSHandshakeTimeout)
 }
 
+func TestPerPhaseTimeouts(t *testing.T) {
+	c := tc().
+		SetDialTimeout(time.Second).
+		SetResponseHeaderTimeout(2 * time.Second).
+		SetResponseBodyReadIdleTimeout(50 * time.Millisecond)
+	tests.AssertEqual(t, time.Second, c.DialTimeout)
+	tests.AssertEqual(t, 2*time.Second, c.ResponseHeaderTimeout)
+
+	_, err := c.R().Get("/slow-body")
+	if !errors.Is(err, ErrResponseBodyReadTimeout) {
+		t.Errorf("expected ErrResponseBodyReadTimeout, got %v", err)
+	}
+
+	resp, err := c.SetResponseBodyReadIdleTimeout(time.Second).R().Get("/slow-body")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "slow body", resp.String())
+}
+
+func TestHostRule(t *testing.T) {
+	c := tc()
+	c.HostRule("example.com").SetHeader("X-Rule", "example")
+	c.HostRule("127.0.0.*").SetHeader("X-Rule", "local").SetTimeout(50 * time.Millisecond)
+	tests.AssertEqual(t, 2, len(c.hostRules))
+	tests.AssertEqual(t, "127.0.0.*", c.HostRule("127.0.0.*").Pattern())
+	wildcard := &HostRule{pattern: "*.example.com"}
+	tests.AssertEqual(t, true, wildcard.Match("a.example.com:443"))
+	tests.AssertEqual(t, true, wildcard.Match("a.b.example.com"))
+	tests.AssertEqual(t, false, wildcard.Match("example.com"))
+	tests.AssertEqual(t, false, wildcard.Match("aexample.com"))
+
+	headers := make(http.Header)
+	resp, err := c.R().SetSuccessResult(&headers).Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "local", headers.Get("X-Rule"))
+
+	resp, err = c.R().SetHeader("X-Rule", "request").SetSuccessResult(&headers).Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "request", headers.Get("X-Rule"))
+
+	_, err = c.R().Get("/slow-body")
+	tests.AssertErrorContains(t, err, "context deadline exceeded")
+
+	cc := c.Clone()
+	cc.HostRule("127.0.0.*").SetTimeout(0)
+	resp, err = cc.R().Get("/slow-body")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "slow body", resp.String())
+
+	pool := x509.NewCertPool()
+	c.HostRule("h3.example.com").SetRootCAs(pool)
+	cfg := c.hostRuleTLSConfig("h3.example.com:443", c.TLSClientConfig)
+	tests.AssertEqual(t, true, cfg.RootCAs == pool)
+	tests.AssertEqual(t, true, c.TLSClientConfig.RootCAs != pool)
+	tests.AssertEqual(t, true, c.hostRuleTLSConfig("other.com:443", c.TLSClientConfig) == c.TLSClientConfig)
+}
+
+func TestAuditLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().EnableAuditLog(&AuditOptions{
+		Output: buf,
+		Principal: func(r *Request) string {
+			return "tester"
+		},
+	})
+	resp, err := c.R().SetBody("hello").SetQueryParam("token", "secret").Post("/")
+	assertSuccess(t, resp, err)
+
+	var record AuditRecord
+	tests.AssertNoError(t, json.Unmarshal(buf.Bytes(), &record))
+	tests.AssertEqual(t, "tester", record.Principal)
+	tests.AssertEqual(t, http.MethodPost, record.Method)
+	tests.AssertEqual(t, http.StatusOK, record.Status)
+	tests.AssertEqual(t, int64(5), record.BytesSent)
+	tests.AssertEqual(t, int64(len("TestPost: text response")), record.BytesReceived)
+	tests.AssertContains(t, record.URL, "token=redacted", true)
+	tests.AssertContains(t, record.URL, "secret", false)
+
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	c.EnableAuditLog(&AuditOptions{Filename: filename, MaxSize: 1, MaxBackups: 1})
+	cc := c.Clone()
+	tests.AssertIsNil(t, cc.auditLog)
+	cc.DisableAuditLog()
+	for i := 0; i < 3; i++ {
+		resp, err = c.R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+	c.DisableAuditLog()
+	files, err := filepath.Glob(filename + "*")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, len(files))
+}
+
+func TestRequestLogMiddleware(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().OnAfterResponse(NewRequestLogMiddleware(&RequestLogOptions{Output: buf}))
+	resp, err := c.R().SetQueryParam("token", "secret").
+		SetRetryCount(1).
+		SetRetryFixedInterval(time.Millisecond).
+		AddRetryCondition(func(resp *Response, err error) bool {
+			return resp.Request.RetryAttempt == 0
+		}).
+		Get("/")
+	assertSuccess(t, resp, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tests.AssertEqual(t, 2, len(lines))
+	tests.AssertContains(t, lines[0], `] "get https://127.0.0.1:`, true)
+	tests.AssertContains(t, lines[0], `/?token=redacted http/2.0" 200 22 `, true)
+	tests.AssertContains(t, lines[0], "retries=0", true)
+	tests.AssertContains(t, lines[1], "retries=1", true)
+	tests.AssertContains(t, lines[0], "secret", false)
+
+	buf.Reset()
+	c = tc().EnableTraceAll().OnAfterResponse(NewRequestLogMiddleware(&RequestLogOptions{
+		Output: buf,
+		Format: LogFormatJSON,
+		Skip: func(resp *Response) bool {
+			return resp.Request.Method == http.MethodHead
+		},
+	}))
+	resp, err = c.R().SetBody("hello").Post("/")
+	assertSuccess(t, resp, err)
+	resp, err = c.R().Head("/")
+	assertSuccess(t, resp, err)
+	var entry struct {
+		RequestLogEntry
+		Trace map[string]interface{} `json:"trace"`
+	}
+	tests.AssertNoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	tests.AssertEqual(t, http.MethodPost, entry.Method)
+	tests.AssertEqual(t, http.StatusOK, entry.Status)
+	tests.AssertEqual(t, int64(5), entry.BytesSent)
+	tests.AssertEqual(t, int64(len("TestPost: text response")), entry.BytesReceived)
+	tests.AssertEqual(t, true, entry.Latency > 0)
+	tests.AssertEqual(t, true, entry.Trace["remote_addr"] != nil)
+
+	buf.Reset()
+	c = tc().EnableTraceAll().OnAfterResponse(NewRequestLogMiddleware(&RequestLogOptions{
+		Output:   buf,
+		Template: template.Must(template.New("log").Parse(`{{.Method}} {{.Status}} {{.TraceSummary}}`)),
+	}))
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), "get 200 dns=", true)
+	tests.AssertEqual(t, true, strings.HasSuffix(buf.String(), "\n"))
+}
+
+func TestFormatCommonLog(t *testing.T) {
+	e := &RequestLogEntry{
+		Time:    time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Host:    "example.com",
+		Method:  http.MethodGet,
+		URL:     "https://example.com/",
+		Latency: 12 * time.Millisecond,
+		Error:   "dial tcp: connection refused",
+	}
+	tests.AssertEqual(t, `example.com - - [02/Jan/2024:15:04:05 +0000] "GET https://example.com/ -" - - 12ms retries=0 error="dial tcp: connection refused"`+"\n", string(formatCommonLog(e)))
+}
+
+func TestNtlmAuth(t *testing.T) {
+	// test vectors of MS-NLMP 4.2.4
+	key := ntowfv2("User", "Password", "Domain")
+	tests.AssertEqual(t, "0c868a403bfd7a93a3001ef22ef02e3f", hex.EncodeToString(key))
+	targetInfo, _ := hex.DecodeString("02000c0044006f006d00610069006e0001000c00530065007200760065007200000000")
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	_, lm := ntlmV2Response(key, serverChallenge, clientChallenge, make([]byte, 8), targetInfo)
+	tests.AssertEqual(t, "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa", hex.EncodeToString(lm))
+
+	challenge := make([]byte, 48)
+	copy(challenge, ntlmSignature)
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], ntlmDefaultFlags)
+	copy(challenge[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(challenge[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(challenge[44:], 48)
+	challenge = append(challenge, targetInfo...)
+
+	var handshakeAddr string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		msg, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Header.Get("Authorization"), "NTLM "))
+		switch {
+		case len(msg) > 8 && msg[8] == 1:
+			handshakeAddr = r.RemoteAddr
+			w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challenge))
+		case len(msg) > 8 && msg[8] == 3 && r.RemoteAddr == handshakeAddr:
+			nt, _ := ntlmField(msg, 20)
+			user, _ := ntlmField(msg, 36)
+			proof := hmacMD5(ntowfv2("roc", "123456", "CORP"), serverChallenge, nt[16:])
+			if bytes.Equal(proof, nt[:16]) && bytes.Equal(user, encodeUTF16LE("roc")) {
+				w.Write(body)
+				return
+			}
+		default:
+			w.Header().Set("WWW-Authenticate", "Negotiate")
+			w.Header().Add("WWW-Authenticate", "NTLM")
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	resp, err := C().SetCommonNtlmAuth(`CORP\roc`, "123456").R().SetBody("hello").Post(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello", resp.String())
+
+	resp, err = C().SetCommonNtlmAuth(`CORP\roc`, "wrong").R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSpnegoAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Negotiate " + base64.StdEncoding.EncodeToString([]byte("init")):
+			w.Header().Set("WWW-Authenticate", "Negotiate "+base64.StdEncoding.EncodeToString([]byte("continue")))
+		case "Negotiate " + base64.StdEncoding.EncodeToString([]byte("final")):
+			w.Write([]byte("authorized"))
+			return
+		default:
+			w.Header().Set("WWW-Authenticate", "Negotiate")
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	var spns []string
+	c := C().SetCommonSpnegoAuth(SpnegoProviderFunc(func(ctx context.Context, spn string, challenge []byte) ([]byte, error) {
+		spns = append(spns, spn)
+		if challenge == nil {
+			return []byte("init"), nil
+		}
+		tests.AssertEqual(t, "continue", string(challenge))
+		return []byte("final"), nil
+	}))
+	resp, err := c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "authorized", resp.String())
+	tests.AssertEqual(t, []string{"HTTP/127.0.0.1", "HTTP/127.0.0.1"}, spns)
+
+	_, err = C().SetCommonSpnegoAuth(SpnegoProviderFunc(func(ctx context.Context, spn string, challenge []byte) ([]byte, error) {
+		return nil, errors.New("no credentials")
+	})).R().Get(server.URL)
+	tests.AssertErrorContains(t, err, "no credentials")
+}
+
+func TestNetrc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		w.Write([]byte(username + ":" + password))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	netrcFile := filepath.Join(t.TempDir(), ".netrc")
+	err := os.WriteFile(netrcFile, []byte(`# comment
+machine example.com login other password secret
+macdef init
+machine 127.0.0.1 login macro password macro
+
+machine 127.0.0.1
+	login roc
+	password 123456
+default login anonymous password guest
+`), 0600)
+	tests.AssertNoError(t, err)
+
+	c := C().EnableNetrc(netrcFile)
+	resp, err := c.R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "roc:123456", resp.String())
+
+	// default entry
+	resp, err = c.R().Get("http://localhost:" + u.Port())
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "anonymous:guest", resp.String())
+
+	// explicit credentials take precedence
+	resp, err = c.R().SetBasicAuth("admin", "pass").Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "admin:pass", resp.String())
+
+	resp, err = c.DisableNetrc().R().Get(server.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, ":", resp.String())
+}
+
+func TestNegativeCache(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	c := C().SetBaseURL(server.URL).EnableNegativeCache(map[int]time.Duration{
+		http.StatusNotFound: time.Hour,
+		http.StatusGone:     time.Millisecond,
+	})
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get("/missing")
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+		tests.AssertEqual(t, "not found", resp.String())
+	}
+	tests.AssertEqual(t, 1, hits)
+
+	// not cached for other methods and status codes
+	c.R().Post("/missing")
+	c.R().Get("/")
+	c.R().Get("/")
+	tests.AssertEqual(t, 4, hits)
+
+	// expired
+	c.R().Get("/gone")
+	time.Sleep(5 * time.Millisecond)
+	c.R().Get("/gone")
+	tests.AssertEqual(t, 6, hits)
+
+	// cached separately per credentials.
+	c.R().SetBearerAuthToken("alice").Get("/missing")
+	c.R().SetBearerAuthToken("alice").Get("/missing")
+	tests.AssertEqual(t, 7, hits)
+	c.R().SetBearerAuthToken("bob").Get("/missing")
+	c.R().SetHeader("Cookie", "session=alice").Get("/missing")
+	tests.AssertEqual(t, 9, hits)
+
+	c.DisableNegativeCache()
+	c.R().Get("/missing")
+	tests.AssertEqual(t, 10, hits)
+
+	// the least recently used response is evicted.
+	nc := newNegativeCache(map[int]time.Duration{http.StatusNotFound: time.Minute})
+	now := time.Now()
+	newRequest := func(i int) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/%d", i), nil)
+		return req
+	}
+	for i := 0; i <= maxNegativeCacheEntries; i++ {
+		tests.AssertNoError(t, nc.store(&http.Response{StatusCode: http.StatusNotFound, Request: newRequest(i)}, now))
+		if i == 1 {
+			tests.AssertNotNil(t, nc.get(newRequest(0), now))
+		}
+	}
+	tests.AssertEqual(t, maxNegativeCacheEntries, len(nc.entries))
+	tests.AssertNotNil(t, nc.get(newRequest(0), now))
+	tests.AssertIsNil(t, nc.get(newRequest(1), now))
+}
+
+func TestSecureMode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().SetLogger(NewLogger(buf, "", 0)).SetSecureMode(SecureModeWarn)
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), "insecureskipverify is enabled", true)
+
+	c.SetSecureMode(SecureModeStrict)
+	_, err = c.R().Get("/")
+	if !errors.Is(err, ErrInsecureConfig) {
+		t.Fatalf("expected ErrInsecureConfig, got %v", err)
+	}
+	c.DisableInsecureSkipVerify()
+	_, err = c.R().SetBearerAuthToken("token").Get("http://127.0.0.1:1/")
+	tests.AssertErrorContains(t, err, "credentials are sent over plain HTTP")
+
+	c.SetRedirectPolicy(AlwaysCopyHeaderRedirectPolicy("Authorization"))
+	header := make(http.Header)
+	header.Set("Authorization", "test")
+	from, _ := url.Parse("https://api.example.com/")
+	to, _ := url.Parse("https://other.example.com/")
+	err = c.GetClient().CheckRedirect(&http.Request{URL: to, Header: make(http.Header)}, []*http.Request{{URL: from, Header: header}})
+	if !errors.Is(err, ErrInsecureConfig) {
+		t.Fatalf("expected ErrInsecureConfig, got %v", err)
+	}
+	to, _ = url.Parse("https://v2.api.example.com/")
+	err = c.GetClient().CheckRedirect(&http.Request{URL: to, Header: make(http.Header)}, []*http.Request{{URL: from, Header: header}})
+	tests.AssertNoError(t, err)
+}
+
+func TestWithDefaultClient(t *testing.T) {
+	original := DefaultClient()
+	c := tc()
+	WithDefaultClient(c, func() {
+		tests.AssertEqual(t, c, DefaultClient())
+		resp, err := Get("/")
+		assertSuccess(t, resp, err)
+	})
+	tests.AssertEqual(t, original, DefaultClient())
+
+	// nested calls don't deadlock, and restore the outer one.
+	c2 := C()
+	WithDefaultClient(c, func() {
+		WithDefaultClient(c2, func() {
+			tests.AssertEqual(t, c2, DefaultClient())
+		})
+		tests.AssertEqual(t, c, DefaultClient())
+	})
+	tests.AssertEqual(t, original, DefaultClient())
+
+	// overlapping calls restore the latest running one.
+	entered, exit := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WithDefaultClient(c, func() {
+			close(entered)
+			<-exit
+		})
+	}()
+	<-entered
+	WithDefaultClient(c2, func() {
+		close(exit)
+		<-done
+		tests.AssertEqual(t, c2, DefaultClient())
+	})
+	tests.AssertEqual(t, original, DefaultClient())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			WithDefaultClient(C(), func() {})
+			SetDefaultClient(original)
+			DefaultClient().R()
+		}()
+	}
+	wg.Wait()
+	SetDefaultClient(original)
+}
+
+func TestProxyFromEnvRefresh(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	c := tc().EnableProxyFromEnvRefresh(0)
+	t.Setenv("HTTP_PROXY", "http://proxy1.local:8080")
+	u, err := c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "proxy1.local:8080", u.Host)
+
+	t.Setenv("HTTP_PROXY", "http://proxy2.local:8080")
+	u, err = c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "proxy2.local:8080", u.Host)
+
+	t.Setenv("NO_PROXY", "example.com")
+	u, err = c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertIsNil(t, u)
+}
+
+func TestSchemeProxyURLs(t *testing.T) {
+	c := tc().SetSchemeProxyURLs("http://proxy.local:8080", "socks5://proxy.local:1080")
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	u, err := c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "http://proxy.local:8080", u.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "https://example.com", nil)
+	u, err = c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "socks5://proxy.local:1080", u.String())
+
+	c.SetSchemeProxyURLs("http://proxy.local:8080", "")
+	u, err = c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertIsNil(t, u)
+}
+
+func TestProxyPAC(t *testing.T) {
+	var fetched int32
+	pacServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		w.Write([]byte(`function FindProxyForURL(url, host) {
+			if (isPlainHostName(host) || dnsDomainIs(host, ".corp.local"))
+				return "DIRECT";
+			if (shExpMatch(url, "https://*"))
+				return "SOCKS4 old.local:1080; SOCKS secure.local:1080";
+			return "PROXY proxy.local:8080; DIRECT";
+		}`))
+	}))
+	defer pacServer.Close()
+
+	c := tc().SetProxyPAC(pacServer.URL + "/proxy.pac")
+	for _, cs := range []struct{ url, proxy string }{
+		{"http://intranet/", ""},
+		{"http://wiki.corp.local/", ""},
+		{"https://example.com/", "socks5://secure.local:1080"},
+		{"http://example.com/", "http://proxy.local:8080"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, cs.url, nil)
+		u, err := c.Proxy(req)
+		tests.AssertNoError(t, err)
+		if cs.proxy == "" {
+			tests.AssertIsNil(t, u)
+		} else {
+			tests.AssertEqual(t, cs.proxy, u.String())
+		}
+	}
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&fetched))
+
+	pacFile := filepath.Join(t.TempDir(), "proxy.pac")
+	tests.AssertNoError(t, os.WriteFile(pacFile, []byte(`function FindProxyForURL(url, host) { return "HTTPS proxy.local:443"; }`), 0644))
+	c = tc().SetProxyPAC(pacFile)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	u, err := c.Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "https://proxy.local:443", u.String())
+
+	// fall back to DIRECT if the script can't be loaded or evaluated.
+	var buf bytes.Buffer
+	for _, script := range []string{
+		"",
+		`function FindProxyForURL(url, host) { for (;;) {} }`,
+		`function FindProxyForURL(url, host) { return host.foo.bar; }`,
+	} {
+		buf.Reset()
+		c = tc().SetLogger(NewLogger(&buf, "", 0)).SetProxyPAC(filepath.Join(t.TempDir(), "missing.pac"))
+		if script != "" {
+			tests.AssertNoError(t, os.WriteFile(pacFile, []byte(script), 0644))
+			c.SetProxyPAC(pacFile)
+		}
+		u, err = c.Proxy(req)
+		tests.AssertNoError(t, err)
+		tests.AssertIsNil(t, u)
+		tests.AssertContains(t, buf.String(), "pac script", true)
+	}
+
+	// the replaced http.DefaultTransport is not used to fetch the script.
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = http.RoundTripper(nil)
+	defer func() { http.DefaultTransport = defaultTransport }()
+	u, err = tc().SetProxyPAC(pacServer.URL + "/proxy.pac").Proxy(req)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "http://proxy.local:8080", u.String())
+}
+
+func TestProxyPool(t *testing.T) {
+	newProxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	p1, p2 := newProxy("p1"), newProxy("p2")
+	defer p1.Close()
+	defer p2.Close()
+	dead := newProxy("dead")
+	dead.Close()
+
+	c := tc().SetProxyPool([]string{p1.URL, dead.URL, p2.URL}, ProxyPoolRoundRobin, &ProxyPoolOptions{
+		MaxFails:           1,
+		QuarantineDuration: time.Minute,
+	})
+	var got []string
+	for i := 0; i < 5; i++ {
+		resp, err := c.R().Get("http://example.com")
+		if err != nil {
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, resp.String())
+	}
+	// the dead proxy is quarantined after the failure.
+	tests.AssertEqual(t, []string{"p1", "error", "p2", "p1", "p2"}, got)
+	tests.AssertEqual(t, 2, len(c.proxyPool.Available()))
+
+	pool, err := NewProxyPool([]string{p1.URL, p2.URL}, ProxyPoolLeastFailures)
+	tests.AssertNoError(t, err)
+	u1, _ := url.Parse(p1.URL)
+	pool.report(pool.proxies[0], nil, 100*time.Millisecond)
+	// the unknown latency doesn't drag the average latency down.
+	pool.ReportResult(u1, nil)
+	tests.AssertEqual(t, 100*time.Millisecond, pool.Stats()[0].Latency)
+	pool.ReportResult(u1, errors.New("test"))
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	for i := 0; i < 3; i++ {
+		u, err := pool.Proxy(req)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, p2.URL, u.String())
+	}
+
+	_, err = NewProxyPool(nil, ProxyPoolRandom)
+	tests.AssertErrorContains(t, err, "no proxy url")
+}
+
+func TestProxyPoolFailover(t *testing.T) {
+	newProxy := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(name + ":" + string(body)))
+		})
+	}
+	p1 := httptest.NewServer(newProxy("p1"))
+	defer p1.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	deadAddr := l.Addr().String()
+	l.Close()
+
+	c := tc().SetProxyPool([]string{"http://" + deadAddr, p1.URL}, ProxyPoolRoundRobin, &ProxyPoolOptions{
+		MaxFails:           1,
+		QuarantineDuration: time.Minute,
+		Failover:           true,
+		RetestInterval:     10 * time.Millisecond,
+	})
+	// the request fails over to p1 with the body resent.
+	resp, err := c.R().SetBody("hello").Post("http://example.com")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "p1:hello", resp.String())
+	stats := c.ProxyPoolStats()
+	tests.AssertEqual(t, 2, len(stats))
+	tests.AssertEqual(t, int64(1), stats[0].Requests)
+	tests.AssertEqual(t, int64(1), stats[0].Failures)
+	tests.AssertEqual(t, float64(0), stats[0].SuccessRate)
+	tests.AssertEqual(t, true, stats[0].Quarantined)
+	tests.AssertNotNil(t, stats[0].LastError)
+	tests.AssertEqual(t, int64(1), stats[1].Requests)
+	tests.AssertEqual(t, float64(1), stats[1].SuccessRate)
+	tests.AssertEqual(t, true, stats[1].Latency > 0)
+
+	// the dead proxy is back once it's reachable again.
+	l, err = net.Listen("tcp", deadAddr)
+	tests.AssertNoError(t, err)
+	revived := httptest.NewUnstartedServer(newProxy("revived"))
+	revived.Listener.Close()
+	revived.Listener = l
+	revived.Start()
+	defer revived.Close()
+	time.Sleep(20 * time.Millisecond)
+	resp, err = c.R().Get("http://example.com")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "p1:", resp.String())
+	tests.AssertEqual(t, true, tests.WaitCondition(time.Second, 5*time.Millisecond, func() bool {
+		return !c.ProxyPoolStats()[0].Quarantined
+	}))
+	resp, err = c.R().Get("http://example.com")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "revived:", resp.String())
+
+	tests.AssertIsNil(t, C().ProxyPoolStats())
+}
+
+func TestProxyBasicAuth(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Proxy-Authorization")))
+	}))
+	defer proxy.Close()
+	resp, err := C().SetProxyURL(proxy.URL).SetProxyBasicAuth("roc", "123456").R().Get("http://example.com")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "Basic cm9jOjEyMzQ1Ng==", resp.String())
+}
+
+func TestProxyConnectDigestAuth(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	defer ln.Close()
+	md5Hex := func(s string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			r, err := http.ReadRequest(br)
+			if err != nil || r.Method != http.MethodConnect {
+				return
+			}
+			params := make(map[string]string)
+			auth := r.Header.Get("Proxy-Authorization")
+			for _, p := range splitChallengeParams(strings.TrimPrefix(auth, "Digest ")) {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				params[k] = strings.Trim(v, `"`)
+			}
+			ha1 := md5Hex("roc:proxy:123456")
+			ha2 := md5Hex("CONNECT:" + params["uri"])
+			expected := md5Hex(strings.Join([]string{ha1, "abc", params["nc"], params["cnonce"], "auth", ha2}, ":"))
+			if params["response"] != expected || params["uri"] != r.Host {
+				fmt.Fprint(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Digest realm=\"proxy\", nonce=\"abc\", qop=\"auth\"\r\nContent-Length: 0\r\n\r\n")
+				continue
+			}
+			upstream, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				return
+			}
+			defer upstream.Close()
+			fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			go io.Copy(upstream, br)
+			io.Copy(conn, upstream)
+			return
+		}
+	}()
+
+	buf := new(bytes.Buffer)
+	c := C().SetProxyURL("http://"+ln.Addr().String()).
+		SetProxyBasicAuth("roc", "123456").
+		EnableInsecureSkipVerify().
+		EnableDumpAllTo(buf).
+		EnableDumpAllWithoutBody()
+	resp, err := c.R().Get(target.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+	tests.AssertContains(t, buf.String(), "connect "+strings.ToLower(strings.TrimPrefix(target.URL, "https://")), true)
+	tests.AssertContains(t, buf.String(), "407 proxy authentication required", true)
+	tests.AssertContains(t, buf.String(), "200 connection established", true)
+}
+
+func TestTokenProvider(t *testing.T) {
+	// the first token is rejected, refresh and resend once
+	var fetched []string
+	token := "badtoken"
+	c := tc().SetCommonTokenProvider(NewCachedTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		fetched = append(fetched, token)
+		t := token
+		token = "goodtoken"
+		return t, time.Now().Add(time.Hour), nil
+	}))
+	resp, err := c.R().Get("/protected")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "good", resp.String())
+	tests.AssertEqual(t, []string{"badtoken", "goodtoken"}, fetched)
+
+	// cached
+	resp, err = c.R().Get("/protected")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 2, len(fetched))
+
+	// resend only once
+	resp, err = c.R().SetTokenProvider(TokenProviderFunc(func(ctx context.Context, refresh bool) (string, error) {
+		fetched = append(fetched, "bad")
+		return "bad", nil
+	})).Get("/protected")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+	tests.AssertEqual(t, 4, len(fetched))
+
+	_, err = c.R().SetTokenProvider(TokenProviderFunc(func(ctx context.Context, refresh bool) (string, error) {
+		return "", errors.New("no token")
+	})).Get("/protected")
+	tests.AssertErrorContains(t, err, "no token")
+}
+
+func TestSetNowFunc(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var fetched int
+	c := C().SetBaseURL(server.URL).
+		SetNowFunc(func() time.Time { return now }).
+		EnableNegativeCache(map[int]time.Duration{http.StatusNotFound: time.Hour}).
+		SetCommonTokenProvider(NewCachedTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+			fetched++
+			return "token", now.Add(time.Hour), nil
+		}))
+	for i := 0; i < 2; i++ {
+		c.R().Get("/")
+	}
+	tests.AssertEqual(t, 1, hits)
+	tests.AssertEqual(t, 1, fetched)
+
+	// the cached response and token expire after an hour.
+	now = now.Add(time.Hour + time.Second)
+	c.R().Get("/")
+	tests.AssertEqual(t, 2, hits)
+	tests.AssertEqual(t, 2, fetched)
+
+	// the retry deadline is exceeded by the time travel.
+	var attempts int
+	c.SetCommonRetryCount(3).
+		SetCommonRetryCondition(func(resp *Response, err error) bool { return true }).
+		SetCommonRetryHook(func(resp *Response, err error) {
+			attempts++
+			now = now.Add(time.Minute)
+		})
+	c.R().SetRetryBudget(RetryBudget{Deadline: 30 * time.Second}).Get("/retry")
+	tests.AssertEqual(t, 1, attempts)
+}
+
+func TestBOMStripping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/xml" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte("\xef\xbb\xbf<user><name>roc</name></user>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("\xef\xbb\xbf{\"name\": \"roc\"}"))
+	}))
+	defer server.Close()
+
+	type User struct {
+		Name string `json:"name" xml:"name"`
+	}
+	c := tc()
+	var user User
+	resp, err := c.R().SetSuccessResult(&user).Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "roc", user.Name)
+
+	user = User{}
+	resp, err = c.R().Get(server.URL + "/xml")
+	assertSuccess(t, resp, err)
+	tests.AssertNoError(t, resp.UnmarshalXml(&user))
+	tests.AssertEqual(t, "roc", user.Name)
+
+	user = User{}
+	resp, err = c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertNoError(t, resp.Unmarshal(&user))
+	tests.AssertEqual(t, "roc", user.Name)
+
+	_, err = c.DisableBOMStripping().R().SetSuccessResult(&user).Get(server.URL)
+	tests.AssertErrorContains(t, err, "invalid character")
+}
+
+func TestConnectionHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	for _, url := range []string{server.URL, getTestServerURL()} {
+		opened := make(chan ConnectionInfo, 10)
+		closed := make(chan ConnectionInfo, 10)
+		c := tc().OnConnectionOpen(func(info ConnectionInfo) {
+			opened <- info
+		}).OnConnectionClose(func(info ConnectionInfo) {
+			closed <- info
+		})
+		for i := 0; i < 3; i++ {
+			resp, err := c.R().Get(url)
+			assertSuccess(t, resp, err)
+		}
+		c.CloseIdleConnections()
+
+		info := <-opened
+		tests.AssertEqual(t, "tcp", info.Network)
+		tests.AssertEqual(t, info.Addr, info.RemoteAddr.String())
+		tests.AssertEqual(t, int64(0), info.Requests)
+		select {
+		case info = <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("connection is not closed")
+		}
+		tests.AssertEqual(t, int64(3), info.Requests)
+		tests.AssertEqual(t, 0, len(opened))
+	}
+}
+
+func TestConnectionHooksWithDialTLS(t *testing.T) {
+	c := tc()
+	tlsConfig := c.GetTLSClientConfig().Clone()
+	closed := make(chan ConnectionInfo, 10)
+	c.SetDialTLS(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := &tls.Dialer{Config: tlsConfig}
+		return d.DialContext(ctx, network, addr)
+	}).OnConnectionClose(func(info ConnectionInfo) {
+		closed <- info
+	})
+	resp, err := c.R().Get(getTestServerURL())
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+	c.CloseIdleConnections()
+	select {
+	case info := <-closed:
+		tests.AssertEqual(t, int64(1), info.Requests)
+	case <-time.After(time.Second):
+		t.Fatal("connection is not closed")
+	}
+}
+
+func TestIDNHost(t *testing.T) {
+	addr := strings.TrimPrefix(getTestServerURL(), "https://")
+	_, port, _ := net.SplitHostPort(addr)
+	c := tc().SetDial(func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	})
+	resp, err := c.R().Get("https://bücher.example:" + port + "/host-header")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "xn--bcher-kva.example:"+port, resp.ASCIIHost())
+	tests.AssertEqual(t, "xn--bcher-kva.example:"+port, resp.String())
+
+	// "pаypal" with Cyrillic "а"
+	c.EnableIDNStrictMode()
+	_, err = c.R().Get("https://p\u0430ypal.example:" + port + "/host-header")
+	if !errors.Is(err, ErrConfusableHost) {
+		t.Errorf("expected ErrConfusableHost, got %v", err)
+	}
+	resp, err = c.R().Get("https://bücher.example:" + port + "/host-header")
+	assertSuccess(t, resp, err)
+}
+
 func TestSetDial(t *testing.T) {
 	testErr := errors.New("test")
 	testDial := func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -156,6 +1090,39 @@ func TestOnAfterResponse(t *testing.T) {
 	tests.AssertEqual(t, true, len1+1 == len2)
 }
 
+func TestMiddlewareErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	c := tc().OnAfterResponse(func(client *Client, resp *Response) error {
+		return errA
+	})
+	_, err := c.R().Get("/")
+	tests.AssertEqual(t, errA, err)
+
+	c.OnAfterResponse(checkStatusMiddleware)
+	_, err = c.R().Get("/")
+	tests.AssertEqual(t, true, errors.Is(err, errA))
+	tests.AssertErrorContains(t, err, "v3.checkStatusMiddleware: bad status")
+	var me *MiddlewareError
+	tests.AssertEqual(t, true, errors.As(err, &me))
+	tests.AssertEqual(t, "v3.TestMiddlewareErrors.func1", me.Name)
+
+	// all request-level middlewares run even if one fails
+	called := false
+	_, err = tc().R().OnAfterResponse(func(client *Client, resp *Response) error {
+		return errA
+	}).OnAfterResponse(func(client *Client, resp *Response) error {
+		called = true
+		return errB
+	}).Get("/")
+	tests.AssertEqual(t, true, called)
+	tests.AssertEqual(t, true, errors.Is(err, errA))
+	tests.AssertEqual(t, true, errors.Is(err, errB))
+}
+
+func checkStatusMiddleware(client *Client, resp *Response) error {
+	return errors.New("bad status")
+}
+
 func TestOnBeforeRequest(t *testing.T) {
 	c := tc().OnBeforeRequest(func(client *Client, request *Request) error {
 		return nil
@@ -225,6 +1192,35 @@ func TestSetUserAgent(t *testing.T) {
 	tests.AssertEqual(t, "test", c.Headers.Get(header.UserAgent))
 }
 
+func TestSetLocale(t *testing.T) {
+	var locales []string
+	c := tc().SetLocale("fr-FR", "de").SetLocaleHook(func(locale string, req *Request) {
+		locales = append(locales, locale)
+		req.SetQueryParam("lang", locale)
+	})
+	for _, expected := range []string{"fr-FR,fr;q=0.9", "de", "fr-FR,fr;q=0.9"} {
+		resp, err := c.R().Get("/header")
+		assertSuccess(t, resp, err)
+		var hdr http.Header
+		tests.AssertNoError(t, resp.Unmarshal(&hdr))
+		tests.AssertEqual(t, expected, hdr.Get("Accept-Language"))
+	}
+	tests.AssertEqual(t, []string{"fr-FR", "de", "fr-FR"}, locales)
+
+	resp, err := c.R().SetLocale("ja").Get("/query-parameter")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "lang=ja", resp.String())
+
+	// the explicit common Accept-Language is not overridden.
+	c.SetCommonHeader("Accept-Language", "en")
+	resp, err = c.R().Get("/header")
+	assertSuccess(t, resp, err)
+	var hdr http.Header
+	tests.AssertNoError(t, resp.Unmarshal(&hdr))
+	tests.AssertEqual(t, "en", hdr.Get("Accept-Language"))
+	tests.AssertEqual(t, 4, len(locales))
+}
+
 func TestAutoDecode(t *testing.T) {
 	c := tc().DisableAutoDecode()
 	resp, err := c.R().Get("/gbk")
@@ -420,6 +1416,38 @@ func TestSetCerts(t *testing.T) {
 	tests.AssertEqual(t, true, len(c.TLSClientConfig.Certificates) == 2)
 }
 
+func TestTLSSetters(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := C().SetBaseURL(getTestServerURL()).
+		SetLogger(NewLogger(buf, "", 0)).
+		SetInsecureSkipVerify(true).
+		SetTLSMaxVersion(tls.VersionTLS12).
+		SetCipherSuites(tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384)
+	tests.AssertContains(t, buf.String(), "insecureskipverify is enabled", true)
+	buf.Reset()
+	c.EnableInsecureSkipVerify().SetInsecureSkipVerify(true)
+	tests.AssertEqual(t, "", buf.String())
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, uint16(tls.VersionTLS12), resp.TLS.Version)
+	tests.AssertEqual(t, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, resp.TLS.CipherSuite)
+
+	buf.Reset()
+	c.SetTLSMinVersion(tls.VersionTLS13)
+	tests.AssertContains(t, buf.String(), "tls min version tls 1.3 is greater than max version tls 1.2", true)
+	c.SetTLSMinVersion(0x0305)
+	tests.AssertContains(t, buf.String(), "unsupported tls version 0x0305", true)
+	c.SetCipherSuites(0xffff)
+	tests.AssertContains(t, buf.String(), "unknown cipher suite 0xffff", true)
+	c.SetCipherSuites(tls.TLS_RSA_WITH_RC4_128_SHA)
+	tests.AssertContains(t, buf.String(), "insecure cipher suite tls_rsa_with_rc4_128_sha is enabled", true)
+
+	c = tc().SetTLSMinVersion(tls.VersionTLS13).SetTLSMaxVersion(tls.VersionTLS13)
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+}
+
 func TestSetCertFromFile(t *testing.T) {
 	c := tc().SetCertFromFile(
 		tests.GetTestFilePath("sample-client.pem"),
@@ -428,6 +1456,67 @@ func TestSetCertFromFile(t *testing.T) {
 	tests.AssertEqual(t, true, len(c.TLSClientConfig.Certificates) == 1)
 }
 
+// writeTestCert writes a self-signed client cert with the common name to
+// the cert and key file.
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	tests.AssertNoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(nil, tmpl, tmpl, pub, key)
+	tests.AssertNoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	tests.AssertNoError(t, err)
+	tests.AssertNoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	tests.AssertNoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+func TestSetCertFromFileWithReload(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	writeTestCert(t, certFile, keyFile, "client-v1")
+	c := tc().DisableKeepAlives().SetCertFromFileWithReload(certFile, keyFile)
+	resp, err := c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "client-v1", resp.String())
+
+	writeTestCert(t, certFile, keyFile, "client-v2")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	resp, err = c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "client-v2", resp.String())
+
+	// keep the last loaded cert if the reload fails.
+	os.WriteFile(keyFile, []byte("invalid"), 0600)
+	resp, err = c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "client-v2", resp.String())
+
+	var calls int32
+	_, err = tc().SetCertGetter(func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("no cert available")
+	}).R().Get(server.URL)
+	tests.AssertErrorContains(t, err, "no cert available")
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&calls))
+}
+
 func TestSetOutputDirectory(t *testing.T) {
 	outFile := "test_output_dir"
 	resp, err := tc().
@@ -440,6 +1529,44 @@ func TestSetOutputDirectory(t *testing.T) {
 	tests.AssertEqual(t, "TestGet: text response", content)
 }
 
+func TestSanitizeOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	c := tc().SetOutputDirectory(dir)
+
+	// the filename set explicitly is used as is.
+	resp, err := c.R().SetOutputFile("sub/../a:b.txt").Get("/")
+	assertSuccess(t, resp, err)
+	_, err = os.Stat(filepath.Join(dir, "a:b.txt"))
+	tests.AssertNoError(t, err)
+
+	resp, err = c.R().SetOutputFileFromURL().Get("/download/a%3Fb/c*.txt")
+	tests.AssertNoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "download", "a_b", "c_.txt"))
+	tests.AssertNoError(t, err)
+
+	c.SetOutputFilenameMapper(func(r *Request, filename string) string {
+		return "mapped/" + filename
+	})
+	resp, err = c.R().SetOutputFileFromURL().Get("/")
+	assertSuccess(t, resp, err)
+	b, err := os.ReadFile(filepath.Join(dir, "mapped", "index.html"))
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "TestGet: text response", string(b))
+
+	cases := map[string]string{
+		"a/b.txt":          filepath.Join("a", "b.txt"),
+		"/etc/passwd":      filepath.Join("etc", "passwd"),
+		"a/../../../b":     "b",
+		`..\..\win.ini`:    "win.ini",
+		"con.txt":          "_con.txt",
+		"name<1>:2|.txt. ": "name_1__2_.txt",
+		"..":               "index.html",
+	}
+	for name, expected := range cases {
+		tests.AssertEqual(t, expected, sanitizeOutputPath(name))
+	}
+}
+
 func TestSetBaseURL(t *testing.T) {
 	baseURL := "http://dummy-req.local/test"
 	resp, _ := tc().SetTimeout(time.Nanosecond).SetBaseURL(baseURL).R().Get("/req")
@@ -604,16 +1731,216 @@ func TestEnableDumpAllToFile(t *testing.T) {
 	tests.AssertContains(t, dump, "testpost: text response", true)
 }
 
-func TestEnableDumpAllAsync(t *testing.T) {
-	c := tc()
-	buf := new(bytes.Buffer)
-	c.EnableDumpAllTo(buf).EnableDumpAllAsync()
-	tests.AssertEqual(t, true, c.getDumpOptions().Async)
+func TestEnableDumpAllToFileWithRotation(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "dump.log")
+	c := tc().EnableDumpAllToFileWithRotation(filename, 1024, 2)
+	for i := 0; i < 5; i++ {
+		resp, err := c.R().SetBody(strings.Repeat("a", 600)).Post("/")
+		assertSuccess(t, resp, err)
+	}
+	backups, err := filepath.Glob(filename + ".*")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, len(backups))
+	for _, backup := range backups {
+		info, err := os.Stat(backup)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, true, info.Size() <= 1024)
+	}
+
+	c.RotateDump()
+	content, err := os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 0, len(content))
+	c.DisableDumpAll()
 }
 
-func TestSetResponseBodyTransformer(t *testing.T) {
-	c := tc().SetResponseBodyTransformer(func(rawBody []byte, req *Request, resp *Response) (transformedBody []byte, err error) {
-		if resp.IsSuccessState() {
+func TestCloseAndReopenDump(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "dump.log")
+	c := tc().EnableDumpAllToFile(filename).EnableDumpAllAsync()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+
+	// simulate logrotate
+	rotated := filename + ".1"
+	tests.AssertNoError(t, os.Rename(filename, rotated))
+	c.ReopenDump()
+	resp, err = c.R().SetHeader("X-After-Reopen", "yes").Get("/")
+	assertSuccess(t, resp, err)
+
+	c.CloseDump()
+	tests.AssertIsNil(t, c.Dump)
+	tests.AssertIsNil(t, c.dumpFile)
+	content, err := os.ReadFile(rotated)
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, string(content), "testget: text response", true)
+	tests.AssertEqual(t, false, strings.Contains(strings.ToLower(string(content)), "x-after-reopen"))
+	content, err = os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, string(content), "x-after-reopen: yes", true)
+
+	resp, err = c.R().SetHeader("X-After-Close", "yes").Get("/")
+	assertSuccess(t, resp, err)
+	content, err = os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, false, strings.Contains(strings.ToLower(string(content)), "x-after-close"))
+}
+
+func TestCloneReopenDumpOnSignal(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dump.log")
+	c := tc().EnableDumpAllToFile(filename).ReopenDumpOnSignal()
+	cc := c.Clone()
+	tests.AssertIsNil(t, cc.dumpFile)
+	tests.AssertIsNil(t, cc.dumpSignal)
+	cc.CloseDump()
+	c.CloseDump()
+
+	// the signals racing CloseDump
+	for i := 0; i < 10; i++ {
+		c.EnableDumpAllToFile(filename).EnableDumpAllAsync().ReopenDumpOnSignal()
+		ds, w := c.dumpSignal, c.dumpFile
+		go func() {
+			for j := 0; j < 10; j++ {
+				select {
+				case ds.ch <- os.Interrupt:
+				case <-ds.done:
+					return
+				}
+			}
+		}()
+		c.CloseDump()
+		tests.AssertEqual(t, os.ErrClosed, w.Reopen())
+		_, err := w.Write([]byte("x"))
+		tests.AssertEqual(t, os.ErrClosed, err)
+	}
+}
+
+func TestEnableDumpAllAsync(t *testing.T) {
+	c := tc()
+	buf := new(bytes.Buffer)
+	c.EnableDumpAllTo(buf).EnableDumpAllAsync()
+	tests.AssertEqual(t, true, c.getDumpOptions().Async)
+}
+
+// blockingWriter blocks the writes until it's released.
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestFlushDump(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	c := tc().SetCommonDumpOptions(&DumpOptions{
+		Output:          w,
+		RequestHeader:   true,
+		ResponseBody:    true,
+		Async:           true,
+		AsyncQueueSize:  1,
+		AsyncDropOnFull: true,
+	}).EnableDumpAll()
+	// the output is blocked, the dumps are dropped instead of blocking the
+	// requests, only the one being written and the queued one are kept.
+	for i := 0; i < 5; i++ {
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+	close(w.release)
+	tests.AssertEqual(t, true, c.DroppedDumpWrites() > 0)
+	// the dropped writes are marked before the next write.
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	c.FlushDump()
+	tests.AssertEqual(t, true, strings.Count(w.String(), "TestGet: text response") < 6)
+	tests.AssertContains(t, w.String(), "dump writes dropped]", true)
+
+	c.DisableDumpAll().SetCommonDumpOptions(&DumpOptions{
+		Output:         w,
+		RequestHeader:  true,
+		ResponseBody:   true,
+		Async:          true,
+		AsyncQueueSize: 100,
+	}).EnableDumpAll()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	c.FlushDump()
+	tests.AssertContains(t, w.String(), "testget: text response", true)
+	tests.AssertContains(t, w.String(), "user-agent", true)
+	FlushDump()
+}
+
+func TestDumpHooks(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		var mu sync.Mutex
+		parts := make(map[string]*bytes.Buffer)
+		hook := func(name string) func(p []byte) {
+			parts[name] = new(bytes.Buffer)
+			return func(p []byte) {
+				mu.Lock()
+				parts[name].Write(p)
+				mu.Unlock()
+			}
+		}
+		c.SetCommonDumpOptions(&DumpOptions{
+			Output:           io.Discard,
+			RequestHeader:    true,
+			RequestBody:      true,
+			ResponseHeader:   true,
+			ResponseBody:     true,
+			OnRequestHeader:  hook("reqHeader"),
+			OnRequestBody:    hook("reqBody"),
+			OnResponseHeader: hook("respHeader"),
+			OnResponseBody:   hook("respBody"),
+		}).EnableDumpAll()
+		resp, err := c.R().SetBody("test body").Post("/")
+		assertSuccess(t, resp, err)
+		mu.Lock()
+		defer mu.Unlock()
+		tests.AssertContains(t, parts["reqHeader"].String(), "user-agent", true)
+		tests.AssertEqual(t, "test body", parts["reqBody"].String())
+		tests.AssertContains(t, parts["respHeader"].String(), "content-type", true)
+		tests.AssertEqual(t, "TestPost: text response", parts["respBody"].String())
+	})
+}
+
+func TestContextWithDump(t *testing.T) {
+	c := tc()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", resp.Dump())
+
+	buf := new(bytes.Buffer)
+	ctx := ContextWithDump(context.Background(), &DumpOptions{Output: buf, RequestHeader: true})
+	resp, err = c.R().SetContext(ctx).Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), "user-agent", true)
+	tests.AssertContains(t, buf.String(), "testget", false)
+
+	// dump options of the client
+	buf.Reset()
+	c.SetCommonDumpOptions(&DumpOptions{Output: buf, ResponseBody: true})
+	resp, err = c.R().SetContext(ContextWithDump(context.Background())).Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "TestGet: text response", strings.TrimSpace(buf.String()))
+}
+
+func TestSetResponseBodyTransformer(t *testing.T) {
+	c := tc().SetResponseBodyTransformer(func(rawBody []byte, req *Request, resp *Response) (transformedBody []byte, err error) {
+		if resp.IsSuccessState() {
 			result, err := url.QueryUnescape(string(rawBody))
 			return []byte(result), err
 		}
@@ -664,3 +1991,1828 @@ func TestCloneCookieJar(t *testing.T) {
 	tests.AssertEqual(t, true, c2.cookiejarFactory == nil)
 	tests.AssertEqual(t, true, c2.httpClient.Jar == nil)
 }
+
+func TestDerive(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "tenant", Value: r.Header.Get("X-Tenant")})
+		cookie, _ := r.Cookie("tenant")
+		if cookie != nil {
+			w.Write([]byte(r.Header.Get("X-Tenant") + " " + cookie.Value))
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Tenant")))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	base := C().SetBaseURL(server.URL)
+	a := base.Derive().SetCommonHeader("X-Tenant", "a")
+	b := base.Derive().SetCommonHeader("X-Tenant", "b").
+		OnBeforeRequest(func(client *Client, req *Request) error {
+			req.SetQueryParam("tenant", "b")
+			return nil
+		})
+	tests.AssertEqual(t, true, base.Transport == a.Transport)
+	tests.AssertEqual(t, "", base.Headers.Get("X-Tenant"))
+
+	for i := 0; i < 2; i++ {
+		resp, err := a.R().Get("/")
+		assertSuccess(t, resp, err)
+		if i == 0 {
+			tests.AssertEqual(t, "a", resp.String())
+		} else {
+			tests.AssertEqual(t, "a a", resp.String())
+		}
+		resp, err = b.R().Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "tenant=b", resp.Request.RawRequest.URL.RawQuery)
+		if i == 0 {
+			tests.AssertEqual(t, "b", resp.String())
+		} else {
+			tests.AssertEqual(t, "b b", resp.String())
+		}
+	}
+	resp, err := base.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", resp.String())
+	tests.AssertEqual(t, "", resp.Request.RawRequest.URL.RawQuery)
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&conns))
+
+	// the deprecated Transport.Headers is still honored.
+	base.Transport.Headers = http.Header{"X-Tenant": []string{"transport"}}
+	resp, err = base.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, true, strings.HasPrefix(resp.String(), "transport"))
+	resp, err = a.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, true, strings.HasPrefix(resp.String(), "a "))
+
+	// the debug log of the transport follows the child.
+	var baseLog, childLog bytes.Buffer
+	base.SetLogger(NewLogger(&baseLog, "", 0))
+	child := base.Derive().SetLogger(NewLogger(&childLog, "", 0)).EnableDebugLog()
+	resp, err = child.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, childLog.String(), "http/1.1 get", true)
+	tests.AssertEqual(t, "", baseLog.String())
+}
+
+func TestAltSvc(t *testing.T) {
+	alt := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("alt " + r.Proto))
+	}))
+	alt.EnableHTTP2 = true
+	alt.StartTLS()
+	_, altPort, _ := net.SplitHostPort(alt.Listener.Addr().String())
+
+	var count int32
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.Header().Set("Alt-Svc", fmt.Sprintf(`h2=":%s"; ma=60`, altPort))
+		}
+		w.Write([]byte("origin"))
+	}))
+	origin.EnableHTTP2 = true
+	origin.StartTLS()
+	defer origin.Close()
+
+	var proxied sync.Map
+	c := tc().EnableInsecureSkipVerify().EnableAltSvc().SetProxy(func(r *http.Request) (*url.URL, error) {
+		proxied.Store(r.URL.Host, true)
+		return nil, nil
+	})
+	resp, err := c.R().Get(origin.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "origin", resp.String())
+
+	resp, err = c.R().Get(origin.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "alt HTTP/2.0", resp.String())
+	// the alternative service is dialed through the proxy as well.
+	_, ok := proxied.Load("127.0.0.1:" + altPort)
+	tests.AssertEqual(t, true, ok)
+
+	// fall back to the origin if the alternative service fails.
+	alt.Close()
+	resp, err = c.R().SetBody("test").Post(origin.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "origin", resp.String())
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&count))
+}
+
+// serveSocks5 serves a minimal socks5 proxy with username/password auth on
+// ln, which reports the requested target host to hosts.
+func serveSocks5(ln net.Listener, username, password string, hosts chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			br := bufio.NewReader(conn)
+			buf := make([]byte, 256)
+			// greeting
+			if _, err := io.ReadFull(br, buf[:2]); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(br, buf[:buf[1]]); err != nil {
+				return
+			}
+			conn.Write([]byte{5, 2})
+			// username/password
+			if _, err := io.ReadFull(br, buf[:2]); err != nil {
+				return
+			}
+			user := make([]byte, buf[1])
+			io.ReadFull(br, user)
+			io.ReadFull(br, buf[:1])
+			pass := make([]byte, buf[0])
+			io.ReadFull(br, pass)
+			if string(user) != username || string(pass) != password {
+				conn.Write([]byte{1, 1})
+				return
+			}
+			conn.Write([]byte{1, 0})
+			// connect
+			if _, err := io.ReadFull(br, buf[:4]); err != nil {
+				return
+			}
+			var host string
+			switch buf[3] {
+			case 1:
+				io.ReadFull(br, buf[:4])
+				host = net.IP(buf[:4]).String()
+			case 3:
+				io.ReadFull(br, buf[:1])
+				name := make([]byte, buf[0])
+				io.ReadFull(br, name)
+				host = string(name)
+			default:
+				return
+			}
+			io.ReadFull(br, buf[:2])
+			port := binary.BigEndian.Uint16(buf[:2])
+			hosts <- host
+			target, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(port)))
+			if err != nil {
+				conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer target.Close()
+			conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+			go io.Copy(target, br)
+			io.Copy(conn, target)
+		}()
+	}
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.Host))
+	}))
+	defer target.Close()
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	defer ln.Close()
+	hosts := make(chan string, 10)
+	go serveSocks5(ln, "roc", "123456", hosts)
+
+	for _, scheme := range []string{"socks5", "socks5h"} {
+		buf := new(bytes.Buffer)
+		c := C().SetProxyURL(fmt.Sprintf("%s://roc:123456@%s", scheme, ln.Addr().String())).
+			EnableDumpAllTo(buf).EnableTraceAll()
+		resp, err := c.R().Get("http://test.local:" + port)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "hello test.local:"+port, resp.String())
+		tests.AssertEqual(t, "test.local", <-hosts)
+		tests.AssertContains(t, buf.String(), "hello test.local", true)
+		tests.AssertEqual(t, true, resp.TraceInfo().ConnectTime > 0)
+	}
+
+	c := C().SetProxyURL("socks5h://"+ln.Addr().String()).SetProxyBasicAuth("roc", "123456")
+	resp, err := c.R().Get("http://test.local:" + port)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "test.local", <-hosts)
+
+	_, err = C().SetProxyURL("socks5h://roc:wrong@" + ln.Addr().String()).R().Get("http://test.local:" + port)
+	tests.AssertNotNil(t, err)
+}
+
+func TestSLA(t *testing.T) {
+	var slow int32 = 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&slow) == 1 {
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var events []SLAEvent
+	c := tc().SetSLA("127.0.0.1", 30*time.Millisecond, &SLAOptions{
+		WindowSize:   5,
+		MinSamples:   3,
+		OpenDuration: 100 * time.Millisecond,
+		OnStateChange: func(e SLAEvent) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		},
+	})
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get(ts.URL)
+		assertSuccess(t, resp, err)
+	}
+	_, err := c.R().Get(ts.URL)
+	tests.AssertErrorContains(t, err, "circuit is open")
+	tests.AssertEqual(t, true, errors.Is(err, ErrSLACircuitOpen))
+
+	// hosts without SLA are not affected.
+	resp, err := c.R().Get(strings.Replace(ts.URL, "127.0.0.1", "localhost", 1))
+	assertSuccess(t, resp, err)
+
+	// the probe request closes the circuit if the host recovers.
+	atomic.StoreInt32(&slow, 0)
+	time.Sleep(120 * time.Millisecond)
+	resp, err = c.R().Get(ts.URL)
+	assertSuccess(t, resp, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	tests.AssertEqual(t, 3, len(events))
+	tests.AssertEqual(t, SLAStateClosed, events[0].From)
+	tests.AssertEqual(t, SLAStateOpen, events[0].To)
+	tests.AssertEqual(t, true, events[0].P99 > 30*time.Millisecond)
+	tests.AssertEqual(t, SLAStateHalfOpen, events[1].To)
+	tests.AssertEqual(t, SLAStateClosed, events[2].To)
+	tests.AssertEqual(t, "127.0.0.1", events[2].Host)
+}
+
+func TestSLAReleaseProbe(t *testing.T) {
+	now := time.Now()
+	tracker := newSLATracker("api.example.com", time.Millisecond, SLAOptions{MinSamples: 1, OpenDuration: time.Second})
+	tracker.record(time.Second, false, now)
+	tests.AssertEqual(t, SLAStateOpen, tracker.state)
+	now = now.Add(2 * time.Second)
+	tests.AssertNoError(t, tracker.allow(now))
+	tests.AssertEqual(t, ErrSLACircuitOpen, tracker.allow(now))
+	// the probe is not sent, e.g. the concurrency limiter rejects it.
+	tracker.release()
+	tests.AssertNoError(t, tracker.allow(now))
+}
+
+func TestSLAFailedProbe(t *testing.T) {
+	now := time.Now()
+	tracker := newSLATracker("api.example.com", time.Second, SLAOptions{MinSamples: 1, OpenDuration: time.Second})
+	// the fast failure is not a good latency.
+	tracker.record(time.Millisecond, true, now)
+	tests.AssertEqual(t, SLAStateOpen, tracker.state)
+	now = now.Add(2 * time.Second)
+	tests.AssertNoError(t, tracker.allow(now))
+	tracker.record(time.Millisecond, true, now)
+	tests.AssertEqual(t, SLAStateOpen, tracker.state)
+	tests.AssertEqual(t, ErrSLACircuitOpen, tracker.allow(now))
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	var inflight, maxInflight int32
+	var fail int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	var changes int32
+	c := tc().EnableAdaptiveConcurrency(&AdaptiveConcurrencyOptions{
+		InitialLimit: 4,
+		MaxLimit:     6,
+		OnLimitChange: func(h string, limit int) {
+			tests.AssertEqual(t, host, h)
+			atomic.AddInt32(&changes, 1)
+		},
+	})
+	tests.AssertEqual(t, 4, c.ConcurrencyLimit(host))
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.R().Get(ts.URL)
+			assertSuccess(t, resp, err)
+		}()
+	}
+	wg.Wait()
+	tests.AssertEqual(t, true, atomic.LoadInt32(&maxInflight) <= 6)
+	tests.AssertEqual(t, 6, c.ConcurrencyLimit(host))
+	tests.AssertEqual(t, true, atomic.LoadInt32(&changes) > 0)
+
+	// the limit is decreased when the upstream is throttling.
+	atomic.StoreInt32(&fail, 1)
+	for i := 0; i < 3; i++ {
+		c.R().Get(ts.URL)
+	}
+	tests.AssertEqual(t, 3, c.ConcurrencyLimit(host))
+
+	// wait for the slot until the context is done.
+	c.EnableAdaptiveConcurrency(&AdaptiveConcurrencyOptions{InitialLimit: 1, MaxLimit: 1})
+	atomic.StoreInt32(&fail, 0)
+	go c.R().Get(ts.URL)
+	time.Sleep(5 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err := c.R().SetContext(ctx).Get(ts.URL)
+	tests.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestSLAWithAdaptiveConcurrency(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	var opened int32
+	c := tc().EnableAdaptiveConcurrency(&AdaptiveConcurrencyOptions{InitialLimit: 1, MaxLimit: 1}).
+		SetSLA("127.0.0.1", 50*time.Millisecond, &SLAOptions{
+			MinSamples: 3,
+			OnStateChange: func(e SLAEvent) {
+				atomic.AddInt32(&opened, 1)
+			},
+		})
+	// the time waiting for the slot is not counted in the latency.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.R().Get(ts.URL)
+			assertSuccess(t, resp, err)
+		}()
+	}
+	wg.Wait()
+	tests.AssertEqual(t, int32(0), atomic.LoadInt32(&opened))
+}
+
+func TestRequestHeaderLimits(t *testing.T) {
+	c := tc().SetRequestHeaderLimits(512, 10)
+	resp, err := c.R().SetHeader("X-Test", "ok").Get("/")
+	assertSuccess(t, resp, err)
+
+	_, err = c.R().SetHeader("X-Large", strings.Repeat("a", 512)).Get("/")
+	var limitErr *RequestHeaderLimitError
+	tests.AssertEqual(t, true, errors.As(err, &limitErr))
+	tests.AssertEqual(t, true, limitErr.Bytes > 512)
+	tests.AssertErrorContains(t, err, "exceeds the limit 512")
+
+	r := c.R()
+	for i := 0; i < 10; i++ {
+		r.SetCookies(&http.Cookie{Name: fmt.Sprintf("c%d", i), Value: "v"})
+		r.SetHeader(fmt.Sprintf("X-Test-%d", i), "v")
+	}
+	_, err = r.Get("/")
+	tests.AssertEqual(t, true, errors.As(err, &limitErr))
+	tests.AssertEqual(t, 10, limitErr.MaxCount)
+	tests.AssertErrorContains(t, err, "header count")
+
+	// the cookies of the jar are counted.
+	u, _ := url.Parse(getTestServerURL())
+	c.GetClient().Jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: strings.Repeat("a", 512)}})
+	_, err = c.R().Get("/")
+	tests.AssertEqual(t, true, errors.As(err, &limitErr))
+	tests.AssertEqual(t, true, limitErr.Bytes > 512)
+}
+
+func TestRequestAdapter(t *testing.T) {
+	var raw string
+	c := tc().SetRequestAdapter(func(ctx context.Context, rawRequest []byte) ([]byte, error) {
+		raw = string(rawRequest)
+		if bytes.Contains(rawRequest, []byte("secret")) {
+			return nil, errors.New("secret is not allowed")
+		}
+		if bytes.Contains(rawRequest, []byte("/echo")) {
+			adapted := bytes.Replace(rawRequest, []byte("Content-Length: 5\r\n"), []byte("Content-Length: 11\r\nX-Scanned: yes\r\n"), 1)
+			return bytes.Replace(adapted, []byte("\r\n\r\nhello"), []byte("\r\n\r\nhello world"), 1), nil
+		}
+		return nil, nil
+	})
+
+	resp, err := c.R().SetHeader("X-Test", "ok").Get("/header")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, raw, "get /header http/1.1\r\n", true)
+	tests.AssertContains(t, raw, "x-test: ok\r\n", true)
+	tests.AssertContains(t, resp.String(), "x-test", true)
+
+	var e Echo
+	resp, err = c.R().SetBody("hello").SetSuccessResult(&e).Post("/echo")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "yes", e.Header.Get("X-Scanned"))
+	tests.AssertEqual(t, "hello world", e.Body)
+
+	_, err = c.R().SetBody("top secret").Post("/echo")
+	tests.AssertErrorContains(t, err, "vetoed by the adapter: secret is not allowed")
+
+	// the streaming body is buffered and scanned too.
+	_, err = c.R().SetBody(io.NopCloser(strings.NewReader("top secret"))).Post("/echo")
+	tests.AssertErrorContains(t, err, "vetoed by the adapter: secret is not allowed")
+	e = Echo{}
+	resp, err = c.R().SetBody(io.NopCloser(strings.NewReader("hello"))).SetSuccessResult(&e).Post("/echo")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "hello world", e.Body)
+	resp, err = c.R().SetBody(io.NopCloser(strings.NewReader("plain text"))).Post("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, raw, "content-length: 10\r\n\r\nplain text", true)
+}
+
+func TestContentTypeSniffing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Write([]byte(r.URL.Query().Get("body")))
+	}))
+	defer server.Close()
+
+	c := tc().EnableContentTypeSniffing()
+	cases := []struct {
+		contentType, body, detected string
+	}{
+		{"application/json", `{"name": "req"}`, ""},
+		{"application/json; charset=utf-8", "\ufeff [1, 2]", ""},
+		{"application/json", "<!DOCTYPE html><html><body>Login</body></html>", "html"},
+		{"application/xml", `<?xml version="1.0"?><name>req</name>`, ""},
+		{"text/xml", `{"name": "req"}`, "json"},
+		{"application/json", `<?xml version="1.0"?><error/>`, "xml"},
+		{"text/html", `{"name": "req"}`, ""},
+		{"application/json", "not found", ""},
+	}
+	for _, cs := range cases {
+		resp, err := c.R().SetQueryParams(map[string]string{
+			"type": cs.contentType,
+			"body": cs.body,
+		}).Get(server.URL)
+		if cs.detected == "" {
+			tests.AssertNoError(t, err)
+			continue
+		}
+		tests.AssertEqual(t, true, errors.Is(err, ErrUnexpectedContentType))
+		var e *ContentTypeMismatchError
+		tests.AssertEqual(t, true, errors.As(err, &e))
+		tests.AssertEqual(t, cs.contentType, e.Declared)
+		tests.AssertEqual(t, cs.detected, e.Detected)
+		tests.AssertEqual(t, cs.body, resp.String())
+	}
+
+	resp, err := c.DisableContentTypeSniffing().R().SetQueryParams(map[string]string{
+		"type": "application/json",
+		"body": "<html></html>",
+	}).Get(server.URL)
+	assertSuccess(t, resp, err)
+}
+
+func TestSetTLSHandshake(t *testing.T) {
+	var addrs []string
+	c := tc().SetTLSHandshake(func(ctx context.Context, addr string, plainConn net.Conn) (net.Conn, *tls.ConnectionState, error) {
+		addrs = append(addrs, addr)
+		conn := tls.Client(plainConn, &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"http/1.1"},
+		})
+		if err := conn.HandshakeContext(ctx); err != nil {
+			return nil, nil, err
+		}
+		state := conn.ConnectionState()
+		return conn, &state, nil
+	})
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.1", resp.Proto)
+	tests.AssertEqual(t, 1, len(addrs))
+
+	resp, err = tc().SetTLSFingerprintChrome().R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+}
+
+func TestDetectInterception(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/generate_204":
+			w.WriteHeader(http.StatusNoContent)
+		case "/success":
+			w.Write([]byte("<HTML><BODY>Success</BODY></HTML>"))
+		case "/portal":
+			w.Header().Set("Via", "1.1 portal")
+			w.Write([]byte("<html>Please login</html>"))
+		case "/proxied":
+			w.Header().Add("Via", "1.1 corp-proxy (squid/5.7), 1.1 edge")
+			w.Header().Add("Via", "HTTP/1.1 my-corp-proxy")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Redirect(w, r, "/portal", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	c := tc()
+	result, err := c.DetectInterception(server.URL + "/generate_204")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, false, result.Intercepted)
+	tests.AssertEqual(t, http.StatusNoContent, result.StatusCode)
+
+	result, err = c.DetectInterception(server.URL+"/success", &InterceptionProbeOptions{
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   "Success",
+	})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, false, result.Intercepted)
+
+	result, err = c.DetectInterception(server.URL + "/hotspot")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, result.Intercepted)
+	tests.AssertEqual(t, server.URL+"/portal", result.FinalURL)
+	tests.AssertEqual(t, 4, len(result.Reasons))
+	tests.AssertContains(t, result.Reasons[0], "redirected to", true)
+
+	// the received-by tokens of the known proxies are compared exactly.
+	result, err = c.DetectInterception(server.URL+"/proxied", &InterceptionProbeOptions{
+		KnownProxies: []string{"corp-proxy", "edge"},
+	})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, result.Intercepted)
+	tests.AssertEqual(t, []string{`response is relayed via "my-corp-proxy"`}, result.Reasons)
+
+	// the certificate of the test server is not trusted.
+	result, err = C().DetectInterception(getTestServerURL())
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, result.Intercepted)
+	tests.AssertContains(t, result.Reasons[0], "certificate verification failed", true)
+
+	_, err = c.DetectInterception("http://127.0.0.1:1/generate_204")
+	tests.AssertNotNil(t, err)
+}
+
+// serveSSH serves a minimal SSH server which only supports password auth
+// and direct-tcpip channels (port forwarding) on ln.
+func serveSSH(ln net.Listener, config *ssh.ServerConfig, targets chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			for ch := range chans {
+				if ch.ChannelType() != "direct-tcpip" {
+					ch.Reject(ssh.UnknownChannelType, "unsupported")
+					continue
+				}
+				var payload struct {
+					Host       string
+					Port       uint32
+					OriginHost string
+					OriginPort uint32
+				}
+				if err := ssh.Unmarshal(ch.ExtraData(), &payload); err != nil {
+					ch.Reject(ssh.ConnectionFailed, err.Error())
+					continue
+				}
+				addr := net.JoinHostPort(payload.Host, fmt.Sprint(payload.Port))
+				targets <- addr
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					ch.Reject(ssh.ConnectionFailed, err.Error())
+					continue
+				}
+				channel, requests, err := ch.Accept()
+				if err != nil {
+					target.Close()
+					continue
+				}
+				go ssh.DiscardRequests(requests)
+				go func() {
+					defer channel.Close()
+					defer target.Close()
+					go io.Copy(target, channel)
+					io.Copy(channel, target)
+				}()
+			}
+		}()
+	}
+}
+
+func TestSSHTunnel(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	tests.AssertNoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	tests.AssertNoError(t, err)
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "roc" && string(password) == "123456" {
+				return nil, nil
+			}
+			return nil, errors.New("bad password")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	defer ln.Close()
+	targets := make(chan string, 10)
+	go serveSSH(ln, serverConfig, targets)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("private"))
+	}))
+	defer target.Close()
+
+	c := tc().SetSSHTunnelConfig(ln.Addr().String(), &ssh.ClientConfig{
+		User:            "roc",
+		Auth:            []ssh.AuthMethod{ssh.Password("123456")},
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := c.R().Get(target.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "private", resp.String())
+	}
+	tests.AssertEqual(t, strings.TrimPrefix(target.URL, "http://"), <-targets)
+
+	_, err = tc().SetSSHTunnelConfig(ln.Addr().String(), &ssh.ClientConfig{
+		User:            "roc",
+		Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	}).R().Get(target.URL)
+	tests.AssertErrorContains(t, err, "failed to connect to ssh jump host")
+}
+
+func TestHTTP2Tuning(t *testing.T) {
+	var conns, inflight, maxInflight int32
+	body := strings.Repeat("a", 1<<20)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(body))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	c := tc().EnableInsecureSkipVerify().
+		SetHTTP2MaxConcurrentStreams(2).
+		SetHTTP2StrictMaxConcurrentStreams(true).
+		SetHTTP2InitialWindowSize(16 << 10).
+		SetHTTP2ReadIdleTimeout(time.Second)
+	tests.AssertEqual(t, uint32(2), c.Clone().t2.MaxConcurrentStreams)
+	tests.AssertEqual(t, uint32(16<<10), c.Clone().t2.InitialWindowSize)
+	tests.AssertEqual(t, uint32(16<<10), c.Clone().SetHTTP2InitialWindowSize(1<<31).t2.InitialWindowSize)
+	resp, err := c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.R().Get(server.URL)
+			assertSuccess(t, resp, err)
+			tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+			tests.AssertEqual(t, len(body), len(resp.String()))
+		}()
+	}
+	wg.Wait()
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&conns))
+	tests.AssertEqual(t, true, atomic.LoadInt32(&maxInflight) <= 2)
+}
+
+func TestBulkFetcher(t *testing.T) {
+	var failPage int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if int32(page) == atomic.LoadInt32(&failPage) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if page < 5 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=5>; rel="last"`, page+1))
+		}
+		w.Write([]byte(strconv.Itoa(page)))
+	}))
+	defer server.Close()
+
+	checkpoint := filepath.Join(t.TempDir(), "sync.checkpoint")
+	var pages []string
+	handle := func(resp *Response) error {
+		pages = append(pages, resp.String())
+		return nil
+	}
+	fetcher := C().NewBulkFetcher(server.URL+"/items", &BulkFetchOptions{
+		Rate:           20,
+		CheckpointFile: checkpoint,
+	})
+
+	// crash on the page 3, the checkpoint is kept
+	atomic.StoreInt32(&failPage, 3)
+	err := fetcher.Run(context.Background(), handle)
+	var se *StatusError
+	tests.AssertEqual(t, true, errors.As(err, &se))
+	tests.AssertEqual(t, http.StatusServiceUnavailable, se.StatusCode)
+	tests.AssertEqual(t, []string{"1", "2"}, pages)
+	b, err := os.ReadFile(checkpoint)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, server.URL+"/items?page=3", string(b))
+
+	// resume from the page 3 at the rate
+	atomic.StoreInt32(&failPage, 0)
+	start := time.Now()
+	tests.AssertNoError(t, fetcher.Run(context.Background(), handle))
+	tests.AssertEqual(t, true, time.Since(start) >= 100*time.Millisecond)
+	tests.AssertEqual(t, []string{"1", "2", "3", "4", "5"}, pages)
+	_, err = os.Stat(checkpoint)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests.AssertEqual(t, true, errors.Is(fetcher.Run(ctx, handle), context.Canceled))
+}
+
+func TestDnsCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	var lookups int32
+	c := C().EnableDnsCache(time.Minute, 2).SetResolver(ResolverFunc(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		atomic.AddInt32(&lookups, 1)
+		if strings.HasPrefix(host, "missing") {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}))
+	c.DisableKeepAlives()
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get("http://api.test:" + port)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "ok", resp.String())
+	}
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&lookups))
+
+	for i := 0; i < 2; i++ {
+		_, err := c.R().Get("http://missing.test:" + port)
+		tests.AssertErrorContains(t, err, "no such host")
+	}
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&lookups))
+	tests.AssertEqual(t, DnsCacheStats{Hits: 3, NegativeHits: 1, Misses: 2, Entries: 2}, c.DnsCacheStats())
+
+	// the least recently used api.test is evicted
+	_, err := c.R().Get("http://missing2.test:" + port)
+	tests.AssertErrorContains(t, err, "no such host")
+	resp, err := c.R().Get("http://api.test:" + port)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, DnsCacheStats{Hits: 3, NegativeHits: 1, Misses: 4, Evictions: 2, Entries: 2}, c.DnsCacheStats())
+
+	c.DisableDnsCache()
+	tests.AssertEqual(t, DnsCacheStats{}, c.DnsCacheStats())
+}
+
+func TestReverseProxy(t *testing.T) {
+	var attempts int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/flaky" && atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Connection", "X-Internal")
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("X-Upstream", "users")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s?%s host=%s auth=%s xff=%s xfh=%s xfp=%s hop=%s body=%s",
+			r.Method, r.URL.Path, r.URL.RawQuery, r.Host, r.Header.Get("Authorization"),
+			r.Header.Get("X-Forwarded-For"), r.Header.Get("X-Forwarded-Host"),
+			r.Header.Get("X-Forwarded-Proto"), r.Header.Get("Keep-Alive"), body)
+	}))
+	defer upstream.Close()
+
+	client := C().SetBaseURL(upstream.URL).
+		SetCommonBearerAuthToken("token").
+		SetCommonRetryCount(1).
+		SetCommonRetryCondition(func(resp *Response, err error) bool {
+			return err != nil || resp.StatusCode == http.StatusServiceUnavailable
+		})
+	gateway := httptest.NewServer(client.ReverseProxy(&ReverseProxyOptions{
+		StripPrefix: "/users",
+		Rewrite: func(path string) string {
+			return "/api/v2" + path
+		},
+	}))
+	defer gateway.Close()
+
+	resp, err := C().R().
+		SetHeader("Keep-Alive", "timeout=5").
+		SetBody("hello").
+		Post(gateway.URL + "/users/roc?fields=name")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusCreated, resp.StatusCode)
+	tests.AssertEqual(t, "users", resp.Header.Get("X-Upstream"))
+	tests.AssertEqual(t, "", resp.Header.Get("X-Internal"))
+	gatewayHost := strings.TrimPrefix(gateway.URL, "http://")
+	tests.AssertEqual(t, fmt.Sprintf("POST /api/v2/roc?fields=name host=%s auth=Bearer token xff=127.0.0.1 xfh=%s xfp=http hop= body=hello",
+		strings.TrimPrefix(upstream.URL, "http://"), gatewayHost), resp.String())
+
+	// retried with the retry policy of the client
+	resp, err = C().R().Get(gateway.URL + "/users/flaky")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusCreated, resp.StatusCode)
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&attempts))
+
+	upstream.Close()
+	resp, err = C().R().Get(gateway.URL + "/users/roc")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusBadGateway, resp.StatusCode)
+
+	// the body is forwarded without being decoded to utf-8
+	gbkGateway := httptest.NewServer(tc().ReverseProxy())
+	defer gbkGateway.Close()
+	resp, err = C().DisableAutoDecode().R().Get(gbkGateway.URL + "/gbk")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "text/plain; charset=gbk", resp.Header.Get("Content-Type"))
+	tests.AssertEqual(t, int64(len(toGbk("我是roc"))), resp.ContentLength)
+	tests.AssertEqual(t, toGbk("我是roc"), resp.Bytes())
+
+	gbkGateway.Config.Handler = tc().ReverseProxy(&ReverseProxyOptions{
+		ModifyRequest: func(r *Request, in *http.Request) {
+			r.SetResponseCharset("gbk")
+		},
+	})
+	resp, err = C().DisableAutoDecode().R().Get(gbkGateway.URL + "/gbk")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	tests.AssertEqual(t, "我是roc", resp.String())
+}
+
+func TestSetResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	var queries int32
+	doh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p dnsmessage.Parser
+		h, err := p.Start(body)
+		if err != nil || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		q, _ := p.Question()
+		atomic.AddInt32(&queries, 1)
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true})
+		b.StartQuestions()
+		b.Question(q)
+		b.StartAnswers()
+		if q.Name.String() == "api.test." && q.Type == dnsmessage.TypeA {
+			b.AResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60},
+				dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}})
+		}
+		msg, _ := b.Finish()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(msg)
+	}))
+	defer doh.Close()
+
+	c := C().SetResolver(NewDoHResolver(doh.URL)).EnableTraceAll()
+	resp, err := c.R().Get("http://api.test:" + port)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+	tests.AssertEqual(t, true, atomic.LoadInt32(&queries) > 0)
+	tests.AssertEqual(t, true, resp.TraceInfo().DNSLookupTime > 0)
+
+	ips, err := NewDNSResolver().LookupIPAddr(context.Background(), "localhost")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, len(ips) > 0)
+}
+
+func TestSetDNSLookupTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	c := C().SetDialTimeout(5 * time.Second).SetDNSLookupTimeout(50 * time.Millisecond).
+		SetResolver(ResolverFunc(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			if host == "hang.test" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+		}))
+	start := time.Now()
+	_, err := c.R().Get("http://hang.test:" + port)
+	tests.AssertEqual(t, true, errors.Is(err, ErrDNSTimeout))
+	tests.AssertEqual(t, true, time.Since(start) < 5*time.Second)
+	var netErr net.Error
+	tests.AssertEqual(t, true, errors.As(err, &netErr) && netErr.Timeout())
+
+	resp, err := c.R().Get("http://api.test:" + port)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "ok", resp.String())
+}
+
+func TestSetHostMapping(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.TLS.ServerName))
+	}))
+	defer server.Close()
+	c := C().SetRootCertFromString(string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}))).SetHostMapping(map[string]string{
+		"EXAMPLE.com:443": server.Listener.Addr().String(),
+	})
+	resp, err := c.R().Get("https://example.com/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "example.com example.com", resp.String())
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	c.SetHostMapping(map[string]string{"example.com": "127.0.0.1"})
+	resp, err = c.R().Get("https://example.com:" + port + "/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "example.com:"+port+" example.com", resp.String())
+
+	c.SetHostMapping(map[string]string{"example.com": "staging.local"})
+	tests.AssertEqual(t, "127.0.0.1:443", c.mapHost("example.com:443"))
+}
+
+func TestConnReuseInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := C()
+	resp, err := c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	info := resp.ConnReuseInfo()
+	tests.AssertEqual(t, false, info.Reused)
+	tests.AssertEqual(t, "HTTP/1.1", info.Protocol)
+	tests.AssertEqual(t, server.Listener.Addr().String(), info.RemoteAddr.String())
+
+	time.Sleep(10 * time.Millisecond)
+	resp, err = c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+	info = resp.ConnReuseInfo()
+	tests.AssertEqual(t, true, info.Reused)
+	tests.AssertEqual(t, true, info.WasIdle)
+	tests.AssertEqual(t, true, info.IdleTime >= 10*time.Millisecond)
+
+	resp, err = tc().R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.ConnReuseInfo().Protocol)
+}
+
+func TestSetIPPreference(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	var mu sync.Mutex
+	var dialed []string
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			dialed = append(dialed, addr)
+			mu.Unlock()
+		},
+	})
+	c := C().DisableKeepAlives().SetResolver(ResolverFunc(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}))
+
+	c.SetIPPreference(IPPreferenceIPv4)
+	resp, err := c.R().SetContext(ctx).Get("http://dual.test:" + port)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, []string{"127.0.0.1:" + port}, dialed)
+
+	// the IPv6 address is refused, fallback to IPv4 immediately
+	dialed = nil
+	c.SetIPPreference(IPPreferenceDefault).SetDialFallbackDelay(time.Minute)
+	resp, err = c.R().SetContext(ctx).Get("http://dual.test:" + port)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, []string{"[::1]:" + port, "127.0.0.1:" + port}, dialed)
+
+	dialed = nil
+	c.SetIPPreference(IPPreferenceIPv6Only)
+	_, err = c.R().SetContext(ctx).Get("http://dual.test:" + port)
+	tests.AssertNotNil(t, err)
+	tests.AssertEqual(t, []string{"[::1]:" + port}, dialed)
+}
+
+func TestHealthCheck(t *testing.T) {
+	var unhealthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&unhealthy) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	waitFor := func(cond func() bool) {
+		for i := 0; i < 200 && !cond(); i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		tests.AssertEqual(t, true, cond())
+	}
+	var probes int32
+	c := C().SetSLA(server.Listener.Addr().String(), time.Minute, &SLAOptions{OpenDuration: time.Hour})
+	checker := c.HealthCheck(server.URL+"/healthz", 10*time.Millisecond, func(status HealthStatus) {
+		atomic.AddInt32(&probes, 1)
+	}, &HealthCheckOptions{FailureThreshold: 2})
+	defer checker.Stop()
+
+	waitFor(func() bool { return checker.Status().Healthy })
+	tests.AssertEqual(t, http.StatusOK, checker.Status().StatusCode)
+	tests.AssertEqual(t, server.URL+"/healthz", checker.Status().URL)
+
+	atomic.StoreInt32(&unhealthy, 1)
+	waitFor(func() bool { return !checker.Status().Healthy })
+	status := checker.Status()
+	tests.AssertEqual(t, true, status.ConsecutiveFailures >= 2)
+	tests.AssertEqual(t, http.StatusServiceUnavailable, status.StatusCode)
+	_, err := c.R().Get(server.URL)
+	tests.AssertEqual(t, true, errors.Is(err, ErrSLACircuitOpen))
+
+	// the probes bypass the open circuit, so the recovery is detected
+	atomic.StoreInt32(&unhealthy, 0)
+	waitFor(func() bool { return checker.Status().Healthy })
+	resp, err := c.R().Get(server.URL)
+	assertSuccess(t, resp, err)
+
+	checker.Stop()
+	n := atomic.LoadInt32(&probes)
+	time.Sleep(30 * time.Millisecond)
+	tests.AssertEqual(t, n, atomic.LoadInt32(&probes))
+}
+
+func TestConnectionPoolSetters(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	var closed int32
+	c := C().SetMaxIdleConns(10).
+		SetMaxIdleConnsPerHost(5).
+		SetMaxConnsPerHost(1).
+		SetIdleConnTimeout(time.Minute).
+		OnConnectionClose(func(info ConnectionInfo) {
+			atomic.AddInt32(&closed, 1)
+		})
+	tests.AssertEqual(t, 10, c.MaxIdleConns)
+	tests.AssertEqual(t, 5, c.MaxIdleConnsPerHost)
+	tests.AssertEqual(t, time.Minute, c.IdleConnTimeout)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.R().Get(server.URL)
+			assertSuccess(t, resp, err)
+		}()
+	}
+	wg.Wait()
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&conns))
+
+	c.CloseIdleConnections()
+	tests.AssertEqual(t, int32(1), atomic.LoadInt32(&closed))
+}
+
+func TestTransportStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	c := C()
+	tests.AssertEqual(t, TransportStats{}, c.TransportStats())
+	reported := make(chan TransportStats, 1)
+	c.EnableTransportStats().OnTransportStats(10*time.Millisecond, func(stats TransportStats) {
+		select {
+		case reported <- stats:
+		default:
+		}
+	})
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get(server.URL)
+		assertSuccess(t, resp, err)
+	}
+	stats := c.TransportStats()
+	tests.AssertEqual(t, int64(1), stats.Dials)
+	tests.AssertEqual(t, int64(1), stats.NewConns)
+	tests.AssertEqual(t, int64(2), stats.ReusedConns)
+	tests.AssertEqual(t, HostConnStats{Open: 1, Idle: 1}, stats.Hosts[addr])
+	tests.AssertEqual(t, true, stats.BytesRead > 0 && stats.BytesWritten > 0)
+
+	select {
+	case stats = <-reported:
+		tests.AssertEqual(t, true, stats.Dials > 0)
+	case <-time.After(time.Second):
+		t.Fatal("transport stats are not reported")
+	}
+
+	// the concurrent calls don't leak the reporting goroutines.
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.OnTransportStats(time.Millisecond, func(stats TransportStats) {
+				atomic.AddInt32(&calls, 1)
+			})
+		}()
+	}
+	wg.Wait()
+	c.OnTransportStats(0, nil)
+	time.Sleep(5 * time.Millisecond)
+	n := atomic.LoadInt32(&calls)
+	time.Sleep(10 * time.Millisecond)
+	tests.AssertEqual(t, n, atomic.LoadInt32(&calls))
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := l.Addr().String()
+	l.Close()
+	_, err := c.R().Get("http://" + closedAddr)
+	tests.AssertNotNil(t, err)
+	stats = c.TransportStats()
+	tests.AssertEqual(t, int64(2), stats.Dials)
+	tests.AssertEqual(t, int64(1), stats.DialFailures)
+
+	c.CloseIdleConnections()
+	_, ok := c.TransportStats().Hosts[addr]
+	tests.AssertEqual(t, false, ok)
+}
+
+func TestRandomizeFingerprint(t *testing.T) {
+	noise := func(seed int64) *fingerprintNoise {
+		c := C().RandomizeFingerprint(&FingerprintRandomizationOptions{Seed: seed})
+		return c.Transport.fingerprintNoise
+	}
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.Header.Set("Accept", "*/*")
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+		req.Header.Set("Accept-Language", "en-US")
+		req.Header.Set("X-Test", "1")
+		return req
+	}
+
+	// stable in the session
+	req := newReq()
+	r1 := noise(1).apply(req)
+	r2 := noise(1).apply(newReq())
+	tests.AssertEqual(t, r1.Header[header.HeaderOderKey], r2.Header[header.HeaderOderKey])
+	tests.AssertEqual(t, r1.Header.Get("Accept-Encoding"), r2.Header.Get("Accept-Encoding"))
+	tests.AssertEqual(t, 5, len(r1.Header[header.HeaderOderKey]))
+	// the original request is not modified
+	tests.AssertEqual(t, 0, len(req.Header[header.HeaderOderKey]))
+
+	// differs between sessions
+	orders := map[string]bool{}
+	encodings := map[string]bool{}
+	for seed := int64(1); seed <= 10; seed++ {
+		r := noise(seed).apply(newReq())
+		orders[strings.Join(r.Header[header.HeaderOderKey], ",")] = true
+		encodings[r.Header.Get("Accept-Encoding")] = true
+	}
+	tests.AssertEqual(t, true, len(orders) > 1)
+	tests.AssertEqual(t, true, len(encodings) > 1)
+
+	// the existing order is only perturbed
+	order := []string{"host", "accept", "user-agent", "accept-encoding", "accept-language", "x-test"}
+	req = newReq()
+	req.Header[header.HeaderOderKey] = order
+	r := noise(1).apply(req)
+	perturbed := r.Header[header.HeaderOderKey]
+	tests.AssertEqual(t, "host", perturbed[0])
+	tests.AssertEqual(t, false, strings.Join(order, ",") == strings.Join(perturbed, ","))
+	tests.AssertEqual(t, "host,accept,user-agent,accept-encoding,accept-language,x-test", strings.Join(order, ","))
+
+	c := tc().ImpersonateChrome().RandomizeFingerprint()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+	c = tc().SetTLSFingerprintFirefox().RandomizeFingerprint()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+
+	// the clone has its own fingerprint.
+	cc := c.Clone()
+	tests.AssertEqual(t, c.Transport.fingerprintNoise.tlsExtensionOrder, cc.Transport.fingerprintNoise.tlsExtensionOrder)
+	tests.AssertEqual(t, false, c.Transport.fingerprintNoise.seed == cc.Transport.fingerprintNoise.seed)
+}
+
+func TestReplayDump(t *testing.T) {
+	// the raw server records the raw bytes of the requests.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.AssertNoError(t, err)
+	defer ln.Close()
+	var mu sync.Mutex
+	var raws []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					var raw bytes.Buffer
+					cl := 0
+					for {
+						line, err := br.ReadString('\n')
+						if err != nil {
+							return
+						}
+						raw.WriteString(line)
+						if k, v, ok := strings.Cut(strings.TrimSpace(line), ": "); ok && strings.EqualFold(k, "Content-Length") {
+							cl, _ = strconv.Atoi(v)
+						}
+						if line == "\r\n" {
+							break
+						}
+					}
+					body := make([]byte, cl)
+					if _, err := io.ReadFull(br, body); err != nil {
+						return
+					}
+					raw.Write(body)
+					mu.Lock()
+					raws = append(raws, raw.String())
+					mu.Unlock()
+					conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+				}
+			}(conn)
+		}
+	}()
+	baseURL := "http://" + ln.Addr().String()
+
+	var dumpBuf bytes.Buffer
+	c := C().SetBaseURL(baseURL).EnableDumpAllTo(&dumpBuf).SetCommonHeader("X-Common", "1")
+	_, err = c.R().SetHeaderNonCanonical("x-lower", "a").
+		SetHeader("X-Multi-Line", "b").
+		SetBody("line1\r\nHTTP/1.1 200 OK\r\nline3").
+		Post("/post?q=1")
+	tests.AssertNoError(t, err)
+	_, err = c.R().AddRetryCondition(func(resp *Response, err error) bool {
+		return resp.Request.RetryAttempt < 1
+	}).SetRetryCount(1).SetRetryFixedInterval(time.Millisecond).Get("/get")
+	tests.AssertNoError(t, err)
+	mu.Lock()
+	captured := append([]string(nil), raws...)
+	raws = nil
+	mu.Unlock()
+	tests.AssertEqual(t, 3, len(captured))
+
+	resps, err := C().SetBaseURL(baseURL).SetCommonHeader("X-Other", "2").ReplayDump(&dumpBuf)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 3, len(resps))
+	tests.AssertEqual(t, "ok", resps[0].String())
+	mu.Lock()
+	defer mu.Unlock()
+	tests.AssertEqual(t, captured, raws)
+
+	// the HTTP/1.x request is sent with the scheme of the options, and the
+	// redacted header is dropped.
+	raws = nil
+	mu.Unlock()
+	var logBuf bytes.Buffer
+	dump := "GET /get HTTP/1.1\r\nHost: " + ln.Addr().String() + "\r\nAuthorization: REDACTED\r\n\r\n"
+	resps, err = C().SetLogger(NewLogger(&logBuf, "", 0)).ReplayDump(strings.NewReader(dump), &ReplayDumpOptions{Scheme: "http"})
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "ok", resps[0].String())
+	tests.AssertContains(t, logBuf.String(), "drop the redacted header authorization", true)
+	mu.Lock()
+	tests.AssertEqual(t, 1, len(raws))
+	tests.AssertEqual(t, false, strings.Contains(raws[0], "Authorization"))
+
+	_, err = C().ReplayDump(strings.NewReader("HTTP/1.1 200 OK\r\n\r\n"))
+	tests.AssertErrorContains(t, err, "no request found in the dump")
+	_, err = C().ReplayDump(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 10\r\n\r\nabc\r\nHTTP/1.1 200 OK\r\n"))
+	tests.AssertErrorContains(t, err, "is not (fully) dumped")
+}
+
+func TestSetAutoReadPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("binary"))
+		case "/error":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"` + strings.Repeat("x", 100) + `"}`))
+		default:
+			w.Write([]byte(strings.Repeat("x", 100)))
+		}
+	}))
+	defer server.Close()
+
+	c := C().SetBaseURL(server.URL).SetAutoReadPolicy(&AutoReadPolicy{
+		MaxErrorBodySize: 10,
+		SkipContentTypes: []string{"application/octet-stream"},
+	})
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 100, len(resp.String()))
+	tests.AssertEqual(t, false, resp.IsBodyTruncated())
+
+	var errMsg struct {
+		Message string `json:"message"`
+	}
+	resp, err = c.R().SetErrorResult(&errMsg).Get("/error")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, `{"message"`, resp.String())
+	tests.AssertEqual(t, true, resp.IsBodyTruncated())
+	tests.AssertEqual(t, "", errMsg.Message)
+
+	// the skipped body is not read even if the result is set.
+	var result struct{}
+	resp, err = c.R().SetSuccessResult(&result).Get("/binary")
+	tests.AssertNoError(t, err)
+	tests.AssertIsNil(t, resp.SuccessResult())
+	body, err := io.ReadAll(resp.Body)
+	tests.AssertNoError(t, err)
+	resp.Body.Close()
+	tests.AssertEqual(t, "binary", string(body))
+
+	c.SetAutoReadPolicy(&AutoReadPolicy{MaxSuccessBodySize: 100})
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 100, len(resp.String()))
+	tests.AssertEqual(t, false, resp.IsBodyTruncated())
+	resp, err = c.R().Get("/error")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, false, resp.IsBodyTruncated())
+}
+
+// inflightBody counts the bodies being read.
+type inflightBody struct {
+	io.ReadCloser
+	started     bool
+	inflight    *int32
+	maxInflight *int32
+}
+
+func (b *inflightBody) Read(p []byte) (int, error) {
+	if !b.started {
+		b.started = true
+		n := atomic.AddInt32(b.inflight, 1)
+		for {
+			m := atomic.LoadInt32(b.maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(b.maxInflight, m, n) {
+				break
+			}
+		}
+	}
+	return b.ReadCloser.Read(p)
+}
+
+func (b *inflightBody) Close() error {
+	if b.started {
+		b.started = false
+		atomic.AddInt32(b.inflight, -1)
+	}
+	return b.ReadCloser.Close()
+}
+
+func TestAutoReadMaxConcurrentReads(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("slow"))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(" body"))
+	}))
+	defer ts.Close()
+
+	var inflight, maxInflight int32
+	c := C().SetAutoReadPolicy(&AutoReadPolicy{MaxConcurrentReads: 2})
+	c.Transport.WrapRoundTripFunc(func(rt http.RoundTripper) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := rt.RoundTrip(req)
+			if err == nil {
+				resp.Body = &inflightBody{ReadCloser: resp.Body, inflight: &inflight, maxInflight: &maxInflight}
+			}
+			return resp, err
+		}
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.R().Get(ts.URL)
+			assertSuccess(t, resp, err)
+			tests.AssertEqual(t, "slow body", resp.String())
+		}()
+	}
+	wg.Wait()
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&maxInflight))
+	tests.AssertEqual(t, int32(0), atomic.LoadInt32(&inflight))
+
+	// the only read slot is taken, the read waits until the request is canceled
+	c.SetAutoReadPolicy(&AutoReadPolicy{MaxConcurrentReads: 1})
+	c.autoReadSem <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.R().SetContext(ctx).Get(ts.URL)
+	tests.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+
+	// the clone has its own read slots.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := c.Clone().R().SetContext(ctx).Get(ts.URL)
+	assertSuccess(t, resp, err)
+	<-c.autoReadSem
+
+	c.SetAutoReadPolicy(&AutoReadPolicy{})
+	tests.AssertIsNil(t, c.autoReadSem)
+}
+
+func TestTraceInfoHandler(t *testing.T) {
+	var mu sync.Mutex
+	var infos []TraceInfo
+	c := tc().SetTraceInfoHandler(func(ti TraceInfo) {
+		mu.Lock()
+		infos = append(infos, ti)
+		mu.Unlock()
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := c.R().Get("/")
+		assertSuccess(t, resp, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	tests.AssertEqual(t, 2, len(infos))
+	ti := infos[0]
+	tests.AssertEqual(t, "HTTP/2.0", ti.Protocol)
+	tests.AssertEqual(t, false, ti.IsConnReused)
+	tests.AssertEqual(t, true, infos[1].IsConnReused)
+	tests.AssertNotNil(t, ti.RemoteAddr)
+	tests.AssertNotNil(t, ti.LocalAddr)
+	tests.AssertEqual(t, true, ti.TotalTime > 0)
+
+	data, err := json.Marshal(ti)
+	tests.AssertNoError(t, err)
+	var m map[string]interface{}
+	tests.AssertNoError(t, json.Unmarshal(data, &m))
+	tests.AssertEqual(t, ti.RemoteAddr.String(), m["remote_addr"])
+	tests.AssertEqual(t, "HTTP/2.0", m["protocol"])
+	tests.AssertEqual(t, float64(ti.TotalTime), m["total_time"])
+
+	var decoded TraceInfo
+	tests.AssertNoError(t, json.Unmarshal(data, &decoded))
+	tests.AssertEqual(t, ti.TotalTime, decoded.TotalTime)
+	tests.AssertEqual(t, ti.TLSHandshakeTime, decoded.TLSHandshakeTime)
+	tests.AssertEqual(t, ti.RemoteAddr.String(), decoded.RemoteAddr.String())
+	tests.AssertEqual(t, ti.String(), decoded.String())
+
+	// the nil handler disables the trace enabled by the handler only.
+	c.SetTraceInfoHandler(nil)
+	tests.AssertEqual(t, false, c.trace)
+	c.EnableTraceAll().SetTraceInfoHandler(func(ti TraceInfo) {}).SetTraceInfoHandler(nil)
+	tests.AssertEqual(t, true, c.trace)
+}
+
+func TestEnableHARLog(t *testing.T) {
+	var buf bytes.Buffer
+	c := tc().EnableHARLog(&buf)
+	resp, err := c.R().SetBody("hello").SetQueryParam("a", "1").Post("/echo")
+	assertSuccess(t, resp, err)
+	resp, err = c.R().SetBearerAuthToken("secret").SetCookies(&http.Cookie{Name: "session", Value: "secret"}).Get("/")
+	assertSuccess(t, resp, err)
+	// the clone doesn't record to the HAR log of the client
+	resp, err = c.Clone().R().Get("/")
+	assertSuccess(t, resp, err)
+	c.DisableHARLog()
+
+	var har HAR
+	tests.AssertNoError(t, json.Unmarshal(buf.Bytes(), &har))
+	tests.AssertEqual(t, "1.2", har.Log.Version)
+	tests.AssertEqual(t, 2, len(har.Log.Entries))
+	tests.AssertEqual(t, false, strings.Contains(buf.String(), "secret"))
+	tests.AssertEqual(t, true, strings.Contains(buf.String(), `{"name":"Authorization","value":"REDACTED"}`))
+	tests.AssertEqual(t, []HARCookie{{Name: "session", Value: "REDACTED"}}, har.Log.Entries[1].Request.Cookies)
+	e := har.Log.Entries[0]
+	tests.AssertEqual(t, http.MethodPost, e.Request.Method)
+	tests.AssertEqual(t, true, strings.HasSuffix(e.Request.URL, "/echo?a=1"))
+	tests.AssertEqual(t, []HARNameValue{{Name: "a", Value: "1"}}, e.Request.QueryString)
+	tests.AssertNotNil(t, e.Request.PostData)
+	tests.AssertEqual(t, "hello", e.Request.PostData.Text)
+	tests.AssertEqual(t, "HTTP/2.0", e.Response.HTTPVersion)
+	tests.AssertEqual(t, http.StatusOK, e.Response.Status)
+	tests.AssertEqual(t, header.JsonContentType, e.Response.Content.MimeType)
+	body, err := e.Response.Content.Body()
+	tests.AssertNoError(t, err)
+	var echo Echo
+	tests.AssertNoError(t, json.Unmarshal(body, &echo))
+	tests.AssertEqual(t, "hello", echo.Body)
+	tests.AssertEqual(t, true, e.Timings.Connect > 0)
+	tests.AssertEqual(t, true, e.Timings.SSL > 0)
+	tests.AssertEqual(t, true, e.Time > 0)
+	tests.AssertEqual(t, float64(-1), har.Log.Entries[1].Timings.Connect)
+	tests.AssertEqual(t, "TestGet: text response", func() string {
+		b, _ := har.Log.Entries[1].Response.Content.Body()
+		return string(b)
+	}())
+
+	// the empty HAR is still valid
+	buf.Reset()
+	C().EnableHARLog(&buf).DisableHARLog()
+	har = HAR{}
+	tests.AssertNoError(t, json.Unmarshal(buf.Bytes(), &har))
+	tests.AssertEqual(t, 0, len(har.Log.Entries))
+
+	// the requests in flight when it's disabled don't corrupt the HAR.
+	buf.Reset()
+	l := &harLogger{w: &buf}
+	tests.AssertNoError(t, l.close())
+	tests.AssertNoError(t, l.record(resp.Request, resp))
+	tests.AssertNoError(t, l.close())
+	har = HAR{}
+	tests.AssertNoError(t, json.Unmarshal(buf.Bytes(), &har))
+	tests.AssertEqual(t, 0, len(har.Log.Entries))
+}
+
+func TestRecordReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "replay.har")
+	c := tc().EnableRecordReplay(filename)
+	resp, err := c.R().SetBody("hello").Post("/echo")
+	assertSuccess(t, resp, err)
+	recorded := resp.String()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	c.DisableHARLog()
+
+	// replay without sending the requests
+	c = tc().EnableRecordReplay(filename, &ReplayOptions{MatchBody: true})
+	c.SetDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("should not dial")
+	})
+	resp, err = c.R().SetBody("hello").Post("/echo")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, recorded, resp.String())
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "TestGet: text response", resp.String())
+	_, err = c.R().SetBody("world").Post("/echo")
+	tests.AssertEqual(t, true, errors.Is(err, ErrNoRecordedResponse))
+	_, err = c.R().Get("/not-recorded")
+	tests.AssertEqual(t, true, errors.Is(err, ErrNoRecordedResponse))
+
+	// replay from the dump
+	var buf bytes.Buffer
+	c = tc().EnableForceHTTP1().EnableDumpAllTo(&buf)
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	c = tc().SetReplayFromDump(&buf)
+	c.SetDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("should not dial")
+	})
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "TestGet: text response", resp.String())
+	_, err = c.DisableReplay().R().Get("/")
+	tests.AssertErrorContains(t, err, "should not dial")
+}
+
+func TestEnableRequestCompression(t *testing.T) {
+	var mu sync.Mutex
+	var encodings []string
+	handler := func(acceptGzip bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ce := r.Header.Get("Content-Encoding")
+			mu.Lock()
+			encodings = append(encodings, ce)
+			mu.Unlock()
+			var body io.Reader = r.Body
+			if ce != "" {
+				if !acceptGzip {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				gr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				body = gr
+			}
+			io.Copy(w, body)
+		}
+	}
+	accepting := httptest.NewServer(handler(true))
+	defer accepting.Close()
+	rejecting := httptest.NewServer(handler(false))
+	defer rejecting.Close()
+
+	c := C().EnableRequestCompression(&RequestCompressionOptions{MinSize: 10})
+	large := strings.Repeat("hello", 10)
+	resp, err := c.R().SetBody(large).Post(accepting.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, large, resp.String())
+	// small body is not compressed
+	resp, err = c.R().SetBody("hi").Post(accepting.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, []string{"gzip", ""}, encodings)
+
+	// resent uncompressed once rejected, and not compressed afterwards.
+	encodings = nil
+	resp, err = c.R().SetBody(large).Put(rejecting.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, large, resp.String())
+	resp, err = c.R().SetBody(large).Put(rejecting.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, []string{"gzip", "", ""}, encodings)
+
+	// the non-idempotent request is not resent.
+	c.EnableRequestCompression(&RequestCompressionOptions{MinSize: 10})
+	encodings = nil
+	resp, err = c.R().SetBody(large).Post(rejecting.URL)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	resp, err = c.R().SetBody(large).Post(rejecting.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, []string{"gzip", ""}, encodings)
+
+	// 400 is the rejection only if it has the Accept-Encoding header.
+	badRequest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		mu.Unlock()
+		if r.URL.Path == "/encoding" && r.Header.Get("Content-Encoding") != "" {
+			w.Header().Set("Accept-Encoding", "identity")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("unsupported content encoding: gzip is broken<br>"))
+	}))
+	defer badRequest.Close()
+	c.EnableRequestCompression(&RequestCompressionOptions{MinSize: 10})
+	encodings = nil
+	resp, err = c.R().SetBody(large).Put(badRequest.URL + "/validation")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "unsupported content encoding: gzip is broken<br>", resp.String())
+	resp, err = c.R().SetBody(large).Put(badRequest.URL + "/encoding")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "unsupported content encoding: gzip is broken<br>", resp.String())
+	tests.AssertEqual(t, []string{"gzip", "gzip", ""}, encodings)
+
+	c.SetLogger(nil).EnableRequestCompression(&RequestCompressionOptions{Encoding: "lzma"})
+	tests.AssertNotNil(t, c.requestCompression)
+	c.DisableRequestCompression()
+	tests.AssertIsNil(t, c.requestCompression)
+}
+
+func TestDeprecation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1":
+			w.Header().Set("Deprecation", "@1688169600")
+			w.Header().Set("Sunset", "Wed, 01 Jan 2099 00:00:00 GMT")
+			w.Header().Set("Link", `</docs/migrate>; rel="deprecation"; type="text/html"`)
+		case "/k8s":
+			w.Header().Add("Warning", `299 - "extensions/v1beta1 Ingress is deprecated"`)
+			w.Header().Add("Warning", `110 - "Response is stale"`)
+		}
+	}))
+	defer ts.Close()
+
+	var calls []string
+	c := C().OnDeprecation(func(resp *Response, d *Deprecation) {
+		calls = append(calls, resp.Request.URL.Path)
+	})
+	resp, err := c.R().Get(ts.URL + "/v1")
+	assertSuccess(t, resp, err)
+	d := resp.Deprecation()
+	tests.AssertNotNil(t, d)
+	tests.AssertEqual(t, true, d.Deprecated)
+	tests.AssertEqual(t, int64(1688169600), d.Date.Unix())
+	tests.AssertEqual(t, 2099, d.Sunset.Year())
+	tests.AssertEqual(t, false, d.IsSunset())
+	tests.AssertEqual(t, ts.URL+"/docs/migrate", d.Link)
+
+	resp, err = c.R().Get(ts.URL + "/k8s")
+	assertSuccess(t, resp, err)
+	d = resp.Deprecation()
+	tests.AssertNotNil(t, d)
+	tests.AssertEqual(t, false, d.Deprecated)
+	tests.AssertEqual(t, []string{"extensions/v1beta1 Ingress is deprecated"}, d.Warnings)
+
+	resp, err = c.R().Get(ts.URL + "/v2")
+	assertSuccess(t, resp, err)
+	tests.AssertIsNil(t, resp.Deprecation())
+	tests.AssertEqual(t, []string{"/v1", "/k8s"}, calls)
+
+	// warn once a day per endpoint
+	var buf bytes.Buffer
+	now := time.Now()
+	c = C().SetLogger(NewLogger(&buf, "", 0)).EnableDeprecationWarning().SetNowFunc(func() time.Time { return now })
+	for i := 0; i < 2; i++ {
+		resp, err = c.R().Get(ts.URL + "/v1")
+		assertSuccess(t, resp, err)
+	}
+	tests.AssertEqual(t, 1, strings.Count(buf.String(), "WARN [req]"))
+	tests.AssertEqual(t, true, strings.Contains(buf.String(), "/v1 is deprecated since 2023-07-01T00:00:00Z, sunset at 2099-01-01T00:00:00Z"))
+	now = time.Date(2099, 1, 2, 0, 0, 0, 0, time.UTC)
+	resp, err = c.R().Get(ts.URL + "/v1")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 2, strings.Count(buf.String(), "WARN [req]"))
+	tests.AssertEqual(t, true, resp.Deprecation().IsSunset())
+
+	// the warned endpoints are bounded.
+	n := newDeprecationNotifier(nil)
+	for i := 0; i < maxDeprecationWarnedEndpoints+10; i++ {
+		tests.AssertEqual(t, true, n.shouldWarn(strconv.Itoa(i), now))
+	}
+	tests.AssertEqual(t, maxDeprecationWarnedEndpoints, len(n.warned))
+	tests.AssertEqual(t, true, n.shouldWarn("0", now))
+	tests.AssertEqual(t, false, n.shouldWarn("20", now))
+}
+
+func TestEnableDumpEachRequestOnError(t *testing.T) {
+	c := tc().EnableDumpEachRequestOnError()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", resp.Dump())
+
+	resp, err = c.R().Get("/bad-request")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, true, resp.IsErrorState())
+	tests.AssertContains(t, resp.Dump(), ":path: /bad-request", true)
+	tests.AssertContains(t, resp.Dump(), ":status: 400", true)
+
+	resp, err = tc().R().EnableDumpOnError().Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, resp.Dump(), "too many requests", true)
+}
@@ -5,23 +5,25 @@ import (
 	"crypto/tls"
 	"github.com/imroc/req/v3/http2"
 	utls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/ssh"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 )
 
 // WrapRoundTrip is a global wrapper methods which delegated
 // to the default client's Client.WrapRoundTrip.
 func WrapRoundTrip(wrappers ...RoundTripWrapper) *Client {
-	return defaultClient.WrapRoundTrip(wrappers...)
+	return DefaultClient().WrapRoundTrip(wrappers...)
 }
 
 // WrapRoundTripFunc is a global wrapper methods which delegated
 // to the default client's Client.WrapRoundTripFunc.
 func WrapRoundTripFunc(funcs ...RoundTripWrapperFunc) *Client {
-	return defaultClient.WrapRoundTripFunc(funcs...)
+	return DefaultClient().WrapRoundTripFunc(funcs...)
 }
 
 // SetCommonError is a global wrapper methods which delegated
@@ -29,761 +31,1367 @@ func WrapRoundTripFunc(funcs ...RoundTripWrapperFunc) *Client {
 //
 // Deprecated: Use SetCommonErrorResult instead.
 func SetCommonError(err interface{}) *Client {
-	return defaultClient.SetCommonErrorResult(err)
+	return DefaultClient().SetCommonErrorResult(err)
 }
 
 // SetCommonErrorResult is a global wrapper methods which delegated
 // to the default client's Client.SetCommonError.
 func SetCommonErrorResult(err interface{}) *Client {
-	return defaultClient.SetCommonErrorResult(err)
+	return DefaultClient().SetCommonErrorResult(err)
 }
 
 // SetResultStateCheckFunc is a global wrapper methods which delegated
 // to the default client's Client.SetCommonResultStateCheckFunc.
 func SetResultStateCheckFunc(fn func(resp *Response) ResultState) *Client {
-	return defaultClient.SetResultStateCheckFunc(fn)
+	return DefaultClient().SetResultStateCheckFunc(fn)
 }
 
 // SetCommonFormDataFromValues is a global wrapper methods which delegated
 // to the default client's Client.SetCommonFormDataFromValues.
 func SetCommonFormDataFromValues(data url.Values) *Client {
-	return defaultClient.SetCommonFormDataFromValues(data)
+	return DefaultClient().SetCommonFormDataFromValues(data)
 }
 
 // SetCommonFormData is a global wrapper methods which delegated
 // to the default client's Client.SetCommonFormData.
 func SetCommonFormData(data map[string]string) *Client {
-	return defaultClient.SetCommonFormData(data)
+	return DefaultClient().SetCommonFormData(data)
 }
 
 // SetBaseURL is a global wrapper methods which delegated
 // to the default client's Client.SetBaseURL.
 func SetBaseURL(u string) *Client {
-	return defaultClient.SetBaseURL(u)
+	return DefaultClient().SetBaseURL(u)
 }
 
 // SetOutputDirectory is a global wrapper methods which delegated
 // to the default client's Client.SetOutputDirectory.
 func SetOutputDirectory(dir string) *Client {
-	return defaultClient.SetOutputDirectory(dir)
+	return DefaultClient().SetOutputDirectory(dir)
 }
 
 // SetCertFromFile is a global wrapper methods which delegated
 // to the default client's Client.SetCertFromFile.
 func SetCertFromFile(certFile, keyFile string) *Client {
-	return defaultClient.SetCertFromFile(certFile, keyFile)
+	return DefaultClient().SetCertFromFile(certFile, keyFile)
+}
+
+// SetCertGetter is a global wrapper methods which delegated
+// to the default client's Client.SetCertGetter.
+func SetCertGetter(getter func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) *Client {
+	return DefaultClient().SetCertGetter(getter)
+}
+
+// SetCertFromFileWithReload is a global wrapper methods which delegated
+// to the default client's Client.SetCertFromFileWithReload.
+func SetCertFromFileWithReload(certFile, keyFile string) *Client {
+	return DefaultClient().SetCertFromFileWithReload(certFile, keyFile)
 }
 
 // SetCerts is a global wrapper methods which delegated
 // to the default client's Client.SetCerts.
 func SetCerts(certs ...tls.Certificate) *Client {
-	return defaultClient.SetCerts(certs...)
+	return DefaultClient().SetCerts(certs...)
 }
 
 // SetRootCertFromString is a global wrapper methods which delegated
 // to the default client's Client.SetRootCertFromString.
 func SetRootCertFromString(pemContent string) *Client {
-	return defaultClient.SetRootCertFromString(pemContent)
+	return DefaultClient().SetRootCertFromString(pemContent)
 }
 
 // SetRootCertsFromFile is a global wrapper methods which delegated
 // to the default client's Client.SetRootCertsFromFile.
 func SetRootCertsFromFile(pemFiles ...string) *Client {
-	return defaultClient.SetRootCertsFromFile(pemFiles...)
+	return DefaultClient().SetRootCertsFromFile(pemFiles...)
 }
 
 // GetTLSClientConfig is a global wrapper methods which delegated
 // to the default client's Client.GetTLSClientConfig.
 func GetTLSClientConfig() *tls.Config {
-	return defaultClient.GetTLSClientConfig()
+	return DefaultClient().GetTLSClientConfig()
 }
 
 // SetRedirectPolicy is a global wrapper methods which delegated
 // to the default client's Client.SetRedirectPolicy.
 func SetRedirectPolicy(policies ...RedirectPolicy) *Client {
-	return defaultClient.SetRedirectPolicy(policies...)
+	return DefaultClient().SetRedirectPolicy(policies...)
 }
 
 // DisableKeepAlives is a global wrapper methods which delegated
 // to the default client's Client.DisableKeepAlives.
 func DisableKeepAlives() *Client {
-	return defaultClient.DisableKeepAlives()
+	return DefaultClient().DisableKeepAlives()
 }
 
 // EnableKeepAlives is a global wrapper methods which delegated
 // to the default client's Client.EnableKeepAlives.
 func EnableKeepAlives() *Client {
-	return defaultClient.EnableKeepAlives()
+	return DefaultClient().EnableKeepAlives()
 }
 
 // DisableCompression is a global wrapper methods which delegated
 // to the default client's Client.DisableCompression.
 func DisableCompression() *Client {
-	return defaultClient.DisableCompression()
+	return DefaultClient().DisableCompression()
 }
 
 // EnableCompression is a global wrapper methods which delegated
 // to the default client's Client.EnableCompression.
 func EnableCompression() *Client {
-	return defaultClient.EnableCompression()
+	return DefaultClient().EnableCompression()
 }
 
 // SetTLSClientConfig is a global wrapper methods which delegated
 // to the default client's Client.SetTLSClientConfig.
 func SetTLSClientConfig(conf *tls.Config) *Client {
-	return defaultClient.SetTLSClientConfig(conf)
+	return DefaultClient().SetTLSClientConfig(conf)
 }
 
 // EnableInsecureSkipVerify is a global wrapper methods which delegated
 // to the default client's Client.EnableInsecureSkipVerify.
 func EnableInsecureSkipVerify() *Client {
-	return defaultClient.EnableInsecureSkipVerify()
+	return DefaultClient().EnableInsecureSkipVerify()
 }
 
 // DisableInsecureSkipVerify is a global wrapper methods which delegated
 // to the default client's Client.DisableInsecureSkipVerify.
 func DisableInsecureSkipVerify() *Client {
-	return defaultClient.DisableInsecureSkipVerify()
+	return DefaultClient().DisableInsecureSkipVerify()
 }
 
 // SetCommonQueryParams is a global wrapper methods which delegated
 // to the default client's Client.SetCommonQueryParams.
 func SetCommonQueryParams(params map[string]string) *Client {
-	return defaultClient.SetCommonQueryParams(params)
+	return DefaultClient().SetCommonQueryParams(params)
 }
 
 // AddCommonQueryParam is a global wrapper methods which delegated
 // to the default client's Client.AddCommonQueryParam.
 func AddCommonQueryParam(key, value string) *Client {
-	return defaultClient.AddCommonQueryParam(key, value)
+	return DefaultClient().AddCommonQueryParam(key, value)
 }
 
 // AddCommonQueryParams is a global wrapper methods which delegated
 // to the default client's Client.AddCommonQueryParams.
 func AddCommonQueryParams(key string, values ...string) *Client {
-	return defaultClient.AddCommonQueryParams(key, values...)
+	return DefaultClient().AddCommonQueryParams(key, values...)
 }
 
 // SetCommonPathParam is a global wrapper methods which delegated
 // to the default client's Client.SetCommonPathParam.
 func SetCommonPathParam(key, value string) *Client {
-	return defaultClient.SetCommonPathParam(key, value)
+	return DefaultClient().SetCommonPathParam(key, value)
 }
 
 // SetCommonPathParams is a global wrapper methods which delegated
 // to the default client's Client.SetCommonPathParams.
 func SetCommonPathParams(pathParams map[string]string) *Client {
-	return defaultClient.SetCommonPathParams(pathParams)
+	return DefaultClient().SetCommonPathParams(pathParams)
 }
 
 // SetCommonQueryParam is a global wrapper methods which delegated
 // to the default client's Client.SetCommonQueryParam.
 func SetCommonQueryParam(key, value string) *Client {
-	return defaultClient.SetCommonQueryParam(key, value)
+	return DefaultClient().SetCommonQueryParam(key, value)
+}
+
+// SetCommonQueryParamAny is a global wrapper methods which delegated
+// to the default client's Client.SetCommonQueryParamAny.
+func SetCommonQueryParamAny(key string, value interface{}) *Client {
+	return DefaultClient().SetCommonQueryParamAny(key, value)
+}
+
+// SetParamTimeFormat is a global wrapper methods which delegated
+// to the default client's Client.SetParamTimeFormat.
+func SetParamTimeFormat(layout string) *Client {
+	return DefaultClient().SetParamTimeFormat(layout)
 }
 
 // SetCommonQueryString is a global wrapper methods which delegated
 // to the default client's Client.SetCommonQueryString.
 func SetCommonQueryString(query string) *Client {
-	return defaultClient.SetCommonQueryString(query)
+	return DefaultClient().SetCommonQueryString(query)
 }
 
 // SetCommonCookies is a global wrapper methods which delegated
 // to the default client's Client.SetCommonCookies.
 func SetCommonCookies(cookies ...*http.Cookie) *Client {
-	return defaultClient.SetCommonCookies(cookies...)
+	return DefaultClient().SetCommonCookies(cookies...)
 }
 
 // DisableDebugLog is a global wrapper methods which delegated
 // to the default client's Client.DisableDebugLog.
 func DisableDebugLog() *Client {
-	return defaultClient.DisableDebugLog()
+	return DefaultClient().DisableDebugLog()
 }
 
 // EnableDebugLog is a global wrapper methods which delegated
 // to the default client's Client.EnableDebugLog.
 func EnableDebugLog() *Client {
-	return defaultClient.EnableDebugLog()
+	return DefaultClient().EnableDebugLog()
 }
 
 // DevMode is a global wrapper methods which delegated
 // to the default client's Client.DevMode.
 func DevMode() *Client {
-	return defaultClient.DevMode()
+	return DefaultClient().DevMode()
 }
 
 // SetScheme is a global wrapper methods which delegated
 // to the default client's Client.SetScheme.
 func SetScheme(scheme string) *Client {
-	return defaultClient.SetScheme(scheme)
+	return DefaultClient().SetScheme(scheme)
 }
 
 // SetLogger is a global wrapper methods which delegated
 // to the default client's Client.SetLogger.
 func SetLogger(log Logger) *Client {
-	return defaultClient.SetLogger(log)
+	return DefaultClient().SetLogger(log)
 }
 
 // SetTimeout is a global wrapper methods which delegated
 // to the default client's Client.SetTimeout.
 func SetTimeout(d time.Duration) *Client {
-	return defaultClient.SetTimeout(d)
+	return DefaultClient().SetTimeout(d)
 }
 
 // EnableDumpAll is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAll.
 func EnableDumpAll() *Client {
-	return defaultClient.EnableDumpAll()
+	return DefaultClient().EnableDumpAll()
 }
 
 // EnableDumpAllToFile is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllToFile.
 func EnableDumpAllToFile(filename string) *Client {
-	return defaultClient.EnableDumpAllToFile(filename)
+	return DefaultClient().EnableDumpAllToFile(filename)
 }
 
 // EnableDumpAllTo is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllTo.
 func EnableDumpAllTo(output io.Writer) *Client {
-	return defaultClient.EnableDumpAllTo(output)
+	return DefaultClient().EnableDumpAllTo(output)
 }
 
 // EnableDumpAllAsync is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllAsync.
 func EnableDumpAllAsync() *Client {
-	return defaultClient.EnableDumpAllAsync()
+	return DefaultClient().EnableDumpAllAsync()
 }
 
 // EnableDumpAllWithoutRequestBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutRequestBody.
 func EnableDumpAllWithoutRequestBody() *Client {
-	return defaultClient.EnableDumpAllWithoutRequestBody()
+	return DefaultClient().EnableDumpAllWithoutRequestBody()
 }
 
 // EnableDumpAllWithoutResponseBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutResponseBody.
 func EnableDumpAllWithoutResponseBody() *Client {
-	return defaultClient.EnableDumpAllWithoutResponseBody()
+	return DefaultClient().EnableDumpAllWithoutResponseBody()
 }
 
 // EnableDumpAllWithoutResponse is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutResponse.
 func EnableDumpAllWithoutResponse() *Client {
-	return defaultClient.EnableDumpAllWithoutResponse()
+	return DefaultClient().EnableDumpAllWithoutResponse()
 }
 
 // EnableDumpAllWithoutRequest is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutRequest.
 func EnableDumpAllWithoutRequest() *Client {
-	return defaultClient.EnableDumpAllWithoutRequest()
+	return DefaultClient().EnableDumpAllWithoutRequest()
 }
 
 // EnableDumpAllWithoutHeader is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutHeader.
 func EnableDumpAllWithoutHeader() *Client {
-	return defaultClient.EnableDumpAllWithoutHeader()
+	return DefaultClient().EnableDumpAllWithoutHeader()
 }
 
 // EnableDumpAllWithoutBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpAllWithoutBody.
 func EnableDumpAllWithoutBody() *Client {
-	return defaultClient.EnableDumpAllWithoutBody()
+	return DefaultClient().EnableDumpAllWithoutBody()
 }
 
 // EnableDumpEachRequest is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequest.
 func EnableDumpEachRequest() *Client {
-	return defaultClient.EnableDumpEachRequest()
+	return DefaultClient().EnableDumpEachRequest()
 }
 
 // EnableDumpEachRequestWithoutBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequestWithoutBody.
 func EnableDumpEachRequestWithoutBody() *Client {
-	return defaultClient.EnableDumpEachRequestWithoutBody()
+	return DefaultClient().EnableDumpEachRequestWithoutBody()
 }
 
 // EnableDumpEachRequestWithoutHeader is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequestWithoutHeader.
 func EnableDumpEachRequestWithoutHeader() *Client {
-	return defaultClient.EnableDumpEachRequestWithoutHeader()
+	return DefaultClient().EnableDumpEachRequestWithoutHeader()
 }
 
 // EnableDumpEachRequestWithoutResponse is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequestWithoutResponse.
 func EnableDumpEachRequestWithoutResponse() *Client {
-	return defaultClient.EnableDumpEachRequestWithoutResponse()
+	return DefaultClient().EnableDumpEachRequestWithoutResponse()
 }
 
 // EnableDumpEachRequestWithoutRequest is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequestWithoutRequest.
 func EnableDumpEachRequestWithoutRequest() *Client {
-	return defaultClient.EnableDumpEachRequestWithoutRequest()
+	return DefaultClient().EnableDumpEachRequestWithoutRequest()
 }
 
 // EnableDumpEachRequestWithoutResponseBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequestWithoutResponseBody.
 func EnableDumpEachRequestWithoutResponseBody() *Client {
-	return defaultClient.EnableDumpEachRequestWithoutResponseBody()
+	return DefaultClient().EnableDumpEachRequestWithoutResponseBody()
 }
 
 // EnableDumpEachRequestWithoutRequestBody is a global wrapper methods which delegated
 // to the default client's Client.EnableDumpEachRequestWithoutRequestBody.
 func EnableDumpEachRequestWithoutRequestBody() *Client {
-	return defaultClient.EnableDumpEachRequestWithoutRequestBody()
+	return DefaultClient().EnableDumpEachRequestWithoutRequestBody()
+}
+
+// EnableDumpEachRequestOnError is a global wrapper methods which delegated
+// to the default client's Client.EnableDumpEachRequestOnError.
+func EnableDumpEachRequestOnError() *Client {
+	return DefaultClient().EnableDumpEachRequestOnError()
+}
+
+// SetInsecureSkipVerify is a global wrapper methods which delegated
+// to the default client's Client.SetInsecureSkipVerify.
+func SetInsecureSkipVerify(skip bool) *Client {
+	return DefaultClient().SetInsecureSkipVerify(skip)
+}
+
+// SetTLSMinVersion is a global wrapper methods which delegated
+// to the default client's Client.SetTLSMinVersion.
+func SetTLSMinVersion(version uint16) *Client {
+	return DefaultClient().SetTLSMinVersion(version)
+}
+
+// SetTLSMaxVersion is a global wrapper methods which delegated
+// to the default client's Client.SetTLSMaxVersion.
+func SetTLSMaxVersion(version uint16) *Client {
+	return DefaultClient().SetTLSMaxVersion(version)
+}
+
+// SetCipherSuites is a global wrapper methods which delegated
+// to the default client's Client.SetCipherSuites.
+func SetCipherSuites(suites ...uint16) *Client {
+	return DefaultClient().SetCipherSuites(suites...)
+}
+
+// OnConnectionOpen is a global wrapper methods which delegated
+// to the default client's Client.OnConnectionOpen.
+func OnConnectionOpen(fn func(info ConnectionInfo)) *Client {
+	return DefaultClient().OnConnectionOpen(fn)
+}
+
+// OnConnectionClose is a global wrapper methods which delegated
+// to the default client's Client.OnConnectionClose.
+func OnConnectionClose(fn func(info ConnectionInfo)) *Client {
+	return DefaultClient().OnConnectionClose(fn)
+}
+
+// DisableBOMStripping is a global wrapper methods which delegated
+// to the default client's Client.DisableBOMStripping.
+func DisableBOMStripping() *Client {
+	return DefaultClient().DisableBOMStripping()
+}
+
+// EnableBOMStripping is a global wrapper methods which delegated
+// to the default client's Client.EnableBOMStripping.
+func EnableBOMStripping() *Client {
+	return DefaultClient().EnableBOMStripping()
 }
 
 // DisableAutoReadResponse is a global wrapper methods which delegated
 // to the default client's Client.DisableAutoReadResponse.
 func DisableAutoReadResponse() *Client {
-	return defaultClient.DisableAutoReadResponse()
+	return DefaultClient().DisableAutoReadResponse()
 }
 
 // EnableAutoReadResponse is a global wrapper methods which delegated
 // to the default client's Client.EnableAutoReadResponse.
 func EnableAutoReadResponse() *Client {
-	return defaultClient.EnableAutoReadResponse()
+	return DefaultClient().EnableAutoReadResponse()
 }
 
 // SetAutoDecodeContentType is a global wrapper methods which delegated
 // to the default client's Client.SetAutoDecodeContentType.
 func SetAutoDecodeContentType(contentTypes ...string) *Client {
-	return defaultClient.SetAutoDecodeContentType(contentTypes...)
+	return DefaultClient().SetAutoDecodeContentType(contentTypes...)
 }
 
 // SetAutoDecodeContentTypeFunc is a global wrapper methods which delegated
 // to the default client's Client.SetAutoDecodeAllTypeFunc.
 func SetAutoDecodeContentTypeFunc(fn func(contentType string) bool) *Client {
-	return defaultClient.SetAutoDecodeContentTypeFunc(fn)
+	return DefaultClient().SetAutoDecodeContentTypeFunc(fn)
 }
 
 // SetAutoDecodeAllContentType is a global wrapper methods which delegated
 // to the default client's Client.SetAutoDecodeAllContentType.
 func SetAutoDecodeAllContentType() *Client {
-	return defaultClient.SetAutoDecodeAllContentType()
+	return DefaultClient().SetAutoDecodeAllContentType()
 }
 
 // DisableAutoDecode is a global wrapper methods which delegated
 // to the default client's Client.DisableAutoDecode.
 func DisableAutoDecode() *Client {
-	return defaultClient.DisableAutoDecode()
+	return DefaultClient().DisableAutoDecode()
 }
 
 // EnableAutoDecode is a global wrapper methods which delegated
 // to the default client's Client.EnableAutoDecode.
 func EnableAutoDecode() *Client {
-	return defaultClient.EnableAutoDecode()
+	return DefaultClient().EnableAutoDecode()
+}
+
+// SetLocale is a global wrapper methods which delegated
+// to the default client's Client.SetLocale.
+func SetLocale(tags ...string) *Client {
+	return DefaultClient().SetLocale(tags...)
+}
+
+// SetLocaleHook is a global wrapper methods which delegated
+// to the default client's Client.SetLocaleHook.
+func SetLocaleHook(hook LocaleHookFunc) *Client {
+	return DefaultClient().SetLocaleHook(hook)
 }
 
 // SetUserAgent is a global wrapper methods which delegated
 // to the default client's Client.SetUserAgent.
 func SetUserAgent(userAgent string) *Client {
-	return defaultClient.SetUserAgent(userAgent)
+	return DefaultClient().SetUserAgent(userAgent)
 }
 
 // SetCommonBearerAuthToken is a global wrapper methods which delegated
 // to the default client's Client.SetCommonBearerAuthToken.
 func SetCommonBearerAuthToken(token string) *Client {
-	return defaultClient.SetCommonBearerAuthToken(token)
+	return DefaultClient().SetCommonBearerAuthToken(token)
 }
 
 // SetCommonBasicAuth is a global wrapper methods which delegated
 // to the default client's Client.SetCommonBasicAuth.
 func SetCommonBasicAuth(username, password string) *Client {
-	return defaultClient.SetCommonBasicAuth(username, password)
+	return DefaultClient().SetCommonBasicAuth(username, password)
+}
+
+// SetCommonTokenProvider is a global wrapper methods which delegated
+// to the default client's Client.SetCommonTokenProvider.
+func SetCommonTokenProvider(provider TokenProvider) *Client {
+	return DefaultClient().SetCommonTokenProvider(provider)
+}
+
+// SetCommonAwsSigV4 is a global wrapper methods which delegated
+// to the default client's Client.SetCommonAwsSigV4.
+func SetCommonAwsSigV4(accessKey, secretKey, region, service string) *Client {
+	return DefaultClient().SetCommonAwsSigV4(accessKey, secretKey, region, service)
 }
 
 // SetCommonDigestAuth is a global wrapper methods which delegated
 // to the default client's Client.SetCommonDigestAuth.
 func SetCommonDigestAuth(username, password string) *Client {
-	return defaultClient.SetCommonDigestAuth(username, password)
+	return DefaultClient().SetCommonDigestAuth(username, password)
+}
+
+// EnableNetrc is a global wrapper methods which delegated
+// to the default client's Client.EnableNetrc.
+func EnableNetrc(path ...string) *Client {
+	return DefaultClient().EnableNetrc(path...)
+}
+
+// DisableNetrc is a global wrapper methods which delegated
+// to the default client's Client.DisableNetrc.
+func DisableNetrc() *Client {
+	return DefaultClient().DisableNetrc()
+}
+
+// SetCommonNtlmAuth is a global wrapper methods which delegated
+// to the default client's Client.SetCommonNtlmAuth.
+func SetCommonNtlmAuth(username, password string) *Client {
+	return DefaultClient().SetCommonNtlmAuth(username, password)
+}
+
+// SetCommonSpnegoAuth is a global wrapper methods which delegated
+// to the default client's Client.SetCommonSpnegoAuth.
+func SetCommonSpnegoAuth(provider SpnegoProvider) *Client {
+	return DefaultClient().SetCommonSpnegoAuth(provider)
 }
 
 // SetCommonHeaders is a global wrapper methods which delegated
 // to the default client's Client.SetCommonHeaders.
 func SetCommonHeaders(hdrs map[string]string) *Client {
-	return defaultClient.SetCommonHeaders(hdrs)
+	return DefaultClient().SetCommonHeaders(hdrs)
 }
 
 // SetCommonHeader is a global wrapper methods which delegated
 // to the default client's Client.SetCommonHeader.
 func SetCommonHeader(key, value string) *Client {
-	return defaultClient.SetCommonHeader(key, value)
+	return DefaultClient().SetCommonHeader(key, value)
 }
 
 // SetCommonHeaderOrder is a global wrapper methods which delegated
 // to the default client's Client.SetCommonHeaderOrder.
 func SetCommonHeaderOrder(keys ...string) *Client {
-	return defaultClient.SetCommonHeaderOrder(keys...)
+	return DefaultClient().SetCommonHeaderOrder(keys...)
 }
 
 // SetCommonPseudoHeaderOder is a global wrapper methods which delegated
 // to the default client's Client.SetCommonPseudoHeaderOder.
 func SetCommonPseudoHeaderOder(keys ...string) *Client {
-	return defaultClient.SetCommonPseudoHeaderOder(keys...)
+	return DefaultClient().SetCommonPseudoHeaderOder(keys...)
 }
 
 // SetHTTP2SettingsFrame is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2SettingsFrame.
 func SetHTTP2SettingsFrame(settings ...http2.Setting) *Client {
-	return defaultClient.SetHTTP2SettingsFrame(settings...)
+	return DefaultClient().SetHTTP2SettingsFrame(settings...)
 }
 
 // SetHTTP2ConnectionFlow is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2ConnectionFlow.
 func SetHTTP2ConnectionFlow(flow uint32) *Client {
-	return defaultClient.SetHTTP2ConnectionFlow(flow)
+	return DefaultClient().SetHTTP2ConnectionFlow(flow)
 }
 
 // SetHTTP2HeaderPriority is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2HeaderPriority.
 func SetHTTP2HeaderPriority(priority http2.PriorityParam) *Client {
-	return defaultClient.SetHTTP2HeaderPriority(priority)
+	return DefaultClient().SetHTTP2HeaderPriority(priority)
 }
 
 // SetHTTP2PriorityFrames is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2PriorityFrames.
 func SetHTTP2PriorityFrames(frames ...http2.PriorityFrame) *Client {
-	return defaultClient.SetHTTP2PriorityFrames(frames...)
+	return DefaultClient().SetHTTP2PriorityFrames(frames...)
 }
 
 // SetHTTP2MaxHeaderListSize is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2MaxHeaderListSize.
 func SetHTTP2MaxHeaderListSize(max uint32) *Client {
-	return defaultClient.SetHTTP2MaxHeaderListSize(max)
+	return DefaultClient().SetHTTP2MaxHeaderListSize(max)
 }
 
 // SetHTTP2StrictMaxConcurrentStreams is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2StrictMaxConcurrentStreams.
 func SetHTTP2StrictMaxConcurrentStreams(strict bool) *Client {
-	return defaultClient.SetHTTP2StrictMaxConcurrentStreams(strict)
+	return DefaultClient().SetHTTP2StrictMaxConcurrentStreams(strict)
+}
+
+// SetHTTP2MaxConcurrentStreams is a global wrapper methods which delegated
+// to the default client's Client.SetHTTP2MaxConcurrentStreams.
+func SetHTTP2MaxConcurrentStreams(max uint32) *Client {
+	return DefaultClient().SetHTTP2MaxConcurrentStreams(max)
+}
+
+// SetHTTP2InitialWindowSize is a global wrapper methods which delegated
+// to the default client's Client.SetHTTP2InitialWindowSize.
+func SetHTTP2InitialWindowSize(size uint32) *Client {
+	return DefaultClient().SetHTTP2InitialWindowSize(size)
 }
 
 // SetHTTP2ReadIdleTimeout is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2ReadIdleTimeout.
 func SetHTTP2ReadIdleTimeout(timeout time.Duration) *Client {
-	return defaultClient.SetHTTP2ReadIdleTimeout(timeout)
+	return DefaultClient().SetHTTP2ReadIdleTimeout(timeout)
 }
 
 // SetHTTP2PingTimeout is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2PingTimeout.
 func SetHTTP2PingTimeout(timeout time.Duration) *Client {
-	return defaultClient.SetHTTP2PingTimeout(timeout)
+	return DefaultClient().SetHTTP2PingTimeout(timeout)
 }
 
 // SetHTTP2WriteByteTimeout is a global wrapper methods which delegated
 // to the default client's Client.SetHTTP2WriteByteTimeout.
 func SetHTTP2WriteByteTimeout(timeout time.Duration) *Client {
-	return defaultClient.SetHTTP2WriteByteTimeout(timeout)
+	return DefaultClient().SetHTTP2WriteByteTimeout(timeout)
 }
 
 // ImpersonateChrome is a global wrapper methods which delegated
 // to the default client's Client.ImpersonateChrome.
 func ImpersonateChrome() *Client {
-	return defaultClient.ImpersonateChrome()
+	return DefaultClient().ImpersonateChrome()
 }
 
 // SetCommonContentType is a global wrapper methods which delegated
 // to the default client's Client.SetCommonContentType.
 func SetCommonContentType(ct string) *Client {
-	return defaultClient.SetCommonContentType(ct)
+	return DefaultClient().SetCommonContentType(ct)
 }
 
 // DisableDumpAll is a global wrapper methods which delegated
 // to the default client's Client.DisableDumpAll.
 func DisableDumpAll() *Client {
-	return defaultClient.DisableDumpAll()
+	return DefaultClient().DisableDumpAll()
+}
+
+// EnableAuditLog is a global wrapper methods which delegated
+// to the default client's Client.EnableAuditLog.
+func EnableAuditLog(opt *AuditOptions) *Client {
+	return DefaultClient().EnableAuditLog(opt)
+}
+
+// DisableAuditLog is a global wrapper methods which delegated
+// to the default client's Client.DisableAuditLog.
+func DisableAuditLog() *Client {
+	return DefaultClient().DisableAuditLog()
+}
+
+// EnableIDNStrictMode is a global wrapper methods which delegated
+// to the default client's Client.EnableIDNStrictMode.
+func EnableIDNStrictMode() *Client {
+	return DefaultClient().EnableIDNStrictMode()
+}
+
+// DisableIDNStrictMode is a global wrapper methods which delegated
+// to the default client's Client.DisableIDNStrictMode.
+func DisableIDNStrictMode() *Client {
+	return DefaultClient().DisableIDNStrictMode()
+}
+
+// SetRequestAdapter is a global wrapper methods which delegated
+// to the default client's Client.SetRequestAdapter.
+func SetRequestAdapter(adapter RequestAdapter) *Client {
+	return DefaultClient().SetRequestAdapter(adapter)
+}
+
+// EnableContentTypeSniffing is a global wrapper methods which delegated
+// to the default client's Client.EnableContentTypeSniffing.
+func EnableContentTypeSniffing() *Client {
+	return DefaultClient().EnableContentTypeSniffing()
+}
+
+// DisableContentTypeSniffing is a global wrapper methods which delegated
+// to the default client's Client.DisableContentTypeSniffing.
+func DisableContentTypeSniffing() *Client {
+	return DefaultClient().DisableContentTypeSniffing()
+}
+
+// DetectInterception is a global wrapper methods which delegated
+// to the default client's Client.DetectInterception.
+func DetectInterception(url string, opts ...*InterceptionProbeOptions) (*InterceptionResult, error) {
+	return DefaultClient().DetectInterception(url, opts...)
+}
+
+// RegisterStreamDecoder is a global wrapper methods which delegated
+// to the default client's Client.RegisterStreamDecoder.
+func RegisterStreamDecoder(contentType string, decoder StreamDecoder) *Client {
+	return DefaultClient().RegisterStreamDecoder(contentType, decoder)
+}
+
+// SetNowFunc is a global wrapper methods which delegated
+// to the default client's Client.SetNowFunc.
+func SetNowFunc(now func() time.Time) *Client {
+	return DefaultClient().SetNowFunc(now)
+}
+
+// NewBulkFetcher is a global wrapper methods which delegated
+// to the default client's Client.NewBulkFetcher.
+func NewBulkFetcher(url string, opts ...*BulkFetchOptions) *BulkFetcher {
+	return DefaultClient().NewBulkFetcher(url, opts...)
+}
+
+// EnableDnsCache is a global wrapper methods which delegated
+// to the default client's Client.EnableDnsCache.
+func EnableDnsCache(ttl time.Duration, maxEntries int) *Client {
+	return DefaultClient().EnableDnsCache(ttl, maxEntries)
+}
+
+// DisableDnsCache is a global wrapper methods which delegated
+// to the default client's Client.DisableDnsCache.
+func DisableDnsCache() *Client {
+	return DefaultClient().DisableDnsCache()
+}
+
+// ReverseProxy is a global wrapper methods which delegated
+// to the default client's Client.ReverseProxy.
+func ReverseProxy(opts ...*ReverseProxyOptions) http.Handler {
+	return DefaultClient().ReverseProxy(opts...)
+}
+
+// SetResolver is a global wrapper methods which delegated
+// to the default client's Client.SetResolver.
+func SetResolver(resolver Resolver) *Client {
+	return DefaultClient().SetResolver(resolver)
+}
+
+// SetHostMapping is a global wrapper methods which delegated
+// to the default client's Client.SetHostMapping.
+func SetHostMapping(mapping map[string]string) *Client {
+	return DefaultClient().SetHostMapping(mapping)
+}
+
+// SetDialFallbackDelay is a global wrapper methods which delegated
+// to the default client's Client.SetDialFallbackDelay.
+func SetDialFallbackDelay(delay time.Duration) *Client {
+	return DefaultClient().SetDialFallbackDelay(delay)
+}
+
+// SetIPPreference is a global wrapper methods which delegated
+// to the default client's Client.SetIPPreference.
+func SetIPPreference(preference IPPreference) *Client {
+	return DefaultClient().SetIPPreference(preference)
+}
+
+// HealthCheck is a global wrapper methods which delegated
+// to the default client's Client.HealthCheck.
+func HealthCheck(url string, interval time.Duration, callback func(status HealthStatus), opts ...*HealthCheckOptions) *HealthChecker {
+	return DefaultClient().HealthCheck(url, interval, callback, opts...)
+}
+
+// EnableTransportStats is a global wrapper methods which delegated
+// to the default client's Client.EnableTransportStats.
+func EnableTransportStats() *Client {
+	return DefaultClient().EnableTransportStats()
+}
+
+// OnTransportStats is a global wrapper methods which delegated
+// to the default client's Client.OnTransportStats.
+func OnTransportStats(interval time.Duration, fn func(stats TransportStats)) *Client {
+	return DefaultClient().OnTransportStats(interval, fn)
+}
+
+// RandomizeFingerprint is a global wrapper methods which delegated
+// to the default client's Client.RandomizeFingerprint.
+func RandomizeFingerprint(opts ...*FingerprintRandomizationOptions) *Client {
+	return DefaultClient().RandomizeFingerprint(opts...)
+}
+
+// ReplayDump is a global wrapper methods which delegated
+// to the default client's Client.ReplayDump.
+func ReplayDump(reader io.Reader, opts ...*ReplayDumpOptions) ([]*Response, error) {
+	return DefaultClient().ReplayDump(reader, opts...)
+}
+
+// SetAutoReadPolicy is a global wrapper methods which delegated
+// to the default client's Client.SetAutoReadPolicy.
+func SetAutoReadPolicy(policy *AutoReadPolicy) *Client {
+	return DefaultClient().SetAutoReadPolicy(policy)
+}
+
+// SetTraceInfoHandler is a global wrapper methods which delegated
+// to the default client's Client.SetTraceInfoHandler.
+func SetTraceInfoHandler(handler func(TraceInfo)) *Client {
+	return DefaultClient().SetTraceInfoHandler(handler)
+}
+
+// SetCommonPathEscapeOptions is a global wrapper methods which delegated
+// to the default client's Client.SetCommonPathEscapeOptions.
+func SetCommonPathEscapeOptions(opts *PathEscapeOptions) *Client {
+	return DefaultClient().SetCommonPathEscapeOptions(opts)
+}
+
+// EnableHARLog is a global wrapper methods which delegated
+// to the default client's Client.EnableHARLog.
+func EnableHARLog(w io.Writer) *Client {
+	return DefaultClient().EnableHARLog(w)
+}
+
+// DisableHARLog is a global wrapper methods which delegated
+// to the default client's Client.DisableHARLog.
+func DisableHARLog() *Client {
+	return DefaultClient().DisableHARLog()
+}
+
+// SetOutputFilenameMapper is a global wrapper methods which delegated
+// to the default client's Client.SetOutputFilenameMapper.
+func SetOutputFilenameMapper(mapper func(r *Request, filename string) string) *Client {
+	return DefaultClient().SetOutputFilenameMapper(mapper)
+}
+
+// SetReplayFromHAR is a global wrapper methods which delegated
+// to the default client's Client.SetReplayFromHAR.
+func SetReplayFromHAR(r io.Reader, opts ...*ReplayOptions) *Client {
+	return DefaultClient().SetReplayFromHAR(r, opts...)
+}
+
+// SetReplayFromDump is a global wrapper methods which delegated
+// to the default client's Client.SetReplayFromDump.
+func SetReplayFromDump(r io.Reader, opts ...*ReplayOptions) *Client {
+	return DefaultClient().SetReplayFromDump(r, opts...)
+}
+
+// EnableRecordReplay is a global wrapper methods which delegated
+// to the default client's Client.EnableRecordReplay.
+func EnableRecordReplay(filename string, opts ...*ReplayOptions) *Client {
+	return DefaultClient().EnableRecordReplay(filename, opts...)
+}
+
+// DisableReplay is a global wrapper methods which delegated
+// to the default client's Client.DisableReplay.
+func DisableReplay() *Client {
+	return DefaultClient().DisableReplay()
+}
+
+// EnableMock is a global wrapper methods which delegated
+// to the default client's Client.EnableMock.
+func EnableMock(mock http.RoundTripper) *Client {
+	return DefaultClient().EnableMock(mock)
+}
+
+// DisableMock is a global wrapper methods which delegated
+// to the default client's Client.DisableMock.
+func DisableMock() *Client {
+	return DefaultClient().DisableMock()
+}
+
+// EnableRequestCompression is a global wrapper methods which delegated
+// to the default client's Client.EnableRequestCompression.
+func EnableRequestCompression(opts ...*RequestCompressionOptions) *Client {
+	return DefaultClient().EnableRequestCompression(opts...)
+}
+
+// DisableRequestCompression is a global wrapper methods which delegated
+// to the default client's Client.DisableRequestCompression.
+func DisableRequestCompression() *Client {
+	return DefaultClient().DisableRequestCompression()
+}
+
+// OnDeprecation is a global wrapper methods which delegated
+// to the default client's Client.OnDeprecation.
+func OnDeprecation(callback func(resp *Response, d *Deprecation)) *Client {
+	return DefaultClient().OnDeprecation(callback)
+}
+
+// EnableDeprecationWarning is a global wrapper methods which delegated
+// to the default client's Client.EnableDeprecationWarning.
+func EnableDeprecationWarning() *Client {
+	return DefaultClient().EnableDeprecationWarning()
+}
+
+// DisableDeprecationWarning is a global wrapper methods which delegated
+// to the default client's Client.DisableDeprecationWarning.
+func DisableDeprecationWarning() *Client {
+	return DefaultClient().DisableDeprecationWarning()
+}
+
+// SetDNSLookupTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetDNSLookupTimeout.
+func SetDNSLookupTimeout(timeout time.Duration) *Client {
+	return DefaultClient().SetDNSLookupTimeout(timeout)
+}
+
+// FlushDump is a global wrapper methods which delegated
+// to the default client's Client.FlushDump.
+func FlushDump() *Client {
+	return DefaultClient().FlushDump()
+}
+
+// EnableDumpAllToFileWithRotation is a global wrapper methods which delegated
+// to the default client's Client.EnableDumpAllToFileWithRotation.
+func EnableDumpAllToFileWithRotation(filename string, maxSize int64, maxBackups int) *Client {
+	return DefaultClient().EnableDumpAllToFileWithRotation(filename, maxSize, maxBackups)
+}
+
+// RotateDump is a global wrapper methods which delegated
+// to the default client's Client.RotateDump.
+func RotateDump() *Client {
+	return DefaultClient().RotateDump()
+}
+
+// ReopenDump is a global wrapper methods which delegated
+// to the default client's Client.ReopenDump.
+func ReopenDump() *Client {
+	return DefaultClient().ReopenDump()
+}
+
+// ReopenDumpOnSignal is a global wrapper methods which delegated
+// to the default client's Client.ReopenDumpOnSignal.
+func ReopenDumpOnSignal(sigs ...os.Signal) *Client {
+	return DefaultClient().ReopenDumpOnSignal(sigs...)
+}
+
+// CloseDump is a global wrapper methods which delegated
+// to the default client's Client.CloseDump.
+func CloseDump() *Client {
+	return DefaultClient().CloseDump()
+}
+
+// SetRequestHeaderLimits is a global wrapper methods which delegated
+// to the default client's Client.SetRequestHeaderLimits.
+func SetRequestHeaderLimits(maxBytes, maxCount int) *Client {
+	return DefaultClient().SetRequestHeaderLimits(maxBytes, maxCount)
+}
+
+// SetProxyPool is a global wrapper methods which delegated
+// to the default client's Client.SetProxyPool.
+func SetProxyPool(proxyURLs []string, strategy ProxyPoolStrategy, opts ...*ProxyPoolOptions) *Client {
+	return DefaultClient().SetProxyPool(proxyURLs, strategy, opts...)
+}
+
+// SetProxyPAC is a global wrapper methods which delegated
+// to the default client's Client.SetProxyPAC.
+func SetProxyPAC(urlOrPath string) *Client {
+	return DefaultClient().SetProxyPAC(urlOrPath)
+}
+
+// EnableNegativeCache is a global wrapper methods which delegated
+// to the default client's Client.EnableNegativeCache.
+func EnableNegativeCache(ttls map[int]time.Duration) *Client {
+	return DefaultClient().EnableNegativeCache(ttls)
+}
+
+// EnableAdaptiveConcurrency is a global wrapper methods which delegated
+// to the default client's Client.EnableAdaptiveConcurrency.
+func EnableAdaptiveConcurrency(opts ...*AdaptiveConcurrencyOptions) *Client {
+	return DefaultClient().EnableAdaptiveConcurrency(opts...)
+}
+
+// DisableAdaptiveConcurrency is a global wrapper methods which delegated
+// to the default client's Client.DisableAdaptiveConcurrency.
+func DisableAdaptiveConcurrency() *Client {
+	return DefaultClient().DisableAdaptiveConcurrency()
+}
+
+// SetSLA is a global wrapper methods which delegated
+// to the default client's Client.SetSLA.
+func SetSLA(host string, p99Target time.Duration, opts ...*SLAOptions) *Client {
+	return DefaultClient().SetSLA(host, p99Target, opts...)
+}
+
+// RemoveSLA is a global wrapper methods which delegated
+// to the default client's Client.RemoveSLA.
+func RemoveSLA(host string) *Client {
+	return DefaultClient().RemoveSLA(host)
+}
+
+// DisableNegativeCache is a global wrapper methods which delegated
+// to the default client's Client.DisableNegativeCache.
+func DisableNegativeCache() *Client {
+	return DefaultClient().DisableNegativeCache()
+}
+
+// SetSecureMode is a global wrapper methods which delegated
+// to the default client's Client.SetSecureMode.
+func SetSecureMode(mode SecureMode) *Client {
+	return DefaultClient().SetSecureMode(mode)
 }
 
 // SetCommonDumpOptions is a global wrapper methods which delegated
 // to the default client's Client.SetCommonDumpOptions.
 func SetCommonDumpOptions(opt *DumpOptions) *Client {
-	return defaultClient.SetCommonDumpOptions(opt)
+	return DefaultClient().SetCommonDumpOptions(opt)
 }
 
 // SetProxy is a global wrapper methods which delegated
 // to the default client's Client.SetProxy.
 func SetProxy(proxy func(*http.Request) (*url.URL, error)) *Client {
-	return defaultClient.SetProxy(proxy)
+	return DefaultClient().SetProxy(proxy)
 }
 
 // OnBeforeRequest is a global wrapper methods which delegated
 // to the default client's Client.OnBeforeRequest.
 func OnBeforeRequest(m RequestMiddleware) *Client {
-	return defaultClient.OnBeforeRequest(m)
+	return DefaultClient().OnBeforeRequest(m)
 }
 
 // OnAfterResponse is a global wrapper methods which delegated
 // to the default client's Client.OnAfterResponse.
 func OnAfterResponse(m ResponseMiddleware) *Client {
-	return defaultClient.OnAfterResponse(m)
+	return DefaultClient().OnAfterResponse(m)
 }
 
 // SetProxyURL is a global wrapper methods which delegated
 // to the default client's Client.SetProxyURL.
 func SetProxyURL(proxyUrl string) *Client {
-	return defaultClient.SetProxyURL(proxyUrl)
+	return DefaultClient().SetProxyURL(proxyUrl)
+}
+
+// SetProxyBasicAuth is a global wrapper methods which delegated
+// to the default client's Client.SetProxyBasicAuth.
+func SetProxyBasicAuth(username, password string) *Client {
+	return DefaultClient().SetProxyBasicAuth(username, password)
+}
+
+// EnableProxyFromEnvRefresh is a global wrapper methods which delegated
+// to the default client's Client.EnableProxyFromEnvRefresh.
+func EnableProxyFromEnvRefresh(interval time.Duration) *Client {
+	return DefaultClient().EnableProxyFromEnvRefresh(interval)
+}
+
+// SetSchemeProxyURLs is a global wrapper methods which delegated
+// to the default client's Client.SetSchemeProxyURLs.
+func SetSchemeProxyURLs(httpProxyURL, httpsProxyURL string) *Client {
+	return DefaultClient().SetSchemeProxyURLs(httpProxyURL, httpsProxyURL)
 }
 
 // DisableTraceAll is a global wrapper methods which delegated
 // to the default client's Client.DisableTraceAll.
 func DisableTraceAll() *Client {
-	return defaultClient.DisableTraceAll()
+	return DefaultClient().DisableTraceAll()
 }
 
 // EnableTraceAll is a global wrapper methods which delegated
 // to the default client's Client.EnableTraceAll.
 func EnableTraceAll() *Client {
-	return defaultClient.EnableTraceAll()
+	return DefaultClient().EnableTraceAll()
 }
 
 // SetCookieJar is a global wrapper methods which delegated
 // to the default client's Client.SetCookieJar.
 func SetCookieJar(jar http.CookieJar) *Client {
-	return defaultClient.SetCookieJar(jar)
+	return DefaultClient().SetCookieJar(jar)
 }
 
 // GetCookies is a global wrapper methods which delegated
 // to the default client's Client.GetCookies.
 func GetCookies(url string) ([]*http.Cookie, error) {
-	return defaultClient.GetCookies(url)
+	return DefaultClient().GetCookies(url)
 }
 
 // ClearCookies is a global wrapper methods which delegated
 // to the default client's Client.ClearCookies.
 func ClearCookies() *Client {
-	return defaultClient.ClearCookies()
+	return DefaultClient().ClearCookies()
 }
 
 // SetJsonMarshal is a global wrapper methods which delegated
 // to the default client's Client.SetJsonMarshal.
 func SetJsonMarshal(fn func(v interface{}) ([]byte, error)) *Client {
-	return defaultClient.SetJsonMarshal(fn)
+	return DefaultClient().SetJsonMarshal(fn)
 }
 
 // SetJsonUnmarshal is a global wrapper methods which delegated
 // to the default client's Client.SetJsonUnmarshal.
 func SetJsonUnmarshal(fn func(data []byte, v interface{}) error) *Client {
-	return defaultClient.SetJsonUnmarshal(fn)
+	return DefaultClient().SetJsonUnmarshal(fn)
 }
 
 // SetXmlMarshal is a global wrapper methods which delegated
 // to the default client's Client.SetXmlMarshal.
 func SetXmlMarshal(fn func(v interface{}) ([]byte, error)) *Client {
-	return defaultClient.SetXmlMarshal(fn)
+	return DefaultClient().SetXmlMarshal(fn)
 }
 
 // SetXmlUnmarshal is a global wrapper methods which delegated
 // to the default client's Client.SetXmlUnmarshal.
 func SetXmlUnmarshal(fn func(data []byte, v interface{}) error) *Client {
-	return defaultClient.SetXmlUnmarshal(fn)
+	return DefaultClient().SetXmlUnmarshal(fn)
 }
 
 // SetDialTLS is a global wrapper methods which delegated
 // to the default client's Client.SetDialTLS.
 func SetDialTLS(fn func(ctx context.Context, network, addr string) (net.Conn, error)) *Client {
-	return defaultClient.SetDialTLS(fn)
+	return DefaultClient().SetDialTLS(fn)
 }
 
 // SetDial is a global wrapper methods which delegated
 // to the default client's Client.SetDial.
 func SetDial(fn func(ctx context.Context, network, addr string) (net.Conn, error)) *Client {
-	return defaultClient.SetDial(fn)
+	return DefaultClient().SetDial(fn)
+}
+
+// SetSSHTunnel is a global wrapper methods which delegated
+// to the default client's Client.SetSSHTunnel.
+func SetSSHTunnel(user, host string, auth ...ssh.AuthMethod) *Client {
+	return DefaultClient().SetSSHTunnel(user, host, auth...)
+}
+
+// SetSSHTunnelConfig is a global wrapper methods which delegated
+// to the default client's Client.SetSSHTunnelConfig.
+func SetSSHTunnelConfig(host string, config *ssh.ClientConfig) *Client {
+	return DefaultClient().SetSSHTunnelConfig(host, config)
+}
+
+// SetTLSHandshake is a global wrapper methods which delegated
+// to the default client's Client.SetTLSHandshake.
+func SetTLSHandshake(fn func(ctx context.Context, addr string, plainConn net.Conn) (conn net.Conn, tlsState *tls.ConnectionState, err error)) *Client {
+	return DefaultClient().SetTLSHandshake(fn)
+}
+
+// SetMaxIdleConns is a global wrapper methods which delegated
+// to the default client's Client.SetMaxIdleConns.
+func SetMaxIdleConns(max int) *Client {
+	return DefaultClient().SetMaxIdleConns(max)
+}
+
+// SetMaxIdleConnsPerHost is a global wrapper methods which delegated
+// to the default client's Client.SetMaxIdleConnsPerHost.
+func SetMaxIdleConnsPerHost(max int) *Client {
+	return DefaultClient().SetMaxIdleConnsPerHost(max)
+}
+
+// SetMaxConnsPerHost is a global wrapper methods which delegated
+// to the default client's Client.SetMaxConnsPerHost.
+func SetMaxConnsPerHost(max int) *Client {
+	return DefaultClient().SetMaxConnsPerHost(max)
+}
+
+// SetIdleConnTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetIdleConnTimeout.
+func SetIdleConnTimeout(timeout time.Duration) *Client {
+	return DefaultClient().SetIdleConnTimeout(timeout)
+}
+
+// CloseIdleConnections is a global wrapper methods which delegated
+// to the default client's Client.CloseIdleConnections.
+func CloseIdleConnections() {
+	DefaultClient().CloseIdleConnections()
 }
 
 // SetTLSHandshakeTimeout is a global wrapper methods which delegated
 // to the default client's Client.SetTLSHandshakeTimeout.
 func SetTLSHandshakeTimeout(timeout time.Duration) *Client {
-	return defaultClient.SetTLSHandshakeTimeout(timeout)
+	return DefaultClient().SetTLSHandshakeTimeout(timeout)
+}
+
+// SetDialTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetDialTimeout.
+func SetDialTimeout(timeout time.Duration) *Client {
+	return DefaultClient().SetDialTimeout(timeout)
+}
+
+// SetResponseHeaderTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetResponseHeaderTimeout.
+func SetResponseHeaderTimeout(timeout time.Duration) *Client {
+	return DefaultClient().SetResponseHeaderTimeout(timeout)
+}
+
+// SetResponseBodyReadIdleTimeout is a global wrapper methods which delegated
+// to the default client's Client.SetResponseBodyReadIdleTimeout.
+func SetResponseBodyReadIdleTimeout(timeout time.Duration) *Client {
+	return DefaultClient().SetResponseBodyReadIdleTimeout(timeout)
 }
 
 // EnableForceHTTP1 is a global wrapper methods which delegated
 // to the default client's Client.EnableForceHTTP1.
 func EnableForceHTTP1() *Client {
-	return defaultClient.EnableForceHTTP1()
+	return DefaultClient().EnableForceHTTP1()
 }
 
 // EnableForceHTTP2 is a global wrapper methods which delegated
 // to the default client's Client.EnableForceHTTP2.
 func EnableForceHTTP2() *Client {
-	return defaultClient.EnableForceHTTP2()
+	return DefaultClient().EnableForceHTTP2()
 }
 
 // EnableForceHTTP3 is a global wrapper methods which delegated
 // to the default client's Client.EnableForceHTTP3.
 func EnableForceHTTP3() *Client {
-	return defaultClient.EnableForceHTTP3()
+	return DefaultClient().EnableForceHTTP3()
 }
 
 // EnableHTTP3 is a global wrapper methods which delegated
 // to the default client's Client.EnableHTTP3.
 func EnableHTTP3() *Client {
-	return defaultClient.EnableHTTP3()
+	return DefaultClient().EnableHTTP3()
+}
+
+// EnableAltSvc is a global wrapper methods which delegated
+// to the default client's Client.EnableAltSvc.
+func EnableAltSvc() *Client {
+	return DefaultClient().EnableAltSvc()
+}
+
+// DisableAltSvc is a global wrapper methods which delegated
+// to the default client's Client.DisableAltSvc.
+func DisableAltSvc() *Client {
+	return DefaultClient().DisableAltSvc()
 }
 
 // DisableForceHttpVersion is a global wrapper methods which delegated
 // to the default client's Client.DisableForceHttpVersion.
 func DisableForceHttpVersion() *Client {
-	return defaultClient.DisableForceHttpVersion()
+	return DefaultClient().DisableForceHttpVersion()
 }
 
 // EnableH2C is a global wrapper methods which delegated
 // to the default client's Client.EnableH2C.
 func EnableH2C() *Client {
-	return defaultClient.EnableH2C()
+	return DefaultClient().EnableH2C()
 }
 
 // DisableH2C is a global wrapper methods which delegated
 // to the default client's Client.DisableH2C.
 func DisableH2C() *Client {
-	return defaultClient.DisableH2C()
+	return DefaultClient().DisableH2C()
 }
 
 // DisableAllowGetMethodPayload is a global wrapper methods which delegated
 // to the default client's Client.DisableAllowGetMethodPayload.
 func DisableAllowGetMethodPayload() *Client {
-	return defaultClient.DisableAllowGetMethodPayload()
+	return DefaultClient().DisableAllowGetMethodPayload()
 }
 
 // EnableAllowGetMethodPayload is a global wrapper methods which delegated
 // to the default client's Client.EnableAllowGetMethodPayload.
 func EnableAllowGetMethodPayload() *Client {
-	return defaultClient.EnableAllowGetMethodPayload()
+	return DefaultClient().EnableAllowGetMethodPayload()
 }
 
 // SetCommonRetryCount is a global wrapper methods which delegated
 // to the default client's Client.SetCommonRetryCount.
 func SetCommonRetryCount(count int) *Client {
-	return defaultClient.SetCommonRetryCount(count)
+	return DefaultClient().SetCommonRetryCount(count)
 }
 
 // SetCommonRetryInterval is a global wrapper methods which delegated
 // to the default client's Client.SetCommonRetryInterval.
 func SetCommonRetryInterval(getRetryIntervalFunc GetRetryIntervalFunc) *Client {
-	return defaultClient.SetCommonRetryInterval(getRetryIntervalFunc)
+	return DefaultClient().SetCommonRetryInterval(getRetryIntervalFunc)
 }
 
 // SetCommonRetryFixedInterval is a global wrapper methods which delegated
 // to the default client's Client.SetCommonRetryFixedInterval.
 func SetCommonRetryFixedInterval(interval time.Duration) *Client {
-	return defaultClient.SetCommonRetryFixedInterval(interval)
+	return DefaultClient().SetCommonRetryFixedInterval(interval)
 }
 
 // SetCommonRetryBackoffInterval is a global wrapper methods which delegated
 // to the default client's Client.SetCommonRetryBackoffInterval.
 func SetCommonRetryBackoffInterval(min, max time.Duration) *Client {
-	return defaultClient.SetCommonRetryBackoffInterval(min, max)
+	return DefaultClient().SetCommonRetryBackoffInterval(min, max)
 }
 
 // SetCommonRetryHook is a global wrapper methods which delegated
 // to the default client's Client.SetCommonRetryHook.
 func SetCommonRetryHook(hook RetryHookFunc) *Client {
-	return defaultClient.SetCommonRetryHook(hook)
+	return DefaultClient().SetCommonRetryHook(hook)
 }
 
 // AddCommonRetryHook is a global wrapper methods which delegated
 // to the default client's Client.AddCommonRetryHook.
 func AddCommonRetryHook(hook RetryHookFunc) *Client {
-	return defaultClient.AddCommonRetryHook(hook)
+	return DefaultClient().AddCommonRetryHook(hook)
 }
 
 // SetCommonRetryCondition is a global wrapper methods which delegated
 // to the default client's Client.SetCommonRetryCondition.
 func SetCommonRetryCondition(condition RetryConditionFunc) *Client {
-	return defaultClient.SetCommonRetryCondition(condition)
+	return DefaultClient().SetCommonRetryCondition(condition)
 }
 
 // AddCommonRetryCondition is a global wrapper methods which delegated
 // to the default client's Client.AddCommonRetryCondition.
 func AddCommonRetryCondition(condition RetryConditionFunc) *Client {
-	return defaultClient.AddCommonRetryCondition(condition)
+	return DefaultClient().AddCommonRetryCondition(condition)
+}
+
+// SetErrorClassifier is a global wrapper methods which delegated
+// to the default client's Client.SetErrorClassifier.
+func SetErrorClassifier(classifier ErrorClassifier) *Client {
+	return DefaultClient().SetErrorClassifier(classifier)
+}
+
+// SetCommonRetryPolicy is a global wrapper methods which delegated
+// to the default client's Client.SetCommonRetryPolicy.
+func SetCommonRetryPolicy(policy string) *Client {
+	return DefaultClient().SetCommonRetryPolicy(policy)
+}
+
+// SetCommonRetryBudget is a global wrapper methods which delegated
+// to the default client's Client.SetCommonRetryBudget.
+func SetCommonRetryBudget(budget RetryBudget) *Client {
+	return DefaultClient().SetCommonRetryBudget(budget)
+}
+
+// SetSharedRetryBudget is a global wrapper methods which delegated
+// to the default client's Client.SetSharedRetryBudget.
+func SetSharedRetryBudget(budget SharedRetryBudget) *Client {
+	return DefaultClient().SetSharedRetryBudget(budget)
+}
+
+// DisableSharedRetryBudget is a global wrapper methods which delegated
+// to the default client's Client.DisableSharedRetryBudget.
+func DisableSharedRetryBudget() *Client {
+	return DefaultClient().DisableSharedRetryBudget()
 }
 
 // SetResponseBodyTransformer is a global wrapper methods which delegated
 // to the default client's Client.SetResponseBodyTransformer.
 func SetResponseBodyTransformer(fn func(rawBody []byte, req *Request, resp *Response) (transformedBody []byte, err error)) *Client {
-	return defaultClient.SetResponseBodyTransformer(fn)
+	return DefaultClient().SetResponseBodyTransformer(fn)
 }
 
 // SetUnixSocket is a global wrapper methods which delegated
 // to the default client's Client.SetUnixSocket.
 func SetUnixSocket(file string) *Client {
-	return defaultClient.SetUnixSocket(file)
+	return DefaultClient().SetUnixSocket(file)
 }
 
 // SetTLSFingerprint is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprint.
 func SetTLSFingerprint(clientHelloID utls.ClientHelloID) *Client {
-	return defaultClient.SetTLSFingerprint(clientHelloID)
+	return DefaultClient().SetTLSFingerprint(clientHelloID)
 }
 
 // SetTLSFingerprintRandomized is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintRandomized.
 func SetTLSFingerprintRandomized() *Client {
-	return defaultClient.SetTLSFingerprintRandomized()
+	return DefaultClient().SetTLSFingerprintRandomized()
 }
 
 // SetTLSFingerprintChrome is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintChrome.
 func SetTLSFingerprintChrome() *Client {
-	return defaultClient.SetTLSFingerprintChrome()
+	return DefaultClient().SetTLSFingerprintChrome()
 }
 
 // SetTLSFingerprintAndroid is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintAndroid.
 func SetTLSFingerprintAndroid() *Client {
-	return defaultClient.SetTLSFingerprintAndroid()
+	return DefaultClient().SetTLSFingerprintAndroid()
 }
 
 // SetTLSFingerprint360 is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprint360.
 func SetTLSFingerprint360() *Client {
-	return defaultClient.SetTLSFingerprint360()
+	return DefaultClient().SetTLSFingerprint360()
 }
 
 // SetTLSFingerprintEdge is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintEdge.
 func SetTLSFingerprintEdge() *Client {
-	return defaultClient.SetTLSFingerprintEdge()
+	return DefaultClient().SetTLSFingerprintEdge()
 }
 
 // SetTLSFingerprintFirefox is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintFirefox.
 func SetTLSFingerprintFirefox() *Client {
-	return defaultClient.SetTLSFingerprintFirefox()
+	return DefaultClient().SetTLSFingerprintFirefox()
 }
 
 // SetTLSFingerprintQQ is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintQQ.
 func SetTLSFingerprintQQ() *Client {
-	return defaultClient.SetTLSFingerprintQQ()
+	return DefaultClient().SetTLSFingerprintQQ()
 }
 
 // SetTLSFingerprintIOS is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintIOS.
 func SetTLSFingerprintIOS() *Client {
-	return defaultClient.SetTLSFingerprintIOS()
+	return DefaultClient().SetTLSFingerprintIOS()
 }
 
 // SetTLSFingerprintSafari is a global wrapper methods which delegated
 // to the default client's Client.SetTLSFingerprintSafari.
 func SetTLSFingerprintSafari() *Client {
-	return defaultClient.SetTLSFingerprintSafari()
+	return DefaultClient().SetTLSFingerprintSafari()
 }
 
 // GetClient is a global wrapper methods which delegated
 // to the default client's Client.GetClient.
 func GetClient() *http.Client {
-	return defaultClient.GetClient()
+	return DefaultClient().GetClient()
 }
 
 // NewRequest is a global wrapper methods which delegated
 // to the default client's Client.NewRequest.
 func NewRequest() *Request {
-	return defaultClient.R()
+	return DefaultClient().R()
 }
 
 // R is a global wrapper methods which delegated
 // to the default client's Client.R().
 func R() *Request {
-	return defaultClient.R()
+	return DefaultClient().R()
 }
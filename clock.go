@@ -0,0 +1,40 @@
+package req
+
+import (
+	"context"
+	"time"
+)
+
+type nowFuncKey struct{}
+
+// nowFromContext returns the current time of the now function set by
+// Client.SetNowFunc, which is passed in the ctx, e.g. to the TokenProvider.
+func nowFromContext(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(nowFuncKey{}).(func() time.Time); ok {
+		return now()
+	}
+	return time.Now()
+}
+
+func (c *Client) now() time.Time {
+	if c.nowFunc != nil {
+		return c.nowFunc()
+	}
+	return time.Now()
+}
+
+// SetNowFunc set the function which returns the current time, it's used by
+// the expiry of the negative cache, the retry budget and deadline, the
+// token refresh of NewCachedTokenProvider, and the deprecation sunset and
+// warnings, so that the tests and simulation runs can travel in time
+// deterministically. The nil func restores time.Now.
+//
+// Cookies are out of scope: the default cookie jar of net/http/cookiejar
+// always expires the cookies by the wall clock and can't be given another
+// clock, use SetCookieJar to set a jar with its own clock if the tests
+// depend on the cookie expiry. The timeouts of the underlying connections
+// and the latencies are not affected either.
+func (c *Client) SetNowFunc(now func() time.Time) *Client {
+	c.nowFunc = now
+	return c
+}
@@ -0,0 +1,204 @@
+package req
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAIMDInitialLimit = 20
+	defaultAIMDMaxLimit     = 1000
+	defaultAIMDBackoffRatio = 0.9
+	defaultAIMDTimeout      = 5 * time.Second
+)
+
+// AdaptiveConcurrencyOptions is the options of
+// Client.EnableAdaptiveConcurrency.
+type AdaptiveConcurrencyOptions struct {
+	// InitialLimit is the initial max in-flight requests per host,
+	// default is 20.
+	InitialLimit int
+	// MinLimit is the minimum of the limit, default is 1.
+	MinLimit int
+	// MaxLimit is the maximum of the limit, default is 1000.
+	MaxLimit int
+	// BackoffRatio is multiplied to the limit when the request is dropped,
+	// which must be in (0.5, 1), default is 0.9.
+	BackoffRatio float64
+	// Timeout is the latency threshold above which the request is treated
+	// as dropped, default is 5s.
+	Timeout time.Duration
+	// OnLimitChange is called when the limit of the host changes.
+	OnLimitChange func(host string, limit int)
+}
+
+// aimdLimiter limits the in-flight requests of a host with the AIMD
+// (additive increase, multiplicative decrease) algorithm: the limit is
+// increased by one when a request succeeds while the limit is in use, and
+// multiplied by the backoff ratio when a request is dropped (e.g. fails,
+// is throttled or exceeds the timeout).
+type aimdLimiter struct {
+	host string
+	opts *AdaptiveConcurrencyOptions
+
+	mu       sync.Mutex
+	limit    int
+	inflight int
+	changed  chan struct{}
+}
+
+// acquire waits until the in-flight requests are below the limit or the
+// ctx is done.
+func (l *aimdLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inflight < l.limit {
+			l.inflight++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release releases the slot and adjusts the limit by the result.
+func (l *aimdLimiter) release(latency time.Duration, dropped bool) {
+	l.mu.Lock()
+	old := l.limit
+	if dropped || latency > l.opts.Timeout {
+		l.limit = int(float64(l.limit) * l.opts.BackoffRatio)
+		if l.limit < l.opts.MinLimit {
+			l.limit = l.opts.MinLimit
+		}
+	} else if l.inflight*2 >= l.limit && l.limit < l.opts.MaxLimit {
+		l.limit++
+	}
+	l.inflight--
+	limit := l.limit
+	close(l.changed)
+	l.changed = make(chan struct{})
+	l.mu.Unlock()
+	if limit != old && l.opts.OnLimitChange != nil {
+		l.opts.OnLimitChange(l.host, limit)
+	}
+}
+
+// adaptiveConcurrency holds the limiters of hosts.
+type adaptiveConcurrency struct {
+	opts     AdaptiveConcurrencyOptions
+	mu       sync.Mutex
+	limiters map[string]*aimdLimiter
+}
+
+func (ac *adaptiveConcurrency) limiter(host string) *aimdLimiter {
+	host = strings.ToLower(host)
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	l, ok := ac.limiters[host]
+	if !ok {
+		l = &aimdLimiter{
+			host:    host,
+			opts:    &ac.opts,
+			limit:   ac.opts.InitialLimit,
+			changed: make(chan struct{}),
+		}
+		ac.limiters[host] = l
+	}
+	return l
+}
+
+func (ac *adaptiveConcurrency) limit(host string) int {
+	ac.mu.Lock()
+	l, ok := ac.limiters[strings.ToLower(host)]
+	ac.mu.Unlock()
+	if !ok {
+		return ac.opts.InitialLimit
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+func isDroppedResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+// EnableAdaptiveConcurrency limits the max in-flight requests per host
+// (host:port) fired from the client, which is adjusted by the observed
+// latency and errors with the AIMD algorithm (like Netflix's
+// concurrency-limits): it's increased by one when requests succeed while
+// the limit is in use, and multiplied by the BackoffRatio when a request
+// fails, is throttled (429 or 503), or its latency (until the response
+// header is received) exceeds the Timeout, which protects struggling
+// upstreams automatically. Requests exceeding the limit wait until a slot
+// is available or the context is done. For example:
+//
+//	client.EnableAdaptiveConcurrency(&req.AdaptiveConcurrencyOptions{
+//		InitialLimit: 10,
+//		MaxLimit:     100,
+//		Timeout:      time.Second,
+//	})
+func (c *Client) EnableAdaptiveConcurrency(opts ...*AdaptiveConcurrencyOptions) *Client {
+	var o AdaptiveConcurrencyOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.MinLimit <= 0 {
+		o.MinLimit = 1
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = defaultAIMDMaxLimit
+	}
+	if o.MaxLimit < o.MinLimit {
+		o.MaxLimit = o.MinLimit
+	}
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = defaultAIMDInitialLimit
+	}
+	if o.InitialLimit < o.MinLimit {
+		o.InitialLimit = o.MinLimit
+	} else if o.InitialLimit > o.MaxLimit {
+		o.InitialLimit = o.MaxLimit
+	}
+	if o.BackoffRatio <= 0.5 || o.BackoffRatio >= 1 {
+		o.BackoffRatio = defaultAIMDBackoffRatio
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultAIMDTimeout
+	}
+	c.adaptiveConcurrency = &adaptiveConcurrency{
+		opts:     o,
+		limiters: make(map[string]*aimdLimiter),
+	}
+	return c
+}
+
+// DisableAdaptiveConcurrency disables the adaptive concurrency limit
+// (disabled by default).
+func (c *Client) DisableAdaptiveConcurrency() *Client {
+	c.adaptiveConcurrency = nil
+	return c
+}
+
+// ConcurrencyLimit returns the current max in-flight requests of the host
+// (host:port) set by the adaptive concurrency limit, 0 is returned if
+// EnableAdaptiveConcurrency is not called.
+func (c *Client) ConcurrencyLimit(host string) int {
+	if c.adaptiveConcurrency == nil {
+		return 0
+	}
+	return c.adaptiveConcurrency.limit(host)
+}
@@ -0,0 +1,68 @@
+package req
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ConnReuseInfo is the information of the connection which the request is
+// sent on, see Response.ConnReuseInfo.
+type ConnReuseInfo struct {
+	// Reused is whether the connection has been used for previous requests.
+	Reused bool
+	// WasIdle is whether the connection was obtained from the idle pool.
+	WasIdle bool
+	// IdleTime is how long the connection was idle, if WasIdle is true.
+	IdleTime time.Duration
+	// Protocol is the negotiated protocol, e.g. "HTTP/1.1", "HTTP/2.0" or
+	// "HTTP/3.0".
+	Protocol string
+	// LocalAddr is the local address of the connection.
+	LocalAddr net.Addr
+	// RemoteAddr is the remote address of the connection.
+	RemoteAddr net.Addr
+}
+
+// connReuseTracker records the GotConnInfo of the request, the hook may be
+// called concurrently by the hedged requests.
+type connReuseTracker struct {
+	mu   sync.Mutex
+	info httptrace.GotConnInfo
+}
+
+func (t *connReuseTracker) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.info = info
+			t.mu.Unlock()
+		},
+	})
+}
+
+// ConnReuseInfo returns whether the connection which the request is sent on
+// was reused, how long it was idle, and the negotiated protocol, which is
+// recorded for every request without enabling the trace. The Reused and
+// idle fields are always false for HTTP3.
+func (r *Response) ConnReuseInfo() ConnReuseInfo {
+	var info ConnReuseInfo
+	if r.Response != nil {
+		info.Protocol = r.Proto
+	}
+	if r.connReuse == nil {
+		return info
+	}
+	r.connReuse.mu.Lock()
+	defer r.connReuse.mu.Unlock()
+	info.Reused = r.connReuse.info.Reused
+	info.WasIdle = r.connReuse.info.WasIdle
+	info.IdleTime = r.connReuse.info.IdleTime
+	if conn := r.connReuse.info.Conn; conn != nil {
+		info.LocalAddr = conn.LocalAddr()
+		info.RemoteAddr = conn.RemoteAddr()
+	}
+	return info
+}
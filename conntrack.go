@@ -0,0 +1,205 @@
+package req
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	reqtls "github.com/imroc/req/v3/pkg/tls"
+)
+
+// ConnectionInfo is the information of the connection passed to the hooks
+// set by Client.OnConnectionOpen and Client.OnConnectionClose.
+type ConnectionInfo struct {
+	// Network is the network of the connection, e.g. "tcp".
+	Network string
+	// Addr is the dialed address, which is the address of the proxy if
+	// the request is sent via the proxy.
+	Addr string
+	// LocalAddr is the local address of the connection.
+	LocalAddr net.Addr
+	// RemoteAddr is the remote address of the connection.
+	RemoteAddr net.Addr
+	// OpenedAt is when the connection is established.
+	OpenedAt time.Time
+	// Requests is the number of requests sent on the connection, which is
+	// reused if it's greater than 1. It's always 0 in OnConnectionOpen.
+	Requests int64
+}
+
+// trackedConn calls the connection hooks of the transport when it's
+// established and closed, and counts the requests sent on it.
+type trackedConn struct {
+	net.Conn
+	t         *Transport
+	stats     *transportStats
+	info      ConnectionInfo
+	requests  atomic.Int64
+	closeOnce sync.Once
+}
+
+// trackedTLSConn is the trackedConn of the TLS connection returned by the
+// custom TLS dialer, which keeps implementing reqtls.Conn so that the ALPN
+// negotiated protocol is still honored.
+type trackedTLSConn struct {
+	*trackedConn
+	tlsConn reqtls.Conn
+}
+
+func (c *trackedTLSConn) ConnectionState() tls.ConnectionState {
+	return c.tlsConn.ConnectionState()
+}
+
+func (c *trackedTLSConn) Handshake() error {
+	return c.tlsConn.Handshake()
+}
+
+func (c *trackedTLSConn) HandshakeContext(ctx context.Context) error {
+	return c.tlsConn.HandshakeContext(ctx)
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.stats != nil {
+		atomic.AddInt64(&c.stats.bytesRead, int64(n))
+	}
+	return n, err
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if c.stats != nil {
+		atomic.AddInt64(&c.stats.bytesWritten, int64(n))
+	}
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		if c.stats != nil {
+			c.stats.closed(c.info.Addr)
+		}
+		if c.t.onConnClose != nil {
+			info := c.info
+			info.Requests = c.requests.Load()
+			c.t.onConnClose(info)
+		}
+	})
+	return err
+}
+
+func (t *Transport) trackConn(conn net.Conn, network, addr string) net.Conn {
+	if t.onConnOpen == nil && t.onConnClose == nil && t.stats == nil {
+		return conn
+	}
+	if t.stats != nil {
+		t.stats.opened(addr)
+	}
+	c := &trackedConn{
+		Conn:  conn,
+		t:     t,
+		stats: t.stats,
+		info: ConnectionInfo{
+			Network:    network,
+			Addr:       addr,
+			LocalAddr:  conn.LocalAddr(),
+			RemoteAddr: conn.RemoteAddr(),
+			OpenedAt:   time.Now(),
+		},
+	}
+	if t.onConnOpen != nil {
+		t.onConnOpen(c.info)
+	}
+	if tc, ok := conn.(reqtls.Conn); ok {
+		return &trackedTLSConn{trackedConn: c, tlsConn: tc}
+	}
+	return c
+}
+
+// unwrapTrackedConn returns the trackedConn under the conn, e.g. the
+// underlying conn of the TLS conn.
+func unwrapTrackedConn(conn net.Conn) *trackedConn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *trackedConn:
+			return c
+		case *trackedTLSConn:
+			return c.trackedConn
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// withConnRequestCounter counts the request on the connection it's sent
+// on if the connection is tracked.
+func (t *Transport) withConnRequestCounter(req *http.Request) *http.Request {
+	if t.onConnClose == nil && t.stats == nil {
+		return req
+	}
+	stats := t.stats
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if c := unwrapTrackedConn(info.Conn); c != nil {
+				c.requests.Add(1)
+			}
+			if stats != nil {
+				if info.Reused {
+					atomic.AddInt64(&stats.reusedConns, 1)
+				} else {
+					atomic.AddInt64(&stats.newConns, 1)
+				}
+			}
+		},
+	})
+	return req.WithContext(ctx)
+}
+
+// OnConnectionOpen set the hook which is called when a new connection is
+// established (before the TLS handshake), HTTP3 connections are not
+// included as they're over UDP.
+func (t *Transport) OnConnectionOpen(fn func(info ConnectionInfo)) *Transport {
+	t.onConnOpen = fn
+	return t
+}
+
+// OnConnectionClose set the hook which is called when the connection is
+// closed, e.g. it's idle for too long or broken, with the number of
+// requests sent on it. HTTP3 connections are not included as they're
+// over UDP.
+func (t *Transport) OnConnectionClose(fn func(info ConnectionInfo)) *Transport {
+	t.onConnClose = fn
+	return t
+}
+
+// OnConnectionOpen set the hook which is called when a new connection is
+// established (before the TLS handshake), with the dialed and remote
+// address. HTTP3 connections are not included as they're over UDP.
+func (c *Client) OnConnectionOpen(fn func(info ConnectionInfo)) *Client {
+	c.Transport.OnConnectionOpen(fn)
+	return c
+}
+
+// OnConnectionClose set the hook which is called when the pooled
+// connection is closed, e.g. it's idle for too long or broken, with the
+// number of requests sent on it (the connection is reused if it's greater
+// than 1). HTTP3 connections are not included as they're over UDP. For
+// example:
+//
+//	client.OnConnectionClose(func(info req.ConnectionInfo) {
+//		log.Printf("connection to %s closed after %s and %d requests",
+//			info.RemoteAddr, time.Since(info.OpenedAt), info.Requests)
+//	})
+func (c *Client) OnConnectionClose(fn func(info ConnectionInfo)) *Client {
+	c.Transport.OnConnectionClose(fn)
+	return c
+}
@@ -1,11 +1,23 @@
 package req
 
 import (
+	"bytes"
 	"github.com/imroc/req/v3/internal/charsets"
 	"io"
 	"strings"
 )
 
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// stripBOM strips the UTF-8 BOM of the body before unmarshalling, which is
+// rejected by encoding/json, unless it's disabled.
+func (c *Client) stripBOM(body []byte) []byte {
+	if c.disableBOMStripping {
+		return body
+	}
+	return bytes.TrimPrefix(body, utf8BOM)
+}
+
 var textContentTypes = []string{"text", "json", "xml", "html", "java"}
 
 var autoDecodeText = autoDecodeContentTypeFunc(textContentTypes...)
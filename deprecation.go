@@ -0,0 +1,253 @@
+package req
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deprecation is the deprecation and sunset information of the endpoint
+// announced by the server in the response headers:
+//
+//   - Deprecation (RFC 9745): "@1688169599" or the HTTP-date of the
+//     deprecation, or "true" of the earlier drafts.
+//   - Sunset (RFC 8594): the HTTP-date after which the endpoint will be
+//     unavailable.
+//   - Link with rel="deprecation" or rel="sunset": the documentation.
+//   - Warning with code 299 (e.g. sent by Kubernetes): the deprecation
+//     notices.
+type Deprecation struct {
+	// Deprecated is true if the Deprecation header is present.
+	Deprecated bool
+	// Date is the date of the deprecation, zero if it's not specified.
+	Date time.Time
+	// Sunset is the date of the sunset, zero if it's not specified.
+	Sunset time.Time
+	// Link is the url of the documentation of the deprecation or sunset.
+	Link string
+	// Warnings is the text of the 299 warnings.
+	Warnings []string
+
+	// now is the clock of the client of the response.
+	now func() time.Time
+}
+
+// IsSunset reports whether the sunset date is passed.
+func (d *Deprecation) IsSunset() bool {
+	if d.Sunset.IsZero() {
+		return false
+	}
+	now := time.Now
+	if d.now != nil {
+		now = d.now
+	}
+	return !now().Before(d.Sunset)
+}
+
+func (d *Deprecation) String() string {
+	var parts []string
+	if d.Deprecated {
+		s := "deprecated"
+		if !d.Date.IsZero() {
+			s += " since " + d.Date.UTC().Format(time.RFC3339)
+		}
+		parts = append(parts, s)
+	}
+	if !d.Sunset.IsZero() {
+		parts = append(parts, "sunset at "+d.Sunset.UTC().Format(time.RFC3339))
+	}
+	if d.Link != "" {
+		parts = append(parts, "see "+d.Link)
+	}
+	for _, w := range d.Warnings {
+		parts = append(parts, strconv.Quote(w))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseDeprecationDate parses the value of the Deprecation header.
+func parseDeprecationDate(v string) time.Time {
+	if strings.HasPrefix(v, "@") {
+		if sec, err := strconv.ParseInt(v[1:], 10, 64); err == nil {
+			return time.Unix(sec, 0)
+		}
+		return time.Time{}
+	}
+	t, _ := http.ParseTime(v)
+	return t
+}
+
+// parseWarning returns the text of the Warning header value if its code is
+// 299, e.g. `299 - "extensions/v1beta1 Ingress is deprecated"`.
+func parseWarning(v string) (string, bool) {
+	code, rest, _ := strings.Cut(strings.TrimSpace(v), " ")
+	if code != "299" {
+		return "", false
+	}
+	_, text, _ := strings.Cut(strings.TrimSpace(rest), " ") // skip warn-agent
+	text = strings.TrimSpace(text)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		return unquoted, true
+	}
+	if i := strings.LastIndex(text, `" `); strings.HasPrefix(text, `"`) && i > 0 { // with warn-date
+		if unquoted, err := strconv.Unquote(text[:i+1]); err == nil {
+			return unquoted, true
+		}
+	}
+	return text, true
+}
+
+// Deprecation returns the deprecation and sunset information of the
+// endpoint parsed from the response headers, nil if the server does not
+// announce it.
+func (r *Response) Deprecation() *Deprecation {
+	if r.Response == nil {
+		return nil
+	}
+	d := &Deprecation{}
+	found := false
+	if v := r.Header.Get("Deprecation"); v != "" {
+		found = true
+		if !strings.EqualFold(v, "false") {
+			d.Deprecated = true
+			d.Date = parseDeprecationDate(v)
+		}
+	}
+	if v := r.Header.Get("Sunset"); v != "" {
+		found = true
+		d.Sunset, _ = http.ParseTime(v)
+	}
+	for _, v := range r.Header.Values("Warning") {
+		if text, ok := parseWarning(v); ok {
+			found = true
+			d.Warnings = append(d.Warnings, text)
+		}
+	}
+	if !found {
+		return nil
+	}
+	link := findLink(r.Header, "deprecation")
+	if link == "" {
+		link = findLink(r.Header, "sunset")
+	}
+	if link != "" && r.Request != nil && r.Request.URL != nil {
+		if u, err := r.Request.URL.Parse(link); err == nil {
+			link = u.String()
+		}
+	}
+	d.Link = link
+	if r.Request != nil && r.Request.client != nil {
+		d.now = r.Request.client.now
+	}
+	return d
+}
+
+const (
+	// deprecationWarnInterval is the interval of the warnings of the same
+	// endpoint.
+	deprecationWarnInterval = 24 * time.Hour
+	// maxDeprecationWarnedEndpoints is the max number of the warned
+	// endpoints remembered, the least recently warned one is evicted.
+	maxDeprecationWarnedEndpoints = 1024
+)
+
+type deprecationWarned struct {
+	endpoint string
+	at       time.Time
+}
+
+// deprecationNotifier calls the callback for the responses of the
+// deprecated endpoints, or warns once a day per endpoint.
+type deprecationNotifier struct {
+	callback func(resp *Response, d *Deprecation)
+
+	mu     sync.Mutex
+	warned map[string]*list.Element
+	lru    *list.List
+}
+
+func newDeprecationNotifier(callback func(resp *Response, d *Deprecation)) *deprecationNotifier {
+	return &deprecationNotifier{
+		callback: callback,
+		warned:   make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// shouldWarn reports whether the endpoint should be warned, which is true
+// if it's not warned in the deprecationWarnInterval.
+func (n *deprecationNotifier) shouldWarn(endpoint string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if e, ok := n.warned[endpoint]; ok {
+		w := e.Value.(*deprecationWarned)
+		if now.Sub(w.at) < deprecationWarnInterval {
+			return false
+		}
+		w.at = now
+		n.lru.MoveToFront(e)
+		return true
+	}
+	n.warned[endpoint] = n.lru.PushFront(&deprecationWarned{endpoint: endpoint, at: now})
+	if n.lru.Len() > maxDeprecationWarnedEndpoints {
+		oldest := n.lru.Back()
+		n.lru.Remove(oldest)
+		delete(n.warned, oldest.Value.(*deprecationWarned).endpoint)
+	}
+	return true
+}
+
+func handleDeprecation(c *Client, resp *Response) error {
+	n := c.deprecation
+	if n == nil {
+		return nil
+	}
+	d := resp.Deprecation()
+	if d == nil {
+		return nil
+	}
+	if n.callback != nil {
+		n.callback(resp, d)
+		return nil
+	}
+	endpoint := resp.Request.Method + " " + resp.Request.URL.Host + resp.Request.URL.Path
+	if n.shouldWarn(endpoint, c.now()) {
+		c.log.Warnf("%s is %s", endpoint, d)
+	}
+	return nil
+}
+
+// OnDeprecation set the callback which is called with the deprecation
+// information (see Response.Deprecation) if the server announces the
+// endpoint is deprecated or will be sunset, so that the teams learn about
+// the API sunsets before they break. For example:
+//
+//	client.OnDeprecation(func(resp *req.Response, d *req.Deprecation) {
+//		metrics.DeprecatedCalls.WithLabelValues(resp.Request.URL.Path).Inc()
+//	})
+func (c *Client) OnDeprecation(callback func(resp *Response, d *Deprecation)) *Client {
+	if callback == nil {
+		c.deprecation = nil
+		return c
+	}
+	c.deprecation = newDeprecationNotifier(callback)
+	return c
+}
+
+// EnableDeprecationWarning logs a warning with the logger of the client
+// once a day for each endpoint (method, host and path) which the server
+// announces is deprecated or will be sunset.
+func (c *Client) EnableDeprecationWarning() *Client {
+	c.deprecation = newDeprecationNotifier(nil)
+	return c
+}
+
+// DisableDeprecationWarning disables the callback set by OnDeprecation or
+// the warning enabled by EnableDeprecationWarning.
+func (c *Client) DisableDeprecationWarning() *Client {
+	c.deprecation = nil
+	return c
+}
@@ -0,0 +1,213 @@
+package req
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// IPPreference is the preference of the address family when dialing, see
+// Client.SetIPPreference.
+type IPPreference int
+
+const (
+	// IPPreferenceDefault dials the addresses in the order returned by the
+	// resolver, the other address family is raced after the fallback delay
+	// (Happy Eyeballs, RFC 6555).
+	IPPreferenceDefault IPPreference = iota
+	// IPPreferenceIPv4 dials the IPv4 addresses first, the IPv6 addresses
+	// are raced after the fallback delay.
+	IPPreferenceIPv4
+	// IPPreferenceIPv6 dials the IPv6 addresses first, the IPv4 addresses
+	// are raced after the fallback delay.
+	IPPreferenceIPv6
+	// IPPreferenceIPv4Only dials the IPv4 addresses only.
+	IPPreferenceIPv4Only
+	// IPPreferenceIPv6Only dials the IPv6 addresses only.
+	IPPreferenceIPv6Only
+)
+
+// defaultFallbackDelay is the same as the net.Dialer.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+func (t *Transport) netDialer() *net.Dialer {
+	if t.fallbackDelay == 0 {
+		return &zeroDialer
+	}
+	return &net.Dialer{FallbackDelay: t.fallbackDelay}
+}
+
+// ipNetwork restricts the "tcp" network to the address family if only one
+// family is allowed.
+func (t *Transport) ipNetwork(network string) string {
+	if network != "tcp" {
+		return network
+	}
+	switch t.ipPreference {
+	case IPPreferenceIPv4Only:
+		return "tcp4"
+	case IPPreferenceIPv6Only:
+		return "tcp6"
+	}
+	return network
+}
+
+// partitionAddrs returns the addresses of the preferred address family (or
+// the family of the first address) as the primaries, and the others as the
+// fallbacks, the addresses not allowed by the network are skipped.
+func (t *Transport) partitionAddrs(network string, ips []net.IPAddr, port string) (primaries, fallbacks []string) {
+	var preferIPv4 bool
+	switch t.ipPreference {
+	case IPPreferenceIPv4:
+		preferIPv4 = true
+	case IPPreferenceIPv6:
+		preferIPv4 = false
+	default:
+		if len(ips) > 0 {
+			preferIPv4 = ips[0].IP.To4() != nil
+		}
+	}
+	for _, ip := range ips {
+		isIPv4 := ip.IP.To4() != nil
+		if (network == "tcp4" && !isIPv4) || (network == "tcp6" && isIPv4) {
+			continue
+		}
+		addr := net.JoinHostPort(ip.String(), port)
+		if isIPv4 == preferIPv4 {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	if len(primaries) == 0 {
+		primaries, fallbacks = fallbacks, nil
+	}
+	return
+}
+
+// dialSerial dials the addresses in order until one succeeds.
+func (t *Transport) dialSerial(ctx context.Context, network string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := t.netDialer().DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// dialParallel races the primaries and the fallbacks which are started
+// after the fallback delay (or the primaries fail), the first established
+// connection is returned, like the net.Dialer.
+func (t *Transport) dialParallel(ctx context.Context, network string, primaries, fallbacks []string) (net.Conn, error) {
+	if len(fallbacks) == 0 || t.fallbackDelay < 0 {
+		return t.dialSerial(ctx, network, append(primaries, fallbacks...))
+	}
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan dialResult)
+	returned := make(chan struct{})
+	defer close(returned)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	startRacer := func(primary bool) {
+		addrs := primaries
+		if !primary {
+			addrs = fallbacks
+		}
+		conn, err := t.dialSerial(ctx, network, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+	go startRacer(true)
+
+	delay := t.fallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var primaryErr error
+	fallbackStarted, primaryDone, fallbackDone := false, false, false
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				go startRacer(false)
+			}
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryDone, primaryErr = true, res.err
+			} else {
+				fallbackDone = true
+			}
+			if primaryDone && fallbackDone {
+				return nil, primaryErr
+			}
+			if res.primary && !fallbackStarted {
+				timer.Stop()
+				fallbackStarted = true
+				go startRacer(false)
+			}
+		}
+	}
+}
+
+// SetDialFallbackDelay set the fallback delay, see Client.SetDialFallbackDelay.
+func (t *Transport) SetDialFallbackDelay(delay time.Duration) *Transport {
+	t.fallbackDelay = delay
+	return t
+}
+
+// SetIPPreference set the address family preference, see
+// Client.SetIPPreference.
+func (t *Transport) SetIPPreference(preference IPPreference) *Transport {
+	t.ipPreference = preference
+	return t
+}
+
+// SetDialFallbackDelay set how long to wait before racing the addresses of
+// the other address family (Happy Eyeballs, RFC 6555) when the connection
+// to the first one is not established, default is 300ms, and the negative
+// delay disables the racing, which dials the addresses one by one. Reduce
+// it in the dual-stack environments with broken IPv6, which add the delay
+// to each new connection. It takes no effect if the custom dial function
+// is set (e.g. SetDial) or on HTTP3.
+func (c *Client) SetDialFallbackDelay(delay time.Duration) *Client {
+	c.Transport.SetDialFallbackDelay(delay)
+	return c
+}
+
+// SetIPPreference set the preferred address family when dialing, the
+// addresses of the preferred family are dialed first, and the others are
+// raced after the fallback delay (see SetDialFallbackDelay), or the others
+// are never dialed with IPPreferenceIPv4Only and IPPreferenceIPv6Only.
+// Default is IPPreferenceDefault which respects the order of the resolver.
+// For example:
+//
+//	client.SetIPPreference(req.IPPreferenceIPv4)
+func (c *Client) SetIPPreference(preference IPPreference) *Client {
+	c.Transport.SetIPPreference(preference)
+	return c
+}
@@ -1,6 +1,7 @@
 package req
 
 import (
+	"bytes"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha256"
@@ -28,8 +29,8 @@ var hashFuncs = map[string]func() hash.Hash{
 	"MD5-sess":         md5.New,
 	"SHA-256":          sha256.New,
 	"SHA-256-sess":     sha256.New,
-	"SHA-512-256":      sha512.New,
-	"SHA-512-256-sess": sha512.New,
+	"SHA-512-256":      sha512.New512_256,
+	"SHA-512-256-sess": sha512.New512_256,
 }
 
 // create response middleware for http digest authentication.
@@ -42,33 +43,68 @@ func handleDigestAuthFunc(username, password string) ResponseMiddleware {
 		if err != nil {
 			return err
 		}
-		r := resp.Request
-		req := *r.RawRequest
-		if req.Body != nil {
-			err = parseRequestBody(client, r) // re-setup body
+		req, err := newResendRequest(client, resp.Request)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(header.Authorization, auth)
+		httpResp, err := client.GetTransport().RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		return replaceResponse(resp, httpResp)
+	}
+}
+
+// newResendRequest returns a copy of the raw request with the body re-setup,
+// which is used to resend the request with credentials.
+func newResendRequest(client *Client, r *Request) (*http.Request, error) {
+	req := *r.RawRequest
+	if req.Body != nil {
+		err := parseRequestBody(client, r) // re-setup body
+		if err != nil {
+			return nil, err
+		}
+		if r.GetBody != nil {
+			body, err := r.GetBody()
 			if err != nil {
-				return err
-			}
-			if r.GetBody != nil {
-				body, err := r.GetBody()
-				if err != nil {
-					return err
-				}
-				req.Body = body
-				req.GetBody = r.GetBody
+				return nil, err
 			}
+			req.Body = body
+			req.GetBody = r.GetBody
 		}
-		if req.Header == nil {
-			req.Header = make(http.Header)
+	}
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	return &req, nil
+}
+
+// replaceResponse replaces the underlying response with the one of the
+// resent request.
+func replaceResponse(resp *Response, httpResp *http.Response) error {
+	autoRead := resp.body != nil
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	resp.Response = httpResp
+	resp.resetBody()
+	if autoRead { // re-read the body of the authorized response
+		if _, err := resp.ToBytes(); err != nil {
+			return err
 		}
-		req.Header.Set(header.Authorization, auth)
-		resp.Response, err = client.GetTransport().RoundTrip(&req)
-		return err
+		resp.Body = io.NopCloser(bytes.NewReader(resp.body))
 	}
+	return nil
 }
 
 func createDigestAuth(resp *http.Response, username, password string) (auth string, err error) {
-	chal := resp.Header.Get(header.WwwAuthenticate)
+	return digestAuthorization(resp.Header.Get(header.WwwAuthenticate), resp.Request.URL.RequestURI(), resp.Request.Method, username, password)
+}
+
+// digestAuthorization returns the credentials of the challenge.
+func digestAuthorization(chal, digestURI, method, username, password string) (auth string, err error) {
 	if chal == "" {
 		return "", errDigestBadChallenge
 	}
@@ -79,7 +115,7 @@ func createDigestAuth(resp *http.Response, username, password string) (auth stri
 	}
 
 	// Form credentials based on the challenge
-	cr := newCredentials(resp.Request.URL.RequestURI(), resp.Request.Method, username, password, c)
+	cr := newCredentials(digestURI, method, username, password, c)
 	auth, err = cr.authorize()
 	return
 }
@@ -120,7 +156,7 @@ func parseChallenge(input string) (*challenge, error) {
 		return nil, errDigestBadChallenge
 	}
 	s = strings.Trim(s[7:], ws)
-	sl := strings.Split(s, ",")
+	sl := splitChallengeParams(s)
 	c := &challenge{}
 	var r []string
 	for i := range sl {
@@ -156,6 +192,26 @@ func parseChallenge(input string) (*challenge, error) {
 	return c, nil
 }
 
+// splitChallengeParams splits the comma separated params of challenge, the
+// commas within quoted values (e.g. qop="auth,auth-int") are not separators.
+func splitChallengeParams(s string) []string {
+	var params []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(params, s[start:])
+}
+
 type credentials struct {
 	username   string
 	userhash   string
@@ -215,19 +271,13 @@ func (c *credentials) validateQop() error {
 	if c.messageQop == "" {
 		return nil
 	}
-	possibleQops := strings.Split(c.messageQop, ", ")
-	var authSupport bool
-	for _, qop := range possibleQops {
-		if qop == "auth" {
-			authSupport = true
-			break
+	for _, qop := range strings.Split(c.messageQop, ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			c.messageQop = "auth" // choose auth from the list, e.g. "auth, auth-int"
+			return nil
 		}
 	}
-	if !authSupport {
-		return errDigestQopNotSupported
-	}
-
-	return nil
+	return errDigestQopNotSupported
 }
 
 func (c *credentials) h(data string) string {
@@ -0,0 +1,186 @@
+package req
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DnsCacheStats is the metrics of the DNS cache enabled by
+// Client.EnableDnsCache.
+type DnsCacheStats struct {
+	// Hits is the number of lookups answered by the cache, including the
+	// NegativeHits.
+	Hits int64
+	// NegativeHits is the number of lookups answered by the cached "no such
+	// host" errors.
+	NegativeHits int64
+	// Misses is the number of lookups sent to the resolver.
+	Misses int64
+	// Evictions is the number of entries evicted as the cache is full.
+	Evictions int64
+	// Entries is the number of entries in the cache.
+	Entries int
+}
+
+type dnsCacheEntry struct {
+	host    string
+	ips     []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// dnsLookup is an in-flight lookup, which is shared by the concurrent
+// lookups of the same host.
+type dnsLookup struct {
+	done chan struct{}
+	ips  []net.IPAddr
+	err  error
+}
+
+// dnsCache memoizes the lookups of hosts for the ttl, the least recently
+// used entry is evicted if there are more than maxEntries entries.
+type dnsCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*dnsLookup
+	stats    DnsCacheStats
+}
+
+func newDnsCache(ttl time.Duration, maxEntries int) *dnsCache {
+	return &dnsCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		inflight:   make(map[string]*dnsLookup),
+	}
+}
+
+// isNotFound reports whether the err is a "no such host" error, which is
+// cached, other errors (e.g. timeout) are not.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// lookup returns the cached addresses of the host, the resolver is used to
+// look up the host if it's not cached or expired.
+func (c *dnsCache) lookup(ctx context.Context, host string, resolver Resolver) ([]net.IPAddr, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if e, ok := c.entries[host]; ok {
+		entry := e.Value.(*dnsCacheEntry)
+		if now.Before(entry.expires) {
+			c.lru.MoveToFront(e)
+			c.stats.Hits++
+			if entry.err != nil {
+				c.stats.NegativeHits++
+			}
+			c.mu.Unlock()
+			return entry.ips, entry.err
+		}
+		c.lru.Remove(e)
+		delete(c.entries, host)
+	}
+	l, ok := c.inflight[host]
+	if !ok {
+		c.stats.Misses++
+		l = &dnsLookup{done: make(chan struct{})}
+		c.inflight[host] = l
+		// the lookup is shared, so it's not canceled with the ctx
+		go c.resolve(context.WithoutCancel(ctx), host, resolver, l)
+	}
+	c.mu.Unlock()
+	select {
+	case <-l.done:
+		return l.ips, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *dnsCache) resolve(ctx context.Context, host string, resolver Resolver, l *dnsLookup) {
+	l.ips, l.err = resolver.LookupIPAddr(ctx, host)
+	c.mu.Lock()
+	delete(c.inflight, host)
+	if l.err == nil || isNotFound(l.err) {
+		c.entries[host] = c.lru.PushFront(&dnsCacheEntry{
+			host:    host,
+			ips:     l.ips,
+			err:     l.err,
+			expires: time.Now().Add(c.ttl),
+		})
+		for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*dnsCacheEntry).host)
+			c.stats.Evictions++
+		}
+	}
+	c.mu.Unlock()
+	close(l.done)
+}
+
+func (c *dnsCache) getStats() DnsCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// EnableDnsCache enables the DNS cache, see Client.EnableDnsCache.
+func (t *Transport) EnableDnsCache(ttl time.Duration, maxEntries int) *Transport {
+	t.dnsCache = newDnsCache(ttl, maxEntries)
+	return t
+}
+
+// DisableDnsCache disables the DNS cache (disabled by default).
+func (t *Transport) DisableDnsCache() *Transport {
+	t.dnsCache = nil
+	return t
+}
+
+// DnsCacheStats returns the metrics of the DNS cache, the zero value is
+// returned if the DNS cache is not enabled.
+func (t *Transport) DnsCacheStats() DnsCacheStats {
+	if t.dnsCache == nil {
+		return DnsCacheStats{}
+	}
+	return t.dnsCache.getStats()
+}
+
+// EnableDnsCache enables the DNS cache which memoizes the lookups of hosts
+// inside the dialer for the ttl, so that high-QPS clients don't hammer the
+// resolver on each dial. The "no such host" errors are cached too, and the
+// least recently used entry is evicted if there are more than maxEntries
+// (no limit if it's not positive) entries. Concurrent lookups of the same
+// host are merged into one, and the resolved addresses are dialed in order
+// until one succeeds. It takes no effect if the custom dial function is set
+// (e.g. SetDial, SetSSHTunnel) as the host is resolved by it, and on HTTP3
+// which is over UDP, only the host of the proxy is resolved if the request
+// is sent via the proxy. Use DnsCacheStats to get the metrics. For example:
+//
+//	client.EnableDnsCache(time.Minute, 1000)
+func (c *Client) EnableDnsCache(ttl time.Duration, maxEntries int) *Client {
+	if ttl <= 0 {
+		c.log.Warnf("ignore non-positive ttl %s in EnableDnsCache", ttl)
+		return c
+	}
+	c.Transport.EnableDnsCache(ttl, maxEntries)
+	return c
+}
+
+// DisableDnsCache disables the DNS cache (disabled by default).
+func (c *Client) DisableDnsCache() *Client {
+	c.Transport.DisableDnsCache()
+	return c
+}
@@ -1,9 +1,11 @@
 package req
 
 import (
+	"context"
 	"github.com/imroc/req/v3/internal/dump"
 	"io"
 	"os"
+	"strings"
 )
 
 // DumpOptions controls the dump behavior.
@@ -20,8 +22,60 @@ type DumpOptions struct {
 	ResponseHeader       bool
 	ResponseBody         bool
 	Async                bool
+	// RetryDiff only dumps the request headers which differ from the
+	// previous attempt when retry, and the dump of all attempts are kept
+	// in Response.Dump(). It only takes effect in request-level dump.
+	RetryDiff bool
+	// OnRequestHeader, OnRequestBody, OnResponseHeader and OnResponseBody
+	// are called with the raw wire data of the corresponding part if it's
+	// dumped, which can be used to feed custom sinks (e.g. protobuf logs)
+	// without parsing the text dump, set Output to io.Discard if the text
+	// dump is not needed. They're called synchronously even if Async is
+	// true, and the byte slice must not be retained after the call returns.
+	OnRequestHeader  func(p []byte)
+	OnRequestBody    func(p []byte)
+	OnResponseHeader func(p []byte)
+	OnResponseBody   func(p []byte)
+	// RedactHeaders is the headers (case-insensitive) whose values are
+	// masked as "REDACTED" in the dump (including the data passed to the
+	// hooks above), so that the credentials are not leaked into the logs.
+	// Default is Authorization, Proxy-Authorization, Cookie and Set-Cookie
+	// if it's nil, set it to an empty slice to disable the redaction.
+	RedactHeaders []string
+	// MaxBodySize is the max size of each request or response body to be
+	// dumped, the rest is truncated with a placeholder like
+	// "[1.2MB application/json truncated to 64KB]". Default is 0 (no limit).
+	MaxBodySize int64
+	// OmitBinaryBody omits the bodies whose Content-Type is not textual
+	// (text, json, xml, html, javascript, yaml or urlencoded form) with a
+	// placeholder like "[1.2MB application/octet-stream omitted]", the
+	// bodies without the Content-Type are still dumped.
+	OmitBinaryBody bool
+	// OmitBodyContentTypes is the content types (matched by substring, e.g.
+	// "image/", "protobuf") whose bodies are omitted like OmitBinaryBody.
+	OmitBodyContentTypes []string
+	// AsyncQueueSize is the max number of the pending writes of the async
+	// dump, default is 20, it takes effect when the dump is enabled.
+	AsyncQueueSize int
+	// AsyncDropOnFull drops the dump instead of blocking the request when
+	// the queue of the async dump is full, so that a slow output never
+	// slows down the requests, at the cost of the incomplete dump. The
+	// dropped writes are marked in the output, and counted by
+	// Client.DroppedDumpWrites.
+	AsyncDropOnFull bool
 }
 
+// isDumpText reports whether the body of the content type is dumped as text
+// when OmitBinaryBody is enabled.
+func isDumpText(contentType string) bool {
+	return autoDecodeText(contentType) || strings.Contains(contentType, "form-urlencoded") || strings.Contains(contentType, "yaml")
+}
+
+// redactedValue is the value of the redacted headers.
+const redactedValue = "REDACTED"
+
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
 // Clone return a copy of DumpOptions
 func (do *DumpOptions) Clone() *DumpOptions {
 	if do == nil {
@@ -102,6 +156,60 @@ func (o dumpOptions) Async() bool {
 	return o.DumpOptions.Async
 }
 
+func (o dumpOptions) AsyncQueueSize() int {
+	return o.DumpOptions.AsyncQueueSize
+}
+
+func (o dumpOptions) AsyncDropOnFull() bool {
+	return o.DumpOptions.AsyncDropOnFull
+}
+
+func (o dumpOptions) OnDump(part dump.Part, p []byte) {
+	var hook func(p []byte)
+	switch part {
+	case dump.RequestHeaderPart:
+		hook = o.DumpOptions.OnRequestHeader
+	case dump.RequestBodyPart:
+		hook = o.DumpOptions.OnRequestBody
+	case dump.ResponseHeaderPart:
+		hook = o.DumpOptions.OnResponseHeader
+	case dump.ResponseBodyPart:
+		hook = o.DumpOptions.OnResponseBody
+	}
+	if hook != nil {
+		hook(p)
+	}
+}
+
+func (o dumpOptions) RedactHeader(name string) bool {
+	headers := o.DumpOptions.RedactHeaders
+	if headers == nil {
+		headers = defaultRedactHeaders
+	}
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o dumpOptions) MaxBodySize() int64 {
+	return o.DumpOptions.MaxBodySize
+}
+
+func (o dumpOptions) OmitBody(contentType string) bool {
+	if o.DumpOptions.OmitBinaryBody && !isDumpText(contentType) {
+		return true
+	}
+	for _, ct := range o.DumpOptions.OmitBodyContentTypes {
+		if strings.Contains(contentType, ct) {
+			return true
+		}
+	}
+	return false
+}
+
 func (o dumpOptions) Clone() dump.Options {
 	return dumpOptions{o.DumpOptions.Clone()}
 }
@@ -125,3 +233,40 @@ func newDumper(opt *DumpOptions) *dump.Dumper {
 	}
 	return dump.NewDumper(dumpOptions{opt})
 }
+
+type contextDumpKey struct{}
+
+// ContextWithDump returns a copy of ctx which enables dump for requests sent
+// with it (e.g. via Request.SetContext), so that the dump can be toggled
+// for a single inbound request (e.g. by trace ID) without enabling the
+// global dump. The dump options of the client (see Client.SetCommonDumpOptions)
+// are used if opt is not specified, and the dump is written to stdout by
+// default. For example:
+//
+//	if r.Header.Get("X-Debug") == "1" {
+//		ctx = req.ContextWithDump(ctx)
+//	}
+//	client.R().SetContext(ctx).Get(url)
+func ContextWithDump(ctx context.Context, opt ...*DumpOptions) context.Context {
+	var o *DumpOptions
+	if len(opt) > 0 {
+		o = opt[0]
+	}
+	return context.WithValue(ctx, contextDumpKey{}, o)
+}
+
+func handleContextDump(c *Client, r *Request) error {
+	o, ok := r.Context().Value(contextDumpKey{}).(*DumpOptions)
+	if !ok || r.Context().Value(dump.DumperKey) != nil { // not enabled or already enabled
+		return nil
+	}
+	if o == nil {
+		o = c.dumpOptions
+	}
+	if o == nil {
+		o = newDefaultDumpOptions()
+	}
+	r.SetDumpOptions(o.Clone())
+	r.EnableDump()
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !js || !wasm
+
+package req
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultReopenSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,8 @@
+//go:build js && wasm
+
+package req
+
+import "os"
+
+// SIGHUP is not available on js/wasm.
+var defaultReopenSignals []os.Signal
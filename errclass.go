@@ -0,0 +1,130 @@
+package req
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+)
+
+// ErrorClass is the category of an error which occurs when sending a
+// request, it's used by retry (e.g. SetRetryPolicy) and can be used by
+// metrics so that errors are categorized consistently.
+type ErrorClass int
+
+const (
+	// ErrorClassNone means no error.
+	ErrorClassNone ErrorClass = iota
+	// ErrorClassUnknown means the error can not be classified.
+	ErrorClassUnknown
+	// ErrorClassCanceled means the request is canceled.
+	ErrorClassCanceled
+	// ErrorClassTimeout means timeout occurs, e.g. dial timeout or
+	// the deadline of context is exceeded.
+	ErrorClassTimeout
+	// ErrorClassDNS means failed to resolve the host.
+	ErrorClassDNS
+	// ErrorClassConnRefused means the connection is refused.
+	ErrorClassConnRefused
+	// ErrorClassConnReset means the connection is reset or broken.
+	ErrorClassConnReset
+	// ErrorClassTLS means TLS handshake or certificate verification failed.
+	ErrorClassTLS
+)
+
+var errorClassNames = map[ErrorClass]string{
+	ErrorClassNone:        "none",
+	ErrorClassUnknown:     "unknown",
+	ErrorClassCanceled:    "canceled",
+	ErrorClassTimeout:     "timeout",
+	ErrorClassDNS:         "dns",
+	ErrorClassConnRefused: "conn-refused",
+	ErrorClassConnReset:   "conn-reset",
+	ErrorClassTLS:         "tls",
+}
+
+// String returns the name of the error class, which is also the name used
+// in the "on" field of SetRetryPolicy, e.g. "timeout" and "conn-reset".
+func (c ErrorClass) String() string {
+	if name, ok := errorClassNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func parseErrorClass(name string) (ErrorClass, bool) {
+	for c, n := range errorClassNames {
+		if n == name && c != ErrorClassNone {
+			return c, true
+		}
+	}
+	return ErrorClassUnknown, false
+}
+
+// ErrorClassifier classifies the error which occurs when sending a request,
+// return ErrorClassUnknown to fall back to the default classification.
+type ErrorClassifier func(err error) ErrorClass
+
+// SetErrorClassifier set the ErrorClassifier for requests fired from the
+// client, which can be used to categorize organization-specific errors
+// (e.g. errors from custom dialers or proxies).
+func (c *Client) SetErrorClassifier(classifier ErrorClassifier) *Client {
+	c.errorClassifier = classifier
+	return c
+}
+
+// ClassifyError returns the ErrorClass of the error, the ErrorClassifier
+// set by SetErrorClassifier takes precedence over the default one.
+func (c *Client) ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+	if c.errorClassifier != nil {
+		if class := c.errorClassifier(err); class != ErrorClassUnknown {
+			return class
+		}
+	}
+	return defaultClassifyError(err)
+}
+
+func defaultClassifyError(err error) ErrorClass {
+	var (
+		dnsErr       *net.DNSError
+		netErr       net.Error
+		recordErr    tls.RecordHeaderError
+		certErr      *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.As(err, &dnsErr):
+		return ErrorClassDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case isConnRefused(err):
+		return ErrorClassConnRefused
+	case isConnReset(err), strings.Contains(err.Error(), "connection reset by peer"):
+		return ErrorClassConnReset
+	case errors.As(err, &recordErr), errors.As(err, &certErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return ErrorClassTLS
+	}
+	return ErrorClassUnknown
+}
+
+// ErrorClass returns the ErrorClass of Response.Err, classified by the
+// client of the request.
+func (r *Response) ErrorClass() ErrorClass {
+	if r.Err == nil {
+		return ErrorClassNone
+	}
+	if r.Request == nil || r.Request.client == nil {
+		return defaultClassifyError(r.Err)
+	}
+	return r.Request.client.ClassifyError(r.Err)
+}
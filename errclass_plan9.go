@@ -0,0 +1,15 @@
+//go:build plan9
+
+package req
+
+import "strings"
+
+// plan9 has no errno, the errors are matched by the messages.
+
+func isConnRefused(err error) bool {
+	return strings.Contains(err.Error(), "connection refused")
+}
+
+func isConnReset(err error) bool {
+	return strings.Contains(err.Error(), "broken pipe")
+}
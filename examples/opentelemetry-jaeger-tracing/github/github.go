@@ -97,7 +97,7 @@ func (c *Client) SetTracer(tracer trace.Tracer) {
 			ctx := req.Context()
 			apiName, ok := ctx.Value(apiNameKey).(string)
 			if !ok {
-				apiName = req.URL.Path
+				apiName = req.OperationName()
 			}
 			_, span := tracer.Start(req.Context(), apiName)
 			defer span.End()
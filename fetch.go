@@ -0,0 +1,70 @@
+package req
+
+import (
+	"context"
+	"fmt"
+)
+
+// fetchRetryPolicy is the retry policy used by FetchJSON and FetchBytes.
+const fetchRetryPolicy = "retries=3 backoff=exp base=100ms max=2s on=429,502,503,504,error"
+
+// StatusError is returned by FetchJSON and FetchBytes if the response
+// status code is not 2xx.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Body is the response body, which may be useful for debugging.
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("req: unexpected response status %q", e.Status)
+}
+
+func fetch(ctx context.Context, url string, setReq func(r *Request)) (*Response, error) {
+	r := DefaultClient().R().SetRetryPolicy(fetchRetryPolicy)
+	if ctx != nil {
+		r.SetContext(ctx)
+	}
+	if setReq != nil {
+		setReq(r)
+	}
+	resp, err := r.Get(url)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       resp.Bytes(),
+		}
+	}
+	return resp, nil
+}
+
+// FetchJSON sends a GET request to the url with the default client and
+// unmarshal the JSON response body into out, which is a one-liner with
+// good defaults for scripts. Requests are retried on network errors and
+// 429/502/503/504 with exponential backoff, and a *StatusError is returned
+// if the final response status code is not 2xx.
+func FetchJSON(ctx context.Context, url string, out interface{}) error {
+	resp, err := fetch(ctx, url, func(r *Request) {
+		r.SetHeader("Accept", "application/json")
+	})
+	if err != nil {
+		return err
+	}
+	return resp.UnmarshalJson(out)
+}
+
+// FetchBytes sends a GET request to the url with the default client and
+// returns the response body, see FetchJSON for the retry and status check
+// behavior.
+func FetchBytes(ctx context.Context, url string) ([]byte, error) {
+	resp, err := fetch(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.ToBytes()
+}
@@ -0,0 +1,199 @@
+package req
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/imroc/req/v3/internal/header"
+	utls "github.com/refraction-networking/utls"
+)
+
+// FingerprintRandomizationOptions is the options of
+// Client.RandomizeFingerprint.
+type FingerprintRandomizationOptions struct {
+	// Seed is the seed of the session, the same seed produces the same
+	// fingerprint, default is random.
+	Seed int64
+	// DisableHeaderOrder disables randomizing the header order.
+	DisableHeaderOrder bool
+	// DisableAcceptEncoding disables randomizing the order of the
+	// Accept-Encoding values.
+	DisableAcceptEncoding bool
+	// DisableTLSExtensionOrder disables randomizing the TLS extension order.
+	DisableTLSExtensionOrder bool
+}
+
+// fingerprintNoise randomizes the non-semantic aspects of the requests,
+// which is stable in the session (determined by the seed).
+type fingerprintNoise struct {
+	seed              int64
+	orderHeaders      bool
+	acceptEncoding    bool
+	tlsExtensionOrder bool
+}
+
+// clone returns the noise with the same options and a new seed, so that
+// the cloned client doesn't share the fingerprint of the session.
+func (n *fingerprintNoise) clone() *fingerprintNoise {
+	if n == nil {
+		return nil
+	}
+	nn := *n
+	nn.seed = rand.Int63()
+	return &nn
+}
+
+// rand returns the rand of the session for the key, so that the same key
+// is always randomized in the same way in the session.
+func (n *fingerprintNoise) rand(key string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return rand.New(rand.NewSource(n.seed ^ int64(h.Sum64())))
+}
+
+// perturbHeaderOrder swaps one or two pairs of the adjacent headers in the
+// order, the first header (usually host) is kept.
+func (n *fingerprintNoise) perturbHeaderOrder(order []string) []string {
+	if len(order) < 3 {
+		return order
+	}
+	order = append([]string(nil), order...)
+	r := n.rand(strings.Join(order, ","))
+	for swaps := 1 + r.Intn(2); swaps > 0; swaps-- {
+		i := 1 + r.Intn(len(order)-2)
+		order[i], order[i+1] = order[i+1], order[i]
+	}
+	return order
+}
+
+// headerOrder returns the order of the headers sorted by their random rank
+// in the session.
+func (n *fingerprintNoise) headerOrder(h http.Header) []string {
+	var order []string
+	for k := range h {
+		if !strings.HasPrefix(k, "__") {
+			order = append(order, strings.ToLower(k))
+		}
+	}
+	ranks := make(map[string]int64, len(order))
+	for _, k := range order {
+		ranks[k] = n.rand(k).Int63()
+	}
+	sort.Slice(order, func(i, j int) bool { return ranks[order[i]] < ranks[order[j]] })
+	return order
+}
+
+func (n *fingerprintNoise) shuffleAcceptEncoding(v string) string {
+	values := strings.Split(v, ",")
+	if len(values) < 2 {
+		return v
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	n.rand(v).Shuffle(len(values), func(i, j int) {
+		values[i], values[j] = values[j], values[i]
+	})
+	return strings.Join(values, ", ")
+}
+
+// shuffleTLSExtensions shuffles the extensions except the GREASE, padding
+// and pre_shared_key ones, whose positions matter, like Chrome does.
+func (n *fingerprintNoise) shuffleTLSExtensions(exts []utls.TLSExtension) {
+	fixed := func(ext utls.TLSExtension) bool {
+		switch ext.(type) {
+		case *utls.UtlsGREASEExtension, *utls.UtlsPaddingExtension, utls.PreSharedKeyExtension:
+			return true
+		}
+		return false
+	}
+	n.rand("tls").Shuffle(len(exts), func(i, j int) {
+		if !fixed(exts[i]) && !fixed(exts[j]) {
+			exts[i], exts[j] = exts[j], exts[i]
+		}
+	})
+}
+
+// apply randomizes the header order and Accept-Encoding of the request, the
+// header is copied if it's changed.
+func (n *fingerprintNoise) apply(req *http.Request) *http.Request {
+	if req.Header == nil || (!n.orderHeaders && !n.acceptEncoding) {
+		return req
+	}
+	h := req.Header.Clone()
+	changed := false
+	if n.orderHeaders {
+		if order := h[header.HeaderOderKey]; len(order) > 0 {
+			h[header.HeaderOderKey] = n.perturbHeaderOrder(order)
+		} else {
+			h[header.HeaderOderKey] = n.headerOrder(h)
+		}
+		changed = true
+	}
+	if n.acceptEncoding {
+		if v := h.Get("Accept-Encoding"); v != "" {
+			if shuffled := n.shuffleAcceptEncoding(v); shuffled != v {
+				h.Set("Accept-Encoding", shuffled)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return req
+	}
+	r := *req
+	r.Header = h
+	return &r
+}
+
+// newUTLSConn creates the uTLS conn with the fingerprint, whose extensions
+// are shuffled if the TLS extension order is randomized.
+func (t *Transport) newUTLSConn(plainConn net.Conn, config *utls.Config, clientHelloID utls.ClientHelloID) (*uTLSConn, error) {
+	n := t.fingerprintNoise
+	if n == nil || !n.tlsExtensionOrder {
+		return &uTLSConn{utls.UClient(plainConn, config, clientHelloID)}, nil
+	}
+	spec, err := utls.UTLSIdToSpec(clientHelloID)
+	if err != nil {
+		return nil, err
+	}
+	n.shuffleTLSExtensions(spec.Extensions)
+	uconn := utls.UClient(plainConn, config, utls.HelloCustom)
+	if err = uconn.ApplyPreset(&spec); err != nil {
+		return nil, err
+	}
+	return &uTLSConn{uconn}, nil
+}
+
+// RandomizeFingerprint slightly randomizes the non-semantic aspects of the
+// requests fired from the client, so that the sessions (clients) don't
+// share the same fingerprint: the header order (one or two pairs of the
+// adjacent headers are swapped if the order is set, e.g. by
+// ImpersonateChrome, otherwise the headers are randomly ordered), the order
+// of the Accept-Encoding values, and the TLS extension order if the TLS
+// fingerprint is set by SetTLSFingerprint (or the Impersonate methods). The
+// randomization is stable in the session, i.e. all requests of the client
+// look the same, and the client created by Clone gets a new seed. For
+// example:
+//
+//	client := req.C().ImpersonateChrome().RandomizeFingerprint()
+func (c *Client) RandomizeFingerprint(opts ...*FingerprintRandomizationOptions) *Client {
+	var o FingerprintRandomizationOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.Seed == 0 {
+		o.Seed = rand.Int63()
+	}
+	c.Transport.fingerprintNoise = &fingerprintNoise{
+		seed:              o.Seed,
+		orderHeaders:      !o.DisableHeaderOrder,
+		acceptEncoding:    !o.DisableAcceptEncoding,
+		tlsExtensionOrder: !o.DisableTLSExtensionOrder,
+	}
+	return c
+}
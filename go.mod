@@ -3,24 +3,24 @@ module github.com/imroc/req/v3
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/hashicorp/go-multierror v1.1.1
+	github.com/klauspost/compress v1.17.8
 	github.com/quic-go/qpack v0.4.0
 	github.com/quic-go/quic-go v0.41.0
 	github.com/refraction-networking/utls v1.6.3
+	golang.org/x/crypto v0.21.0
 	golang.org/x/net v0.22.0
 	golang.org/x/text v0.14.0
 )
 
 require (
-	github.com/andybalholm/brotli v1.1.0 // indirect
 	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
 	github.com/google/pprof v0.0.0-20240227163752-401108e1b7e7 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
-	github.com/klauspost/compress v1.17.8 // indirect
 	github.com/onsi/ginkgo/v2 v2.16.0 // indirect
 	go.uber.org/mock v0.4.0 // indirect
-	golang.org/x/crypto v0.21.0 // indirect
 	golang.org/x/exp v0.0.0-20240222234643-814bf88cf225 // indirect
 	golang.org/x/mod v0.16.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
@@ -0,0 +1,402 @@
+package req
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imroc/req/v3/internal/header"
+)
+
+// HAR is the HTTP Archive (HAR 1.2), which can be read by the browsers'
+// DevTools and Fiddler, see http://www.softwareishard.com/blog/har-12-spec.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of the exported data of HAR.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator is the application which creates the HAR.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is an exported request and its response, the Time is the total
+// elapsed time of the request in milliseconds.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest is the request of HAREntry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is the response of HAREntry.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is the header or query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie is the cookie of the request or response.
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// HARPostData is the body of the request.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is "base64" if the body is not UTF-8 text.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARContent is the body of the response.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	// Encoding is "base64" if the body is not UTF-8 text.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings is the durations in milliseconds of the request phases, -1
+// if the phase does not apply (e.g. dns and connect of the reused
+// connection).
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Body returns the body of the request, which is decoded if it's base64
+// encoded.
+func (p *HARPostData) Body() ([]byte, error) {
+	return decodeHARText(p.Text, p.Encoding)
+}
+
+// Body returns the body of the response, which is decoded if it's base64
+// encoded.
+func (c *HARContent) Body() ([]byte, error) {
+	return decodeHARText(c.Text, c.Encoding)
+}
+
+func decodeHARText(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+func encodeHARText(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// harRedactHeader reports whether the header value should be masked in the
+// HAR, which follows the RedactHeaders of the client's dump options.
+func harRedactHeader(opt *DumpOptions) func(name string) bool {
+	if opt == nil {
+		opt = &DumpOptions{}
+	}
+	return dumpOptions{opt}.RedactHeader
+}
+
+func harHeaders(h http.Header, redact func(name string) bool) []HARNameValue {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	nvs := []HARNameValue{}
+	for _, k := range keys {
+		vs := h[k]
+		if strings.HasPrefix(k, "__") { // e.g. the header order
+			continue
+		}
+		for _, v := range vs {
+			if redact(k) {
+				v = redactedValue
+			}
+			nvs = append(nvs, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return nvs
+}
+
+func harCookies(cookies []*http.Cookie, redact bool) []HARCookie {
+	hcs := []HARCookie{}
+	for _, c := range cookies {
+		hc := HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if redact {
+			hc.Value = redactedValue
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			hc.Expires = &expires
+		}
+		hcs = append(hcs, hc)
+	}
+	return hcs
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harTimings converts the trace info to the timings.
+func harTimings(ti TraceInfo, total time.Duration) HARTimings {
+	t := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if ti.RemoteAddr == nil { // no trace or connection
+		t.Wait = milliseconds(total)
+		return t
+	}
+	if !ti.IsConnReused {
+		t.DNS = milliseconds(ti.DNSLookupTime)
+		// connect includes ssl in HAR
+		t.Connect = milliseconds(ti.TCPConnectTime + ti.TLSHandshakeTime)
+		if ti.TLSHandshakeTime > 0 {
+			t.SSL = milliseconds(ti.TLSHandshakeTime)
+		}
+	}
+	t.Wait = milliseconds(ti.FirstResponseTime)
+	t.Receive = milliseconds(ti.ResponseTime)
+	return t
+}
+
+func newHAREntry(r *Request, resp *Response) *HAREntry {
+	e := &HAREntry{StartedDateTime: r.StartTime}
+	redact := harRedactHeader(r.client.dumpOptions)
+	total := time.Since(r.StartTime)
+	var ti TraceInfo
+	if r.trace != nil {
+		ti = r.TraceInfo()
+		if ti.TotalTime > 0 {
+			total = ti.TotalTime
+		}
+		if ti.RemoteAddr != nil {
+			e.ServerIPAddress = ti.RemoteAddr.String()
+			if host, _, err := net.SplitHostPort(e.ServerIPAddress); err == nil {
+				e.ServerIPAddress = host
+			}
+		}
+	}
+	e.Timings = harTimings(ti, total)
+	for _, t := range []float64{e.Timings.Blocked, e.Timings.DNS, e.Timings.Connect, e.Timings.Send, e.Timings.Wait, e.Timings.Receive} {
+		if t > 0 {
+			e.Time += t
+		}
+	}
+
+	e.Request = HARRequest{
+		Method:      r.Method,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARCookie{},
+		Headers:     []HARNameValue{},
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(r.Body)),
+	}
+	if r.URL != nil {
+		e.Request.URL = r.URL.String()
+		for k, vs := range r.URL.Query() {
+			for _, v := range vs {
+				e.Request.QueryString = append(e.Request.QueryString, HARNameValue{Name: k, Value: v})
+			}
+		}
+	}
+	if hr := r.RawRequest; hr != nil {
+		e.Request.Headers = harHeaders(hr.Header, redact)
+		e.Request.Cookies = harCookies(hr.Cookies(), redact("Cookie"))
+		if hr.ContentLength > 0 {
+			e.Request.BodySize = hr.ContentLength
+		}
+	}
+	if len(r.Body) > 0 {
+		text, encoding := encodeHARText(r.Body)
+		e.Request.PostData = &HARPostData{
+			MimeType: r.getHeader(header.ContentType),
+			Text:     text,
+			Encoding: encoding,
+		}
+	}
+
+	e.Response = HARResponse{
+		Cookies:     []HARCookie{},
+		Headers:     []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if resp.Err != nil {
+		e.Comment = resp.Err.Error()
+	}
+	if hr := resp.Response; hr != nil {
+		e.Request.HTTPVersion = hr.Proto
+		e.Response.Status = hr.StatusCode
+		e.Response.StatusText = http.StatusText(hr.StatusCode)
+		e.Response.HTTPVersion = hr.Proto
+		e.Response.Headers = harHeaders(hr.Header, redact)
+		e.Response.Cookies = harCookies(hr.Cookies(), redact("Set-Cookie"))
+		e.Response.RedirectURL = hr.Header.Get("Location")
+		e.Response.Content.MimeType = hr.Header.Get(header.ContentType)
+		e.Response.Content.Size = hr.ContentLength
+		if resp.body != nil {
+			e.Response.Content.Size = int64(len(resp.body))
+			e.Response.BodySize = e.Response.Content.Size
+			e.Response.Content.Text, e.Response.Content.Encoding = encodeHARText(resp.body)
+		}
+	}
+	return e
+}
+
+// harLogger writes the HAR to the writer as the requests are done, the
+// entries are streamed, and the HAR is completed once it's closed.
+type harLogger struct {
+	mu      sync.Mutex
+	w       io.Writer
+	entries int
+	closed  bool
+	closer  io.Closer
+}
+
+const harLogHeader = `{"log":{"version":"1.2","creator":{"name":"req","version":"v3"},"entries":[`
+
+func (l *harLogger) record(r *Request, resp *Response) error {
+	b, err := json.Marshal(newHAREntry(r, resp))
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed { // the requests in flight when the HAR log is disabled
+		return nil
+	}
+	if l.entries == 0 {
+		b = append([]byte(harLogHeader), b...)
+	} else {
+		b = append([]byte{','}, b...)
+	}
+	l.entries++
+	_, err = l.w.Write(b)
+	return err
+}
+
+func (l *harLogger) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	trailer := "]}}\n"
+	if l.entries == 0 {
+		trailer = harLogHeader + trailer
+	}
+	_, err := io.WriteString(l.w, trailer)
+	if l.closer != nil {
+		if cerr := l.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// EnableHARLog records the requests fired from the client and their
+// responses (headers, bodies and timings) as the HAR (HTTP Archive 1.2)
+// written to w, which can be opened by the browsers' DevTools and Fiddler,
+// and is more useful than the dump for sharing repro cases. Trace is
+// enabled for the requests to collect the timings, each attempt is an
+// entry, and the bodies are recorded only if they are bytes (e.g. set by
+// SetBody with string or bytes) or read automatically. The values of the
+// sensitive headers and cookies are masked as the dump does, see
+// DumpOptions.RedactHeaders.
+//
+// The entries are written as the requests are done, and the HAR is
+// completed (valid JSON) once DisableHARLog is called. For example:
+//
+//	f, _ := os.Create("repro.har")
+//	client.EnableHARLog(f)
+//	...
+//	client.DisableHARLog()
+//	f.Close()
+func (c *Client) EnableHARLog(w io.Writer) *Client {
+	if w == nil {
+		c.log.Warnf("ignore nil writer in EnableHARLog")
+		return c
+	}
+	c.DisableHARLog()
+	c.harLog = &harLogger{w: w}
+	return c
+}
+
+// DisableHARLog disables the HAR log enabled by EnableHARLog, and completes
+// the HAR written to the writer.
+func (c *Client) DisableHARLog() *Client {
+	if c.harLog != nil {
+		if err := c.harLog.close(); err != nil {
+			c.log.Errorf("failed to write har log: %v", err)
+		}
+		c.harLog = nil
+	}
+	return c
+}
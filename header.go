@@ -1,6 +1,7 @@
 package req
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"net/textproto"
@@ -118,3 +119,144 @@ func headerWriteSubset(h http.Header, exclude map[string]bool, writeHeader func(
 	}
 	return nil
 }
+
+// RequestHeaderLimitError is returned when the header of the request
+// exceeds the limits set by Client.SetRequestHeaderLimits, the request is
+// not sent.
+type RequestHeaderLimitError struct {
+	// Bytes is the total size of the header lines in bytes.
+	Bytes int
+	// Count is the number of the header lines.
+	Count int
+	// MaxBytes and MaxCount are the limits, 0 means no limit.
+	MaxBytes int
+	MaxCount int
+}
+
+func (e *RequestHeaderLimitError) Error() string {
+	if e.MaxCount > 0 && e.Count > e.MaxCount {
+		return fmt.Sprintf("req: request header count %d exceeds the limit %d", e.Count, e.MaxCount)
+	}
+	return fmt.Sprintf("req: request header size %d bytes exceeds the limit %d", e.Bytes, e.MaxBytes)
+}
+
+// checkRequestHeaderLimits checks the size and count of the header lines
+// (including Host and Cookie) as they are written in HTTP/1.1, the cookies
+// of the jar which are added to the Cookie header when the request is sent
+// are counted as well.
+func checkRequestHeaderLimits(req *http.Request, jar http.CookieJar, maxBytes, maxCount int) error {
+	if maxBytes <= 0 && maxCount <= 0 {
+		return nil
+	}
+	host := req.Host
+	if host == "" && req.URL != nil {
+		host = req.URL.Host
+	}
+	size := len("Host: \r\n") + len(host)
+	count := 1
+	for k, vv := range req.Header {
+		for _, v := range vv {
+			size += len(k) + len(v) + len(": \r\n")
+			count++
+		}
+	}
+	if jar != nil && req.URL != nil {
+		hasCookie := req.Header.Get("Cookie") != ""
+		for _, c := range jar.Cookies(req.URL) {
+			if hasCookie {
+				size += len("; ")
+			} else {
+				size += len("Cookie: \r\n")
+				count++
+				hasCookie = true
+			}
+			size += len(c.Name) + len("=") + len(c.Value)
+		}
+	}
+	if (maxBytes > 0 && size > maxBytes) || (maxCount > 0 && count > maxCount) {
+		return &RequestHeaderLimitError{
+			Bytes:    size,
+			Count:    count,
+			MaxBytes: maxBytes,
+			MaxCount: maxCount,
+		}
+	}
+	return nil
+}
+
+// commonHeaderKeys maps the lower-case keys of the common headers to the
+// canonical ones, so that the keys set in lower case (e.g. "content-type")
+// are canonicalized without allocation on the hot path.
+var commonHeaderKeys = func() map[string]string {
+	keys := []string{
+		"Accept", "Accept-Charset", "Accept-Encoding", "Accept-Language",
+		"Authorization", "Cache-Control", "Connection", "Content-Encoding",
+		"Content-Length", "Content-Type", "Cookie", "Host", "If-Match",
+		"If-Modified-Since", "If-None-Match", "Origin", "Pragma",
+		"Proxy-Authorization", "Range", "Referer", "User-Agent",
+		"X-Api-Key", "X-Forwarded-For", "X-Request-Id", "X-Requested-With",
+	}
+	m := make(map[string]string, len(keys))
+	for _, k := range keys {
+		m[strings.ToLower(k)] = k
+	}
+	return m
+}()
+
+// canonicalHeaderKey is like http.CanonicalHeaderKey, but the lower-case
+// keys of the common headers are looked up in commonHeaderKeys.
+func canonicalHeaderKey(key string) string {
+	if k, ok := commonHeaderKeys[key]; ok {
+		return k
+	}
+	return http.CanonicalHeaderKey(key)
+}
+
+// cloneHeader is like http.Header.Clone, but the clone has the room for
+// extra headers, so that the map is not grown when the headers are added
+// after cloning (e.g. Cookie and the headers of the signers).
+func cloneHeader(h http.Header, extra int) http.Header {
+	if h == nil {
+		return nil
+	}
+	nv := 0
+	for _, vv := range h {
+		nv += len(vv)
+	}
+	sv := make([]string, nv) // shared backing array for the values
+	h2 := make(http.Header, len(h)+extra)
+	for k, vv := range h {
+		if vv == nil {
+			h2[k] = nil
+			continue
+		}
+		n := copy(sv, vv)
+		h2[k] = sv[:n:n]
+		sv = sv[n:]
+	}
+	return h2
+}
+
+// addCookies is the same as calling req.AddCookie for each cookie, but the
+// Cookie header is built at once instead of being rebuilt for each cookie.
+func addCookies(req *http.Request, cookies []*http.Cookie) {
+	if len(cookies) < 2 {
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return
+	}
+	var b strings.Builder
+	b.WriteString(req.Header.Get("Cookie"))
+	// the cookie is sanitized by AddCookie of the scratch request.
+	scratch := &http.Request{Header: make(http.Header, 1)}
+	for _, cookie := range cookies {
+		scratch.AddCookie(cookie)
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(scratch.Header.Get("Cookie"))
+		delete(scratch.Header, "Cookie")
+	}
+	req.Header.Set("Cookie", b.String())
+}
@@ -0,0 +1,120 @@
+package req
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// headerTagName is the struct tag which maps the response header to the
+// field of the result, e.g. `reqHeader:"ETag"`.
+const headerTagName = "reqHeader"
+
+type headerField struct {
+	header string
+	index  []int
+}
+
+// headerFieldsCache caches the []headerField of struct types.
+var headerFieldsCache sync.Map
+
+func headerFields(t reflect.Type) []headerField {
+	if fields, ok := headerFieldsCache.Load(t); ok {
+		return fields.([]headerField)
+	}
+	var fields []headerField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		if name, ok := f.Tag.Lookup(headerTagName); ok && name != "" && name != "-" {
+			fields = append(fields, headerField{header: name, index: f.Index})
+		}
+	}
+	headerFieldsCache.Store(t, fields)
+	return fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setHeaderField(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		v.Set(reflect.ValueOf(append([]string(nil), values...)).Convert(v.Type()))
+		return nil
+	}
+	value := values[0]
+	if v.Type() == timeType {
+		t, err := http.ParseTime(value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// mapResponseHeaders sets the fields of the result struct tagged with
+// `reqHeader:"Name"` to the values of the response header, the fields of
+// the absent headers are left untouched. The string, []string, bool,
+// integer, float and time.Time (in the HTTP date format) fields are
+// supported.
+func mapResponseHeaders(result interface{}, header http.Header) error {
+	v := reflect.ValueOf(result)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for _, f := range headerFields(v.Type()) {
+		values := header.Values(f.header)
+		if len(values) == 0 {
+			continue
+		}
+		field, err := v.FieldByIndexErr(f.index)
+		if err != nil { // nil embedded pointer
+			continue
+		}
+		if err = setHeaderField(field, values); err != nil {
+			return fmt.Errorf("failed to map response header %s to field %s: %w", f.header, v.Type().FieldByIndex(f.index).Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package req
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultHealthCheckJitter = 0.1
+
+// HealthStatus is the result of the health check, see Client.HealthCheck.
+type HealthStatus struct {
+	// URL is the probed url.
+	URL string
+	// Healthy is whether the endpoint is healthy, which is false until the
+	// first probe succeeds.
+	Healthy bool
+	// StatusCode is the status code of the last probe, 0 if it fails.
+	StatusCode int
+	// Latency is the latency of the last probe.
+	Latency time.Duration
+	// Err is the error of the last probe.
+	Err error
+	// CheckedAt is when the last probe is done, zero if it's not done yet.
+	CheckedAt time.Time
+	// ConsecutiveFailures is the number of the consecutive failed probes.
+	ConsecutiveFailures int
+}
+
+// HealthCheckOptions is the options of Client.HealthCheck.
+type HealthCheckOptions struct {
+	// Jitter is the fraction of the interval which is randomly added to or
+	// subtracted from each interval, so that the probes of many clients
+	// don't hit the endpoint at the same time, default is 0.1, and the
+	// negative value disables it.
+	Jitter float64
+	// Timeout is the timeout of each probe, default is the interval.
+	Timeout time.Duration
+	// FailureThreshold is the number of the consecutive failed probes
+	// after which the endpoint is unhealthy, default is 1.
+	FailureThreshold int
+	// IsHealthy reports whether the probe succeeds, default is no error
+	// and the status code is 2xx.
+	IsHealthy func(resp *Response, err error) bool
+}
+
+// HealthChecker probes the endpoint periodically, which is created by
+// Client.HealthCheck.
+type HealthChecker struct {
+	client   *Client
+	url      string
+	interval time.Duration
+	opts     HealthCheckOptions
+	callback func(status HealthStatus)
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu     sync.Mutex
+	status HealthStatus
+}
+
+// Status returns the current status of the endpoint.
+func (h *HealthChecker) Status() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// Stop stops the health check, it waits until the running probe is done.
+func (h *HealthChecker) Stop() {
+	h.cancel()
+	<-h.done
+}
+
+func (h *HealthChecker) nextInterval() time.Duration {
+	if h.opts.Jitter <= 0 {
+		return h.interval
+	}
+	jitter := float64(h.interval) * h.opts.Jitter * (2*rand.Float64() - 1)
+	return h.interval + time.Duration(jitter)
+}
+
+func (h *HealthChecker) run(ctx context.Context) {
+	defer close(h.done)
+	for {
+		h.probe(ctx)
+		timer := time.NewTimer(h.nextInterval())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (h *HealthChecker) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+	start := h.client.now()
+	r := h.client.R().SetContext(ctx)
+	r.healthProbe = true
+	resp, err := r.Get(h.url)
+	if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+		return // stopped
+	}
+	now := h.client.now()
+	healthy := h.opts.IsHealthy(resp, err)
+
+	h.mu.Lock()
+	wasHealthy, first := h.status.Healthy, h.status.CheckedAt.IsZero()
+	h.status.Latency = now.Sub(start)
+	h.status.CheckedAt = now
+	h.status.Err = err
+	h.status.StatusCode = 0
+	if resp.Response != nil {
+		h.status.StatusCode = resp.StatusCode
+	}
+	if healthy {
+		h.status.ConsecutiveFailures = 0
+		h.status.Healthy = true
+	} else {
+		h.status.ConsecutiveFailures++
+		if h.status.ConsecutiveFailures >= h.opts.FailureThreshold {
+			h.status.Healthy = false
+		}
+	}
+	status := h.status
+	h.mu.Unlock()
+
+	if (status.Healthy != wasHealthy || first) && r.URL != nil {
+		if tracker := h.client.getSLATracker(r.URL.Host); tracker != nil {
+			tracker.setHealthy(status.Healthy, now)
+		}
+	}
+	if h.callback != nil {
+		h.callback(status)
+	}
+}
+
+func defaultIsHealthy(resp *Response, err error) bool {
+	return err == nil && resp.StatusCode >= http.StatusOK && resp.StatusCode <= 299
+}
+
+// HealthCheck probes the url with the client (so that its settings, e.g.
+// the auth, proxy and TLS are used) immediately and then every interval
+// (randomly jittered) in the background until the returned HealthChecker
+// is stopped, the callback (can be nil) is called with the status after
+// each probe, and the current status can be got by HealthChecker.Status.
+// The circuit of the host set by SetSLA is opened when the endpoint becomes
+// unhealthy and closed when it recovers. For example:
+//
+//	checker := client.HealthCheck("https://api.example.com/healthz", 10*time.Second, func(status req.HealthStatus) {
+//		if !status.Healthy {
+//			log.Printf("api is unhealthy: %v", status.Err)
+//		}
+//	})
+//	defer checker.Stop()
+func (c *Client) HealthCheck(url string, interval time.Duration, callback func(status HealthStatus), opts ...*HealthCheckOptions) *HealthChecker {
+	h := &HealthChecker{
+		client:   c,
+		url:      url,
+		interval: interval,
+		callback: callback,
+		done:     make(chan struct{}),
+		status:   HealthStatus{URL: url},
+	}
+	if len(opts) > 0 && opts[0] != nil {
+		h.opts = *opts[0]
+	}
+	if h.interval <= 0 {
+		c.log.Warnf("non-positive health check interval %v, use 10s instead", interval)
+		h.interval = 10 * time.Second
+	}
+	if h.opts.Jitter == 0 {
+		h.opts.Jitter = defaultHealthCheckJitter
+	}
+	if h.opts.Timeout <= 0 {
+		h.opts.Timeout = h.interval
+	}
+	if h.opts.FailureThreshold <= 0 {
+		h.opts.FailureThreshold = 1
+	}
+	if h.opts.IsHealthy == nil {
+		h.opts.IsHealthy = defaultIsHealthy
+	}
+	var ctx context.Context
+	ctx, h.cancel = context.WithCancel(context.Background())
+	go h.run(ctx)
+	return h
+}
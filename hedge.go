@@ -0,0 +1,182 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/imroc/req/v3/internal/dump"
+)
+
+// EnableHedging enables hedged requests, a duplicate request is fired if
+// no successful response (no error and status code < 500) is received
+// after delay, up to maxExtra duplicate requests. The first successful
+// response is returned and the rest requests are canceled via context.
+// Only the winning attempt is reported by dump and trace.
+//
+// It's useful for tail-latency-sensitive APIs, and should only be used for
+// idempotent requests. It's ignored if the request body is not replayable
+// (e.g. set by an io.Reader).
+func (r *Request) EnableHedging(delay time.Duration, maxExtra int) *Request {
+	r.hedgeDelay = delay
+	r.hedgeMaxExtra = maxExtra
+	return r
+}
+
+// DisableHedging disables hedged requests (disabled by default).
+func (r *Request) DisableHedging() *Request {
+	r.hedgeMaxExtra = 0
+	return r
+}
+
+func (r *Request) shouldHedge() bool {
+	return r.hedgeMaxExtra > 0 &&
+		(r.RawRequest.Body == nil || r.RawRequest.Body == http.NoBody || r.RawRequest.GetBody != nil)
+}
+
+type hedgeAttempt struct {
+	req       *http.Request
+	trace     *clientTrace
+	connReuse *connReuseTracker
+	proxyPick *proxyPoolPick
+	deferred  *dump.Deferred
+	cancel    context.CancelFunc
+	resp      *http.Response
+	err       error
+}
+
+func (a *hedgeAttempt) succeeded() bool {
+	return a.err == nil && a.resp.StatusCode < 500
+}
+
+func (a *hedgeAttempt) discard() {
+	a.cancel()
+	a.deferred.Discard()
+	if a.resp != nil {
+		a.resp.Body.Close()
+	}
+}
+
+// newHedgeAttempt creates the attempt of the hedged request, the first one
+// sends the RawRequest, the others derive from the context of the
+// RawRequest with their own trace, conn reuse tracker and proxy pick.
+func (c *Client) newHedgeAttempt(r *Request, first bool) (*hedgeAttempt, error) {
+	a := &hedgeAttempt{deferred: new(dump.Deferred)}
+	var ctx context.Context
+	if first {
+		ctx = r.RawRequest.Context()
+	} else {
+		ctx = r.hedgeCtx
+		if c.proxyPool != nil {
+			a.proxyPick = &proxyPoolPick{}
+			ctx = context.WithValue(ctx, proxyPoolPickKey{}, a.proxyPick)
+		}
+		a.connReuse = &connReuseTracker{}
+		ctx = a.connReuse.withContext(ctx)
+		if r.trace != nil {
+			a.trace = &clientTrace{}
+			ctx = a.trace.createContext(ctx)
+		}
+	}
+	ctx, a.cancel = context.WithCancel(ctx)
+	ctx = context.WithValue(ctx, dump.DeferredKey, a.deferred)
+	if first {
+		a.req = r.RawRequest.WithContext(ctx)
+		return a, nil
+	}
+	a.req = r.RawRequest.Clone(ctx)
+	if r.RawRequest.GetBody != nil {
+		body, err := r.RawRequest.GetBody()
+		if err != nil {
+			a.cancel()
+			return nil, err
+		}
+		a.req.Body = body
+	}
+	return a, nil
+}
+
+// doHedged sends the request with hedging, the winning attempt becomes the
+// RawRequest of the request.
+func (c *Client) doHedged(r *Request, resp *Response) (*http.Response, error) {
+	results := make(chan *hedgeAttempt, r.hedgeMaxExtra+1)
+	var attempts []*hedgeAttempt
+	pending := 0
+	launch := func() error {
+		a, err := c.newHedgeAttempt(r, len(attempts) == 0)
+		if err != nil {
+			return err
+		}
+		attempts = append(attempts, a)
+		pending++
+		go func() {
+			a.resp, a.err = c.httpClient.Do(a.req)
+			results <- a
+		}()
+		return nil
+	}
+	canLaunch := func() bool {
+		return len(attempts) <= r.hedgeMaxExtra
+	}
+	if err := launch(); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(r.hedgeDelay)
+	defer timer.Stop()
+
+	var winner *hedgeAttempt
+loop:
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if canLaunch() && launch() == nil && canLaunch() {
+				timer.Reset(r.hedgeDelay)
+			}
+		case a := <-results:
+			pending--
+			if winner != nil {
+				winner.discard()
+			}
+			winner = a
+			if a.succeeded() {
+				break loop
+			}
+			// fire the next one immediately instead of waiting for the delay
+			if pending == 0 && canLaunch() {
+				launch()
+			}
+		}
+	}
+
+	// report the winning attempt (or the last failed one) and cancel the rest
+	winner.deferred.Commit()
+	if winner.trace != nil {
+		r.trace = winner.trace
+	}
+	if winner.connReuse != nil {
+		resp.connReuse = winner.connReuse
+	}
+	if winner.proxyPick != nil {
+		if pick, ok := r.RawRequest.Context().Value(proxyPoolPickKey{}).(*proxyPoolPick); ok {
+			*pick = *winner.proxyPick
+		}
+	}
+	r.RawRequest = winner.req
+	for _, a := range attempts {
+		if a != winner {
+			a.cancel()
+			a.deferred.Discard()
+		}
+	}
+	go func() {
+		for i := 0; i < pending; i++ {
+			(<-results).discard()
+		}
+	}()
+	if winner.resp != nil && winner.resp.Body != nil {
+		winner.resp.Body = &cancelBody{ReadCloser: winner.resp.Body, cancel: winner.cancel}
+	} else {
+		winner.cancel()
+	}
+	return winner.resp, winner.err
+}
@@ -0,0 +1,225 @@
+package req
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	urlpkg "net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// HostRule holds the settings which only apply to requests whose host
+// matches the pattern, it's created by Transport.HostRule (or Client.HostRule).
+type HostRule struct {
+	pattern string
+	headers http.Header
+	certs   []tls.Certificate
+	rootCAs *x509.CertPool
+	proxy   func(*http.Request) (*urlpkg.URL, error)
+	timeout time.Duration
+}
+
+// Pattern returns the host pattern of the rule.
+func (h *HostRule) Pattern() string {
+	return h.pattern
+}
+
+// Match reports whether the host (port is ignored) matches the pattern
+// of the rule. The leading "*." matches one or more labels, e.g.
+// "*.example.com" matches "a.example.com" and "a.b.example.com" but not
+// "example.com", and the other wildcards follow path.Match.
+func (h *HostRule) Match(host string) bool {
+	host = strings.ToLower(removeHostPort(host))
+	if host == h.pattern {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(h.pattern, "*"); ok && strings.HasPrefix(suffix, ".") && !strings.ContainsAny(suffix, "*?[\\") {
+		return strings.HasSuffix(host, suffix)
+	}
+	matched, _ := path.Match(h.pattern, host)
+	return matched
+}
+
+// SetHeader set a header for requests to the matched hosts, it will not
+// override the header which is already set at client-level or request-level.
+func (h *HostRule) SetHeader(key, value string) *HostRule {
+	if h.headers == nil {
+		h.headers = make(http.Header)
+	}
+	h.headers.Set(key, value)
+	return h
+}
+
+// SetHeaders set headers from a map for requests to the matched hosts.
+func (h *HostRule) SetHeaders(hdrs map[string]string) *HostRule {
+	for k, v := range hdrs {
+		h.SetHeader(k, v)
+	}
+	return h
+}
+
+// SetCerts set client certificates used in TLS handshake with the matched hosts.
+func (h *HostRule) SetCerts(certs ...tls.Certificate) *HostRule {
+	h.certs = append(h.certs, certs...)
+	return h
+}
+
+// SetRootCAs set the root certificate pool used to verify the certificates
+// of the matched hosts.
+func (h *HostRule) SetRootCAs(pool *x509.CertPool) *HostRule {
+	h.rootCAs = pool
+	return h
+}
+
+// SetProxy set the proxy function for requests to the matched hosts, which
+// takes precedence over the proxy of the client. Note the proxy is not used
+// by http3 requests, which never go through a proxy.
+func (h *HostRule) SetProxy(proxy func(*http.Request) (*urlpkg.URL, error)) *HostRule {
+	h.proxy = proxy
+	return h
+}
+
+// SetProxyURL set the proxy url for requests to the matched hosts. Requests
+// to the matched hosts will fail if the proxy url is invalid.
+func (h *HostRule) SetProxyURL(proxyUrl string) *HostRule {
+	u, err := urlpkg.Parse(proxyUrl)
+	if err != nil {
+		return h.SetProxy(func(*http.Request) (*urlpkg.URL, error) {
+			return nil, err
+		})
+	}
+	return h.SetProxy(http.ProxyURL(u))
+}
+
+// DisableProxy bypass the proxy of the client for requests to the matched hosts.
+func (h *HostRule) DisableProxy() *HostRule {
+	return h.SetProxy(func(*http.Request) (*urlpkg.URL, error) {
+		return nil, nil
+	})
+}
+
+// SetTimeout set the timeout for requests to the matched hosts, which
+// covers the whole round trip including reading the response body.
+func (h *HostRule) SetTimeout(d time.Duration) *HostRule {
+	h.timeout = d
+	return h
+}
+
+func (h *HostRule) tlsConfig(cfg *tls.Config) {
+	if len(h.certs) > 0 {
+		cfg.Certificates = h.certs
+	}
+	if h.rootCAs != nil {
+		cfg.RootCAs = h.rootCAs
+	}
+}
+
+func (h *HostRule) clone() *HostRule {
+	hh := *h
+	hh.headers = h.headers.Clone()
+	hh.certs = cloneSlice(h.certs)
+	return &hh
+}
+
+// HostRule returns the rule of the host pattern, which is created if not
+// exists. The pattern can be an exact host (e.g. "api.example.com") or a
+// wildcard pattern (e.g. "*.internal.corp", see HostRule.Match), the first
+// added rule wins if multiple rules match the same host.
+// For example:
+//
+//	client.HostRule("*.internal.corp").
+//		SetHeader("X-Internal-Token", token).
+//		SetCerts(cert).
+//		SetProxyURL("http://proxy.internal.corp:8080").
+//		SetTimeout(5 * time.Second)
+func (t *Transport) HostRule(pattern string) *HostRule {
+	pattern = strings.ToLower(pattern)
+	for _, rule := range t.hostRules {
+		if rule.pattern == pattern {
+			return rule
+		}
+	}
+	rule := &HostRule{pattern: pattern}
+	t.hostRules = append(t.hostRules, rule)
+	return rule
+}
+
+func (t *Transport) matchHostRule(host string) *HostRule {
+	for _, rule := range t.hostRules {
+		if rule.Match(host) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// hostRuleTLSConfig returns the TLS configuration with the certificates of
+// the matched host rule applied, it's used to dial the http3 connections.
+func (t *Transport) hostRuleTLSConfig(hostname string, cfg *tls.Config) *tls.Config {
+	rule := t.matchHostRule(hostname)
+	if rule == nil {
+		return cfg
+	}
+	cfg = cloneTLSConfig(cfg)
+	rule.tlsConfig(cfg)
+	return cfg
+}
+
+// applyHostRule applies headers and timeout of the matched host rule to
+// the request, the returned cancel func must be called if it's not nil.
+func (t *Transport) applyHostRule(req *http.Request) (*http.Request, context.CancelFunc) {
+	rule := t.matchHostRule(req.URL.Host)
+	if rule == nil {
+		return req, nil
+	}
+	var cancel context.CancelFunc
+	if rule.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), rule.timeout)
+		req = req.WithContext(ctx)
+	}
+	if len(rule.headers) > 0 {
+		if cancel == nil {
+			req = req.WithContext(req.Context())
+		}
+		req.Header = req.Header.Clone()
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+		for k, vs := range rule.headers {
+			if len(req.Header[k]) == 0 {
+				req.Header[k] = vs
+			}
+		}
+	}
+	return req, cancel
+}
+
+// cancelBody cancels the context of request when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func removeHostPort(host string) string {
+	if strings.HasPrefix(host, "[") {
+		if i := strings.LastIndex(host, "]"); i > 0 {
+			return host[1:i]
+		}
+		return host
+	}
+	if strings.Count(host, ":") == 1 {
+		return host[:strings.IndexByte(host, ':')]
+	}
+	return host
+}
@@ -0,0 +1,124 @@
+package req
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/imroc/req/v3/internal/ascii"
+	"golang.org/x/net/idna"
+)
+
+// ErrConfusableHost is returned in IDN strict mode when a label of the
+// hostname mixes scripts which can be used in homograph attacks, e.g.
+// "pаypal.com" (the "а" is Cyrillic).
+var ErrConfusableHost = errors.New("req: hostname mixes confusable scripts")
+
+var idnScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Armenian", unicode.Armenian},
+	{"Georgian", unicode.Georgian},
+	{"Hebrew", unicode.Hebrew},
+	{"Arabic", unicode.Arabic},
+	{"Thai", unicode.Thai},
+	{"Devanagari", unicode.Devanagari},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Bopomofo", unicode.Bopomofo},
+}
+
+// allowedScriptSets are the script combinations which are commonly used
+// together in a single label and not considered confusable.
+var allowedScriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+func labelScripts(label string) map[string]bool {
+	scripts := make(map[string]bool)
+	for _, r := range label {
+		if r < unicode.MaxASCII && !unicode.IsLetter(r) {
+			continue // digits and hyphen
+		}
+		for _, s := range idnScripts {
+			if unicode.Is(s.table, r) {
+				scripts[s.name] = true
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+func isAllowedScripts(scripts map[string]bool) bool {
+	if len(scripts) <= 1 {
+		return true
+	}
+	for _, set := range allowedScriptSets {
+		n := 0
+		for _, s := range set {
+			if scripts[s] {
+				n++
+			}
+		}
+		if n == len(scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConfusableHost reports an error if any label of the unicode host
+// mixes scripts which are not commonly used together.
+func checkConfusableHost(host string) error {
+	for _, label := range strings.Split(host, ".") {
+		if ascii.Is(label) {
+			continue
+		}
+		if !isAllowedScripts(labelScripts(label)) {
+			return fmt.Errorf("%w: %q", ErrConfusableHost, host)
+		}
+	}
+	return nil
+}
+
+// toASCIIHost converts the unicode hostname of host (with optional port)
+// to punycode, the confusable check is performed if strict is true.
+func toASCIIHost(host string, strict bool) (string, error) {
+	if ascii.Is(host) {
+		if !strict || !strings.Contains(host, "xn--") {
+			return host, nil
+		}
+	}
+	hostname, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+	unicodeHost, err := idna.Lookup.ToUnicode(hostname)
+	if err != nil {
+		return "", err
+	}
+	if strict {
+		if err = checkConfusableHost(unicodeHost); err != nil {
+			return "", err
+		}
+	}
+	asciiHost, err := idna.Lookup.ToASCII(unicodeHost)
+	if err != nil {
+		return "", err
+	}
+	if port != "" {
+		return net.JoinHostPort(asciiHost, port), nil
+	}
+	return asciiHost, nil
+}
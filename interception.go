@@ -0,0 +1,137 @@
+package req
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultInterceptionProbeURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+// InterceptionProbeOptions is the known answer of the probe used by
+// Client.DetectInterception.
+type InterceptionProbeOptions struct {
+	// ExpectedStatus is the expected status code, default is 204.
+	ExpectedStatus int
+	// ExpectedBody, if not empty, must be contained in the response body,
+	// otherwise the body must be empty.
+	ExpectedBody string
+	// KnownProxies are the pseudonyms or hosts of the trusted proxies (e.g.
+	// the one set by Client.SetProxyURL) which add the Via header, the Via
+	// entries whose received-by token is exactly one of them are not
+	// considered intercepted.
+	KnownProxies []string
+}
+
+// InterceptionResult is the result of Client.DetectInterception.
+type InterceptionResult struct {
+	// Intercepted is true if the probe is answered or rewritten by an
+	// intermediary, e.g. a captive portal or intercepting proxy.
+	Intercepted bool
+	// Reasons are why the probe is considered intercepted.
+	Reasons []string
+	// StatusCode is the status code of the probe response.
+	StatusCode int
+	// FinalURL is the url of the probe response, which is different from
+	// the probe url if redirected.
+	FinalURL string
+}
+
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) || errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// DetectInterception issues a known-answer probe to the url, and reports
+// whether the response is answered or rewritten by an intermediary, e.g.
+// a captive portal of the hotel wifi or an intercepting proxy. By default
+// the probe expects the "204 No Content" response with empty body like
+// http://connectivitycheck.gstatic.com/generate_204, which is used if the
+// url is empty. The probe is considered intercepted if it's redirected,
+// the status code or body doesn't match the known answer, the response
+// is relayed via the proxies other than the known ones (see the Via
+// header and InterceptionProbeOptions.KnownProxies), or the certificate of the https probe can't be
+// verified (which is a sign of TLS interception). The error is only
+// returned if the probe fails otherwise. For example:
+//
+//	result, err := client.DetectInterception("http://captive.apple.com/hotspot-detect.html", &req.InterceptionProbeOptions{
+//		ExpectedStatus: http.StatusOK,
+//		ExpectedBody:   "Success",
+//	})
+func (c *Client) DetectInterception(url string, opts ...*InterceptionProbeOptions) (*InterceptionResult, error) {
+	if url == "" {
+		url = defaultInterceptionProbeURL
+	}
+	var o InterceptionProbeOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.ExpectedStatus == 0 {
+		o.ExpectedStatus = http.StatusNoContent
+	}
+	result := &InterceptionResult{}
+	resp, err := c.R().SetHeader("Cache-Control", "no-cache").Get(url)
+	if err != nil {
+		if isCertificateError(err) {
+			result.Intercepted = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("certificate verification failed: %v", err))
+			return result, nil
+		}
+		return nil, err
+	}
+	body, err := resp.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Response.Request.URL.String()
+	if result.FinalURL != resp.Request.URL.String() {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("redirected to %s", result.FinalURL))
+	}
+	if resp.StatusCode != o.ExpectedStatus {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("unexpected status code %d, expected %d", resp.StatusCode, o.ExpectedStatus))
+	}
+	if o.ExpectedBody == "" && len(strings.TrimSpace(string(body))) > 0 {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("unexpected %d bytes body, expected empty", len(body)))
+	} else if o.ExpectedBody != "" && !strings.Contains(string(body), o.ExpectedBody) {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("body doesn't contain %q", o.ExpectedBody))
+	}
+	for _, receivedBy := range viaReceivedBy(resp.Header.Values("Via")) {
+		if !containsFold(o.KnownProxies, receivedBy) {
+			result.Reasons = append(result.Reasons, fmt.Sprintf("response is relayed via %q", receivedBy))
+		}
+	}
+	result.Intercepted = len(result.Reasons) > 0
+	return result, nil
+}
+
+// viaReceivedBy returns the received-by tokens of the Via header values,
+// e.g. "proxy.local:8080" of "1.1 proxy.local:8080 (squid/5.7)".
+func viaReceivedBy(vias []string) []string {
+	var tokens []string
+	for _, via := range vias {
+		for _, entry := range strings.Split(via, ",") {
+			fields := strings.Fields(entry)
+			if len(fields) >= 2 {
+				tokens = append(tokens, fields[1])
+			}
+		}
+	}
+	return tokens
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package common
+
+import "context"
+
+type debugfKey struct{}
+
+// WithDebugf returns a copy of ctx with the debugf, which overrides the
+// Debugf of the transport for the request.
+func WithDebugf(ctx context.Context, debugf func(format string, v ...interface{})) context.Context {
+	return context.WithValue(ctx, debugfKey{}, debugf)
+}
+
+// Debugf returns the debugf of the request set by WithDebugf, or def if
+// it's not set.
+func Debugf(ctx context.Context, def func(format string, v ...interface{})) func(format string, v ...interface{}) {
+	if debugf, ok := ctx.Value(debugfKey{}).(func(format string, v ...interface{})); ok {
+		return debugf
+	}
+	return def
+}
@@ -1,9 +1,16 @@
 package dump
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Options controls the dump behavior.
@@ -18,50 +25,195 @@ type Options interface {
 	ResponseHeader() bool
 	ResponseBody() bool
 	Async() bool
+	// AsyncQueueSize is the max number of the pending dumps of the async
+	// dump, 0 means the default size.
+	AsyncQueueSize() int
+	// AsyncDropOnFull reports whether the dump is dropped instead of
+	// blocking the caller when the queue of the async dump is full.
+	AsyncDropOnFull() bool
+	// OnDump is called with the raw data of the part before it's dumped.
+	OnDump(part Part, p []byte)
+	// RedactHeader reports whether the value of the header should be
+	// masked in the dump.
+	RedactHeader(name string) bool
+	// MaxBodySize is the max size of the body to be dumped, 0 means no
+	// limit.
+	MaxBodySize() int64
+	// OmitBody reports whether the body of the content type should be
+	// omitted in the dump.
+	OmitBody(contentType string) bool
 	Clone() Options
 }
 
-func (d *Dumper) WrapResponseBodyReadCloser(rc io.ReadCloser) io.ReadCloser {
-	return &dumpReponseBodyReadCloser{rc, d}
+// Part is the part of the HTTP message which is dumped.
+type Part int
+
+const (
+	RequestHeaderPart Part = iota
+	RequestBodyPart
+	ResponseHeaderPart
+	ResponseBodyPart
+)
+
+// BodyFilter truncates the body which exceeds the MaxBodySize, or omits
+// the body of the content type which should be omitted, a placeholder with
+// the size and the content type is dumped instead. It's created for each
+// body, and a nil BodyFilter dumps the body as is.
+type BodyFilter struct {
+	max         int64
+	omit        bool
+	contentType string
+	length      int64
+	n           int64
+}
+
+// NewBodyFilter creates the BodyFilter of the body with the header and the
+// content length (-1 if unknown), nil if the body should be dumped as is.
+func (d *Dumper) NewBodyFilter(header http.Header, length int64) *BodyFilter {
+	contentType := header.Get("Content-Type")
+	omit := contentType != "" && d.OmitBody(contentType)
+	max := d.MaxBodySize()
+	if !omit && max <= 0 {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	return &BodyFilter{
+		max:         max,
+		omit:        omit,
+		contentType: contentType,
+		length:      length,
+	}
+}
+
+// Filter returns the content to be dumped for the next chunk p of the body.
+func (f *BodyFilter) Filter(p []byte) []byte {
+	if f == nil || len(p) == 0 {
+		return p
+	}
+	start := f.n
+	f.n += int64(len(p))
+	if f.omit {
+		if start > 0 || f.length < 0 { // the size is known at the end
+			return nil
+		}
+		return []byte(f.placeholder("omitted"))
+	}
+	if f.n <= f.max {
+		return p
+	}
+	if start >= f.max {
+		return nil
+	}
+	out := make([]byte, 0, f.max-start+64)
+	out = append(out, p[:f.max-start]...)
+	out = append(out, "\r\n"...)
+	out = append(out, f.placeholder("truncated to "+formatSize(f.max))...)
+	return out
+}
+
+// Finish returns the placeholder of the omitted body whose size is unknown
+// until the end of the body, which is called once the body is completed.
+func (f *BodyFilter) Finish() []byte {
+	if f == nil || !f.omit || f.length >= 0 || f.n == 0 {
+		return nil
+	}
+	f.length = f.n
+	return []byte(f.placeholder("omitted"))
+}
+
+// placeholder returns the text dumped instead of the body, e.g.
+// "[1.2MB application/octet-stream omitted]".
+func (f *BodyFilter) placeholder(action string) string {
+	var parts []string
+	if f.length >= 0 {
+		parts = append(parts, formatSize(f.length))
+	}
+	if f.contentType != "" {
+		parts = append(parts, f.contentType)
+	} else {
+		parts = append(parts, "body")
+	}
+	parts = append(parts, action)
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// formatSize formats the size in bytes in the human-readable form, e.g.
+// "512B", "64KB" and "1.2MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	size := float64(n) / unit
+	suffix := "KB"
+	for _, s := range []string{"MB", "GB"} {
+		if size < unit {
+			break
+		}
+		size /= unit
+		suffix = s
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(size, 'f', 1, 64), ".0") + suffix
+}
+
+func (d *Dumper) WrapResponseBodyReadCloser(rc io.ReadCloser, filter *BodyFilter) io.ReadCloser {
+	return &dumpReponseBodyReadCloser{rc, d, filter}
 }
 
 type dumpReponseBodyReadCloser struct {
 	io.ReadCloser
-	dump *Dumper
+	dump   *Dumper
+	filter *BodyFilter
 }
 
 func (r *dumpReponseBodyReadCloser) Read(p []byte) (n int, err error) {
 	n, err = r.ReadCloser.Read(p)
-	r.dump.DumpResponseBody(p[:n])
+	r.dump.DumpResponseBody(r.filter.Filter(p[:n]))
 	if err == io.EOF {
+		r.dump.DumpResponseBody(r.filter.Finish())
 		r.dump.DumpDefault([]byte("\r\n"))
 	}
 	return
 }
 
-func (d *Dumper) WrapRequestBodyWriteCloser(rc io.WriteCloser) io.WriteCloser {
-	return &dumpRequestBodyWriteCloser{rc, d}
+func (d *Dumper) WrapRequestBodyWriteCloser(rc io.WriteCloser, filter *BodyFilter) io.WriteCloser {
+	return &dumpRequestBodyWriteCloser{rc, d, filter}
 }
 
 type dumpRequestBodyWriteCloser struct {
 	io.WriteCloser
-	dump *Dumper
+	dump   *Dumper
+	filter *BodyFilter
 }
 
 func (w *dumpRequestBodyWriteCloser) Write(p []byte) (n int, err error) {
 	n, err = w.WriteCloser.Write(p)
-	w.dump.DumpRequestBody(p[:n])
+	w.dump.DumpRequestBody(w.filter.Filter(p[:n]))
 	return
 }
 
+func (w *dumpRequestBodyWriteCloser) Close() error {
+	w.dump.DumpRequestBody(w.filter.Finish())
+	return w.WriteCloser.Close()
+}
+
 type dumpRequestHeaderWriter struct {
 	w    io.Writer
 	dump *Dumper
+	// line is the incomplete line which is dumped once completed, so that
+	// the sensitive header can be redacted.
+	line []byte
 }
 
 func (w *dumpRequestHeaderWriter) Write(p []byte) (n int, err error) {
 	n, err = w.w.Write(p)
-	w.dump.DumpRequestHeader(p[:n])
+	w.line = append(w.line, p[:n]...)
+	if i := bytes.LastIndexByte(w.line, '\n'); i >= 0 {
+		w.dump.DumpRequestHeader(w.line[:i+1])
+		w.line = append(w.line[:0], w.line[i+1:]...)
+	}
 	return
 }
 
@@ -73,20 +225,22 @@ func (d *Dumper) WrapRequestHeaderWriter(w io.Writer) io.Writer {
 }
 
 type dumpRequestBodyWriter struct {
-	w    io.Writer
-	dump *Dumper
+	w      io.Writer
+	dump   *Dumper
+	filter *BodyFilter
 }
 
 func (w *dumpRequestBodyWriter) Write(p []byte) (n int, err error) {
 	n, err = w.w.Write(p)
-	w.dump.DumpRequestBody(p[:n])
+	w.dump.DumpRequestBody(w.filter.Filter(p[:n]))
 	return
 }
 
-func (d *Dumper) WrapRequestBodyWriter(w io.Writer) io.Writer {
+func (d *Dumper) WrapRequestBodyWriter(w io.Writer, filter *BodyFilter) io.Writer {
 	return &dumpRequestBodyWriter{
-		w:    w,
-		dump: d,
+		w:      w,
+		dump:   d,
+		filter: filter,
 	}
 }
 
@@ -120,18 +274,50 @@ func (ds Dumpers) DumpResponseHeader(p []byte) {
 type Dumper struct {
 	Options
 	ch chan *dumpTask
+
+	// deferred holds the dump which is written to parent once committed.
+	deferred *Deferred
+	parent   *Dumper
+
+	// dropped is the number of the writes dropped by AsyncDropOnFull, and
+	// unreported is the ones not reported by the marker in the output yet.
+	dropped    atomic.Int64
+	unreported atomic.Int64
+
+	// state of request header diff between retry attempts.
+	mu          sync.Mutex
+	record      bool
+	diff        bool
+	headerDone  bool
+	line        []byte
+	prevHeaders []string
+	prevSet     map[string]bool
+	curHeaders  []string
 }
 
 type dumpTask struct {
 	Data   []byte
 	Output io.Writer
+	// done is closed once the tasks queued before are written if it's not
+	// nil, which is used to flush the queue.
+	done chan struct{}
+}
+
+const defaultAsyncQueueSize = 20
+
+func newQueue(opt Options) chan *dumpTask {
+	size := opt.AsyncQueueSize()
+	if size <= 0 {
+		size = defaultAsyncQueueSize
+	}
+	return make(chan *dumpTask, size)
 }
 
 // NewDumper create a new Dumper.
 func NewDumper(opt Options) *Dumper {
 	d := &Dumper{
 		Options: opt,
-		ch:      make(chan *dumpTask, 20),
+		ch:      newQueue(opt),
 	}
 	return d
 }
@@ -145,9 +331,10 @@ func (d *Dumper) Clone() *Dumper {
 	if d == nil {
 		return nil
 	}
+	opt := d.Options.Clone()
 	return &Dumper{
-		Options: d.Options.Clone(),
-		ch:      make(chan *dumpTask, 20),
+		Options: opt,
+		ch:      newQueue(opt),
 	}
 }
 
@@ -155,51 +342,290 @@ func (d *Dumper) DumpTo(p []byte, output io.Writer) {
 	if len(p) == 0 || output == nil {
 		return
 	}
+	if d.deferred != nil {
+		d.deferred.dump(d.parent, p, output)
+		return
+	}
 	if d.Async() {
 		b := make([]byte, len(p))
 		copy(b, p)
+		if d.AsyncDropOnFull() {
+			d.dumpOrDrop(b, output)
+			return
+		}
 		d.ch <- &dumpTask{Data: b, Output: output}
 		return
 	}
 	output.Write(p)
 }
 
+// dumpOrDrop queues the write, or drops it if the queue is full. The
+// number of the dropped writes is reported by a marker like
+// "[3 dump writes dropped]" before the next queued write, so that the gap
+// in the output is visible.
+func (d *Dumper) dumpOrDrop(b []byte, output io.Writer) {
+	n := d.unreported.Swap(0)
+	if n > 0 {
+		b = append([]byte(fmt.Sprintf("\r\n[%d dump writes dropped]\r\n", n)), b...)
+	}
+	select {
+	case d.ch <- &dumpTask{Data: b, Output: output}:
+	default:
+		d.dropped.Add(1)
+		d.unreported.Add(n + 1)
+	}
+}
+
+// Dropped returns the number of the writes dropped as the queue of the
+// async dump is full, see AsyncDropOnFull.
+func (d *Dumper) Dropped() int64 {
+	return d.dropped.Load()
+}
+
 func (d *Dumper) DumpDefault(p []byte) {
 	d.DumpTo(p, d.Output())
 }
 
+// onDump calls the OnDump hook of options.
+func (d *Dumper) onDump(part Part, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	if d.deferred != nil {
+		d.deferred.onDump(d.parent, part, p)
+		return
+	}
+	d.OnDump(part, p)
+}
+
+var redacted = []byte(": REDACTED")
+
+// redactHeader masks the values of the sensitive headers in p, which
+// consists of complete header lines.
+func (d *Dumper) redactHeader(p []byte) []byte {
+	var out []byte
+	for i := 0; i < len(p); {
+		end := bytes.IndexByte(p[i:], '\n')
+		if end < 0 {
+			end = len(p)
+		} else {
+			end += i + 1
+		}
+		line := p[i:end]
+		name, _, ok := bytes.Cut(line, []byte(":"))
+		if ok && len(name) > 0 && d.RedactHeader(string(bytes.TrimSpace(name))) {
+			if out == nil {
+				out = append(make([]byte, 0, len(p)), p[:i]...)
+			}
+			out = append(out, name...)
+			out = append(out, redacted...)
+			if bytes.HasSuffix(line, []byte("\r\n")) {
+				out = append(out, '\r', '\n')
+			} else if bytes.HasSuffix(line, []byte("\n")) {
+				out = append(out, '\n')
+			}
+		} else if out != nil {
+			out = append(out, line...)
+		}
+		i = end
+	}
+	if out == nil {
+		return p
+	}
+	return out
+}
+
 func (d *Dumper) DumpRequestHeader(p []byte) {
+	p = d.redactHeader(p)
+	d.onDump(RequestHeaderPart, p)
+	p = d.diffRequestHeader(p)
 	d.DumpTo(p, d.RequestHeaderOutput())
 }
 
+// StartAttempt is called before each attempt of a request, the dump is
+// annotated with the attempt number on retry. If diff is true, the request
+// headers are recorded, and only the headers which differ from the previous
+// attempt are dumped on retry ("+" for added, "-" for removed).
+func (d *Dumper) StartAttempt(attempt int, diff bool) {
+	d.mu.Lock()
+	d.record = diff
+	d.diff = diff && attempt > 0
+	d.headerDone = false
+	d.line = d.line[:0]
+	d.prevHeaders = d.curHeaders
+	d.prevSet = make(map[string]bool, len(d.prevHeaders))
+	for _, h := range d.prevHeaders {
+		d.prevSet[h] = true
+	}
+	d.curHeaders = nil
+	d.mu.Unlock()
+	if attempt == 0 {
+		return
+	}
+	if d.diff {
+		d.DumpDefault([]byte(fmt.Sprintf("\r\n----- retry attempt %d (request headers diff from previous attempt) -----\r\n", attempt)))
+	} else {
+		d.DumpDefault([]byte(fmt.Sprintf("\r\n----- retry attempt %d -----\r\n", attempt)))
+	}
+}
+
+// diffRequestHeader records the request header lines if the diff is
+// enabled by StartAttempt, and returns the content to be dumped.
+func (d *Dumper) diffRequestHeader(p []byte) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.record || d.headerDone {
+		return p
+	}
+	d.line = append(d.line, p...)
+	var out []byte
+	for !d.headerDone {
+		i := bytes.IndexByte(d.line, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(d.line[:i+1])
+		d.line = d.line[i+1:]
+		if line == "\r\n" { // end of header
+			d.headerDone = true
+			if d.diff {
+				current := make(map[string]bool, len(d.curHeaders))
+				for _, h := range d.curHeaders {
+					current[h] = true
+				}
+				for _, h := range d.prevHeaders {
+					if !current[h] {
+						out = append(out, "- "+h...)
+					}
+				}
+			}
+			out = append(out, line...)
+			break
+		}
+		d.curHeaders = append(d.curHeaders, line)
+		if !d.diff {
+			out = append(out, line...)
+		} else if !d.prevSet[line] {
+			out = append(out, "+ "+line...)
+		}
+	}
+	if d.headerDone && len(d.line) > 0 {
+		out = append(out, d.line...)
+		d.line = d.line[:0]
+	}
+	return out
+}
+
 func (d *Dumper) DumpRequestBody(p []byte) {
+	d.onDump(RequestBodyPart, p)
 	d.DumpTo(p, d.RequestBodyOutput())
 }
 
 func (d *Dumper) DumpResponseHeader(p []byte) {
+	p = d.redactHeader(p)
+	d.onDump(ResponseHeaderPart, p)
 	d.DumpTo(p, d.ResponseHeaderOutput())
 }
 
 func (d *Dumper) DumpResponseBody(p []byte) {
+	d.onDump(ResponseBodyPart, p)
 	d.DumpTo(p, d.ResponseBodyOutput())
 }
 
+// Stop stops the worker started by Start once the pending dumps are
+// written, and blocks until then.
 func (d *Dumper) Stop() {
+	d.Flush()
 	d.ch <- nil
 }
 
+// Flush blocks until the dumps queued before are written by the worker
+// started by Start.
+func (d *Dumper) Flush() {
+	done := make(chan struct{})
+	d.ch <- &dumpTask{done: done}
+	<-done
+}
+
 func (d *Dumper) Start() {
 	for t := range d.ch {
 		if t == nil {
 			return
 		}
+		if t.done != nil {
+			close(t.done)
+			continue
+		}
 		t.Output.Write(t.Data)
 	}
 }
 
 type dumperKeyType int
 
-const DumperKey dumperKeyType = iota
+const (
+	DumperKey dumperKeyType = iota
+	// DeferredKey is the context key of *Deferred, the dump of the request
+	// is held until the Deferred is committed.
+	DeferredKey
+)
+
+const (
+	deferredPending = iota
+	deferredCommitted
+	deferredDiscarded
+)
+
+// Deferred holds the dump of a request (e.g. an attempt of hedged requests)
+// until it's known whether the dump should be written.
+type Deferred struct {
+	mu    sync.Mutex
+	state int
+	tasks []func()
+}
+
+// run runs the dump task if committed, or holds it if pending.
+func (df *Deferred) run(p []byte, task func(b []byte)) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	switch df.state {
+	case deferredCommitted:
+		task(p)
+	case deferredPending:
+		b := make([]byte, len(p))
+		copy(b, p)
+		df.tasks = append(df.tasks, func() { task(b) })
+	}
+}
+
+func (df *Deferred) dump(d *Dumper, p []byte, output io.Writer) {
+	df.run(p, func(b []byte) { d.DumpTo(b, output) })
+}
+
+func (df *Deferred) onDump(d *Dumper, part Part, p []byte) {
+	df.run(p, func(b []byte) { d.OnDump(part, b) })
+}
+
+// Commit writes the held dump, and the subsequent dump is written directly.
+func (df *Deferred) Commit() {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	if df.state != deferredPending {
+		return
+	}
+	for _, task := range df.tasks {
+		task()
+	}
+	df.tasks = nil
+	df.state = deferredCommitted
+}
+
+// Discard drops the held dump and the subsequent dump.
+func (df *Deferred) Discard() {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	df.tasks = nil
+	df.state = deferredDiscarded
+}
 
 func GetDumpers(ctx context.Context, dump *Dumper) []*Dumper {
 	dumps := []*Dumper{}
@@ -212,6 +638,11 @@ func GetDumpers(ctx context.Context, dump *Dumper) []*Dumper {
 	if d, ok := ctx.Value(DumperKey).(*Dumper); ok {
 		dumps = append(dumps, d)
 	}
+	if df, ok := ctx.Value(DeferredKey).(*Deferred); ok && df != nil {
+		for i, d := range dumps {
+			dumps[i] = &Dumper{Options: d.Options, deferred: df, parent: d}
+		}
+	}
 	return dumps
 }
 
@@ -219,7 +650,7 @@ func WrapResponseBodyIfNeeded(res *http.Response, req *http.Request, dump *Dumpe
 	dumps := GetDumpers(req.Context(), dump)
 	for _, d := range dumps {
 		if d.ResponseBody() {
-			res.Body = d.WrapResponseBodyReadCloser(res.Body)
+			res.Body = d.WrapResponseBodyReadCloser(res.Body, d.NewBodyFilter(res.Header, res.ContentLength))
 		}
 	}
 }
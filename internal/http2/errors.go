@@ -7,55 +7,33 @@ package http2
 import (
 	"errors"
 	"fmt"
+
+	"github.com/imroc/req/v3/http2"
 )
 
-// An ErrCode is an unsigned 32-bit error code as defined in the HTTP/2 spec.
-type ErrCode uint32
+// ErrCode is an alias of http2.ErrCode.
+type ErrCode = http2.ErrCode
 
 const (
-	ErrCodeNo                 ErrCode = 0x0
-	ErrCodeProtocol           ErrCode = 0x1
-	ErrCodeInternal           ErrCode = 0x2
-	ErrCodeFlowControl        ErrCode = 0x3
-	ErrCodeSettingsTimeout    ErrCode = 0x4
-	ErrCodeStreamClosed       ErrCode = 0x5
-	ErrCodeFrameSize          ErrCode = 0x6
-	ErrCodeRefusedStream      ErrCode = 0x7
-	ErrCodeCancel             ErrCode = 0x8
-	ErrCodeCompression        ErrCode = 0x9
-	ErrCodeConnect            ErrCode = 0xa
-	ErrCodeEnhanceYourCalm    ErrCode = 0xb
-	ErrCodeInadequateSecurity ErrCode = 0xc
-	ErrCodeHTTP11Required     ErrCode = 0xd
+	ErrCodeNo                 = http2.ErrCodeNo
+	ErrCodeProtocol           = http2.ErrCodeProtocol
+	ErrCodeInternal           = http2.ErrCodeInternal
+	ErrCodeFlowControl        = http2.ErrCodeFlowControl
+	ErrCodeSettingsTimeout    = http2.ErrCodeSettingsTimeout
+	ErrCodeStreamClosed       = http2.ErrCodeStreamClosed
+	ErrCodeFrameSize          = http2.ErrCodeFrameSize
+	ErrCodeRefusedStream      = http2.ErrCodeRefusedStream
+	ErrCodeCancel             = http2.ErrCodeCancel
+	ErrCodeCompression        = http2.ErrCodeCompression
+	ErrCodeConnect            = http2.ErrCodeConnect
+	ErrCodeEnhanceYourCalm    = http2.ErrCodeEnhanceYourCalm
+	ErrCodeInadequateSecurity = http2.ErrCodeInadequateSecurity
+	ErrCodeHTTP11Required     = http2.ErrCodeHTTP11Required
 )
 
-var errCodeName = map[ErrCode]string{
-	ErrCodeNo:                 "NO_ERROR",
-	ErrCodeProtocol:           "PROTOCOL_ERROR",
-	ErrCodeInternal:           "INTERNAL_ERROR",
-	ErrCodeFlowControl:        "FLOW_CONTROL_ERROR",
-	ErrCodeSettingsTimeout:    "SETTINGS_TIMEOUT",
-	ErrCodeStreamClosed:       "STREAM_CLOSED",
-	ErrCodeFrameSize:          "FRAME_SIZE_ERROR",
-	ErrCodeRefusedStream:      "REFUSED_STREAM",
-	ErrCodeCancel:             "CANCEL",
-	ErrCodeCompression:        "COMPRESSION_ERROR",
-	ErrCodeConnect:            "CONNECT_ERROR",
-	ErrCodeEnhanceYourCalm:    "ENHANCE_YOUR_CALM",
-	ErrCodeInadequateSecurity: "INADEQUATE_SECURITY",
-	ErrCodeHTTP11Required:     "HTTP_1_1_REQUIRED",
-}
-
-func (e ErrCode) String() string {
-	if s, ok := errCodeName[e]; ok {
-		return s
-	}
-	return fmt.Sprintf("unknown error code 0x%x", uint32(e))
-}
-
-func (e ErrCode) stringToken() string {
-	if s, ok := errCodeName[e]; ok {
-		return s
+func errCodeToken(e ErrCode) string {
+	if e <= ErrCodeHTTP11Required {
+		return e.String()
 	}
 	return fmt.Sprintf("ERR_UNKNOWN_%d", uint32(e))
 }
@@ -68,13 +46,8 @@ func (e ConnectionError) Error() string {
 	return fmt.Sprintf("connection error: %s", ErrCode(e))
 }
 
-// StreamError is an error that only affects one stream within an
-// HTTP/2 connection.
-type StreamError struct {
-	StreamID uint32
-	Code     ErrCode
-	Cause    error // optional additional detail
-}
+// StreamError is an alias of http2.StreamError.
+type StreamError = http2.StreamError
 
 // errFromPeer is a sentinel error value for StreamError.Cause to
 // indicate that the StreamError was sent from the peer over the wire
@@ -85,13 +58,6 @@ func streamError(id uint32, code ErrCode) StreamError {
 	return StreamError{StreamID: id, Code: code}
 }
 
-func (e StreamError) Error() string {
-	if e.Cause != nil {
-		return fmt.Sprintf("stream error: stream ID %d; %v; %v", e.StreamID, e.Code, e.Cause)
-	}
-	return fmt.Sprintf("stream error: stream ID %d; %v", e.StreamID, e.Code)
-}
-
 // connError represents an HTTP/2 ConnectionError error code, along
 // with a string (for debugging) explaining why.
 //
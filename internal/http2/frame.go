@@ -1646,14 +1646,14 @@ func (h2f *Framer) readMetaFrame(hf *HeadersFrame, dumps []*dump.Dumper) (*MetaH
 		if VerboseLogs {
 			log.Printf("http2: invalid header: %v", invalid)
 		}
-		return nil, StreamError{mh.StreamID, ErrCodeProtocol, invalid}
+		return nil, StreamError{StreamID: mh.StreamID, Code: ErrCodeProtocol, Cause: invalid}
 	}
 	if err := mh.checkPseudos(); err != nil {
 		h2f.errDetail = err
 		if VerboseLogs {
 			log.Printf("http2: invalid pseudo headers: %v", err)
 		}
-		return nil, StreamError{mh.StreamID, ErrCodeProtocol, err}
+		return nil, StreamError{StreamID: mh.StreamID, Code: ErrCodeProtocol, Cause: err}
 	}
 	return mh, nil
 }
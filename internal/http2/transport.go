@@ -64,6 +64,10 @@ const (
 	// defaultMaxConcurrentStreams is a connections default maxConcurrentStreams
 	// if the server doesn't include one in its initial SETTINGS frame.
 	defaultMaxConcurrentStreams = 1000
+
+	// MaxInitialWindowSize is the max value of SETTINGS_INITIAL_WINDOW_SIZE
+	// allowed by the spec.
+	MaxInitialWindowSize = 1<<31 - 1
 )
 
 // Transport is an HTTP/2 Transport.
@@ -109,6 +113,18 @@ type Transport struct {
 	// waiting for their turn.
 	StrictMaxConcurrentStreams bool
 
+	// MaxConcurrentStreams, if non-zero, is the max number of concurrent
+	// streams opened on a connection, which caps the server's
+	// SETTINGS_MAX_CONCURRENT_STREAMS.
+	MaxConcurrentStreams uint32
+
+	// InitialWindowSize, if non-zero, is the http2
+	// SETTINGS_INITIAL_WINDOW_SIZE to send in the initial settings
+	// frame, which is how many bytes the server can send on a stream
+	// before waiting for a WINDOW_UPDATE. If zero or greater than
+	// MaxInitialWindowSize, 4MB is used.
+	InitialWindowSize uint32
+
 	// ReadIdleTimeout is the timeout after which a health check using ping
 	// frame will be carried out if no frame is received on the connection.
 	// Note that a ping response will is considered a received frame, so if
@@ -143,6 +159,20 @@ type Transport struct {
 	connPoolOrDef ClientConnPool // non-nil version of ConnPool
 }
 
+func (t *Transport) maxConcurrentStreams(n uint32) uint32 {
+	if t.MaxConcurrentStreams != 0 && t.MaxConcurrentStreams < n {
+		return t.MaxConcurrentStreams
+	}
+	return n
+}
+
+func (t *Transport) initialWindowSize() uint32 {
+	if t.InitialWindowSize != 0 && t.InitialWindowSize <= MaxInitialWindowSize {
+		return t.InitialWindowSize
+	}
+	return transportDefaultStreamFlow
+}
+
 func (t *Transport) maxHeaderListSize() uint32 {
 	if t.MaxHeaderListSize == 0 {
 		return 10 << 20
@@ -194,6 +224,7 @@ type ClientConn struct {
 	cond            *sync.Cond // hold mu; broadcast on flow/closed changes
 	flow            outflow    // our conn-level flow control quota (cs.outflow is per stream)
 	inflow          inflow     // peer's conn-level flow control
+	streamFlow      uint32     // stream-level flow control tokens we announce to the peer
 	doNotReuse      bool       // whether conn is marked to not be reused for any future requests
 	closing         bool
 	closed          bool
@@ -451,16 +482,10 @@ func (t *Transport) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Res
 	addr := netutil.AuthorityAddr(req.URL.Scheme, req.URL.Host)
 	var cc *ClientConn
 	var err error
-	if opt.OnlyCachedConn {
-		cc, err = t.connPool().GetClientConn(req, addr, false)
-		if err != nil {
-			return nil, err
-		}
-		traceGotConn(req, cc, true)
-		return cc.RoundTrip(req)
-	}
 	for retry := 0; ; retry++ {
-		cc, err = t.connPool().GetClientConn(req, addr, true)
+		// the request refused by the cached conn (e.g. REFUSED_STREAM) is
+		// retried as well, on another cached conn if any.
+		cc, err = t.connPool().GetClientConn(req, addr, !opt.OnlyCachedConn)
 		if err != nil {
 			t.vlogf("http2: Transport failed to get client conn for %s: %v", addr, err)
 			return nil, err
@@ -710,9 +735,14 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 
 	cc.cond = sync.NewCond(&cc.mu)
 
+	cc.maxConcurrentStreams = t.maxConcurrentStreams(cc.maxConcurrentStreams)
+
 	var headerTableSize uint32 = initialHeaderTableSize
+	cc.streamFlow = t.initialWindowSize()
 	for _, setting := range t.Settings {
 		switch setting.ID {
+		case http2.SettingInitialWindowSize:
+			cc.streamFlow = setting.Val
 		case http2.SettingMaxFrameSize:
 			cc.maxFrameSize = setting.Val
 		case http2.SettingMaxHeaderListSize:
@@ -759,7 +789,7 @@ func (t *Transport) newClientConn(c net.Conn, singleUse bool) (*ClientConn, erro
 	} else {
 		initialSettings = []http2.Setting{
 			{ID: http2.SettingEnablePush, Val: 0},
-			{ID: http2.SettingInitialWindowSize, Val: transportDefaultStreamFlow},
+			{ID: http2.SettingInitialWindowSize, Val: cc.streamFlow},
 		}
 		if max := t.maxHeaderListSize(); max != 0 {
 			initialSettings = append(initialSettings, http2.Setting{ID: http2.SettingMaxHeaderListSize, Val: max})
@@ -1151,8 +1181,10 @@ func (cc *ClientConn) decrStreamReservationsLocked() {
 }
 
 func (cc *ClientConn) RoundTrip(req *http.Request) (*http.Response, error) {
-	if cc.t != nil && cc.t.Debugf != nil {
-		cc.t.Debugf("HTTP/2 %s %s", req.Method, req.URL.String())
+	if cc.t != nil {
+		if debugf := common.Debugf(req.Context(), cc.t.Debugf); debugf != nil {
+			debugf("HTTP/2 %s %s", req.Method, req.URL.Redacted())
+		}
 	}
 	ctx := req.Context()
 	cs := &clientStream{
@@ -1683,10 +1715,14 @@ func (cs *clientStream) writeRequestBody(req *http.Request, dumps []*dump.Dumper
 	}
 
 	writeData := cc.fr.WriteData
+	filters := make([]*dump.BodyFilter, len(dumps))
+	for i, d := range dumps {
+		filters[i] = d.NewBodyFilter(req.Header, cs.reqBodyContentLength)
+	}
 	if len(dumps) > 0 {
 		writeData = func(streamID uint32, endStream bool, data []byte) error {
-			for _, dump := range dumps {
-				dump.DumpRequestBody(data)
+			for i, dump := range dumps {
+				dump.DumpRequestBody(filters[i].Filter(data))
 			}
 			return cc.fr.WriteData(streamID, endStream, data)
 		}
@@ -1757,6 +1793,9 @@ func (cs *clientStream) writeRequestBody(req *http.Request, dumps []*dump.Dumper
 			return err
 		}
 	}
+	for i, dump := range dumps {
+		dump.DumpRequestBody(filters[i].Finish())
+	}
 
 	if sentEnd {
 		// Already sent END_STREAM (which implies we have no
@@ -2161,7 +2200,7 @@ type resAndError struct {
 func (cc *ClientConn) addStreamLocked(cs *clientStream) {
 	cs.flow.add(int32(cc.initialWindowSize))
 	cs.flow.setConnFlow(&cc.flow)
-	cs.inflow.init(transportDefaultStreamFlow)
+	cs.inflow.init(int32(cc.streamFlow))
 	cs.ID = cc.nextStreamID
 	cc.nextStreamID += 2
 	cc.streams[cs.ID] = cs
@@ -2285,7 +2324,7 @@ func (cc *ClientConn) countReadFrameError(err error) {
 	}
 	if ce, ok := err.(ConnectionError); ok {
 		errCode := ErrCode(ce)
-		f(fmt.Sprintf("read_frame_conn_error_%s", errCode.stringToken()))
+		f(fmt.Sprintf("read_frame_conn_error_%s", errCodeToken(errCode)))
 		return
 	}
 	if errors.Is(err, io.EOF) {
@@ -2873,7 +2912,7 @@ func (rl *clientConnReadLoop) processGoAway(f *GoAwayFrame) error {
 		// TODO: deal with GOAWAY more. particularly the error code
 		cc.vlogf("transport got GOAWAY with error code = %v", f.ErrCode)
 		if fn := cc.t.CountError; fn != nil {
-			fn("recv_goaway_" + f.ErrCode.stringToken())
+			fn("recv_goaway_" + errCodeToken(f.ErrCode))
 		}
 	}
 	cc.setGoAway(f)
@@ -2916,7 +2955,7 @@ func (rl *clientConnReadLoop) processSettingsNoWrite(f *SettingsFrame) error {
 		case http2.SettingMaxFrameSize:
 			cc.maxFrameSize = s.Val
 		case http2.SettingMaxConcurrentStreams:
-			cc.maxConcurrentStreams = s.Val
+			cc.maxConcurrentStreams = cc.t.maxConcurrentStreams(s.Val)
 			seenMaxConcurrentStreams = true
 		case http2.SettingMaxHeaderListSize:
 			cc.peerMaxHeaderListSize = uint64(s.Val)
@@ -2955,7 +2994,7 @@ func (rl *clientConnReadLoop) processSettingsNoWrite(f *SettingsFrame) error {
 			// didn't contain a MAX_CONCURRENT_STREAMS field so
 			// increase the number of concurrent streams this
 			// connection can establish to our default.
-			cc.maxConcurrentStreams = defaultMaxConcurrentStreams
+			cc.maxConcurrentStreams = cc.t.maxConcurrentStreams(defaultMaxConcurrentStreams)
 		}
 		cc.seenSettings = true
 	}
@@ -3005,7 +3044,7 @@ func (rl *clientConnReadLoop) processResetStream(f *RSTStreamFrame) error {
 		rl.cc.SetDoNotReuse()
 	}
 	if fn := cs.cc.t.CountError; fn != nil {
-		fn("recv_rststream_" + f.ErrCode.stringToken())
+		fn("recv_rststream_" + errCodeToken(f.ErrCode))
 	}
 	cs.abortStream(serr)
 
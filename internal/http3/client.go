@@ -346,7 +346,7 @@ func (c *client) roundTripOpt(req *http.Request, opt RoundTripOpt) (*http.Respon
 	return rsp, maybeReplaceError(rerr.err)
 }
 
-func (c *client) sendRequestBody(str Stream, body io.ReadCloser, dumps []*dump.Dumper) error {
+func (c *client) sendRequestBody(str Stream, body io.ReadCloser, dumps []*dump.Dumper, filters []*dump.BodyFilter) error {
 	defer body.Close()
 	b := make([]byte, bodyCopyBufferSize)
 	writeData := func(data []byte) error {
@@ -357,8 +357,8 @@ func (c *client) sendRequestBody(str Stream, body io.ReadCloser, dumps []*dump.D
 	}
 	if len(dumps) > 0 {
 		writeData = func(data []byte) error {
-			for _, dump := range dumps {
-				dump.DumpRequestBody(data)
+			for i, dump := range dumps {
+				dump.DumpRequestBody(filters[i].Filter(data))
 			}
 			if _, err := str.Write(data); err != nil {
 				return err
@@ -373,7 +373,8 @@ func (c *client) sendRequestBody(str Stream, body io.ReadCloser, dumps []*dump.D
 				continue
 			}
 			if rerr == io.EOF {
-				for _, dump := range dumps {
+				for i, dump := range dumps {
+					dump.DumpRequestBody(filters[i].Finish())
 					dump.DumpDefault([]byte("\r\n\r\n"))
 				}
 				break
@@ -384,7 +385,8 @@ func (c *client) sendRequestBody(str Stream, body io.ReadCloser, dumps []*dump.D
 		}
 		if rerr != nil {
 			if rerr == io.EOF {
-				for _, dump := range dumps {
+				for i, dump := range dumps {
+					dump.DumpRequestBody(filters[i].Finish())
 					dump.DumpDefault([]byte("\r\n\r\n"))
 				}
 				break
@@ -421,12 +423,14 @@ func (c *client) doRequest(req *http.Request, conn quic.EarlyConnection, str qui
 		// send the request body asynchronously
 		go func() {
 			var bodyDumps []*dump.Dumper
+			var filters []*dump.BodyFilter
 			for _, dump := range dumps {
 				if dump.RequestBody() {
 					bodyDumps = append(bodyDumps, dump)
+					filters = append(filters, dump.NewBodyFilter(req.Header, req.ContentLength))
 				}
 			}
-			if err := c.sendRequestBody(hstr, req.Body, bodyDumps); err != nil {
+			if err := c.sendRequestBody(hstr, req.Body, bodyDumps, filters); err != nil {
 				c.opt.Debugf("error writing request: %s", err)
 			}
 			if !opt.DontCloseRequestStream {
@@ -70,6 +70,10 @@ type RoundTripper struct {
 	// and will be reused for subsequent connections to other servers.
 	Dial func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error)
 
+	// TLSConfigForHost, if non-nil, returns the TLS configuration used to
+	// dial the host, which is derived from TLSClientConfig.
+	TLSConfigForHost func(hostname string, cfg *tls.Config) *tls.Config
+
 	newClient func(hostname string, tlsConf *tls.Config, opts *roundTripperOpts, conf *quic.Config, dialer dialFunc, opt *transport.Options) (roundTripCloser, error) // so we can mock it in tests
 	clients   map[string]*roundTripCloserWithCount
 	transport *quic.Transport
@@ -137,7 +141,7 @@ func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.
 	cl, isReused, err := r.getClient(hostname, opt.OnlyCachedConn)
 	if err != ErrNoCachedConn {
 		if debugf := r.Debugf; debugf != nil {
-			debugf("HTTP/3 %s %s", req.Method, req.URL.String())
+			debugf("HTTP/3 %s %s", req.Method, req.URL.Redacted())
 		}
 	}
 	if err != nil {
@@ -213,9 +217,13 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (rtc *roundTr
 			}
 			dial = r.makeDialer()
 		}
+		tlsConf := r.TLSClientConfig
+		if r.TLSConfigForHost != nil {
+			tlsConf = r.TLSConfigForHost(hostname, tlsConf)
+		}
 		c, err := newCl(
 			hostname,
-			r.TLSClientConfig,
+			tlsConf,
 			&roundTripperOpts{
 				EnableDatagram:     r.EnableDatagrams,
 				DisableCompression: r.DisableCompression,
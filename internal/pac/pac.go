@@ -0,0 +1,833 @@
+// Package pac evaluates proxy auto-config (PAC) scripts. The subset of
+// JavaScript used by PAC scripts is supported: function and variable
+// declarations, if/else, return, the ternary, logical, comparison and
+// arithmetic operators, string and array literals, and the common string
+// methods, along with the predefined PAC functions (dateRange is not
+// supported). Loops, switch, objects, regular expressions and exceptions
+// are not supported, and the scripts using them fail to parse.
+package pac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCallDepth limits the recursion of the functions in the script.
+const maxCallDepth = 64
+
+// Script is a parsed PAC script, it's safe for concurrent use.
+type Script struct {
+	stmts []node
+}
+
+// Parse parses the PAC script, which must declare the FindProxyForURL
+// function.
+func Parse(src string) (*Script, error) {
+	stmts, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range stmts {
+		if f, ok := stmt.(*funcDecl); ok && f.name == "FindProxyForURL" {
+			return &Script{stmts: stmts}, nil
+		}
+	}
+	return nil, errors.New("pac: FindProxyForURL is not defined")
+}
+
+// FindProxyForURL calls the FindProxyForURL function of the script with the
+// url and host, and returns the result, e.g. "PROXY proxy.local:8080; DIRECT".
+// The ctx is used by the DNS lookups of the predefined functions.
+func (s *Script) FindProxyForURL(ctx context.Context, url, host string) (string, error) {
+	in := &interp{ctx: ctx, globals: newEnv(nil)}
+	for name, fn := range in.builtins() {
+		in.globals.vars[name] = fn
+	}
+	if _, err := in.execBlock(s.stmts, in.globals); err != nil {
+		return "", err
+	}
+	fn, _ := in.globals.vars["FindProxyForURL"].(*function)
+	if fn == nil {
+		return "", errors.New("pac: FindProxyForURL is not a function")
+	}
+	result, err := in.call(fn, []value{url, host})
+	if err != nil {
+		return "", err
+	}
+	str, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("pac: FindProxyForURL returned %s instead of string", toString(result))
+	}
+	return str, nil
+}
+
+// Proxy is an entry of the result of FindProxyForURL.
+type Proxy struct {
+	// Type is "DIRECT", "PROXY", "HTTP", "HTTPS", "SOCKS", "SOCKS4"
+	// or "SOCKS5".
+	Type string
+	// Host is the "host:port" of the proxy, it's empty for "DIRECT".
+	Host string
+}
+
+// ParseResult parses the result of FindProxyForURL into the list of proxies.
+func ParseResult(result string) ([]Proxy, error) {
+	var proxies []Proxy
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		p := Proxy{Type: strings.ToUpper(fields[0])}
+		switch p.Type {
+		case "DIRECT":
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("pac: bad proxy entry %q", entry)
+			}
+		case "PROXY", "HTTP", "HTTPS", "SOCKS", "SOCKS4", "SOCKS5":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("pac: bad proxy entry %q", entry)
+			}
+			p.Host = fields[1]
+		default:
+			return nil, fmt.Errorf("pac: unknown proxy type %q", fields[0])
+		}
+		proxies = append(proxies, p)
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("pac: no proxy in result %q", result)
+	}
+	return proxies, nil
+}
+
+// value is one of nil, bool, float64, string, []value, *function and
+// builtin.
+type value interface{}
+
+type function struct {
+	decl    *funcDecl
+	closure *env
+}
+
+type builtin func(args []value) (value, error)
+
+type env struct {
+	vars   map[string]value
+	parent *env
+}
+
+func newEnv(parent *env) *env {
+	return &env{vars: make(map[string]value), parent: parent}
+}
+
+func (e *env) lookup(name string) (value, bool) {
+	for ; e != nil; e = e.parent {
+		if v, ok := e.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (e *env) assign(name string, v value) {
+	for s := e; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			s.vars[name] = v
+			return
+		}
+		if s.parent == nil { // implicit global
+			s.vars[name] = v
+		}
+	}
+}
+
+type interp struct {
+	ctx     context.Context
+	globals *env
+	depth   int
+}
+
+// returnSignal carries the return value of a function.
+type returnSignal struct {
+	value value
+}
+
+func (in *interp) execBlock(stmts []node, e *env) (*returnSignal, error) {
+	// hoist the function declarations
+	for _, stmt := range stmts {
+		if f, ok := stmt.(*funcDecl); ok {
+			e.vars[f.name] = &function{decl: f, closure: e}
+		}
+	}
+	for _, stmt := range stmts {
+		ret, err := in.exec(stmt, e)
+		if err != nil || ret != nil {
+			return ret, err
+		}
+	}
+	return nil, nil
+}
+
+func (in *interp) exec(stmt node, e *env) (*returnSignal, error) {
+	switch s := stmt.(type) {
+	case nil, *funcDecl:
+		return nil, nil
+	case *varStmt:
+		var v value
+		if s.init != nil {
+			var err error
+			if v, err = in.eval(s.init, e); err != nil {
+				return nil, err
+			}
+		}
+		e.vars[s.name] = v
+	case *assignStmt:
+		v, err := in.eval(s.value, e)
+		if err != nil {
+			return nil, err
+		}
+		e.assign(s.name, v)
+	case *ifStmt:
+		cond, err := in.eval(s.cond, e)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return in.exec(s.then, e)
+		}
+		return in.exec(s.els, e)
+	case *returnStmt:
+		var v value
+		if s.value != nil {
+			var err error
+			if v, err = in.eval(s.value, e); err != nil {
+				return nil, err
+			}
+		}
+		return &returnSignal{value: v}, nil
+	case *blockStmt:
+		for _, stmt := range s.stmts {
+			ret, err := in.exec(stmt, e)
+			if err != nil || ret != nil {
+				return ret, err
+			}
+		}
+	case *exprStmt:
+		_, err := in.eval(s.expr, e)
+		return nil, err
+	default:
+		return nil, fmt.Errorf("pac: unknown statement %T", stmt)
+	}
+	return nil, nil
+}
+
+func (in *interp) eval(n node, e *env) (value, error) {
+	switch x := n.(type) {
+	case *literal:
+		return x.value, nil
+	case *ident:
+		v, ok := e.lookup(x.name)
+		if !ok {
+			return nil, fmt.Errorf("pac: %s is not defined", x.name)
+		}
+		return v, nil
+	case *arrayExpr:
+		arr := make([]value, len(x.elems))
+		for i, elem := range x.elems {
+			v, err := in.eval(elem, e)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	case *unaryExpr:
+		v, err := in.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "!":
+			return !truthy(v), nil
+		case "-":
+			return -toNumber(v), nil
+		default:
+			return toNumber(v), nil
+		}
+	case *binaryExpr:
+		l, err := in.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "&&":
+			if !truthy(l) {
+				return l, nil
+			}
+			return in.eval(x.y, e)
+		case "||":
+			if truthy(l) {
+				return l, nil
+			}
+			return in.eval(x.y, e)
+		}
+		r, err := in.eval(x.y, e)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(x.op, l, r), nil
+	case *condExpr:
+		cond, err := in.eval(x.cond, e)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return in.eval(x.x, e)
+		}
+		return in.eval(x.y, e)
+	case *memberExpr:
+		v, err := in.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		return member(v, x.name)
+	case *indexExpr:
+		v, err := in.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		index, err := in.eval(x.index, e)
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := index.(string); ok {
+			return member(v, name)
+		}
+		i := int(toNumber(index))
+		switch v := v.(type) {
+		case []value:
+			if i >= 0 && i < len(v) {
+				return v[i], nil
+			}
+		case string:
+			if i >= 0 && i < len(v) {
+				return v[i : i+1], nil
+			}
+		}
+		return nil, nil
+	case *callExpr:
+		fn, err := in.eval(x.fn, e)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]value, len(x.args))
+		for i, arg := range x.args {
+			if args[i], err = in.eval(arg, e); err != nil {
+				return nil, err
+			}
+		}
+		return in.call(fn, args)
+	}
+	return nil, fmt.Errorf("pac: unknown expression %T", n)
+}
+
+func (in *interp) call(fn value, args []value) (value, error) {
+	switch f := fn.(type) {
+	case builtin:
+		return f(args)
+	case *function:
+		if in.depth >= maxCallDepth {
+			return nil, errors.New("pac: maximum call depth exceeded")
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+		e := newEnv(f.closure)
+		for i, param := range f.decl.params {
+			if i < len(args) {
+				e.vars[param] = args[i]
+			} else {
+				e.vars[param] = nil
+			}
+		}
+		ret, err := in.execBlock(f.decl.body, e)
+		if err != nil || ret == nil {
+			return nil, err
+		}
+		return ret.value, nil
+	}
+	return nil, fmt.Errorf("pac: %s is not a function", toString(fn))
+}
+
+func truthy(v value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func toNumber(v value) float64 {
+	switch v := v.(type) {
+	case nil:
+		return math.NaN()
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case float64:
+		return v
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	}
+	return math.NaN()
+}
+
+func toString(v value) string {
+	switch v := v.(type) {
+	case nil:
+		return "undefined"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case []value:
+		strs := make([]string, len(v))
+		for i, elem := range v {
+			if elem != nil {
+				strs[i] = toString(elem)
+			}
+		}
+		return strings.Join(strs, ",")
+	case *function:
+		return "function " + v.decl.name
+	}
+	return "function"
+}
+
+func binaryOp(op string, l, r value) value {
+	switch op {
+	case "===":
+		return strictEqual(l, r)
+	case "!==":
+		return !strictEqual(l, r)
+	case "==":
+		return looseEqual(l, r)
+	case "!=":
+		return !looseEqual(l, r)
+	case "+":
+		_, ls := l.(string)
+		_, rs := r.(string)
+		if ls || rs {
+			return toString(l) + toString(r)
+		}
+		return toNumber(l) + toNumber(r)
+	case "-":
+		return toNumber(l) - toNumber(r)
+	case "*":
+		return toNumber(l) * toNumber(r)
+	case "/":
+		return toNumber(l) / toNumber(r)
+	case "%":
+		return math.Mod(toNumber(l), toNumber(r))
+	}
+	// comparison
+	ls, lok := l.(string)
+	rs, rok := r.(string)
+	if lok && rok {
+		switch op {
+		case "<":
+			return ls < rs
+		case ">":
+			return ls > rs
+		case "<=":
+			return ls <= rs
+		default:
+			return ls >= rs
+		}
+	}
+	a, b := toNumber(l), toNumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case ">":
+		return a > b
+	case "<=":
+		return a <= b
+	default:
+		return a >= b
+	}
+}
+
+func strictEqual(l, r value) bool {
+	switch l := l.(type) {
+	case nil, bool, float64, string:
+		return l == r
+	}
+	return false
+}
+
+func looseEqual(l, r value) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if ls, ok := l.(string); ok {
+		if rs, ok := r.(string); ok {
+			return ls == rs
+		}
+	}
+	switch l.(type) {
+	case bool, float64, string:
+		switch r.(type) {
+		case bool, float64, string:
+			return toNumber(l) == toNumber(r)
+		}
+	}
+	return false
+}
+
+// member returns the property of the value, methods are returned as the
+// builtin bound to the value.
+func member(v value, name string) (value, error) {
+	switch v := v.(type) {
+	case string:
+		if name == "length" {
+			return float64(len(v)), nil
+		}
+		if m, ok := stringMethods[name]; ok {
+			return builtin(func(args []value) (value, error) {
+				return m(v, args), nil
+			}), nil
+		}
+	case []value:
+		switch name {
+		case "length":
+			return float64(len(v)), nil
+		case "join":
+			return builtin(func(args []value) (value, error) {
+				sep := ","
+				if len(args) > 0 && args[0] != nil {
+					sep = toString(args[0])
+				}
+				strs := make([]string, len(v))
+				for i, elem := range v {
+					if elem != nil {
+						strs[i] = toString(elem)
+					}
+				}
+				return strings.Join(strs, sep), nil
+			}), nil
+		case "indexOf":
+			return builtin(func(args []value) (value, error) {
+				for i, elem := range v {
+					if len(args) > 0 && strictEqual(elem, args[0]) {
+						return float64(i), nil
+					}
+				}
+				return float64(-1), nil
+			}), nil
+		}
+	case nil:
+		return nil, fmt.Errorf("pac: cannot read property %q of undefined", name)
+	}
+	return nil, nil
+}
+
+func argString(args []value, i int) string {
+	if i < len(args) {
+		return toString(args[i])
+	}
+	return "undefined"
+}
+
+// argInt returns the i-th argument as an integer, def is returned if it's
+// absent or undefined.
+func argInt(args []value, i, def int) int {
+	if i >= len(args) || args[i] == nil {
+		return def
+	}
+	f := toNumber(args[i])
+	if math.IsNaN(f) {
+		return 0
+	}
+	return int(f)
+}
+
+func clamp(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+var stringMethods = map[string]func(s string, args []value) value{
+	"toLowerCase": func(s string, args []value) value { return strings.ToLower(s) },
+	"toUpperCase": func(s string, args []value) value { return strings.ToUpper(s) },
+	"trim":        func(s string, args []value) value { return strings.TrimSpace(s) },
+	"indexOf": func(s string, args []value) value {
+		from := clamp(argInt(args, 1, 0), 0, len(s))
+		i := strings.Index(s[from:], argString(args, 0))
+		if i < 0 {
+			return float64(-1)
+		}
+		return float64(i + from)
+	},
+	"lastIndexOf": func(s string, args []value) value {
+		return float64(strings.LastIndex(s, argString(args, 0)))
+	},
+	"charAt": func(s string, args []value) value {
+		i := argInt(args, 0, 0)
+		if i < 0 || i >= len(s) {
+			return ""
+		}
+		return s[i : i+1]
+	},
+	"substring": func(s string, args []value) value {
+		start := clamp(argInt(args, 0, 0), 0, len(s))
+		end := clamp(argInt(args, 1, len(s)), 0, len(s))
+		if start > end {
+			start, end = end, start
+		}
+		return s[start:end]
+	},
+	"substr": func(s string, args []value) value {
+		start := argInt(args, 0, 0)
+		if start < 0 {
+			start += len(s)
+		}
+		start = clamp(start, 0, len(s))
+		end := clamp(start+argInt(args, 1, len(s)), start, len(s))
+		return s[start:end]
+	},
+	"slice": func(s string, args []value) value {
+		start, end := argInt(args, 0, 0), argInt(args, 1, len(s))
+		if start < 0 {
+			start += len(s)
+		}
+		if end < 0 {
+			end += len(s)
+		}
+		start, end = clamp(start, 0, len(s)), clamp(end, 0, len(s))
+		if start > end {
+			return ""
+		}
+		return s[start:end]
+	},
+	"startsWith": func(s string, args []value) value { return strings.HasPrefix(s, argString(args, 0)) },
+	"endsWith":   func(s string, args []value) value { return strings.HasSuffix(s, argString(args, 0)) },
+	"includes":   func(s string, args []value) value { return strings.Contains(s, argString(args, 0)) },
+	"replace": func(s string, args []value) value {
+		return strings.Replace(s, argString(args, 0), argString(args, 1), 1)
+	},
+	"split": func(s string, args []value) value {
+		var parts []string
+		if len(args) == 0 || args[0] == nil {
+			parts = []string{s}
+		} else {
+			parts = strings.Split(s, toString(args[0]))
+		}
+		arr := make([]value, len(parts))
+		for i, p := range parts {
+			arr[i] = p
+		}
+		return arr
+	},
+}
+
+// LookupIP resolves the host for dnsResolve, isResolvable and isInNet,
+// which can be replaced in tests.
+var LookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// MyIPAddress returns the IP address of the host for myIpAddress.
+var MyIPAddress = func() string {
+	conn, err := net.Dial("udp", "198.51.100.1:80")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// Now returns the current time for weekdayRange and timeRange.
+var Now = time.Now
+
+func (in *interp) resolve(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	ips, err := LookupIP(in.ctx, host)
+	if err != nil || len(ips) == 0 {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return ips[0]
+}
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday, "MON": time.Monday, "TUE": time.Tuesday, "WED": time.Wednesday,
+	"THU": time.Thursday, "FRI": time.Friday, "SAT": time.Saturday,
+}
+
+// gmtArgs strips the trailing "GMT" argument and returns the current time
+// in the corresponding timezone.
+func gmtArgs(args []value) ([]value, time.Time) {
+	now := Now()
+	if n := len(args); n > 0 && toString(args[n-1]) == "GMT" {
+		return args[:n-1], now.UTC()
+	}
+	return args, now
+}
+
+func (in *interp) builtins() map[string]builtin {
+	return map[string]builtin{
+		"isPlainHostName": func(args []value) (value, error) {
+			return !strings.Contains(argString(args, 0), "."), nil
+		},
+		"dnsDomainIs": func(args []value) (value, error) {
+			return strings.HasSuffix(strings.ToLower(argString(args, 0)), strings.ToLower(argString(args, 1))), nil
+		},
+		"localHostOrDomainIs": func(args []value) (value, error) {
+			host, hostdom := strings.ToLower(argString(args, 0)), strings.ToLower(argString(args, 1))
+			if host == hostdom {
+				return true, nil
+			}
+			return !strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+"."), nil
+		},
+		"isResolvable": func(args []value) (value, error) {
+			return in.resolve(argString(args, 0)) != nil, nil
+		},
+		"isInNet": func(args []value) (value, error) {
+			ip := in.resolve(argString(args, 0))
+			pattern := net.ParseIP(argString(args, 1))
+			mask := net.ParseIP(argString(args, 2))
+			if ip == nil || pattern == nil || mask == nil {
+				return false, nil
+			}
+			ip, pattern, mask = ip.To4(), pattern.To4(), mask.To4()
+			if ip == nil || pattern == nil || mask == nil {
+				return false, nil
+			}
+			m := net.IPMask(mask)
+			return ip.Mask(m).Equal(pattern.Mask(m)), nil
+		},
+		"dnsResolve": func(args []value) (value, error) {
+			if ip := in.resolve(argString(args, 0)); ip != nil {
+				return ip.String(), nil
+			}
+			return nil, nil
+		},
+		"myIpAddress": func(args []value) (value, error) {
+			return MyIPAddress(), nil
+		},
+		"dnsDomainLevels": func(args []value) (value, error) {
+			return float64(strings.Count(argString(args, 0), ".")), nil
+		},
+		"shExpMatch": func(args []value) (value, error) {
+			return shExpMatch(argString(args, 0), argString(args, 1)), nil
+		},
+		"weekdayRange": func(args []value) (value, error) {
+			args, now := gmtArgs(args)
+			if len(args) == 0 {
+				return false, nil
+			}
+			wd1, ok1 := weekdays[strings.ToUpper(toString(args[0]))]
+			wd2, ok2 := wd1, true
+			if len(args) > 1 {
+				wd2, ok2 = weekdays[strings.ToUpper(toString(args[1]))]
+			}
+			if !ok1 || !ok2 {
+				return false, nil
+			}
+			d := now.Weekday()
+			if wd1 <= wd2 {
+				return wd1 <= d && d <= wd2, nil
+			}
+			return d >= wd1 || d <= wd2, nil
+		},
+		"timeRange": func(args []value) (value, error) {
+			args, now := gmtArgs(args)
+			secs := now.Hour()*3600 + now.Minute()*60 + now.Second()
+			var start, end int
+			switch len(args) {
+			case 1:
+				h := argInt(args, 0, 0)
+				return now.Hour() == h, nil
+			case 2:
+				start, end = argInt(args, 0, 0)*3600, (argInt(args, 1, 0)+1)*3600-1
+			case 4:
+				start = argInt(args, 0, 0)*3600 + argInt(args, 1, 0)*60
+				end = argInt(args, 2, 0)*3600 + argInt(args, 3, 0)*60 + 59
+			case 6:
+				start = argInt(args, 0, 0)*3600 + argInt(args, 1, 0)*60 + argInt(args, 2, 0)
+				end = argInt(args, 3, 0)*3600 + argInt(args, 4, 0)*60 + argInt(args, 5, 0)
+			default:
+				return false, nil
+			}
+			if start <= end {
+				return start <= secs && secs <= end, nil
+			}
+			return secs >= start || secs <= end, nil
+		},
+		"dateRange": func(args []value) (value, error) {
+			return nil, errors.New("pac: dateRange is not supported")
+		},
+		"alert": func(args []value) (value, error) {
+			return nil, nil
+		},
+	}
+}
+
+// shExpMatch reports whether the str matches the shell expression, where
+// "*" matches any sequence of characters and "?" matches one character.
+func shExpMatch(str, shexp string) bool {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, c := range shexp {
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(str)
+}
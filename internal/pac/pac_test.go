@@ -0,0 +1,110 @@
+package pac
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3/internal/tests"
+)
+
+const testScript = `
+// proxies of the office
+var office = "PROXY proxy.local:8080";
+
+function isInternal(host) {
+	return dnsDomainIs(host, ".corp.local") || isPlainHostName(host);
+}
+
+function FindProxyForURL(url, host) {
+	host = host.toLowerCase();
+	if (isInternal(host) || isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0"))
+		return "DIRECT";
+	if (shExpMatch(url, "https://*.example.com/*")) {
+		return "HTTPS secure.local:443; " + office;
+	} else if (url.substring(0, 5) == "http:" && localHostOrDomainIs(host, "www.example.org")) {
+		return office;
+	}
+	/* fallback */
+	return dnsDomainLevels(host) > 2 ? "SOCKS5 socks.local:1080" : office + "; DIRECT";
+}
+`
+
+func TestFindProxyForURL(t *testing.T) {
+	LookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+		if host == "db.internal" {
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host}
+	}
+	defer func() {
+		LookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		}
+	}()
+
+	s, err := Parse(testScript)
+	tests.AssertNoError(t, err)
+	cases := []struct {
+		url, host, result string
+	}{
+		{"http://intranet/", "intranet", "DIRECT"},
+		{"http://wiki.corp.local/", "WIKI.corp.local", "DIRECT"},
+		{"http://db.internal/", "db.internal", "DIRECT"},
+		{"https://api.example.com/v1", "api.example.com", "HTTPS secure.local:443; PROXY proxy.local:8080"},
+		{"http://www/", "www", "DIRECT"},
+		{"http://www.example.org/", "www.example.org", "PROXY proxy.local:8080"},
+		{"http://a.b.c.d/", "a.b.c.d", "SOCKS5 socks.local:1080"},
+		{"http://example.net/", "example.net", "PROXY proxy.local:8080; DIRECT"},
+	}
+	for _, c := range cases {
+		result, err := s.FindProxyForURL(context.Background(), c.url, c.host)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, c.result, result)
+	}
+
+	proxies, err := ParseResult("HTTPS secure.local:443; PROXY proxy.local:8080;DIRECT")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, []Proxy{
+		{Type: "HTTPS", Host: "secure.local:443"},
+		{Type: "PROXY", Host: "proxy.local:8080"},
+		{Type: "DIRECT"},
+	}, proxies)
+	_, err = ParseResult("FTP ftp.local:21")
+	tests.AssertErrorContains(t, err, "unknown proxy type")
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(`function foo() { return "DIRECT"; }`)
+	tests.AssertErrorContains(t, err, "FindProxyForURL is not defined")
+	_, err = Parse(`function FindProxyForURL(url, host) { return "DIRECT"; `)
+	tests.AssertErrorContains(t, err, "expected \"}\"")
+	_, err = Parse(`function FindProxyForURL(url, host) { return 'DIRECT }`)
+	tests.AssertErrorContains(t, err, "unterminated string")
+
+	s, err := Parse(`function FindProxyForURL(url, host) { return FindProxyForURL(url, host); }`)
+	tests.AssertNoError(t, err)
+	_, err = s.FindProxyForURL(context.Background(), "http://a/", "a")
+	tests.AssertErrorContains(t, err, "maximum call depth")
+
+	s, err = Parse(`function FindProxyForURL(url, host) { return unknown(host); }`)
+	tests.AssertNoError(t, err)
+	_, err = s.FindProxyForURL(context.Background(), "http://a/", "a")
+	tests.AssertErrorContains(t, err, "unknown is not defined")
+}
+
+func TestTimeFunctions(t *testing.T) {
+	Now = func() time.Time {
+		return time.Date(2024, 3, 6, 14, 30, 0, 0, time.UTC) // Wednesday
+	}
+	defer func() { Now = time.Now }()
+	s, err := Parse(`function FindProxyForURL(url, host) {
+		return [weekdayRange("MON", "FRI", "GMT"), weekdayRange("SAT", "MON", "GMT"),
+			timeRange(9, 17, "GMT"), timeRange(14, "GMT"), timeRange(15, 0, 8, 0, "GMT")].join(",");
+	}`)
+	tests.AssertNoError(t, err)
+	result, err := s.FindProxyForURL(context.Background(), "http://a/", "a")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "true,false,true,true,false", result)
+}
@@ -0,0 +1,529 @@
+package pac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lex splits the script into tokens, comments are skipped.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	puncts := []string{"===", "!==", "==", "!=", "<=", ">=", "&&", "||",
+		"(", ")", "{", "}", "[", "]", ",", ";", ".", "=", "!", "+", "-",
+		"*", "/", "%", "<", ">", "?", ":"}
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("pac: unterminated comment at %d", i)
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for ; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+					switch src[i] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[i])
+					}
+					continue
+				}
+				sb.WriteByte(src[i])
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("pac: unterminated string at %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] == '$' || src[i] >= 'a' && src[i] <= 'z' ||
+				src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			matched := false
+			for _, p := range puncts {
+				if strings.HasPrefix(src[i:], p) {
+					tokens = append(tokens, token{tokPunct, p, i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("pac: unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+type node interface{}
+
+type (
+	funcDecl struct {
+		name   string
+		params []string
+		body   []node
+	}
+	varStmt struct {
+		name string
+		init node
+	}
+	assignStmt struct {
+		name  string
+		value node
+	}
+	ifStmt struct {
+		cond node
+		then node
+		els  node
+	}
+	returnStmt struct {
+		value node
+	}
+	blockStmt struct {
+		stmts []node
+	}
+	exprStmt struct {
+		expr node
+	}
+
+	literal struct {
+		value value
+	}
+	ident struct {
+		name string
+	}
+	unaryExpr struct {
+		op string
+		x  node
+	}
+	binaryExpr struct {
+		op   string
+		x, y node
+	}
+	condExpr struct {
+		cond, x, y node
+	}
+	callExpr struct {
+		fn   node
+		args []node
+	}
+	memberExpr struct {
+		x    node
+		name string
+	}
+	indexExpr struct {
+		x, index node
+	}
+	arrayExpr struct {
+		elems []node
+	}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(values ...string) bool {
+	t := p.peek()
+	if t.kind != tokPunct && t.kind != tokIdent {
+		return false
+	}
+	for _, v := range values {
+		if t.value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) accept(value string) bool {
+	if p.is(value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) error {
+	t := p.next()
+	if (t.kind != tokPunct && t.kind != tokIdent) || t.value != value {
+		return p.errorf(t, "expected %q", value)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf(t, "expected identifier")
+	}
+	return t.value, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	got := t.value
+	if t.kind == tokEOF {
+		got = "EOF"
+	}
+	return fmt.Errorf("pac: %s at %d, got %q", fmt.Sprintf(format, args...), t.pos, got)
+}
+
+// parse parses the script into a list of statements.
+func parse(src string) ([]node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var stmts []node
+	for p.peek().kind != tokEOF {
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if stmt != nil {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts, nil
+}
+
+func (p *parser) statement() (node, error) {
+	switch {
+	case p.accept(";"):
+		return nil, nil
+	case p.is("{"):
+		return p.block()
+	case p.accept("function"):
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		var params []string
+		for !p.accept(")") {
+			if len(params) > 0 {
+				if err = p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			param, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, param)
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &funcDecl{name: name, params: params, body: body.stmts}, nil
+	case p.is("var", "let", "const"):
+		p.next()
+		var stmts []node
+		for {
+			name, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			stmt := &varStmt{name: name}
+			if p.accept("=") {
+				if stmt.init, err = p.expression(); err != nil {
+					return nil, err
+				}
+			}
+			stmts = append(stmts, stmt)
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.accept(";")
+		if len(stmts) == 1 {
+			return stmts[0], nil
+		}
+		return &blockStmt{stmts: stmts}, nil
+	case p.accept("if"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		stmt := &ifStmt{cond: cond}
+		if stmt.then, err = p.statement(); err != nil {
+			return nil, err
+		}
+		if p.accept("else") {
+			if stmt.els, err = p.statement(); err != nil {
+				return nil, err
+			}
+		}
+		return stmt, nil
+	case p.accept("return"):
+		stmt := &returnStmt{}
+		if !p.is(";", "}") {
+			var err error
+			if stmt.value, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+		p.accept(";")
+		return stmt, nil
+	}
+	if t := p.peek(); t.kind == tokIdent && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].value == "=" {
+		p.pos += 2
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		p.accept(";")
+		return &assignStmt{name: t.value, value: value}, nil
+	}
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	p.accept(";")
+	return &exprStmt{expr: expr}, nil
+}
+
+func (p *parser) block() (*blockStmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	b := &blockStmt{}
+	for !p.accept("}") {
+		if p.peek().kind == tokEOF {
+			return nil, p.errorf(p.peek(), "expected %q", "}")
+		}
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if stmt != nil {
+			b.stmts = append(b.stmts, stmt)
+		}
+	}
+	return b, nil
+}
+
+func (p *parser) expression() (node, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return cond, nil
+	}
+	x, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	y, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &condExpr{cond: cond, x: x, y: y}, nil
+}
+
+// binaryLevels lists the binary operators from the lowest precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "===", "!=="},
+	{"<", ">", "<=", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokPunct && p.is(binaryLevels[level]...) {
+		op := p.next().value
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.peek().kind == tokPunct && p.is("!", "-", "+") {
+		op := p.next().value
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("("):
+			call := &callExpr{fn: x}
+			for !p.accept(")") {
+				if len(call.args) > 0 {
+					if err = p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				arg, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+			}
+			x = call
+		case p.accept("."):
+			name, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			x = &memberExpr{x: x, name: name}
+		case p.accept("["):
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexExpr{x: x, index: index}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &literal{value: t.value}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, p.errorf(t, "bad number")
+		}
+		return &literal{value: f}, nil
+	case tokIdent:
+		switch t.value {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null", "undefined":
+			return &literal{value: nil}, nil
+		}
+		return &ident{name: t.value}, nil
+	case tokPunct:
+		switch t.value {
+		case "(":
+			x, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			arr := &arrayExpr{}
+			for !p.accept("]") {
+				if len(arr.elems) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				elem, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				arr.elems = append(arr.elems, elem)
+			}
+			return arr, nil
+		}
+	}
+	return nil, p.errorf(t, "unexpected token")
+}
@@ -0,0 +1,184 @@
+// Package rotate provides an append-only file writer which rotates the
+// file once it reaches the max size.
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000000000"
+
+// Writer is an append-only file writer with size based rotation, the
+// rotated files are renamed with a timestamp suffix, e.g. "audit.log.20240102T150405.000000000".
+type Writer struct {
+	mu         sync.Mutex
+	filename   string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	closed     bool
+}
+
+// New opens (or creates) the file in append mode. The file is rotated
+// before it exceeds maxSize bytes if maxSize > 0, and at most maxBackups
+// rotated files are retained if maxBackups > 0.
+func New(filename string, maxSize int64, maxBackups int) (*Writer, error) {
+	w := &Writer{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Create is like New, but truncates the file if it exists, and the file is
+// never rotated by size.
+func Create(filename string) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{filename: filename, file: file}, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, the file is rotated first if the size
+// would exceed the max size.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err = w.open(); err != nil {
+			return
+		}
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err = w.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return
+}
+
+// Rotate rotates the file immediately.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+	backup := w.filename + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(w.filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.removeOldBackups()
+	return nil
+}
+
+func (w *Writer) removeOldBackups() {
+	if w.maxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(w.filename + ".*")
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, m := range matches {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(m, w.filename+".")); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	if len(backups) <= w.maxBackups {
+		return
+	}
+	sort.Strings(backups) // timestamp suffix sorts in chronological order
+	for _, backup := range backups[:len(backups)-w.maxBackups] {
+		os.Remove(backup)
+	}
+}
+
+// Close closes the file, the Writer can't be written, rotated or reopened
+// after it's closed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Release closes the file but keeps the Writer usable, the file is
+// reopened in append mode on next Write.
+func (w *Writer) Release() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Reopen closes and reopens the file, which is used after the file is
+// moved by the external tools such as logrotate.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+	return w.open()
+}
@@ -17,7 +17,7 @@ type Options struct {
 	// request is aborted with the provided error.
 	//
 	// The proxy type is determined by the URL scheme. "http",
-	// "https", and "socks5" are supported. If the scheme is empty,
+	// "https", "socks5" and "socks5h" are supported. If the scheme is empty,
 	// "http" is assumed.
 	//
 	// If Proxy is nil or returns a nil *URL, no proxy is used.
@@ -62,6 +62,10 @@ type Options struct {
 	// wait for a TLS handshake. Zero means no timeout.
 	TLSHandshakeTimeout time.Duration
 
+	// DialTimeout specifies the maximum amount of time to wait for
+	// a dial to complete. Zero means no timeout.
+	DialTimeout time.Duration
+
 	// DisableKeepAlives, if true, disables HTTP keep-alives and
 	// will only use the connection to the server for a single
 	// HTTP request.
@@ -116,6 +120,12 @@ type Options struct {
 	// time does not include the time to read the response body.
 	ResponseHeaderTimeout time.Duration
 
+	// ResponseBodyReadIdleTimeout, if non-zero, specifies the maximum
+	// amount of time to wait for the next chunk of data while reading
+	// the response body. The timer is reset every time data is received,
+	// so it does not limit the total time of reading the response body.
+	ResponseBodyReadIdleTimeout time.Duration
+
 	// ExpectContinueTimeout, if non-zero, specifies the amount of
 	// time to wait for a server's first response headers after fully
 	// writing the request headers if the request has an
@@ -0,0 +1,59 @@
+package req
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// LocaleHookFunc is invoked with the locale selected for the request, can be
+// used to format locale-sensitive query parameters (e.g. dates and numbers).
+type LocaleHookFunc func(locale string, req *Request)
+
+type localeRotator struct {
+	tags []string
+	next uint32
+}
+
+func (l *localeRotator) pick() string {
+	if l == nil || len(l.tags) == 0 {
+		return ""
+	}
+	n := atomic.AddUint32(&l.next, 1) - 1
+	return l.tags[int(n%uint32(len(l.tags)))]
+}
+
+// acceptLanguage returns the Accept-Language header value of the locale,
+// the base language will be appended with a lower quality if the locale
+// contains a region (e.g. "fr-FR" -> "fr-FR,fr;q=0.9").
+func acceptLanguage(locale string) string {
+	if lang, _, found := strings.Cut(locale, "-"); found && lang != "" {
+		return locale + "," + lang + ";q=0.9"
+	}
+	return locale
+}
+
+func handleLocale(c *Client, r *Request) error {
+	if r.locale == "" {
+		if c.locales == nil {
+			return nil
+		}
+		// the explicit Accept-Language of the request or the client takes
+		// precedence over the locales of the client
+		if r.getHeader("Accept-Language") != "" || c.Headers.Get("Accept-Language") != "" {
+			return nil
+		}
+		r.locale = c.locales.pick()
+	}
+	if r.locale == "" {
+		return nil
+	}
+	if r.RetryAttempt == 0 {
+		if r.getHeader("Accept-Language") == "" {
+			r.SetHeader("Accept-Language", acceptLanguage(r.locale))
+		}
+		if c.localeHook != nil {
+			c.localeHook(r.locale, r)
+		}
+	}
+	return nil
+}
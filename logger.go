@@ -1,9 +1,13 @@
 package req
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 // Logger is the abstract logging interface, gives control to
@@ -14,6 +18,19 @@ type Logger interface {
 	Debugf(format string, v ...interface{})
 }
 
+// StructuredLogger is the Logger which supports the leveled, key-value
+// logging, e.g. *zap.SugaredLogger, or the logger created by NewSlogLogger.
+// If the logger of the client or request implements it, req emits the logs
+// which carry fields (e.g. the request log of the debug log, see
+// Client.EnableDebugLog) with the key-value pairs instead of formatting them
+// into the message.
+type StructuredLogger interface {
+	Logger
+	Errorw(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Debugw(msg string, keysAndValues ...interface{})
+}
+
 // NewLogger create a Logger wraps the *log.Logger
 func NewLogger(output io.Writer, prefix string, flag int) Logger {
 	return &logger{l: log.New(output, prefix, flag)}
@@ -27,7 +44,20 @@ func createDefaultLogger() Logger {
 	return NewLogger(os.Stdout, "", log.Ldate|log.Lmicroseconds)
 }
 
-var _ Logger = (*logger)(nil)
+// NewSlogLogger create a StructuredLogger wraps the *slog.Logger, the
+// default slog logger is used if l is nil.
+func NewSlogLogger(l *slog.Logger) StructuredLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+var (
+	_ StructuredLogger = (*logger)(nil)
+	_ StructuredLogger = (*slogLogger)(nil)
+	_ StructuredLogger = (*disableLogger)(nil)
+)
 
 type disableLogger struct{}
 
@@ -35,6 +65,10 @@ func (l *disableLogger) Errorf(format string, v ...interface{}) {}
 func (l *disableLogger) Warnf(format string, v ...interface{})  {}
 func (l *disableLogger) Debugf(format string, v ...interface{}) {}
 
+func (l *disableLogger) Errorw(msg string, keysAndValues ...interface{}) {}
+func (l *disableLogger) Warnw(msg string, keysAndValues ...interface{})  {}
+func (l *disableLogger) Debugw(msg string, keysAndValues ...interface{}) {}
+
 type logger struct {
 	l *log.Logger
 }
@@ -51,6 +85,18 @@ func (l *logger) Debugf(format string, v ...interface{}) {
 	l.output("DEBUG", format, v...)
 }
 
+func (l *logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.output("ERROR", "%s", formatKeysAndValues(msg, keysAndValues))
+}
+
+func (l *logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.output("WARN", "%s", formatKeysAndValues(msg, keysAndValues))
+}
+
+func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.output("DEBUG", "%s", formatKeysAndValues(msg, keysAndValues))
+}
+
 func (l *logger) output(level, format string, v ...interface{}) {
 	format = level + " [req] " + format
 	if len(v) == 0 {
@@ -59,3 +105,64 @@ func (l *logger) output(level, format string, v ...interface{}) {
 	}
 	l.l.Printf(format, v...)
 }
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (l *slogLogger) Errorf(format string, v ...interface{}) {
+	l.l.Error(fmt.Sprintf(format, v...))
+}
+
+func (l *slogLogger) Warnf(format string, v ...interface{}) {
+	l.l.Warn(fmt.Sprintf(format, v...))
+}
+
+func (l *slogLogger) Debugf(format string, v ...interface{}) {
+	l.l.Debug(fmt.Sprintf(format, v...))
+}
+
+func (l *slogLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
+
+func (l *slogLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (l *slogLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.l.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+// formatKeysAndValues formats the message and key-value pairs as
+// "msg key1=value1 key2=value2" for the printf-style loggers.
+func formatKeysAndValues(msg string, keysAndValues []interface{}) string {
+	if len(keysAndValues) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteByte(' ')
+		if i+1 == len(keysAndValues) {
+			fmt.Fprintf(&b, "!BADKEY=%v", keysAndValues[i])
+			break
+		}
+		v := fmt.Sprint(keysAndValues[i+1])
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, "%v=%s", keysAndValues[i], v)
+	}
+	return b.String()
+}
+
+// logDebugw emits the debug log with the key-value pairs, which are
+// formatted into the message if l is not a StructuredLogger.
+func logDebugw(l Logger, msg string, keysAndValues ...interface{}) {
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.Debugw(msg, keysAndValues...)
+		return
+	}
+	l.Debugf("%s", formatKeysAndValues(msg, keysAndValues))
+}
@@ -3,6 +3,7 @@ package req
 import (
 	"bytes"
 	"log"
+	"log/slog"
 	"testing"
 
 	"github.com/imroc/req/v3/internal/tests"
@@ -29,3 +30,40 @@ func TestLoggerFromStandardLogger(t *testing.T) {
 	c.R().SetOutput(nil)
 	tests.AssertContains(t, buf.String(), "warn", true)
 }
+
+func TestSlogLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	c := tc().SetLogger(l).EnableDebugLog()
+	c.SetProxyURL(":=\\<>ksfj&*&sf")
+	tests.AssertContains(t, buf.String(), "level=error", true)
+	buf.Reset()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), `level=debug msg="request completed" method=get`, true)
+	tests.AssertContains(t, buf.String(), "status=200 duration=", true)
+}
+
+func TestRequestDebugLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := tc().SetLogger(NewLogger(buf, "", 0))
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", buf.String())
+
+	c.EnableDebugLog()
+	resp, err = c.R().Get("/search?username=imroc&type=json")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), "debug [req] request completed method=get url=", true)
+	tests.AssertContains(t, buf.String(), `/search?username=imroc&type=json" status=200 duration=`, true)
+
+	buf.Reset()
+	resp, err = c.R().SetLabels(map[string]string{"operation": "Search", "tenant": "t1"}).Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, buf.String(), `labels="operation=search, tenant=t1"`, true)
+}
+
+func TestFormatKeysAndValues(t *testing.T) {
+	tests.AssertEqual(t, "msg", formatKeysAndValues("msg", nil))
+	tests.AssertEqual(t, `msg a=1 b="x y" c="" !BADKEY=d`, formatKeysAndValues("msg", []interface{}{"a", 1, "b", "x y", "c", "", "d"}))
+}
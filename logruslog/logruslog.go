@@ -0,0 +1,67 @@
+// Package logruslog adapts the logrus logger to the req.StructuredLogger.
+//
+//	client := req.C().SetLogger(logruslog.New(logrus.StandardLogger()))
+package logruslog
+
+import (
+	"fmt"
+
+	"github.com/imroc/req/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// New creates a req.StructuredLogger which logs with l, e.g. a *logrus.Logger
+// or a *logrus.Entry with the contextual fields, the standard logger is used
+// if l is nil. The key-value pairs are logged as the logrus fields.
+func New(l logrus.FieldLogger) req.StructuredLogger {
+	if l == nil {
+		l = logrus.StandardLogger()
+	}
+	return &logger{l: l}
+}
+
+type logger struct {
+	l logrus.FieldLogger
+}
+
+func (l *logger) Errorf(format string, v ...interface{}) {
+	l.l.Errorf(format, v...)
+}
+
+func (l *logger) Warnf(format string, v ...interface{}) {
+	l.l.Warnf(format, v...)
+}
+
+func (l *logger) Debugf(format string, v ...interface{}) {
+	l.l.Debugf(format, v...)
+}
+
+func (l *logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.l.WithFields(fields(keysAndValues)).Error(msg)
+}
+
+func (l *logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.l.WithFields(fields(keysAndValues)).Warn(msg)
+}
+
+func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.l.WithFields(fields(keysAndValues)).Debug(msg)
+}
+
+// fields converts the key-value pairs to the logrus fields, the value of
+// the dangling key is logged with the "!BADKEY" key.
+func fields(keysAndValues []interface{}) logrus.Fields {
+	f := make(logrus.Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			f["!BADKEY"] = keysAndValues[i]
+			break
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		f[key] = keysAndValues[i+1]
+	}
+	return f
+}
@@ -0,0 +1,53 @@
+package logruslog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	l, hook := test.NewNullLogger()
+	l.SetLevel(logrus.DebugLevel)
+	client := req.C().SetLogger(New(l)).EnableDebugLog()
+	if _, err := client.R().Get(server.URL + "/tea"); err != nil {
+		t.Fatal(err)
+	}
+	var entry *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "request completed" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("missing request log")
+	}
+	if entry.Level != logrus.DebugLevel {
+		t.Errorf("unexpected level %v", entry.Level)
+	}
+	if entry.Data["method"] != http.MethodGet {
+		t.Errorf("unexpected method %v", entry.Data["method"])
+	}
+	if entry.Data["status"] != http.StatusTeapot {
+		t.Errorf("unexpected status %v", entry.Data["status"])
+	}
+	if _, ok := entry.Data["duration"]; !ok {
+		t.Error("missing duration")
+	}
+}
+
+func TestFields(t *testing.T) {
+	f := fields([]interface{}{"a", 1, 2, "b", "dangling"})
+	if f["a"] != 1 || f["2"] != "b" || f["!BADKEY"] != "dangling" {
+		t.Errorf("unexpected fields %v", f)
+	}
+}
@@ -0,0 +1,183 @@
+// Package metrics exports the metrics of requests fired from req clients
+// as Prometheus collectors.
+//
+//	m := metrics.New()
+//	prometheus.MustRegister(m)
+//	client := m.Instrument(req.C())
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultNamespace = "req"
+
+// Options is the options of New.
+type Options struct {
+	// Namespace is the namespace of the metrics, default is "req".
+	Namespace string
+	// Subsystem is the subsystem of the metrics.
+	Subsystem string
+	// Buckets is the buckets of the request duration histogram in seconds,
+	// default is prometheus.DefBuckets.
+	Buckets []float64
+	// ConstLabels is the constant labels attached to all the metrics, e.g.
+	// the name of the client.
+	ConstLabels prometheus.Labels
+	// RequestLabels is the keys of the request labels (see
+	// req.Request.SetLabel) added as the labels of the requests, duration
+	// and retries metrics, e.g. "operation", the value is empty if the
+	// request doesn't have the label. The keys must be valid Prometheus
+	// label names, and the label values should be low-cardinality.
+	RequestLabels []string
+}
+
+// Metrics holds the collectors of the request metrics:
+//
+//   - <namespace>_requests_total{method,host,status}: the requests sent,
+//     including the retries, status is "error" if no response is received.
+//   - <namespace>_request_duration_seconds{method,host,status}: the
+//     duration of the requests, including reading the response body if
+//     it's read automatically.
+//   - <namespace>_in_flight_requests{host}: the requests in flight (until the
+//     response header is received).
+//   - <namespace>_retries_total{method,host}: the retries.
+//
+// The request labels of Options.RequestLabels are added to all the metrics
+// except the requests in flight.
+//
+// Metrics implements prometheus.Collector, it should be registered to the
+// prometheus registry, and can be shared by multiple clients.
+type Metrics struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	inFlight      *prometheus.GaugeVec
+	retries       *prometheus.CounterVec
+	requestLabels []string
+}
+
+// New creates the Metrics.
+func New(opts ...*Options) *Metrics {
+	var o Options
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.Namespace == "" {
+		o.Namespace = defaultNamespace
+	}
+	if len(o.Buckets) == 0 {
+		o.Buckets = prometheus.DefBuckets
+	}
+	labels := func(names ...string) []string {
+		return append(names, o.RequestLabels...)
+	}
+	return &Metrics{
+		requestLabels: o.RequestLabels,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.Namespace,
+			Subsystem:   o.Subsystem,
+			Name:        "requests_total",
+			Help:        "Total number of HTTP requests sent, including retries.",
+			ConstLabels: o.ConstLabels,
+		}, labels("method", "host", "status")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.Namespace,
+			Subsystem:   o.Subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Duration of HTTP requests in seconds.",
+			Buckets:     o.Buckets,
+			ConstLabels: o.ConstLabels,
+		}, labels("method", "host", "status")),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   o.Namespace,
+			Subsystem:   o.Subsystem,
+			Name:        "in_flight_requests",
+			Help:        "Number of HTTP requests in flight.",
+			ConstLabels: o.ConstLabels,
+		}, []string{"host"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.Namespace,
+			Subsystem:   o.Subsystem,
+			Name:        "retries_total",
+			Help:        "Total number of HTTP request retries.",
+			ConstLabels: o.ConstLabels,
+		}, labels("method", "host")),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.inFlight.Describe(ch)
+	m.retries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.inFlight.Collect(ch)
+	m.retries.Collect(ch)
+}
+
+// Instrument instruments the client with the metrics: the requests and
+// their durations are recorded by the response middleware, the requests
+// in flight by the transport, and the retries by the retry hook.
+func (m *Metrics) Instrument(c *req.Client) *req.Client {
+	c.GetTransport().WrapRoundTripFunc(m.transportHook)
+	return c.OnAfterResponse(m.ResponseMiddleware).AddCommonRetryHook(m.RetryHook)
+}
+
+// ResponseMiddleware records the request and its duration, it's added by
+// Instrument, use it directly only if the client is not instrumented.
+func (m *Metrics) ResponseMiddleware(client *req.Client, resp *req.Response) error {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return nil
+	}
+	status := "error"
+	if resp.Response != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	values := m.labelValues(resp.Request, resp.Request.Method, resp.Request.URL.Host, status)
+	m.requests.WithLabelValues(values...).Inc()
+	m.duration.WithLabelValues(values...).Observe(duration(resp).Seconds())
+	return nil
+}
+
+// RetryHook counts the retry, it's added by Instrument.
+func (m *Metrics) RetryHook(resp *req.Response, err error) {
+	if resp == nil || resp.Request == nil || resp.Request.URL == nil {
+		return
+	}
+	m.retries.WithLabelValues(m.labelValues(resp.Request, resp.Request.Method, resp.Request.URL.Host)...).Inc()
+}
+
+// labelValues appends the values of the request labels to the values.
+func (m *Metrics) labelValues(r *req.Request, values ...string) []string {
+	for _, key := range m.requestLabels {
+		values = append(values, r.GetLabel(key))
+	}
+	return values
+}
+
+func (m *Metrics) transportHook(rt http.RoundTripper) req.HttpRoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		g := m.inFlight.WithLabelValues(r.URL.Host)
+		g.Inc()
+		defer g.Dec()
+		return rt.RoundTrip(r)
+	}
+}
+
+func duration(resp *req.Response) time.Duration {
+	if d := resp.TotalTime(); d > 0 {
+		return d
+	}
+	return time.Since(resp.Request.StartTime)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" {
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	m := New(&Options{Namespace: "test"})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(m)
+	c := m.Instrument(req.C().SetBaseURL(server.URL))
+
+	resp, err := c.R().Get("/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response: %v, %v", resp, err)
+	}
+	resp, err = c.R().SetRetryCount(3).AddRetryCondition(func(resp *req.Response, err error) bool {
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}).Post("/flaky")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response: %v, %v", resp, err)
+	}
+
+	assertValue := func(c prometheus.Collector, expected float64) {
+		t.Helper()
+		if v := testutil.ToFloat64(c); v != expected {
+			t.Errorf("expected %v, got %v", expected, v)
+		}
+	}
+	assertValue(m.requests.WithLabelValues("GET", host, "200"), 1)
+	assertValue(m.requests.WithLabelValues("POST", host, "503"), 2)
+	assertValue(m.requests.WithLabelValues("POST", host, "200"), 1)
+	assertValue(m.retries.WithLabelValues("POST", host), 2)
+	assertValue(m.inFlight.WithLabelValues(host), 0)
+	if n := testutil.CollectAndCount(m.duration); n != 3 {
+		t.Errorf("expected 3 duration series, got %d", n)
+	}
+
+	_, err = c.R().Get("http://127.0.0.1:1/")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	assertValue(m.requests.WithLabelValues("GET", "127.0.0.1:1", "error"), 1)
+
+	// the request labels
+	m = New(&Options{RequestLabels: []string{"operation"}})
+	c = m.Instrument(req.C().SetBaseURL(server.URL))
+	resp, err = c.R().SetLabel("operation", "Home").Get("/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response: %v, %v", resp, err)
+	}
+	resp, err = c.R().Get("/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response: %v, %v", resp, err)
+	}
+	assertValue(m.requests.WithLabelValues("GET", host, "200", "Home"), 1)
+	assertValue(m.requests.WithLabelValues("GET", host, "200", ""), 1)
+}
@@ -2,7 +2,9 @@ package req
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
@@ -10,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"time"
 
@@ -25,6 +28,53 @@ type (
 	ResponseMiddleware func(client *Client, resp *Response) error
 )
 
+// MiddlewareError is the error returned by a response middleware with the
+// name of the middleware. When multiple response middlewares fail, their
+// errors are joined (see errors.Join) as MiddlewareError, which can be
+// inspected with errors.Is and errors.As, otherwise the only error is
+// returned as is.
+type MiddlewareError struct {
+	// Name is the function name of the middleware, e.g. "main.checkStatus"
+	// or "main.main.func1" for anonymous functions.
+	Name string
+	Err  error
+}
+
+func (e *MiddlewareError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *MiddlewareError) Unwrap() error {
+	return e.Err
+}
+
+func newMiddlewareError(m interface{}, err error) *MiddlewareError {
+	name := "unknown"
+	if f := runtime.FuncForPC(reflect.ValueOf(m).Pointer()); f != nil {
+		name = f.Name()
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+	}
+	return &MiddlewareError{Name: name, Err: err}
+}
+
+// joinMiddlewareErrors returns the only error as is, or the joined errors
+// if multiple middlewares fail.
+func joinMiddlewareErrors(errs []*MiddlewareError) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0].Err
+	}
+	joined := make([]error, len(errs))
+	for i, e := range errs {
+		joined[i] = e
+	}
+	return errors.Join(joined...)
+}
+
 func createMultipartHeader(file *FileUpload, contentType string) textproto.MIMEHeader {
 	hdr := make(textproto.MIMEHeader)
 
@@ -72,7 +122,8 @@ func writeMultipartFormFile(w *multipart.Writer, file *FileUpload, r *Request) e
 			}
 		}
 	}
-	// Auto detect actual multipart content type
+	// Auto detect actual multipart content type, by file extension first
+	// and then by content sniffing
 	cbuf := make([]byte, 512)
 	seeEOF := false
 	lastTime := time.Now()
@@ -87,14 +138,17 @@ func writeMultipartFormFile(w *multipart.Writer, file *FileUpload, r *Request) e
 
 	ct := file.ContentType
 	if ct == "" {
-		ct = http.DetectContentType(cbuf)
+		ct = mime.TypeByExtension(filepath.Ext(file.FileName))
+	}
+	if ct == "" {
+		ct = http.DetectContentType(cbuf[:size])
 	}
 	pw, err := w.CreatePart(createMultipartHeader(file, ct))
 	if err != nil {
 		return err
 	}
 
-	if r.forceChunkedEncoding && r.uploadCallback != nil {
+	if r.uploadCallback != nil {
 		pw = &callbackWriter{
 			Writer:    pw,
 			lastTime:  lastTime,
@@ -135,7 +189,7 @@ func writeMultiPart(r *Request, w *multipart.Writer) {
 }
 
 func handleMultiPart(c *Client, r *Request) (err error) {
-	if r.forceChunkedEncoding {
+	if r.forceChunkedEncoding || r.uploadCallback != nil {
 		pr, pw := io.Pipe()
 		r.GetBody = func() (io.ReadCloser, error) {
 			return pr, nil
@@ -170,7 +224,7 @@ func handleMarshalBody(c *Client, r *Request) error {
 		ct = r.Headers.Get(header.ContentType)
 	}
 	if ct == "" {
-		ct = c.Headers.Get(header.ContentType)
+		ct = c.getCommonHeader(header.ContentType)
 	}
 	if ct != "" {
 		if util.IsXMLType(ct) {
@@ -230,7 +284,7 @@ func parseRequestBody(c *Client, r *Request) (err error) {
 	}
 	// body is in-memory []byte, so we can guess content type
 
-	if c.Headers != nil && c.Headers.Get(header.ContentType) != "" { // ignore if content type set at client-level
+	if c.getCommonHeader(header.ContentType) != "" { // ignore if content type set at client-level
 		return
 	}
 	if r.getHeader(header.ContentType) != "" { // ignore if content-type set at request-level
@@ -241,22 +295,18 @@ func parseRequestBody(c *Client, r *Request) (err error) {
 }
 
 func unmarshalBody(c *Client, r *Response, v interface{}) (err error) {
-	body, err := r.ToBytes() // in case req.SetResult or req.SetError with cient.DisalbeAutoReadResponse(true)
-	if err != nil {
-		return
-	}
+	// read the body in case req.SetResult or req.SetError with cient.DisalbeAutoReadResponse(true)
 	ct := r.GetContentType()
 	if util.IsJSONType(ct) {
-		return c.jsonUnmarshal(body, v)
+		return r.decode(v, false)
 	} else if util.IsXMLType(ct) {
-		return c.xmlUnmarshal(body, v)
+		return r.decode(v, true)
 	} else {
 		if c.DebugLog {
-			c.log.Debugf("cannot determine the unmarshal function with %q Content-Type, default to json", ct)
+			r.Request.getLogger().Debugf("cannot determine the unmarshal function with %q Content-Type, default to json", ct)
 		}
-		return c.jsonUnmarshal(body, v)
+		return r.decode(v, false)
 	}
-	return
 }
 
 func defaultResultStateChecker(resp *Response) ResultState {
@@ -270,14 +320,25 @@ func defaultResultStateChecker(resp *Response) ResultState {
 }
 
 func parseResponseBody(c *Client, r *Response) (err error) {
-	if r.Response == nil {
+	if r.Response == nil || r.bodyTruncated { // the truncated body can't be unmarshalled
 		return
 	}
+	if r.body == nil && c.autoReadPolicy.skip(r) { // the body is left to the caller
+		return
+	}
+	if c.contentTypeSniffing {
+		if err = checkContentType(r); err != nil {
+			return
+		}
+	}
 	req := r.Request
 	switch r.ResultState() {
 	case SuccessState:
 		if req.Result != nil && r.StatusCode != http.StatusNoContent {
 			err = unmarshalBody(c, r, r.Request.Result)
+			if err == nil {
+				err = mapResponseHeaders(r.Request.Result, r.Header)
+			}
 			if err == nil {
 				r.result = r.Request.Result
 			}
@@ -288,12 +349,18 @@ func parseResponseBody(c *Client, r *Response) (err error) {
 		}
 		if req.Error != nil {
 			err = unmarshalBody(c, r, req.Error)
+			if err == nil {
+				err = mapResponseHeaders(req.Error, r.Header)
+			}
 			if err == nil {
 				r.error = req.Error
 			}
 		} else if c.commonErrorType != nil {
 			e := reflect.New(c.commonErrorType).Interface()
 			err = unmarshalBody(c, r, e)
+			if err == nil {
+				err = mapResponseHeaders(e, r.Header)
+			}
 			if err == nil {
 				r.error = e
 			}
@@ -356,6 +423,23 @@ func (r *callbackReader) Read(p []byte) (n int, err error) {
 	return
 }
 
+// teeReadCloser writes everything read from the body to w, which is
+// used by Request.AddOutputWriter.
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (n int, err error) {
+	n, err = t.ReadCloser.Read(p)
+	if n > 0 {
+		if _, werr := t.w.Write(p[:n]); werr != nil {
+			err = werr
+		}
+	}
+	return
+}
+
 func handleDownload(c *Client, r *Response) (err error) {
 	if r.Response == nil || !r.Request.isSaveResponse {
 		return nil
@@ -368,47 +452,116 @@ func handleDownload(c *Client, r *Response) (err error) {
 		body = r.Body
 	}
 
-	var output io.Writer
 	if r.Request.outputFile != "" {
-		file := r.Request.outputFile
-		if c.outputDirectory != "" && !filepath.IsAbs(file) {
-			file = c.outputDirectory + string(filepath.Separator) + file
-		}
-
-		file = filepath.Clean(file)
-
-		if err = util.CreateDirectory(filepath.Dir(file)); err != nil {
-			return err
-		}
-		output, err = os.Create(file)
-		if err != nil {
-			return
-		}
-	} else {
-		output = r.Request.output // must not nil
+		return downloadFile(c, r, body)
 	}
 
+	output := r.Request.output // must not nil
 	defer func() {
 		body.Close()
 		closeq(output)
 	}()
 
-	_, err = io.Copy(output, body)
+	cs := r.Request.downloadChecksum
+	if cs == nil {
+		_, err = io.Copy(output, body)
+		r.setReceivedAt()
+		return
+	}
+	h := cs.newHash()
+	_, err = io.Copy(io.MultiWriter(output, h), body)
 	r.setReceivedAt()
+	if err == nil {
+		err = cs.verify(h)
+	}
 	return
 }
 
+// downloadFile downloads the body to the output file atomically, the body
+// is written to a temporary ".part" file in the same directory which is
+// renamed to the output file on success, so interrupted downloads never
+// leave truncated files that look complete.
+func downloadFile(c *Client, r *Response, body io.ReadCloser) (err error) {
+	defer body.Close()
+	file := c.outputFilePath(r.Request)
+	if err = util.CreateDirectory(filepath.Dir(file)); err != nil {
+		return
+	}
+	if shouldDownloadSegments(r) {
+		return downloadSegments(c, r, body, file)
+	}
+
+	var part *os.File
+	if r.Request.resume {
+		part, err = openResumeOutput(r, file)
+	} else {
+		part, err = os.Create(partFilename(file))
+	}
+	if err != nil || part == nil {
+		return
+	}
+	cs := r.Request.downloadChecksum
+	if cs == nil {
+		_, err = io.Copy(part, body)
+		r.setReceivedAt()
+		return finishPartFile(part, file, err, r.Request.resume)
+	}
+	h, err := cs.partHash(r, part.Name())
+	if err == nil {
+		_, err = io.Copy(io.MultiWriter(part, h), body)
+		r.setReceivedAt()
+	}
+	if err == nil {
+		err = cs.verify(h)
+	}
+	// corrupted partial file is not resumable
+	return finishPartFile(part, file, err, r.Request.resume && !isChecksumError(err))
+}
+
+func partFilename(file string) string {
+	return file + ".part"
+}
+
+// finishPartFile closes the part file and renames it to the output file if
+// no error occurs, the part file is removed on error unless keep is true
+// (e.g. resumable download).
+func finishPartFile(part *os.File, file string, err error, keep bool) error {
+	if e := part.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		if !keep {
+			os.Remove(part.Name())
+		}
+		return err
+	}
+	if err = os.Rename(part.Name(), file); err != nil {
+		return err
+	}
+	os.Remove(file + resumeValidatorSuffix)
+	return nil
+}
+
 // generate URL
 func parseRequestURL(c *Client, r *Request) error {
 	tempURL := r.RawURL
+	escape := newPathEscaper(c.pathEscapeOptions)
+	if r.pathEscapeOptions != nil {
+		escape = newPathEscaper(r.pathEscapeOptions)
+	}
 	if len(r.PathParams) > 0 {
 		for p, v := range r.PathParams {
-			tempURL = strings.Replace(tempURL, "{"+p+"}", url.PathEscape(v), -1)
+			tempURL = escape.replace(tempURL, p, v)
+		}
+	}
+	if len(r.PathParamValues) > 0 {
+		for p, vs := range r.PathParamValues {
+			tempURL = escape.replaceValues(tempURL, p, vs)
 		}
 	}
 	if len(c.PathParams) > 0 {
 		for p, v := range c.PathParams {
-			tempURL = strings.Replace(tempURL, "{"+p+"}", url.PathEscape(v), -1)
+			tempURL = escape.replace(tempURL, p, v)
 		}
 	}
 
@@ -470,29 +623,54 @@ func parseRequestURL(c *Client, r *Request) error {
 	}
 
 	reqURL.Host = removeEmptyPort(reqURL.Host)
+	if reqURL.Host, err = toASCIIHost(reqURL.Host, c.idnStrict); err != nil {
+		return err
+	}
 	r.URL = reqURL
 	return nil
 }
 
+// getCommonHeader returns the value of the common header, the deprecated
+// Transport.Headers is honored if it's not set in Client.Headers.
+func (c *Client) getCommonHeader(key string) string {
+	if v := c.Headers.Get(key); v != "" {
+		return v
+	}
+	if c.Transport != nil {
+		return c.Transport.Headers.Get(key)
+	}
+	return ""
+}
+
 func parseRequestHeader(c *Client, r *Request) error {
-	if c.Headers == nil {
-		return nil
+	var th http.Header
+	if c.Transport != nil {
+		th = c.Transport.Headers
 	}
-	if r.Headers == nil {
-		r.Headers = make(http.Header)
+	if c.Headers == nil && th == nil {
+		return nil
 	}
+	r.initHeaders()
 	for k, vs := range c.Headers {
 		if len(r.Headers[k]) == 0 {
 			r.Headers[k] = vs
 		}
 	}
+	for k, vs := range th { // the deprecated Transport.Headers has lower priority
+		if len(r.Headers[k]) == 0 {
+			r.Headers[k] = vs
+		}
+	}
 	return nil
 }
 
 func parseRequestCookie(c *Client, r *Request) error {
-	if len(c.Cookies) == 0 || r.RetryAttempt > 0 {
+	if r.RetryAttempt > 0 {
 		return nil
 	}
 	r.Cookies = append(r.Cookies, c.Cookies...)
+	if c.Transport != nil {
+		r.Cookies = append(r.Cookies, c.Transport.Cookies...)
+	}
 	return nil
 }
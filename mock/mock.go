@@ -0,0 +1,292 @@
+// Package mock serves the requests fired from req clients with canned
+// responses, the requests still go through the client's middlewares,
+// retries and dumps, only the network is replaced:
+//
+//	m := mock.New()
+//	m.On("GET", "/users/*").ReplyJSON(200, map[string]string{"name": "roc"})
+//	m.On("POST", "/users").WithJSONBody(func(v interface{}) bool {
+//		return v.(map[string]interface{})["name"] == "roc"
+//	}).Reply(201, "").Times(1)
+//	client := req.C().EnableMock(m)
+//	...
+//	m.AssertExpectations(t)
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrNoRoute is returned if no route matches the request.
+var ErrNoRoute = errors.New("mock: no route matches the request")
+
+// TestingT is the subset of testing.TB used by AssertExpectations.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Route matches the requests and replies them with the canned response or
+// error.
+type Route struct {
+	method   string
+	pattern  string
+	matchers []func(r *http.Request, body []byte) bool
+
+	status int
+	header http.Header
+	body   []byte
+	err    error
+	fn     func(r *http.Request) (*http.Response, error)
+
+	// times is the expected number of calls, -1 if it's not expected.
+	times int
+	calls int
+}
+
+// WithHeader requires the request header to have the value.
+func (rt *Route) WithHeader(key, value string) *Route {
+	return rt.Match(func(r *http.Request) bool {
+		for _, v := range r.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithQueryParam requires the query parameter of the request URL to have
+// the value.
+func (rt *Route) WithQueryParam(key, value string) *Route {
+	return rt.Match(func(r *http.Request) bool {
+		for _, v := range r.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithBody requires the request body to satisfy the predicate.
+func (rt *Route) WithBody(fn func(body []byte) bool) *Route {
+	rt.matchers = append(rt.matchers, func(r *http.Request, body []byte) bool {
+		return fn(body)
+	})
+	return rt
+}
+
+// WithJSONBody requires the request body to be JSON and the decoded value
+// (map[string]interface{}, []interface{}, etc.) satisfies the predicate.
+func (rt *Route) WithJSONBody(fn func(v interface{}) bool) *Route {
+	return rt.WithBody(func(body []byte) bool {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return false
+		}
+		return fn(v)
+	})
+}
+
+// Match requires the request to satisfy the predicate, the request body
+// can be read and is restored afterwards.
+func (rt *Route) Match(fn func(r *http.Request) bool) *Route {
+	rt.matchers = append(rt.matchers, func(r *http.Request, body []byte) bool {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return fn(r)
+	})
+	return rt
+}
+
+// Reply replies the requests with the status and body.
+func (rt *Route) Reply(status int, body string) *Route {
+	rt.status = status
+	rt.body = []byte(body)
+	return rt
+}
+
+// ReplyJSON replies the requests with the status and v marshaled as JSON,
+// the Content-Type is set to application/json.
+func (rt *Route) ReplyJSON(status int, v interface{}) *Route {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return rt.ReplyError(fmt.Errorf("mock: failed to marshal reply: %w", err))
+	}
+	rt.status = status
+	rt.body = body
+	return rt.ReplyHeader("Content-Type", "application/json; charset=utf-8")
+}
+
+// ReplyHeader adds the header to the response.
+func (rt *Route) ReplyHeader(key, value string) *Route {
+	rt.header.Add(key, value)
+	return rt
+}
+
+// ReplyError fails the requests with the error, e.g. to simulate the
+// network errors.
+func (rt *Route) ReplyError(err error) *Route {
+	rt.err = err
+	return rt
+}
+
+// ReplyFunc replies the requests with the response returned by fn.
+func (rt *Route) ReplyFunc(fn func(r *http.Request) (*http.Response, error)) *Route {
+	rt.fn = fn
+	return rt
+}
+
+// Times sets the expected number of calls, which is verified by
+// AssertExpectations.
+func (rt *Route) Times(n int) *Route {
+	rt.times = n
+	return rt
+}
+
+func (rt *Route) String() string {
+	return rt.method + " " + rt.pattern
+}
+
+func (rt *Route) match(r *http.Request, body []byte) bool {
+	if rt.method != "" && rt.method != "*" && !strings.EqualFold(rt.method, r.Method) {
+		return false
+	}
+	target := r.URL.Path
+	if strings.Contains(rt.pattern, "://") {
+		u := *r.URL
+		u.RawQuery, u.Fragment = "", ""
+		target = u.String()
+	}
+	if ok, _ := path.Match(rt.pattern, target); !ok {
+		return false
+	}
+	for _, m := range rt.matchers {
+		if !m(r, body) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rt *Route) reply(r *http.Request) (*http.Response, error) {
+	if rt.err != nil {
+		return nil, rt.err
+	}
+	if rt.fn != nil {
+		return rt.fn(r)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rt.status, http.StatusText(rt.status)),
+		StatusCode:    rt.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rt.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(rt.body)),
+		ContentLength: int64(len(rt.body)),
+		Request:       r,
+	}, nil
+}
+
+// Mock is the http.RoundTripper which serves the requests with the routes,
+// set it to the client with req.Client.EnableMock.
+type Mock struct {
+	mu        sync.Mutex
+	routes    []*Route
+	unmatched []string
+}
+
+// New creates the Mock.
+func New() *Mock {
+	return &Mock{}
+}
+
+// On adds the route for the requests with the method ("" or "*" for any)
+// and URL pattern, which replies 200 with the empty body by default. The
+// pattern is matched with the URL path, or the URL without the query if
+// it contains the scheme (e.g. "https://api.example.com/users/*"), the
+// syntax is the same as path.Match. The routes are matched in the order
+// they are added.
+func (m *Mock) On(method, pattern string) *Route {
+	rt := &Route{
+		method:  method,
+		pattern: pattern,
+		status:  http.StatusOK,
+		header:  make(http.Header),
+		times:   -1,
+	}
+	m.mu.Lock()
+	m.routes = append(m.routes, rt)
+	m.mu.Unlock()
+	return rt
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *Mock) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.mu.Lock()
+	var route *Route
+	for _, rt := range m.routes {
+		if rt.match(r, body) {
+			route = rt
+			route.calls++
+			break
+		}
+	}
+	if route == nil {
+		m.unmatched = append(m.unmatched, r.Method+" "+r.URL.String())
+	}
+	m.mu.Unlock()
+	if route == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRoute, r.Method, r.URL)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return route.reply(r)
+}
+
+// Calls returns the number of requests matched by the route.
+func (m *Mock) Calls(rt *Route) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return rt.calls
+}
+
+// AssertExpectations reports the routes which are not called the expected
+// times (see Route.Times), and the requests which match no route.
+func (m *Mock) AssertExpectations(t TestingT) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rt := range m.routes {
+		if rt.times >= 0 && rt.calls != rt.times {
+			t.Errorf("mock: %s is expected to be called %d times, but called %d times", rt, rt.times, rt.calls)
+		}
+	}
+	for _, r := range m.unmatched {
+		t.Errorf("mock: unexpected request %s", r)
+	}
+}
+
+// Reset removes the routes and the recorded calls.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	m.routes = nil
+	m.unmatched = nil
+	m.mu.Unlock()
+}
@@ -0,0 +1,170 @@
+package mock_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"github.com/imroc/req/v3/internal/tests"
+	"github.com/imroc/req/v3/mock"
+)
+
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMock(t *testing.T) {
+	m := mock.New()
+	get := m.On("GET", "/users/*").ReplyJSON(http.StatusOK, map[string]string{"name": "roc"}).Times(2)
+	create := m.On("POST", "https://api.example.com/users").WithHeader("X-Token", "t").WithJSONBody(func(v interface{}) bool {
+		return v.(map[string]interface{})["name"] == "roc"
+	}).Reply(http.StatusCreated, "created").ReplyHeader("Location", "/users/1")
+	netErr := errors.New("connection reset")
+	m.On("*", "/broken").ReplyError(netErr)
+
+	var middlewareCalled int
+	c := req.C().EnableMock(m).SetBaseURL("https://api.example.com").
+		OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+			middlewareCalled++
+			return nil
+		})
+
+	var user struct {
+		Name string `json:"name"`
+	}
+	resp, err := c.R().SetSuccessResult(&user).Get("/users/1")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, "roc", user.Name)
+
+	resp, err = c.R().SetHeader("X-Token", "t").SetBody(map[string]string{"name": "roc"}).Post("/users")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusCreated, resp.StatusCode)
+	tests.AssertEqual(t, "created", resp.String())
+	tests.AssertEqual(t, "/users/1", resp.GetHeader("Location"))
+	tests.AssertEqual(t, 1, m.Calls(create))
+
+	// the json body predicate does not match
+	_, err = c.R().SetHeader("X-Token", "t").SetBody(map[string]string{"name": "imroc"}).Post("/users")
+	tests.AssertEqual(t, true, errors.Is(err, mock.ErrNoRoute))
+
+	_, err = c.R().Delete("/broken")
+	tests.AssertEqual(t, true, errors.Is(err, netErr))
+	tests.AssertEqual(t, 4, middlewareCalled)
+
+	rec := &recorder{}
+	m.AssertExpectations(rec)
+	tests.AssertEqual(t, 1, m.Calls(get))
+	tests.AssertEqual(t, []string{
+		"mock: GET /users/* is expected to be called 2 times, but called 1 times",
+		"mock: unexpected request POST https://api.example.com/users",
+	}, rec.errors)
+
+	m.Reset()
+	_, err = c.R().Get("/users/1")
+	tests.AssertEqual(t, true, errors.Is(err, mock.ErrNoRoute))
+	c.DisableMock()
+}
+
+func TestMockDump(t *testing.T) {
+	m := mock.New()
+	m.On("POST", "/users").Reply(http.StatusCreated, "created").ReplyHeader("Location", "/users/1")
+	buf := new(bytes.Buffer)
+	c := req.C().EnableMock(m).SetBaseURL("https://api.example.com").EnableDumpAllTo(buf)
+	resp, err := c.R().SetHeader("X-Token", "t").SetBody("name=roc").Post("/users")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "created", resp.String())
+	dump := buf.String()
+	tests.AssertContains(t, dump, "post /users http/1.1\r\nhost: api.example.com\r\n", true)
+	tests.AssertContains(t, dump, "x-token: t\r\n", true)
+	tests.AssertContains(t, dump, "\r\n\r\nname=roc\r\n", true)
+	tests.AssertContains(t, dump, "http/1.1 201 created\r\n", true)
+	tests.AssertContains(t, dump, "location: /users/1\r\n", true)
+	tests.AssertContains(t, dump, "\r\n\r\ncreated", true)
+}
+
+const petstore = `{
+  "openapi": "3.0.0",
+  "servers": [{"url": "https://api.example.com/v1"}],
+  "paths": {
+    "/pets/{id}": {
+      "get": {
+        "operationId": "getPet",
+        "responses": {
+          "200": {
+            "description": "ok",
+            "headers": {"X-Rate-Limit": {"schema": {"type": "integer", "example": 100}}},
+            "content": {"application/json": {"examples": {
+              "cat": {"value": {"id": 1, "name": "kitty"}},
+              "dog": {"$ref": "#/components/examples/dog"}
+            }}}
+          },
+          "404": {
+            "description": "not found",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+          }
+        }
+      }
+    },
+    "/pets/mine": {
+      "get": {"responses": {"200": {"description": "ok", "content": {"text/plain": {"example": "mine"}}}}}
+    },
+    "/pets": {
+      "post": {"responses": {"201": {"description": "created"}, "default": {"description": "error"}}}
+    }
+  },
+  "components": {
+    "examples": {"dog": {"value": {"id": 2, "name": "doggy"}}},
+    "schemas": {"Error": {"type": "object", "properties": {
+      "code": {"type": "integer", "example": 404},
+      "message": {"type": "string", "default": "not found"}
+    }}}
+  }
+}`
+
+func TestLoadOpenAPI(t *testing.T) {
+	m, err := mock.NewFromOpenAPI([]byte(petstore))
+	tests.AssertNoError(t, err)
+	c := req.C().EnableMock(m).SetBaseURL("https://api.example.com/v1")
+
+	resp, err := c.R().Get("/pets/1")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	tests.AssertEqual(t, `{"id":1,"name":"kitty"}`, resp.String())
+	tests.AssertEqual(t, "100", resp.GetHeader("X-Rate-Limit"))
+	tests.AssertEqual(t, "application/json", resp.GetContentType())
+
+	resp, err = c.R().SetHeader("Prefer", "example=dog").Get("/pets/2")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, `{"id":2,"name":"doggy"}`, resp.String())
+
+	resp, err = c.R().SetHeader("Prefer", "code=404").Get("/pets/3")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+	tests.AssertEqual(t, `{"code":404,"message":"not found"}`, resp.String())
+
+	resp, err = c.R().Get("/pets/mine")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "mine", resp.String())
+
+	resp, err = c.R().Post("/pets")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusCreated, resp.StatusCode)
+
+	_, err = c.R().SetHeader("Prefer", "code=500").Get("/pets/1")
+	tests.AssertErrorContains(t, err, "no 500 response of getPet")
+	_, err = c.R().Get("/pets")
+	tests.AssertEqual(t, true, errors.Is(err, mock.ErrNoRoute))
+
+	_, err = mock.NewFromOpenAPI([]byte(`{"openapi": "3.0.0"}`))
+	tests.AssertErrorContains(t, err, "no paths")
+}
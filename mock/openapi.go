@@ -0,0 +1,441 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIOptions is the options of Mock.LoadOpenAPI.
+type OpenAPIOptions struct {
+	// BasePath is prefixed to the paths of the spec, default is the path of
+	// the first server url (OpenAPI 3) or the basePath (Swagger 2), set it
+	// to "/" to use the paths as is.
+	BasePath string
+	// Unmarshal decodes the spec, default is json.Unmarshal, set it to
+	// yaml.Unmarshal (gopkg.in/yaml.v3) to load the YAML specs.
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+// openAPISpec is the decoded OpenAPI 3 or Swagger 2 document.
+type openAPISpec struct {
+	root map[string]interface{}
+}
+
+// resolve follows the local $ref (e.g. "#/components/examples/user") of v.
+func (s *openAPISpec) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+		var cur interface{} = s.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			obj, _ := cur.(map[string]interface{})
+			cur = obj[token]
+		}
+		v = cur
+	}
+	return v
+}
+
+func (s *openAPISpec) object(v interface{}) map[string]interface{} {
+	m, _ := s.resolve(v).(map[string]interface{})
+	return m
+}
+
+// basePath returns the path prefix of the operations declared in the spec.
+func (s *openAPISpec) basePath() string {
+	if p, ok := s.root["basePath"].(string); ok {
+		return p
+	}
+	if servers, ok := s.root["servers"].([]interface{}); ok && len(servers) > 0 {
+		if server := s.object(servers[0]); server != nil {
+			raw, _ := server["url"].(string)
+			if u, err := url.Parse(raw); err == nil {
+				return u.Path
+			}
+		}
+	}
+	return ""
+}
+
+// example returns the example of the media type object (OpenAPI 3) or the
+// response object (Swagger 2), name selects the named example if it's not
+// empty.
+func (s *openAPISpec) example(obj map[string]interface{}, name string) (interface{}, bool) {
+	if examples := s.object(obj["examples"]); examples != nil {
+		if name != "" {
+			if ex := s.object(examples[name]); ex != nil {
+				v, ok := ex["value"]
+				return v, ok
+			}
+			return nil, false
+		}
+		names := make([]string, 0, len(examples))
+		for n := range examples {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			if ex := s.object(examples[n]); ex != nil {
+				if v, ok := ex["value"]; ok {
+					return v, true
+				}
+			}
+		}
+	}
+	if name != "" {
+		return nil, false
+	}
+	if v, ok := obj["example"]; ok {
+		return v, true
+	}
+	if schema := s.object(obj["schema"]); schema != nil {
+		return s.schemaExample(schema, 0), true
+	}
+	return nil, false
+}
+
+// schemaExample generates the example of the schema from the examples,
+// defaults and enums declared in it.
+func (s *openAPISpec) schemaExample(schema map[string]interface{}, depth int) interface{} {
+	if v, ok := schema["example"]; ok {
+		return v
+	}
+	if v, ok := schema["default"]; ok {
+		return v
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if depth > 8 {
+		return nil
+	}
+	for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+		subs, ok := schema[key].([]interface{})
+		if !ok || len(subs) == 0 {
+			continue
+		}
+		if key != "allOf" {
+			subs = subs[:1]
+		}
+		merged := make(map[string]interface{})
+		for _, sub := range subs {
+			v := s.schemaExample(s.object(sub), depth+1)
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return v
+			}
+			for k, val := range m {
+				merged[k] = val
+			}
+		}
+		return merged
+	}
+	typ, _ := schema["type"].(string)
+	if typ == "" && schema["properties"] != nil {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		obj := make(map[string]interface{})
+		for name, prop := range s.object(schema["properties"]) {
+			obj[name] = s.schemaExample(s.object(prop), depth+1)
+		}
+		return obj
+	case "array":
+		if items := s.object(schema["items"]); items != nil {
+			return []interface{}{s.schemaExample(items, depth+1)}
+		}
+		return []interface{}{}
+	case "string":
+		return "string"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+	return nil
+}
+
+// openAPIResponse is the example response of an operation.
+type openAPIResponse struct {
+	status      int
+	contentType string
+	header      http.Header
+	// body is the media type object (OpenAPI 3) or the response object
+	// (Swagger 2) which holds the examples, nil if there is no body.
+	body map[string]interface{}
+}
+
+func (s *openAPISpec) response(status int, obj map[string]interface{}) *openAPIResponse {
+	resp := &openAPIResponse{status: status, header: make(http.Header)}
+	for name, h := range s.object(obj["headers"]) {
+		header := s.object(h)
+		v, ok := header["example"]
+		if !ok {
+			if schema := s.object(header["schema"]); schema != nil {
+				v = s.schemaExample(schema, 0)
+			}
+		}
+		if v != nil {
+			resp.header.Set(name, fmt.Sprint(v))
+		}
+	}
+	if content := s.object(obj["content"]); len(content) > 0 {
+		types := make([]string, 0, len(content))
+		for ct := range content {
+			types = append(types, ct)
+		}
+		sort.Slice(types, func(i, j int) bool {
+			ji, jj := strings.Contains(types[i], "json"), strings.Contains(types[j], "json")
+			if ji != jj {
+				return ji
+			}
+			return types[i] < types[j]
+		})
+		resp.contentType = types[0]
+		resp.body = s.object(content[types[0]])
+	} else if obj["schema"] != nil || obj["examples"] != nil {
+		resp.contentType = "application/json"
+		if examples := s.object(obj["examples"]); len(examples) > 0 {
+			types := make([]string, 0, len(examples))
+			for ct := range examples {
+				types = append(types, ct)
+			}
+			sort.Strings(types)
+			resp.contentType = types[0]
+			resp.body = map[string]interface{}{"example": examples[types[0]]}
+		} else {
+			resp.body = obj
+		}
+	}
+	return resp
+}
+
+// openAPIOperation replies the requests of an operation with the example
+// responses.
+type openAPIOperation struct {
+	spec      *openAPISpec
+	id        string
+	responses map[int]*openAPIResponse
+	// status is the status code replied by default.
+	status int
+}
+
+// reply replies the request with the example of the default status, the
+// status and the named example can be selected with the Prefer header of
+// the request, e.g. "Prefer: code=404, example=notFound".
+func (op *openAPIOperation) reply(r *http.Request) (*http.Response, error) {
+	status, example := op.status, ""
+	for _, pref := range strings.Split(r.Header.Get("Prefer"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "code":
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("mock: invalid preferred code %q", value)
+			}
+			status = code
+		case "example":
+			example = value
+		}
+	}
+	resp, ok := op.responses[status]
+	if !ok {
+		return nil, fmt.Errorf("mock: no %d response of %s in the OpenAPI spec", status, op.id)
+	}
+	rt := &Route{status: status, header: resp.header.Clone()}
+	if resp.body != nil {
+		v, ok := op.spec.example(resp.body, example)
+		if !ok && example != "" {
+			return nil, fmt.Errorf("mock: no example %q of the %d response of %s in the OpenAPI spec", example, status, op.id)
+		}
+		if s, isString := v.(string); isString && !strings.Contains(resp.contentType, "json") {
+			rt.body = []byte(s)
+		} else if ok {
+			body, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("mock: failed to marshal the example of %s: %w", op.id, err)
+			}
+			rt.body = body
+		}
+		rt.header.Set("Content-Type", resp.contentType)
+	}
+	return rt.reply(r)
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// pathPattern converts the templated path of the spec (e.g. "/users/{id}")
+// to the pattern of the route (e.g. "/users/*").
+func pathPattern(p string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(p, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(p[i:], '}')
+		if j < 0 {
+			break
+		}
+		b.WriteString(escapePattern(p[:i]))
+		b.WriteByte('*')
+		p = p[i+j+1:]
+	}
+	b.WriteString(escapePattern(p))
+	return b.String()
+}
+
+// escapePattern escapes the special characters of path.Match.
+func escapePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(s)
+}
+
+// LoadOpenAPI adds the routes for the operations of the OpenAPI 3 or
+// Swagger 2 spec, which reply the example responses declared in the spec
+// (the example, the first of the examples, or the one generated from the
+// schema), so that the SDKs built on req can be developed and tested before
+// the server exists. The lowest 2xx response (or the default response) is
+// replied by default, the request can select another one with the Prefer
+// header, e.g. "Prefer: code=404, example=notFound". The routes added before
+// take precedence, which can override the operations. For example:
+//
+//	m := mock.New()
+//	m.On("GET", "/v1/users/me").ReplyJSON(200, me)
+//	if err := m.LoadOpenAPI(spec, &mock.OpenAPIOptions{Unmarshal: yaml.Unmarshal}); err != nil {
+//		return err
+//	}
+//	client := req.C().SetBaseURL("https://api.example.com").EnableMock(m)
+func (m *Mock) LoadOpenAPI(spec []byte, opts ...*OpenAPIOptions) error {
+	var o OpenAPIOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.Unmarshal == nil {
+		o.Unmarshal = json.Unmarshal
+	}
+	var root map[string]interface{}
+	if err := o.Unmarshal(spec, &root); err != nil {
+		return fmt.Errorf("mock: failed to decode the OpenAPI spec: %w", err)
+	}
+	s := &openAPISpec{root: normalize(root).(map[string]interface{})}
+	paths := s.object(s.root["paths"])
+	if paths == nil {
+		return errors.New("mock: no paths in the OpenAPI spec")
+	}
+	basePath := o.BasePath
+	if basePath == "" {
+		basePath = s.basePath()
+	}
+	basePath = strings.TrimSuffix(basePath, "/")
+
+	// the literal paths are matched before the templated ones, e.g.
+	// "/users/me" before "/users/{id}".
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ni, nj := strings.Count(keys[i], "{"), strings.Count(keys[j], "{")
+		if ni != nj {
+			return ni < nj
+		}
+		return keys[i] < keys[j]
+	})
+	for _, p := range keys {
+		item := s.object(paths[p])
+		for _, method := range openAPIMethods {
+			opObj := s.object(item[method])
+			if opObj == nil {
+				continue
+			}
+			op := &openAPIOperation{
+				spec:      s,
+				id:        strings.ToUpper(method) + " " + p,
+				responses: make(map[int]*openAPIResponse),
+			}
+			if id, ok := opObj["operationId"].(string); ok {
+				op.id = id
+			}
+			for code, r := range s.object(opObj["responses"]) {
+				if code == "default" {
+					if _, ok := op.responses[http.StatusOK]; !ok {
+						op.responses[http.StatusOK] = s.response(http.StatusOK, s.object(r))
+					}
+					continue
+				}
+				status, err := strconv.Atoi(code)
+				if err != nil {
+					continue // e.g. "2XX"
+				}
+				op.responses[status] = s.response(status, s.object(r))
+				if status >= 200 && status < 300 && (op.status == 0 || status < op.status) {
+					op.status = status
+				}
+			}
+			if _, ok := op.responses[http.StatusOK]; op.status == 0 && ok {
+				op.status = http.StatusOK // the default response
+			} else if op.status == 0 {
+				for status := range op.responses {
+					if op.status == 0 || status < op.status {
+						op.status = status
+					}
+				}
+			}
+			if op.status == 0 { // no response is declared
+				op.status = http.StatusOK
+				op.responses[op.status] = &openAPIResponse{status: op.status, header: make(http.Header)}
+			}
+			m.On(strings.ToUpper(method), escapePattern(basePath)+pathPattern(p)).ReplyFunc(op.reply)
+		}
+	}
+	return nil
+}
+
+// NewFromOpenAPI creates the Mock with the routes of the OpenAPI spec, see
+// Mock.LoadOpenAPI.
+func NewFromOpenAPI(spec []byte, opts ...*OpenAPIOptions) (*Mock, error) {
+	m := New()
+	if err := m.LoadOpenAPI(spec, opts...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// normalize converts the map[interface{}]interface{} decoded by some YAML
+// decoders to map[string]interface{}.
+func normalize(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, val := range vv {
+			vv[k] = normalize(val)
+		}
+		return vv
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, val := range vv {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range vv {
+			vv[i] = normalize(val)
+		}
+		return vv
+	}
+	return v
+}
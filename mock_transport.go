@@ -0,0 +1,104 @@
+package req
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/imroc/req/v3/internal/dump"
+)
+
+// roundTripMock serves the request with the mock, the mocked request and
+// response are dumped as if they're sent over HTTP/1.1.
+func (t *Transport) roundTripMock(req *http.Request) (*http.Response, error) {
+	dumps := dump.GetDumpers(req.Context(), t.Dump)
+	if len(dumps) > 0 {
+		var err error
+		if req, err = dumpMockRequest(req, dumps); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := t.mock.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dumps {
+		if d.ResponseHeader() {
+			d.DumpResponseHeader(dumpResponseHeader(resp))
+		}
+	}
+	return resp, nil
+}
+
+// dumpMockRequest dumps the header and body of the mocked request, the body
+// is buffered and the returned request reads from the buffer.
+func dumpMockRequest(req *http.Request, dumps []*dump.Dumper) (*http.Request, error) {
+	var header []byte
+	dumpBody := false
+	for _, d := range dumps {
+		if d.RequestHeader() {
+			if header == nil {
+				header = dumpRequestHeader(req)
+			}
+			d.DumpRequestHeader(header)
+		}
+		dumpBody = dumpBody || d.RequestBody()
+	}
+	if !dumpBody || req.Body == nil || req.Body == NoBody {
+		return req, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	for _, d := range dumps {
+		if d.RequestBody() {
+			d.DumpRequestBody(d.NewBodyFilter(req.Header, int64(len(body))).Filter(body))
+			d.DumpDefault([]byte("\r\n\r\n"))
+		}
+	}
+	return &r, nil
+}
+
+// dumpRequestHeader returns the header of the request in the HTTP/1.1 wire
+// format.
+func dumpRequestHeader(req *http.Request) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&b, "Host: %s\r\n", host)
+	req.Header.Write(&b)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// EnableMock set the client to serve the requests with the mock (e.g. the
+// mock.Mock of github.com/imroc/req/v3/mock) instead of the network, the
+// requests still go through the middlewares, retries and dumps of the
+// client, which is more realistic than replacing the transport. For example:
+//
+//	m := mock.New()
+//	m.On("GET", "/users/*").ReplyJSON(200, user)
+//	client.EnableMock(m)
+func (c *Client) EnableMock(mock http.RoundTripper) *Client {
+	if mock == nil {
+		c.log.Warnf("ignore nil mock in EnableMock")
+		return c
+	}
+	c.Transport.mock = mock
+	return c
+}
+
+// DisableMock disables the mock set by EnableMock.
+func (c *Client) DisableMock() *Client {
+	c.Transport.mock = nil
+	return c
+}
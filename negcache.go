@@ -0,0 +1,180 @@
+package req
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxNegativeCacheEntries is the max number of the cached responses, the
+// least recently used one is evicted.
+const maxNegativeCacheEntries = 1024
+
+type negativeCacheEntry struct {
+	key     string
+	resp    *http.Response
+	body    []byte
+	expires time.Time
+}
+
+// negativeCache caches the responses of missing resources (e.g. 404 and
+// 410) of GET and HEAD requests for a short TTL per status code.
+type negativeCache struct {
+	mu      sync.Mutex
+	ttls    map[int]time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newNegativeCache(ttls map[int]time.Duration) *negativeCache {
+	nc := &negativeCache{
+		ttls:    make(map[int]time.Duration, len(ttls)),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	for code, ttl := range ttls {
+		if ttl > 0 {
+			nc.ttls[code] = ttl
+		}
+	}
+	return nc
+}
+
+// negativeCacheCredentialHeaders are the headers which carry the
+// credentials, the responses of requests with different credentials are
+// cached separately, so that a response is never served to the requests
+// of other users.
+var negativeCacheCredentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+func negativeCacheKey(req *http.Request) string {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return ""
+	}
+	key := req.Method + " " + req.URL.String()
+	var h []byte
+	for _, name := range negativeCacheCredentialHeaders {
+		for _, v := range req.Header.Values(name) {
+			h = append(h, name...)
+			h = append(h, ':')
+			h = append(h, v...)
+			h = append(h, '\n')
+		}
+	}
+	if len(h) == 0 {
+		return key
+	}
+	sum := sha256.Sum256(h)
+	return key + " " + hex.EncodeToString(sum[:])
+}
+
+// get returns the cached response of the request, or nil if not cached
+// or expired.
+func (nc *negativeCache) get(req *http.Request, now time.Time) *http.Response {
+	key := negativeCacheKey(req)
+	if key == "" {
+		return nil
+	}
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	e, ok := nc.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := e.Value.(*negativeCacheEntry)
+	if now.After(entry.expires) {
+		nc.lru.Remove(e)
+		delete(nc.entries, key)
+		return nil
+	}
+	nc.lru.MoveToFront(e)
+	resp := new(http.Response)
+	*resp = *entry.resp
+	resp.Header = entry.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(entry.body))
+	resp.Request = req
+	return resp
+}
+
+// store caches the response if its status code is configured with a TTL,
+// the body is read and restored for re-reads.
+func (nc *negativeCache) store(resp *http.Response, now time.Time) error {
+	ttl, ok := nc.ttls[resp.StatusCode]
+	if !ok || resp.Request == nil {
+		return nil
+	}
+	key := negativeCacheKey(resp.Request)
+	if key == "" {
+		return nil
+	}
+	var body []byte
+	if resp.Body != nil {
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		body = b
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	cached := new(http.Response)
+	*cached = *resp
+	cached.Header = resp.Header.Clone()
+	cached.Body = nil
+	cached.Request = nil
+	cached.TLS = nil
+
+	entry := &negativeCacheEntry{
+		key:     key,
+		resp:    cached,
+		body:    body,
+		expires: now.Add(ttl),
+	}
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if e, ok := nc.entries[key]; ok {
+		e.Value = entry
+		nc.lru.MoveToFront(e)
+		return nil
+	}
+	nc.entries[key] = nc.lru.PushFront(entry)
+	if nc.lru.Len() > maxNegativeCacheEntries {
+		oldest := nc.lru.Back()
+		nc.lru.Remove(oldest)
+		delete(nc.entries, oldest.Value.(*negativeCacheEntry).key)
+	}
+	return nil
+}
+
+// EnableNegativeCache enables negative caching for GET and HEAD requests
+// fired from the client, responses of missing resources are cached for the
+// TTL of their status code, and repeated lookups are served from the cache
+// without sending requests to the server until the TTL expires, which cuts
+// load on upstream during bulk syncs. At most 1024 responses are cached,
+// the least recently used one is evicted. For example:
+//
+//	client.EnableNegativeCache(map[int]time.Duration{
+//		http.StatusNotFound: 30 * time.Second,
+//		http.StatusGone:     10 * time.Minute,
+//	})
+func (c *Client) EnableNegativeCache(ttls map[int]time.Duration) *Client {
+	c.negativeCache = newNegativeCache(ttls)
+	return c
+}
+
+// DisableNegativeCache disables negative caching for requests fired from
+// the client (disabled by default), the cached responses are dropped.
+func (c *Client) DisableNegativeCache() *Client {
+	c.negativeCache = nil
+	return c
+}
@@ -0,0 +1,162 @@
+package req
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/imroc/req/v3/internal/header"
+	"github.com/imroc/req/v3/internal/util"
+)
+
+// netrcEntry is a machine (or default) entry of the .netrc file.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+type netrc struct {
+	machines    []netrcEntry
+	defaultUser *netrcEntry
+}
+
+// lookup returns the entry of the host, the default entry is returned if
+// no machine matches.
+func (n *netrc) lookup(host string) *netrcEntry {
+	for i := range n.machines {
+		if strings.EqualFold(n.machines[i].machine, host) {
+			return &n.machines[i]
+		}
+	}
+	return n.defaultUser
+}
+
+// parseNetrc parses the .netrc file, macro definitions and comments are
+// ignored.
+func parseNetrc(data string) *netrc {
+	n := &netrc{}
+	var entry *netrcEntry
+	flush := func() {
+		if entry == nil {
+			return
+		}
+		if entry.machine == "" {
+			n.defaultUser = entry
+		} else {
+			n.machines = append(n.machines, *entry)
+		}
+		entry = nil
+	}
+	inMacro := false
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro { // a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := func() string {
+				if i+1 < len(fields) {
+					i++
+					return fields[i]
+				}
+				return ""
+			}
+			switch fields[i] {
+			case "machine":
+				flush()
+				entry = &netrcEntry{machine: value()}
+			case "default":
+				flush()
+				entry = &netrcEntry{}
+			case "login":
+				if entry != nil {
+					entry.login = value()
+				}
+			case "password":
+				if entry != nil {
+					entry.password = value()
+				}
+			case "account":
+				value()
+			case "macdef":
+				flush()
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	flush()
+	return n
+}
+
+// defaultNetrcPath returns the path of the .netrc file, which is specified
+// by the NETRC environment variable, or ~/.netrc (~/_netrc on Windows).
+func defaultNetrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name), nil
+}
+
+// EnableNetrc enables reading credentials from the .netrc file, the basic
+// auth of the login and password of the machine entry (or the default
+// entry) that matches the host is applied to requests which have no
+// Authorization Header and no credentials in the url, just like curl
+// --netrc. The path is the NETRC environment variable or ~/.netrc if not
+// specified, and the file is read only once.
+func (c *Client) EnableNetrc(path ...string) *Client {
+	var p string
+	if len(path) > 0 {
+		p = path[0]
+	} else {
+		var err error
+		if p, err = defaultNetrcPath(); err != nil {
+			c.log.Errorf("failed to locate netrc file: %v", err)
+			return c
+		}
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		c.log.Errorf("failed to read netrc file %s: %v", p, err)
+		return c
+	}
+	c.netrc = parseNetrc(string(data))
+	return c
+}
+
+// DisableNetrc disables reading credentials from the .netrc file (disabled
+// by default).
+func (c *Client) DisableNetrc() *Client {
+	c.netrc = nil
+	return c
+}
+
+func handleNetrc(c *Client, r *Request) error {
+	if c.netrc == nil || r.URL == nil || r.URL.User != nil || r.Headers.Get(header.Authorization) != "" {
+		return nil
+	}
+	entry := c.netrc.lookup(r.URL.Hostname())
+	if entry == nil || entry.login == "" {
+		return nil
+	}
+	r.initHeaders()
+	r.Headers.Set(header.Authorization, util.BasicAuthHeaderValue(entry.login, entry.password))
+	return nil
+}
@@ -0,0 +1,341 @@
+package req
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/imroc/req/v3/internal/header"
+	"golang.org/x/crypto/md4"
+)
+
+var errNtlmBadChallenge = errors.New("ntlm: challenge message is bad")
+
+// maxConnAuthLegs is the max number of requests sent in a handshake.
+const maxConnAuthLegs = 3
+
+// SpnegoProvider generates the SPNEGO tokens of the Negotiate
+// authentication (RFC 4559), typically backed by Kerberos (e.g.
+// github.com/jcmturner/gokrb5) or SSPI on Windows.
+type SpnegoProvider interface {
+	// Token returns the token to be sent to the service principal name
+	// spn (e.g. "HTTP/www.example.com"), the challenge is nil for the
+	// initial token, otherwise it's the token sent back by the server to
+	// continue the handshake.
+	Token(ctx context.Context, spn string, challenge []byte) ([]byte, error)
+}
+
+// SpnegoProviderFunc is a function which implements SpnegoProvider.
+type SpnegoProviderFunc func(ctx context.Context, spn string, challenge []byte) ([]byte, error)
+
+// Token implements SpnegoProvider.
+func (f SpnegoProviderFunc) Token(ctx context.Context, spn string, challenge []byte) ([]byte, error) {
+	return f(ctx, spn, challenge)
+}
+
+// SetCommonNtlmAuth sets the NTLM (NTLMv2) auth scheme for requests fired
+// from the client. If a server responds with 401 and offers NTLM in the
+// WWW-Authenticate Header, the NTLM handshake is performed and the request
+// is resent with the appropriate Authorization Header. The username can be
+// in the "DOMAIN\user" form.
+//
+// NTLM authenticates the connection rather than the request, so all legs
+// of the handshake are sent over a dedicated HTTP/1.1 connection which is
+// never reused by other requests, and is closed once the response body is
+// closed. The request body must be replayable as it's sent for each leg.
+func (c *Client) SetCommonNtlmAuth(username, password string) *Client {
+	c.OnAfterResponse(handleConnAuthFunc(newNtlmAuthenticator(username, password)))
+	return c
+}
+
+// SetCommonSpnegoAuth sets the Negotiate (SPNEGO) auth scheme for requests
+// fired from the client, which is commonly used for Kerberos. If a server
+// responds with 401 and offers Negotiate in the WWW-Authenticate Header,
+// the tokens generated by provider are exchanged with the server, and the
+// request is resent with the appropriate Authorization Header. The
+// connection affinity of the handshake is the same as SetCommonNtlmAuth.
+// For example, with github.com/jcmturner/gokrb5:
+//
+//	client.SetCommonSpnegoAuth(req.SpnegoProviderFunc(func(ctx context.Context, spn string, challenge []byte) ([]byte, error) {
+//		s := spnego.SPNEGOClient(krbClient, spn)
+//		if err := s.AcquireCred(); err != nil {
+//			return nil, err
+//		}
+//		token, err := s.InitSecContext()
+//		if err != nil {
+//			return nil, err
+//		}
+//		return token.Marshal()
+//	}))
+func (c *Client) SetCommonSpnegoAuth(provider SpnegoProvider) *Client {
+	c.OnAfterResponse(handleConnAuthFunc(&connAuthenticator{
+		scheme: "Negotiate",
+		step: func(req *http.Request, challenge []byte) ([]byte, error) {
+			return provider.Token(req.Context(), "HTTP/"+req.URL.Hostname(), challenge)
+		},
+	}))
+	return c
+}
+
+// connAuthenticator performs a connection-oriented auth handshake (NTLM
+// or Negotiate), all legs of which must be sent over the same connection.
+type connAuthenticator struct {
+	scheme string
+	// step returns the token of the next leg, challenge is nil for the
+	// first leg.
+	step func(req *http.Request, challenge []byte) ([]byte, error)
+}
+
+// challenge returns the token of the scheme in the WWW-Authenticate
+// Header, ok is false if the scheme is not offered.
+func (a *connAuthenticator) challenge(resp *http.Response) (token []byte, ok bool, err error) {
+	for _, v := range resp.Header.Values(header.WwwAuthenticate) {
+		for _, c := range strings.Split(v, ",") {
+			scheme, param, _ := strings.Cut(strings.TrimSpace(c), " ")
+			if !strings.EqualFold(scheme, a.scheme) {
+				continue
+			}
+			param = strings.TrimSpace(param)
+			if param == "" {
+				return nil, true, nil
+			}
+			token, err = base64.StdEncoding.DecodeString(param)
+			return token, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// handshake sends all legs of the handshake over a dedicated connection,
+// which is closed once the body of the returned response is closed.
+func (a *connAuthenticator) handshake(t *Transport, req *http.Request) (*http.Response, error) {
+	tt := t.Clone().EnableForceHTTP1()
+	tt.MaxConnsPerHost = 1
+	tt.DisableKeepAlives = false
+	var challenge []byte
+	for leg := 0; ; leg++ {
+		token, err := a.step(req, challenge)
+		if err != nil {
+			tt.CloseIdleConnections()
+			return nil, err
+		}
+		legReq := *req
+		legReq.Header = req.Header.Clone()
+		legReq.Header.Set(header.Authorization, a.scheme+" "+base64.StdEncoding.EncodeToString(token))
+		if leg > 0 && req.GetBody != nil {
+			if legReq.Body, err = req.GetBody(); err != nil {
+				tt.CloseIdleConnections()
+				return nil, err
+			}
+		}
+		resp, err := tt.RoundTrip(&legReq)
+		if err != nil {
+			tt.CloseIdleConnections()
+			return nil, err
+		}
+		var ok bool
+		challenge, ok, err = a.challenge(resp)
+		if resp.StatusCode != http.StatusUnauthorized || !ok || err != nil || len(challenge) == 0 || leg == maxConnAuthLegs-1 {
+			resp.Body = &closeIdleBody{ReadCloser: resp.Body, t: tt}
+			return resp, nil
+		}
+		// drain the body so that the connection can be reused by the next leg
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// closeIdleBody closes the idle connections of the dedicated transport
+// when the body is closed.
+type closeIdleBody struct {
+	io.ReadCloser
+	t *Transport
+}
+
+func (b *closeIdleBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.t.CloseIdleConnections()
+	return err
+}
+
+// create response middleware for connection-oriented authentication.
+func handleConnAuthFunc(a *connAuthenticator) ResponseMiddleware {
+	return func(client *Client, resp *Response) error {
+		if resp.Err != nil || resp.StatusCode != http.StatusUnauthorized || resp.Request.unReplayableBody != nil {
+			return nil
+		}
+		if _, ok, _ := a.challenge(resp.Response); !ok {
+			return nil
+		}
+		req, err := newResendRequest(client, resp.Request)
+		if err != nil {
+			return err
+		}
+		httpResp, err := a.handshake(client.GetTransport(), req)
+		if err != nil {
+			return err
+		}
+		return replaceResponse(resp, httpResp)
+	}
+}
+
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+
+	ntlmDefaultFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSessionSecurity |
+		ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+
+	ntlmAvEOL       = 0
+	ntlmAvTimestamp = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+func newNtlmAuthenticator(username, password string) *connAuthenticator {
+	domain, user, ok := strings.Cut(username, `\`)
+	if !ok {
+		domain, user = "", username
+	}
+	return &connAuthenticator{
+		scheme: "NTLM",
+		step: func(req *http.Request, challenge []byte) ([]byte, error) {
+			if challenge == nil {
+				return ntlmNegotiateMessage(), nil
+			}
+			return ntlmAuthenticateMessage(challenge, domain, user, password)
+		},
+	}
+}
+
+// ntlmNegotiateMessage returns the NEGOTIATE_MESSAGE (type 1).
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmDefaultFlags)
+	return msg
+}
+
+// ntlmAuthenticateMessage returns the AUTHENTICATE_MESSAGE (type 3) for
+// the CHALLENGE_MESSAGE (type 2) with the NTLMv2 response.
+func ntlmAuthenticateMessage(challenge []byte, domain, user, password string) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errNtlmBadChallenge
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+	targetInfo, ok := ntlmField(challenge, 40)
+	if !ok {
+		return nil, errNtlmBadChallenge
+	}
+	timestamp, hasTimestamp := ntlmAvPair(targetInfo, ntlmAvTimestamp)
+	if !hasTimestamp {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	key := ntowfv2(user, password, domain)
+	nt, lm := ntlmV2Response(key, serverChallenge, clientChallenge, timestamp, targetInfo)
+	if hasTimestamp { // the LM response must be zeros if the server sends a timestamp
+		lm = make([]byte, 24)
+	}
+
+	const headerLen = 64
+	payloads := [][]byte{lm, nt, encodeUTF16LE(domain), encodeUTF16LE(user), nil, nil}
+	msg := make([]byte, headerLen)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	for i, p := range payloads {
+		off := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[off:], uint16(len(p)))
+		binary.LittleEndian.PutUint16(msg[off+2:], uint16(len(p)))
+		binary.LittleEndian.PutUint32(msg[off+4:], uint32(len(msg)))
+		msg = append(msg, p...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&ntlmDefaultFlags|ntlmNegotiateUnicode)
+	return msg, nil
+}
+
+// ntlmField returns the payload referenced by the field at off.
+func ntlmField(msg []byte, off int) ([]byte, bool) {
+	n := int(binary.LittleEndian.Uint16(msg[off:]))
+	start := int(binary.LittleEndian.Uint32(msg[off+4:]))
+	if start+n > len(msg) {
+		return nil, false
+	}
+	return msg[start : start+n], true
+}
+
+// ntlmAvPair returns the value of the AV_PAIR with id in the target info.
+func ntlmAvPair(targetInfo []byte, id uint16) ([]byte, bool) {
+	for len(targetInfo) >= 4 {
+		avID := binary.LittleEndian.Uint16(targetInfo)
+		n := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if avID == ntlmAvEOL || 4+n > len(targetInfo) {
+			break
+		}
+		if avID == id {
+			return targetInfo[4 : 4+n], true
+		}
+		targetInfo = targetInfo[4+n:]
+	}
+	return nil, false
+}
+
+// ntowfv2 returns the NTLMv2 response key of the user.
+func ntowfv2(user, password, domain string) []byte {
+	h := md4.New()
+	h.Write(encodeUTF16LE(password))
+	return hmacMD5(h.Sum(nil), encodeUTF16LE(strings.ToUpper(user)+domain))
+}
+
+// ntlmV2Response returns the NTLMv2 and LMv2 challenge responses.
+func ntlmV2Response(key, serverChallenge, clientChallenge, timestamp, targetInfo []byte) (nt, lm []byte) {
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+	nt = append(hmacMD5(key, serverChallenge, temp), temp...)
+	lm = append(hmacMD5(key, serverChallenge, clientChallenge), clientChallenge...)
+	return
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func encodeUTF16LE(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
@@ -0,0 +1,125 @@
+package req
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultOutputFilename is the filename derived from the URL whose path is
+// empty or ends with "/".
+const defaultOutputFilename = "index.html"
+
+// windowsReservedNames are the filenames which can't be used on Windows.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename replaces the characters which are invalid in the
+// filename on any platform, so that the file can be created everywhere.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"|?*\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	return name
+}
+
+// sanitizeOutputPath makes the relative path safe to be joined with the
+// output directory: the path is cleaned (with both "/" and "\" as the
+// separator), the elements escaping the directory ("..") are removed, and
+// the invalid characters of each element are replaced.
+func sanitizeOutputPath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+	var elems []string
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" {
+			continue
+		}
+		elems = append(elems, sanitizeFilename(elem))
+	}
+	if len(elems) == 0 {
+		return defaultOutputFilename
+	}
+	return filepath.Join(elems...)
+}
+
+// outputFilenameFromURL derives the relative filename from the URL path,
+// e.g. "https://example.com/files/a.txt" -> "files/a.txt".
+func outputFilenameFromURL(u *url.URL) string {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += defaultOutputFilename
+	}
+	return sanitizeOutputPath(p)
+}
+
+// outputFilePath returns the path of the output file, the filename derived
+// from the URL is sanitized, while the one set by Request.SetOutputFile is
+// trusted and used as is.
+func (c *Client) outputFilePath(r *Request) string {
+	file := r.outputFile
+	if c.outputFilenameMapper != nil {
+		file = c.outputFilenameMapper(r, file)
+	}
+	if c.outputDirectory != "" && !filepath.IsAbs(file) {
+		if r.outputFileFromURL {
+			file = sanitizeOutputPath(file)
+		}
+		return filepath.Join(c.outputDirectory, file)
+	}
+	return filepath.Clean(file)
+}
+
+func handleOutputFileFromURL(c *Client, r *Request) error {
+	if r.outputFileFromURL && r.URL != nil {
+		r.outputFile = outputFilenameFromURL(r.URL)
+	}
+	return nil
+}
+
+// SetOutputFileFromURL set the response body to be downloaded to the file
+// whose name is derived from the URL path (e.g. "files/a.txt" of
+// "https://example.com/files/a.txt", and "index.html" if the path is empty
+// or ends with "/"), which is relative to the output directory (see
+// Client.SetOutputDirectory). The derived filename is sanitized: the path
+// traversal ("..") is removed and the characters invalid on any platform
+// are replaced with "_", and the missing directories are created.
+func (r *Request) SetOutputFileFromURL() *Request {
+	r.isSaveResponse = true
+	r.outputFileFromURL = true
+	return r
+}
+
+// SetOutputFilenameMapper set the hook which maps the output filename of
+// the request (set by Request.SetOutputFile or derived from the URL) to the
+// one the response body is downloaded to, e.g. to add a prefix or flatten
+// the directories. If the output directory is set (see
+// SetOutputDirectory), the relative filename derived from the URL is
+// sanitized before it's joined with the directory, so that it never
+// escapes the directory.
+// For example:
+//
+//	client.SetOutputDirectory("/data/mirror").
+//		SetOutputFilenameMapper(func(r *req.Request, filename string) string {
+//			return r.URL.Host + "/" + filename
+//		})
+func (c *Client) SetOutputFilenameMapper(mapper func(r *Request, filename string) string) *Client {
+	c.outputFilenameMapper = mapper
+	return c
+}
@@ -0,0 +1,92 @@
+package req
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PathEscapeOptions controls how the path parameters are escaped, which is
+// url.PathEscape by default.
+type PathEscapeOptions struct {
+	// Preserve are the characters which are never escaped, e.g. ":@" to
+	// keep the reserved characters as they are, or "%" if the values are
+	// already escaped.
+	Preserve string
+	// Escape are the extra characters which are always escaped, e.g. "+"
+	// for the servers (like some object stores) which decode "+" in the
+	// path as the space.
+	Escape string
+}
+
+const upperhex = "0123456789ABCDEF"
+
+// pathEscaper escapes the value of the path parameter.
+type pathEscaper func(s string) string
+
+func newPathEscaper(opts *PathEscapeOptions) pathEscaper {
+	if opts == nil || (opts.Preserve == "" && opts.Escape == "") {
+		return url.PathEscape
+	}
+	preserve, escape := opts.Preserve, opts.Escape
+	return func(s string) string {
+		var b strings.Builder
+		b.Grow(len(s))
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case strings.IndexByte(preserve, c) >= 0:
+				b.WriteByte(c)
+			case strings.IndexByte(escape, c) >= 0:
+				b.WriteByte('%')
+				b.WriteByte(upperhex[c>>4])
+				b.WriteByte(upperhex[c&15])
+			default:
+				b.WriteString(url.PathEscape(s[i : i+1]))
+			}
+		}
+		return b.String()
+	}
+}
+
+// segments escapes the segments individually and joins them with "/".
+func (escape pathEscaper) segments(segments []string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = escape(s)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// replace replaces the "{key}" placeholder with the escaped value, and the
+// wildcard "{key...}" with the escaped segments of the value split by "/".
+func (escape pathEscaper) replace(rawURL, key, value string) string {
+	rawURL = strings.Replace(rawURL, "{"+key+"...}", escape.segments(strings.Split(value, "/")), -1)
+	return strings.Replace(rawURL, "{"+key+"}", escape(value), -1)
+}
+
+// replaceValues replaces both the "{key}" and "{key...}" placeholders with
+// the escaped values as the path segments.
+func (escape pathEscaper) replaceValues(rawURL, key string, values []string) string {
+	segments := escape.segments(values)
+	rawURL = strings.Replace(rawURL, "{"+key+"...}", segments, -1)
+	return strings.Replace(rawURL, "{"+key+"}", segments, -1)
+}
+
+// SetCommonPathEscapeOptions set how the path parameters of requests fired
+// from the client are escaped, so that the values containing reserved
+// characters (e.g. the object store keys with "+", ":" or "%") round-trip
+// exactly. For example:
+//
+//	client.SetCommonPathEscapeOptions(&req.PathEscapeOptions{Escape: "+", Preserve: ":"})
+func (c *Client) SetCommonPathEscapeOptions(opts *PathEscapeOptions) *Client {
+	c.pathEscapeOptions = opts
+	return c
+}
+
+// SetPathEscapeOptions set how the path parameters of the request are
+// escaped, which overrides the client-level options, see
+// Client.SetCommonPathEscapeOptions.
+func (r *Request) SetPathEscapeOptions(opts *PathEscapeOptions) *Request {
+	r.pathEscapeOptions = opts
+	return r
+}
@@ -0,0 +1,195 @@
+package req
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	urlpkg "net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3/internal/pac"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// envProxy reads the proxy configuration from environment variables and
+// re-reads it periodically, unlike http.ProxyFromEnvironment which reads
+// it only once per process.
+type envProxy struct {
+	mu       sync.Mutex
+	interval time.Duration
+	loadedAt time.Time
+	proxy    func(*urlpkg.URL) (*urlpkg.URL, error)
+}
+
+func (p *envProxy) Proxy(req *http.Request) (*urlpkg.URL, error) {
+	p.mu.Lock()
+	if p.proxy == nil || time.Since(p.loadedAt) >= p.interval {
+		p.proxy = httpproxy.FromEnvironment().ProxyFunc()
+		p.loadedAt = time.Now()
+	}
+	proxy := p.proxy
+	p.mu.Unlock()
+	return proxy(req.URL)
+}
+
+// EnableProxyFromEnvRefresh set the proxy from environment variables
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY (or the lowercase versions thereof),
+// which are re-read at most once per interval (0 means re-read for each
+// request), so that long-running processes follow the changing proxy
+// settings. By default, the environment variables are read only once.
+func (c *Client) EnableProxyFromEnvRefresh(interval time.Duration) *Client {
+	p := &envProxy{interval: interval}
+	c.SetProxy(p.Proxy)
+	return c
+}
+
+// SetSchemeProxyURLs set different proxies for http and https requests,
+// the request is sent directly if the proxy url of its scheme is empty.
+// For example:
+//
+//	client.SetSchemeProxyURLs("http://proxy.local:8080", "socks5://proxy.local:1080")
+func (c *Client) SetSchemeProxyURLs(httpProxyURL, httpsProxyURL string) *Client {
+	proxies := make(map[string]*urlpkg.URL)
+	for scheme, proxyURL := range map[string]string{"http": httpProxyURL, "https": httpsProxyURL} {
+		if proxyURL == "" {
+			continue
+		}
+		u, err := urlpkg.Parse(proxyURL)
+		if err != nil {
+			c.log.Errorf("failed to parse %s proxy url %s: %v", scheme, proxyURL, err)
+			return c
+		}
+		proxies[scheme] = u
+	}
+	c.SetProxy(func(req *http.Request) (*urlpkg.URL, error) {
+		return proxies[req.URL.Scheme], nil
+	})
+	return c
+}
+
+// pacProxy chooses the proxy of requests by evaluating the PAC (proxy
+// auto-config) script, which is loaded once and cached after parsed.
+type pacProxy struct {
+	location string
+	log      Logger
+	mu       sync.Mutex
+	script   *pac.Script
+}
+
+func (p *pacProxy) load(ctx context.Context) (*pac.Script, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.script != nil {
+		return p.script, nil
+	}
+	var data []byte
+	var err error
+	if u, e := urlpkg.Parse(p.location); e == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		data, err = fetchPAC(ctx, p.location)
+	} else {
+		data, err = os.ReadFile(strings.TrimPrefix(p.location, "file://"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pac script %s: %w", p.location, err)
+	}
+	script, err := pac.Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pac script %s: %w", p.location, err)
+	}
+	p.script = script
+	return script, nil
+}
+
+// fetchPAC downloads the PAC script directly without proxy.
+func fetchPAC(ctx context.Context, url string) ([]byte, error) {
+	t := &http.Transport{}
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	}
+	t.Proxy = nil
+	defer t.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (p *pacProxy) Proxy(req *http.Request) (*urlpkg.URL, error) {
+	proxy, err := p.findProxy(req)
+	if err != nil { // fall back to DIRECT as the browsers do
+		p.log.Errorf("%v, send the request directly", err)
+		return nil, nil
+	}
+	return proxy, nil
+}
+
+func (p *pacProxy) findProxy(req *http.Request) (*urlpkg.URL, error) {
+	script, err := p.load(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	u := *req.URL
+	u.User = nil
+	result, err := script.FindProxyForURL(req.Context(), u.String(), req.URL.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate pac script %s: %w", p.location, err)
+	}
+	proxies, err := pac.ParseResult(result)
+	if err != nil {
+		return nil, err
+	}
+	for _, proxy := range proxies {
+		var scheme string
+		switch proxy.Type {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default: // SOCKS4 is not supported
+			continue
+		}
+		return &urlpkg.URL{Scheme: scheme, Host: proxy.Host}, nil
+	}
+	return nil, fmt.Errorf("no supported proxy in pac result %q", result)
+}
+
+// SetProxyPAC set the proxy of requests from the PAC (proxy auto-config)
+// script, which can be an http(s) url or a file path, the script is loaded
+// on the first request and cached after parsed, and FindProxyForURL of the
+// script is evaluated for each request to choose the proxy. The first
+// supported entry of the result is used ("SOCKS4" is not supported).
+//
+// The script is evaluated by a built-in interpreter of the subset of
+// JavaScript used by PAC scripts: function and variable declarations,
+// if/else, return, the ternary, logical, comparison and arithmetic
+// operators, string and array literals, the common string methods, and the
+// predefined PAC functions except dateRange. Loops, switch, objects, regular
+// expressions and exceptions are not supported. Like the browsers, the
+// request is sent directly and the error is logged if the script can't be
+// loaded, parsed or evaluated. For example:
+//
+//	client.SetProxyPAC("http://wpad.corp.local/wpad.dat")
+func (c *Client) SetProxyPAC(urlOrPath string) *Client {
+	p := &pacProxy{location: urlOrPath, log: c.log}
+	c.SetProxy(p.Proxy)
+	return c
+}
@@ -0,0 +1,387 @@
+package req
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	urlpkg "net/url"
+	"sync"
+	"time"
+)
+
+// ProxyPoolStrategy is the strategy of ProxyPool to choose the proxy for
+// each request.
+type ProxyPoolStrategy int
+
+const (
+	// ProxyPoolRoundRobin chooses the proxies in turn.
+	ProxyPoolRoundRobin ProxyPoolStrategy = iota
+	// ProxyPoolRandom chooses the proxy randomly.
+	ProxyPoolRandom
+	// ProxyPoolLeastFailures chooses the proxy with the least failures, the
+	// proxies with the same failures are chosen in turn.
+	ProxyPoolLeastFailures
+)
+
+const (
+	defaultProxyPoolMaxFails   = 3
+	defaultProxyPoolQuarantine = 30 * time.Second
+)
+
+// ProxyPoolOptions is the options of ProxyPool.
+type ProxyPoolOptions struct {
+	// MaxFails is the number of consecutive failures after which the proxy
+	// is quarantined, default is 3.
+	MaxFails int
+	// QuarantineDuration is how long the failing proxy is not chosen,
+	// default is 30s.
+	QuarantineDuration time.Duration
+	// Failover resends the request via another proxy immediately if it
+	// fails to connect to the proxy, in which case the request has not been
+	// sent to the server, so it's safe even for the non-idempotent requests.
+	// The request whose body can not be re-read is not resent.
+	Failover bool
+	// RetestInterval is the interval to retest the quarantined proxies by
+	// connecting to them, the reachable one is back to the pool before the
+	// quarantine ends, and the quarantine of the unreachable one is
+	// extended, which avoids sending requests to the proxies which are
+	// still dead. The retests are triggered by the requests. Default is 0,
+	// which means no retest.
+	RetestInterval time.Duration
+}
+
+// ProxyStats is the health metrics of a proxy in the ProxyPool.
+type ProxyStats struct {
+	// URL is the url of the proxy.
+	URL *urlpkg.URL
+	// Requests is the number of the requests sent via the proxy, including
+	// the failed ones.
+	Requests int64
+	// Failures is the number of the failed requests.
+	Failures int64
+	// SuccessRate is the ratio of the successful requests, which is 1 if
+	// no request has been sent.
+	SuccessRate float64
+	// Latency is the moving average of the durations of the successful
+	// requests.
+	Latency time.Duration
+	// Quarantined is true if the proxy is quarantined now.
+	Quarantined bool
+	// LastError is the error of the last failed request.
+	LastError error
+}
+
+type pooledProxy struct {
+	url              *urlpkg.URL
+	consecutiveFails int
+	fails            int
+	quarantinedUntil time.Time
+	requests         int64
+	latency          time.Duration
+	lastError        error
+	lastRetest       time.Time
+	retesting        bool
+}
+
+// ProxyPool chooses a proxy from the pool for each request with the
+// strategy, and quarantines the proxies which fail consecutively. Use
+// Client.SetProxyPool to set it on the client, which reports the results
+// of requests automatically.
+type ProxyPool struct {
+	strategy   ProxyPoolStrategy
+	maxFails   int
+	quarantine time.Duration
+	failover   bool
+	retest     time.Duration
+
+	mu      sync.Mutex
+	proxies []*pooledProxy
+	next    int
+}
+
+// NewProxyPool creates a ProxyPool from the proxy urls.
+func NewProxyPool(proxyURLs []string, strategy ProxyPoolStrategy, opts ...*ProxyPoolOptions) (*ProxyPool, error) {
+	if len(proxyURLs) == 0 {
+		return nil, errors.New("no proxy url in the pool")
+	}
+	p := &ProxyPool{
+		strategy:   strategy,
+		maxFails:   defaultProxyPoolMaxFails,
+		quarantine: defaultProxyPoolQuarantine,
+	}
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].MaxFails > 0 {
+			p.maxFails = opts[0].MaxFails
+		}
+		if opts[0].QuarantineDuration > 0 {
+			p.quarantine = opts[0].QuarantineDuration
+		}
+		p.failover = opts[0].Failover
+		p.retest = opts[0].RetestInterval
+	}
+	for _, proxyURL := range proxyURLs {
+		u, err := urlpkg.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url %s: %w", proxyURL, err)
+		}
+		p.proxies = append(p.proxies, &pooledProxy{url: u})
+	}
+	return p, nil
+}
+
+// pick chooses a proxy which is not quarantined and not excluded, the one
+// whose quarantine ends first is chosen if all of them are quarantined.
+func (p *ProxyPool) pick(exclude map[*pooledProxy]bool) *pooledProxy {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var available, candidates []*pooledProxy
+	for i := range p.proxies {
+		// keep the round-robin order starting from next
+		proxy := p.proxies[(p.next+i)%len(p.proxies)]
+		if exclude[proxy] {
+			continue
+		}
+		candidates = append(candidates, proxy)
+		if !now.Before(proxy.quarantinedUntil) {
+			available = append(available, proxy)
+		} else if p.retest > 0 && !proxy.retesting && now.Sub(proxy.lastRetest) >= p.retest {
+			proxy.retesting = true
+			proxy.lastRetest = now
+			go p.retestProxy(proxy)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.proxies
+	}
+	if len(available) == 0 {
+		earliest := candidates[0]
+		for _, proxy := range candidates[1:] {
+			if proxy.quarantinedUntil.Before(earliest.quarantinedUntil) {
+				earliest = proxy
+			}
+		}
+		return earliest
+	}
+	chosen := available[0]
+	switch p.strategy {
+	case ProxyPoolRandom:
+		chosen = available[rand.Intn(len(available))]
+	case ProxyPoolLeastFailures:
+		for _, proxy := range available[1:] {
+			if proxy.fails < chosen.fails {
+				chosen = proxy
+			}
+		}
+	}
+	for i, proxy := range p.proxies {
+		if proxy == chosen {
+			p.next = (i + 1) % len(p.proxies)
+			break
+		}
+	}
+	return chosen
+}
+
+// retestProxy connects to the quarantined proxy, and ends the quarantine
+// if it's reachable, otherwise extends it.
+func (p *ProxyPool) retestProxy(proxy *pooledProxy) {
+	conn, err := net.DialTimeout("tcp", canonicalAddr(proxy.url), p.retest)
+	if err == nil {
+		conn.Close()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	proxy.retesting = false
+	if err != nil {
+		proxy.lastError = err
+		proxy.quarantinedUntil = time.Now().Add(p.quarantine)
+		return
+	}
+	proxy.consecutiveFails = 0
+	proxy.quarantinedUntil = time.Time{}
+}
+
+func (p *ProxyPool) report(proxy *pooledProxy, err error, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		proxy.consecutiveFails = 0
+		return
+	}
+	proxy.requests++
+	if err == nil {
+		proxy.consecutiveFails = 0
+		if latency <= 0 { // unknown, e.g. reported by ReportResult
+			return
+		}
+		if proxy.latency == 0 {
+			proxy.latency = latency
+		} else { // exponentially weighted moving average
+			proxy.latency = (proxy.latency*4 + latency) / 5
+		}
+		return
+	}
+	proxy.lastError = err
+	proxy.fails++
+	proxy.consecutiveFails++
+	if proxy.consecutiveFails >= p.maxFails {
+		proxy.consecutiveFails = 0
+		proxy.quarantinedUntil = time.Now().Add(p.quarantine)
+	}
+}
+
+type proxyPoolPickKey struct{}
+
+// proxyPoolPick records the proxy chosen for the request, so that the
+// result can be reported after the request is done.
+type proxyPoolPick struct {
+	proxy *pooledProxy
+	// tried is the proxies which the request failed to connect to.
+	tried map[*pooledProxy]bool
+}
+
+// Proxy chooses the proxy for the request, which can be used as the proxy
+// function of Transport.SetProxy.
+func (p *ProxyPool) Proxy(req *http.Request) (*urlpkg.URL, error) {
+	pick, _ := req.Context().Value(proxyPoolPickKey{}).(*proxyPoolPick)
+	var exclude map[*pooledProxy]bool
+	if pick != nil {
+		exclude = pick.tried
+	}
+	proxy := p.pick(exclude)
+	if pick != nil {
+		pick.proxy = proxy
+	}
+	return proxy.url, nil
+}
+
+// ReportResult reports the result of the request sent via the proxy, the
+// proxy is quarantined after MaxFails consecutive failures. It's called
+// automatically if the pool is set by Client.SetProxyPool. The latency of
+// the proxy is not updated since it's unknown.
+func (p *ProxyPool) ReportResult(proxyURL *urlpkg.URL, err error) {
+	for _, proxy := range p.proxies {
+		if proxy.url.String() == proxyURL.String() {
+			p.report(proxy, err, 0)
+			return
+		}
+	}
+}
+
+// Available returns the proxy urls which are not quarantined.
+func (p *ProxyPool) Available() []*urlpkg.URL {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var urls []*urlpkg.URL
+	for _, proxy := range p.proxies {
+		if !now.Before(proxy.quarantinedUntil) {
+			urls = append(urls, proxy.url)
+		}
+	}
+	return urls
+}
+
+// Stats returns the health metrics of the proxies in the pool.
+func (p *ProxyPool) Stats() []ProxyStats {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]ProxyStats, 0, len(p.proxies))
+	for _, proxy := range p.proxies {
+		s := ProxyStats{
+			URL:         proxy.url,
+			Requests:    proxy.requests,
+			Failures:    int64(proxy.fails),
+			SuccessRate: 1,
+			Latency:     proxy.latency,
+			Quarantined: now.Before(proxy.quarantinedUntil),
+			LastError:   proxy.lastError,
+		}
+		if proxy.requests > 0 {
+			s.SuccessRate = float64(proxy.requests-s.Failures) / float64(proxy.requests)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func isProxyConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+}
+
+// proxyFailover resends the request via the other proxies while it fails to
+// connect to the proxy, if the failover is enabled.
+func (c *Client) proxyFailover(r *Request, pick *proxyPoolPick, resp *http.Response, err error) (*http.Response, error) {
+	pool := c.proxyPool
+	if !pool.failover {
+		return resp, err
+	}
+	for isProxyConnectError(err) && len(pick.tried)+1 < len(pool.proxies) {
+		if r.RawRequest.Body != nil && r.RawRequest.Body != NoBody && r.RawRequest.GetBody == nil {
+			break
+		}
+		if pick.tried == nil {
+			pick.tried = make(map[*pooledProxy]bool)
+		}
+		pick.tried[pick.proxy] = true
+		pick.proxy = nil
+		req := r.RawRequest.Clone(r.RawRequest.Context())
+		if r.RawRequest.GetBody != nil {
+			body, berr := r.RawRequest.GetBody()
+			if berr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+		r.RawRequest = req
+		start := time.Now()
+		resp, err = c.httpClient.Do(req)
+		if pick.proxy == nil {
+			break
+		}
+		pool.report(pick.proxy, err, time.Since(start))
+	}
+	return resp, err
+}
+
+// ProxyPoolStats returns the health metrics of the proxies in the pool set
+// by SetProxyPool, nil if no pool is set.
+func (c *Client) ProxyPoolStats() []ProxyStats {
+	if c.proxyPool == nil {
+		return nil
+	}
+	return c.proxyPool.Stats()
+}
+
+// SetProxyPool set a pool of proxies for requests fired from the client,
+// the proxy of each request is chosen with the strategy, and the proxies
+// which fail consecutively (the request returns an error) are quarantined
+// for a while, so that one client (and its connection pool and cookies)
+// can be used with rotating proxies. The success rate and latency of each
+// proxy can be got by ProxyPoolStats. For example:
+//
+//	client.SetProxyPool([]string{
+//		"http://proxy1.local:8080",
+//		"socks5://proxy2.local:1080",
+//	}, req.ProxyPoolLeastFailures, &req.ProxyPoolOptions{
+//		MaxFails:           2,
+//		QuarantineDuration: time.Minute,
+//		Failover:           true,
+//		RetestInterval:     10 * time.Second,
+//	})
+func (c *Client) SetProxyPool(proxyURLs []string, strategy ProxyPoolStrategy, opts ...*ProxyPoolOptions) *Client {
+	pool, err := NewProxyPool(proxyURLs, strategy, opts...)
+	if err != nil {
+		c.log.Errorf("failed to create proxy pool: %v", err)
+		return c
+	}
+	c.SetProxy(pool.Proxy)
+	c.proxyPool = pool
+	return c
+}
@@ -0,0 +1,372 @@
+package req
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// requestLineRegexp matches the request line of HTTP/1.x in the dump.
+var requestLineRegexp = regexp.MustCompile(`^[A-Z]+ \S+ HTTP/1\.[01]$`)
+
+// dumpedRequest is the request parsed from the dump.
+type dumpedRequest struct {
+	method string
+	target string
+	// http1 is true if the request is dumped from HTTP/1.x, otherwise it's
+	// dumped from HTTP/2 or HTTP/3 with pseudo headers.
+	http1    bool
+	pseudo   [][2]string
+	headers  [][2]string
+	body     []byte
+	response *dumpedResponse
+}
+
+// dumpedResponse is the response parsed from the dump.
+type dumpedResponse struct {
+	proto      string
+	status     string
+	statusCode int
+	headers    [][2]string
+	body       []byte
+}
+
+// headerValue returns the first value of the header (case-insensitive).
+func headerValue(headers [][2]string, name string) string {
+	for _, kv := range headers {
+		if strings.EqualFold(kv[0], name) {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+func (r *dumpedRequest) header(name string) string {
+	return headerValue(r.headers, name)
+}
+
+func (r *dumpedRequest) pseudoHeader(name string) string {
+	return headerValue(r.pseudo, name)
+}
+
+func isDumpRequestStart(line string) bool {
+	if strings.HasPrefix(line, ":") {
+		return !strings.HasPrefix(line, ":status:")
+	}
+	return requestLineRegexp.MatchString(line)
+}
+
+func isDumpResponseStart(line string) bool {
+	return strings.HasPrefix(line, "HTTP/") || strings.HasPrefix(line, ":status:")
+}
+
+// isDumpAnnotation reports whether the line is added by req (e.g. labels
+// and retry attempts) rather than captured from the wire.
+func isDumpAnnotation(line string) bool {
+	return strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "----- ")
+}
+
+type dumpParser struct {
+	r *bufio.Reader
+	// line is the line which is read but not consumed.
+	line    []byte
+	hasLine bool
+}
+
+func (p *dumpParser) peek() ([]byte, error) {
+	if !p.hasLine {
+		line, err := p.r.ReadBytes('\n')
+		if len(line) == 0 {
+			return nil, err
+		}
+		p.line, p.hasLine = line, true
+	}
+	return p.line, nil
+}
+
+func (p *dumpParser) next() ([]byte, error) {
+	line, err := p.peek()
+	p.hasLine = false
+	return line, err
+}
+
+// parse parses the requests and their responses from the dump, the
+// annotations are skipped.
+func (p *dumpParser) parse() (reqs []*dumpedRequest, err error) {
+	for {
+		line, err := p.peek()
+		if err == io.EOF {
+			return reqs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !isDumpRequestStart(trimLine(line)) {
+			p.next()
+			continue
+		}
+		r, err := p.parseRequest()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, r)
+		if line, _ = p.peek(); isDumpResponseStart(trimLine(line)) {
+			if r.response, err = p.parseResponse(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func trimLine(line []byte) string {
+	return strings.TrimRight(string(line), "\r\n")
+}
+
+// parseHeader parses the header lines until the empty line, the pseudo
+// headers (HTTP/2 and HTTP/3) are returned separately.
+func (p *dumpParser) parseHeader() (pseudo, headers [][2]string, err error) {
+	for {
+		line, err := p.next()
+		if err != nil && len(line) == 0 {
+			return nil, nil, fmt.Errorf("unexpected end of the header: %w", io.ErrUnexpectedEOF)
+		}
+		l := trimLine(line)
+		if l == "" {
+			return pseudo, headers, nil
+		}
+		name, value, ok := strings.Cut(l[1:], ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("malformed header line %q", l)
+		}
+		name = l[:1] + name
+		value = strings.TrimLeft(value, " ")
+		if strings.HasPrefix(name, ":") {
+			pseudo = append(pseudo, [2]string{name, value})
+		} else {
+			headers = append(headers, [2]string{name, value})
+		}
+	}
+}
+
+// scanBody reads the body of unknown length, which is followed by the
+// response, the next request or the annotation.
+func (p *dumpParser) scanBody() []byte {
+	var body []byte
+	for {
+		line, err := p.peek()
+		if len(line) == 0 && err != nil {
+			break
+		}
+		l := trimLine(line)
+		if isDumpResponseStart(l) || isDumpRequestStart(l) || isDumpAnnotation(l) {
+			break
+		}
+		body = append(body, line...)
+		p.next()
+	}
+	// the separator dumped after the body
+	return bytes.TrimSuffix(body, []byte("\r\n"))
+}
+
+func (p *dumpParser) parseRequest() (*dumpedRequest, error) {
+	r := &dumpedRequest{}
+	if line, _ := p.peek(); !strings.HasPrefix(string(line), ":") {
+		p.next()
+		parts := strings.SplitN(trimLine(line), " ", 3)
+		r.method, r.target, r.http1 = parts[0], parts[1], true
+	}
+	var err error
+	if r.pseudo, r.headers, err = p.parseHeader(); err != nil {
+		return nil, err
+	}
+	if !r.http1 {
+		r.method = r.pseudoHeader(":method")
+		r.target = r.pseudoHeader(":path")
+	}
+
+	if cl := headerValue(r.headers, "Content-Length"); cl != "" {
+		n, err := strconv.Atoi(cl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content-length %q", cl)
+		}
+		// the body is followed by the separator
+		body := make([]byte, n+2)
+		if _, err = io.ReadFull(p.r, body); err != nil || !bytes.HasSuffix(body, []byte("\r\n")) {
+			return nil, fmt.Errorf("request body of %s %s is not (fully) dumped", r.method, r.target)
+		}
+		if n > 0 {
+			r.body = body[:n]
+		}
+		return r, nil
+	}
+
+	body := p.scanBody()
+	if !r.http1 {
+		body = bytes.TrimSuffix(body, []byte("\r\n"))
+	}
+	if len(body) > 0 {
+		r.body = body
+	}
+	return r, nil
+}
+
+// parseResponse parses the response, whose body is always scanned as it
+// may be decoded (e.g. chunked) and differs from the Content-Length.
+func (p *dumpParser) parseResponse() (*dumpedResponse, error) {
+	resp := &dumpedResponse{proto: "HTTP/2.0"}
+	if line, _ := p.peek(); strings.HasPrefix(string(line), "HTTP/") {
+		p.next()
+		proto, status, _ := strings.Cut(trimLine(line), " ")
+		status, _, _ = strings.Cut(status, " ")
+		resp.proto, resp.status = proto, status
+	}
+	pseudo, headers, err := p.parseHeader()
+	if err != nil {
+		return nil, err
+	}
+	if resp.status == "" {
+		resp.status = headerValue(pseudo, ":status")
+	}
+	if resp.statusCode, err = strconv.Atoi(resp.status); err != nil {
+		return nil, fmt.Errorf("invalid response status %q", resp.status)
+	}
+	resp.headers = headers
+	resp.body = p.scanBody()
+	return resp, nil
+}
+
+// newRequest creates the request replaying the dumped one with the client.
+func (r *dumpedRequest) newRequest(c *Client, opts *ReplayDumpOptions) (*Request, string, error) {
+	url := r.target
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		host := r.pseudoHeader(":authority")
+		if r.http1 {
+			host = r.header("Host")
+		}
+		scheme := r.pseudoHeader(":scheme")
+		if c.BaseURL != "" {
+			url = strings.TrimSuffix(c.BaseURL, "/") + url
+		} else if host == "" {
+			return nil, "", fmt.Errorf("no host of %s %s in the dump", r.method, r.target)
+		} else {
+			if scheme == "" {
+				scheme = opts.Scheme
+			}
+			if scheme == "" {
+				scheme = "https"
+				if _, port, _ := net.SplitHostPort(host); port == "80" {
+					scheme = "http"
+				}
+			}
+			url = scheme + "://" + host + url
+		}
+	}
+	req := c.R()
+	req.Headers = make(http.Header)
+	var order []string
+	for _, kv := range r.headers {
+		name, value := kv[0], kv[1]
+		if !r.http1 {
+			name = http.CanonicalHeaderKey(name)
+		}
+		if value == redactedValue { // don't send the placeholder as the credential
+			c.log.Warnf("drop the redacted header %s of %s %s in the dump", name, r.method, r.target)
+			continue
+		}
+		order = append(order, name)
+		switch strings.ToLower(name) {
+		case "content-length":
+			continue
+		case "transfer-encoding":
+			req.EnableForceChunkedEncoding()
+			continue
+		}
+		req.Headers[name] = append(req.Headers[name], value)
+	}
+	if !r.http1 {
+		if authority := r.pseudoHeader(":authority"); authority != "" {
+			req.SetHeader("Host", authority)
+		}
+		var pseudoOrder []string
+		for _, kv := range r.pseudo {
+			pseudoOrder = append(pseudoOrder, kv[0])
+		}
+		req.SetPseudoHeaderOrder(pseudoOrder...)
+	}
+	req.SetHeaderOrder(order...)
+	if r.body != nil {
+		req.SetBodyBytes(r.body)
+	}
+	if r.http1 {
+		req.EnableForceHTTP1()
+	} else if strings.HasPrefix(url, "https://") {
+		req.EnableForceHTTP2()
+	}
+	return req, url, nil
+}
+
+// ReplayDumpOptions is the options of ReplayDump.
+type ReplayDumpOptions struct {
+	// Scheme is the scheme of the HTTP/1.x requests which are captured
+	// without the absolute URL in the request line, default is https unless
+	// the port of the captured host is 80. It's ignored if the BaseURL of
+	// the client is set.
+	Scheme string
+}
+
+// ReplayDump re-sends the requests captured in the dump (e.g. written by
+// EnableDumpAllToFile, with the request header and body dumped) exactly as
+// they were captured: the method, path, headers (including the case and
+// order for HTTP/1.x) and body, the responses and annotations in the dump
+// are ignored, which is useful to reproduce the bugs reported with dump
+// attachments. The common headers, cookies and redirects of the client are
+// not applied, as they're already in the dump. The headers redacted in the
+// dump (see DumpOptions.RedactHeaders) are dropped with a warning instead
+// of sending "REDACTED" as their values.
+//
+// The requests are sent to the BaseURL of the client if it's set (the
+// captured Host header is kept), otherwise to the captured host with the
+// captured scheme for HTTP/2 and HTTP/3, and the absolute URL of the
+// request line or the scheme of ReplayDumpOptions for HTTP/1.x. The
+// requests are sent in order, and it stops at the first failed one, the
+// responses received are returned. For example:
+//
+//	f, _ := os.Open("bug-report.dump")
+//	defer f.Close()
+//	resps, err := client.SetBaseURL("http://localhost:8080").ReplayDump(f)
+func (c *Client) ReplayDump(reader io.Reader, opts ...*ReplayDumpOptions) ([]*Response, error) {
+	var opt ReplayDumpOptions
+	if len(opts) > 0 && opts[0] != nil {
+		opt = *opts[0]
+	}
+	p := &dumpParser{r: bufio.NewReader(reader)}
+	reqs, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dump: %w", err)
+	}
+	if len(reqs) == 0 {
+		return nil, errors.New("req: no request found in the dump")
+	}
+	rc := c.Derive().SetRedirectPolicy(NoRedirectPolicy()).SetCookieJar(nil)
+	rc.Headers = nil
+	rc.Cookies = nil
+	var resps []*Response
+	for _, r := range reqs {
+		req, url, err := r.newRequest(rc, &opt)
+		if err != nil {
+			return resps, err
+		}
+		resp, err := req.Send(r.method, url)
+		resps = append(resps, resp)
+		if err != nil {
+			return resps, err
+		}
+	}
+	return resps, nil
+}
@@ -0,0 +1,296 @@
+package req
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrNoRecordedResponse is returned by the replay transport if no recorded
+// response matches the request.
+var ErrNoRecordedResponse = errors.New("req: no recorded response matches the request")
+
+// ReplayOptions controls how the requests are matched with the recorded
+// ones by the replay transport, the method and URL (host, path and query)
+// are always matched.
+type ReplayOptions struct {
+	// MatchHeaders are the headers whose values must be the same as the
+	// recorded ones.
+	MatchHeaders []string
+	// MatchBody requires the request body to be the same as the recorded
+	// one.
+	MatchBody bool
+}
+
+type replayEntry struct {
+	method string
+	host   string
+	uri    string
+	header http.Header
+	body   []byte
+
+	proto      string
+	statusCode int
+	respHeader http.Header
+	respBody   []byte
+}
+
+// replayTransport serves the requests with the recorded responses.
+type replayTransport struct {
+	opts    ReplayOptions
+	mu      sync.Mutex
+	entries []*replayEntry
+	// served is the number of times the entries of the key are served, so
+	// that the same requests get the recorded responses in order.
+	served map[string]int
+}
+
+func replayKey(method, host, uri string) string {
+	return method + " " + strings.ToLower(host) + uri
+}
+
+func newReplayTransport(entries []*replayEntry, opts ...*ReplayOptions) *replayTransport {
+	t := &replayTransport{entries: entries, served: make(map[string]int)}
+	if len(opts) > 0 && opts[0] != nil {
+		t.opts = *opts[0]
+	}
+	return t
+}
+
+func (t *replayTransport) match(req *http.Request, body []byte, e *replayEntry) bool {
+	for _, h := range t.opts.MatchHeaders {
+		if req.Header.Get(h) != e.header.Get(h) {
+			return false
+		}
+	}
+	return !t.opts.MatchBody || bytes.Equal(body, e.body)
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	key := replayKey(req.Method, host, req.URL.RequestURI())
+
+	t.mu.Lock()
+	var matched []*replayEntry
+	for _, e := range t.entries {
+		if replayKey(e.method, e.host, e.uri) == key && t.match(req, body, e) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRecordedResponse, req.Method, req.URL)
+	}
+	// serve the recorded responses in order, the last one is repeated.
+	n := t.served[key]
+	t.served[key] = n + 1
+	t.mu.Unlock()
+	if n >= len(matched) {
+		n = len(matched) - 1
+	}
+	e := matched[n]
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.statusCode, http.StatusText(e.statusCode)),
+		StatusCode:    e.statusCode,
+		Proto:         e.proto,
+		Header:        e.respHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.respBody)),
+		ContentLength: int64(len(e.respBody)),
+		Request:       req,
+	}
+	resp.ProtoMajor, resp.ProtoMinor, _ = http.ParseHTTPVersion(e.proto)
+	return resp, nil
+}
+
+// replayResponseHeader converts the recorded response header, the body is
+// recorded decoded, so the headers of the encoding are removed.
+func replayResponseHeader(headers [][2]string) http.Header {
+	h := make(http.Header)
+	for _, kv := range headers {
+		switch strings.ToLower(kv[0]) {
+		case "content-encoding", "content-length", "transfer-encoding":
+			continue
+		}
+		h.Add(kv[0], kv[1])
+	}
+	return h
+}
+
+func harNameValues(nvs []HARNameValue) [][2]string {
+	kvs := make([][2]string, len(nvs))
+	for i, nv := range nvs {
+		kvs[i] = [2]string{nv.Name, nv.Value}
+	}
+	return kvs
+}
+
+func replayEntriesFromHAR(r io.Reader) ([]*replayEntry, error) {
+	var har HAR
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("failed to parse har: %w", err)
+	}
+	var entries []*replayEntry
+	for i := range har.Log.Entries {
+		he := &har.Log.Entries[i]
+		if he.Response.Status == 0 { // failed request
+			continue
+		}
+		u, err := url.Parse(he.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url %q in har: %w", he.Request.URL, err)
+		}
+		e := &replayEntry{
+			method:     he.Request.Method,
+			host:       u.Host,
+			uri:        u.RequestURI(),
+			header:     make(http.Header),
+			proto:      he.Response.HTTPVersion,
+			statusCode: he.Response.Status,
+			respHeader: replayResponseHeader(harNameValues(he.Response.Headers)),
+		}
+		if e.proto == "" {
+			e.proto = "HTTP/1.1"
+		}
+		for _, h := range he.Request.Headers {
+			e.header.Add(h.Name, h.Value)
+		}
+		if he.Request.PostData != nil {
+			if e.body, err = he.Request.PostData.Body(); err != nil {
+				return nil, fmt.Errorf("invalid request body in har: %w", err)
+			}
+		}
+		if e.respBody, err = he.Response.Content.Body(); err != nil {
+			return nil, fmt.Errorf("invalid response body in har: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func replayEntriesFromDump(r io.Reader) ([]*replayEntry, error) {
+	p := &dumpParser{r: bufio.NewReader(r)}
+	reqs, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dump: %w", err)
+	}
+	var entries []*replayEntry
+	for _, r := range reqs {
+		if r.response == nil {
+			continue
+		}
+		host, uri := r.pseudoHeader(":authority"), r.target
+		if r.http1 {
+			host = r.header("Host")
+		}
+		if u, err := url.Parse(uri); err == nil && u.IsAbs() { // proxy request
+			host, uri = u.Host, u.RequestURI()
+		}
+		e := &replayEntry{
+			method:     r.method,
+			host:       host,
+			uri:        uri,
+			header:     make(http.Header),
+			body:       r.body,
+			proto:      r.response.proto,
+			statusCode: r.response.statusCode,
+			respHeader: replayResponseHeader(r.response.headers),
+			respBody:   r.response.body,
+		}
+		for _, kv := range r.headers {
+			e.header.Add(kv[0], kv[1])
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// SetReplayFromHAR set the client to serve the requests with the responses
+// recorded in the HAR (e.g. written by EnableHARLog or exported from the
+// browsers' DevTools) instead of sending them, so that the integration
+// tests can run offline deterministically. The requests are matched with
+// the recorded ones by the method and URL, and optionally the headers and
+// body (see ReplayOptions), the same requests get the recorded responses
+// in order (the last one is repeated), and ErrNoRecordedResponse is
+// returned if no one matches. For example:
+//
+//	f, _ := os.Open("testdata/api.har")
+//	defer f.Close()
+//	client.SetReplayFromHAR(f, &req.ReplayOptions{MatchBody: true})
+func (c *Client) SetReplayFromHAR(r io.Reader, opts ...*ReplayOptions) *Client {
+	entries, err := replayEntriesFromHAR(r)
+	if err != nil {
+		c.log.Errorf("failed to set replay from har: %v", err)
+		return c
+	}
+	c.Transport.replay = newReplayTransport(entries, opts...)
+	return c
+}
+
+// SetReplayFromDump is like SetReplayFromHAR, but with the responses
+// recorded in the dump (e.g. written by EnableDumpAllToFile), the request
+// and response (including the body) must be dumped.
+func (c *Client) SetReplayFromDump(r io.Reader, opts ...*ReplayOptions) *Client {
+	entries, err := replayEntriesFromDump(r)
+	if err != nil {
+		c.log.Errorf("failed to set replay from dump: %v", err)
+		return c
+	}
+	c.Transport.replay = newReplayTransport(entries, opts...)
+	return c
+}
+
+// EnableRecordReplay records the requests to the HAR file if it does not
+// exist, otherwise replays them from it (see SetReplayFromHAR), so that the
+// tests record once with the real servers, and replay in CI. The HAR file
+// is completed once DisableHARLog is called, delete it to record again.
+// For example:
+//
+//	client.EnableRecordReplay("testdata/api.har")
+//	defer client.DisableHARLog()
+func (c *Client) EnableRecordReplay(filename string, opts ...*ReplayOptions) *Client {
+	f, err := os.Open(filename)
+	if err == nil {
+		defer f.Close()
+		return c.SetReplayFromHAR(f, opts...)
+	}
+	if !os.IsNotExist(err) {
+		c.log.Errorf("failed to open har file: %v", err)
+		return c
+	}
+	if f, err = os.Create(filename); err != nil {
+		c.log.Errorf("failed to create har file: %v", err)
+		return c
+	}
+	c.EnableHARLog(f)
+	c.harLog.closer = f
+	return c
+}
+
+// DisableReplay disables the replay set by SetReplayFromHAR,
+// SetReplayFromDump or EnableRecordReplay, the requests are sent to the
+// servers again.
+func (c *Client) DisableReplay() *Client {
+	c.Transport.replay = nil
+	return c
+}
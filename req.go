@@ -45,7 +45,8 @@ type FileUpload struct {
 	GetFileContent GetContentFunc
 	// Optional file length in bytes.
 	FileSize int64
-	// Optional Content-Type
+	// Optional Content-Type, it will be detected by the extension of
+	// FileName or the file content if not set.
 	ContentType string
 
 	// Optional extra ContentDisposition parameters.
@@ -58,18 +59,19 @@ type FileUpload struct {
 
 // UploadInfo is the information for each UploadCallback call.
 type UploadInfo struct {
-	// parameter name in multipart upload
+	// parameter name in multipart upload, empty in raw body upload.
 	ParamName string
-	// filename in multipart upload
+	// filename in multipart upload, empty in raw body upload.
 	FileName string
-	// total file length in bytes.
+	// total file length in bytes, which is the body length in raw body
+	// upload (0 if the length is unknown).
 	FileSize int64
 	// uploaded file length in bytes.
 	UploadedSize int64
 }
 
 // UploadCallback is the callback which will be invoked during
-// multipart upload.
+// multipart upload or raw body upload.
 type UploadCallback func(info UploadInfo)
 
 // DownloadInfo is the information for each DownloadCallback call.
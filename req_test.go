@@ -1,6 +1,7 @@
 package req
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -20,6 +21,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -116,11 +118,15 @@ func handlePost(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("TestPost: text response"))
 	case "/raw-upload":
 		io.Copy(io.Discard, r.Body)
+	case "/content-length":
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(strconv.FormatInt(r.ContentLength, 10)))
 	case "/file-text":
 		r.ParseMultipartForm(10e6)
 		files := r.MultipartForm.File["file"]
 		file, _ := files[0].Open()
 		b, _ := io.ReadAll(file)
+		w.Header().Set("X-File-Content-Type", files[0].Header.Get(header.ContentType))
 		r.ParseForm()
 		if a := r.FormValue("attempt"); a != "" && a != "2" {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -166,6 +172,8 @@ func handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(fmt.Sprintf("%s's profile", user)))
 }
 
+const resumeContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
 type UserInfo struct {
 	Username string `json:"username" xml:"username"`
 	Email    string `json:"email" xml:"email"`
@@ -256,8 +264,19 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 	case "/chunked":
 		w.Header().Add("Trailer", "Expires")
 		w.Write([]byte(`This is a chunked body`))
+	case "/slow-body":
+		w.Write([]byte("slow"))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(" body"))
 	case "/host-header":
 		w.Write([]byte(r.Host))
+	case "/resume":
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "resume.txt", time.Time{}, strings.NewReader(resumeContent))
+	case "/truncated":
+		w.Header().Set("Content-Length", strconv.Itoa(len(resumeContent)))
+		w.Write([]byte(resumeContent[:10]))
 	case "/json":
 		r.ParseForm()
 		if r.FormValue("type") != "no" {
@@ -287,6 +306,11 @@ func handleGet(w http.ResponseWriter, r *http.Request) {
 	case "/payload":
 		b, _ := io.ReadAll(r.Body)
 		w.Write(b)
+	case "/gzip":
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte("TestGet: gzip response"))
+		gw.Close()
 	case "/gbk":
 		w.Header().Set(header.ContentType, "text/plain; charset=gbk")
 		w.Write(toGbk("我是roc"))
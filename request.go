@@ -3,6 +3,7 @@ package req
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 
 	"github.com/imroc/req/v3/internal/dump"
 	"github.com/imroc/req/v3/internal/header"
+	"github.com/imroc/req/v3/internal/rotate"
 	"github.com/imroc/req/v3/internal/util"
 )
 
@@ -42,6 +45,9 @@ type Request struct {
 	// URL is an auto-generated field, and is nil in request middleware (OnBeforeRequest),
 	// consider using RawURL if you want, it's not nil in client middleware (WrapRoundTripFunc)
 	URL *urlpkg.URL
+	// PathParamValues are the multi-value path parameters, see
+	// SetPathParamValues.
+	PathParamValues map[string][]string
 
 	isMultiPart              bool
 	disableAutoReadResponse  bool
@@ -56,6 +62,8 @@ type Request struct {
 	downloadCallbackInterval time.Duration
 	unReplayableBody         io.ReadCloser
 	retryOption              *retryOption
+	retryPriority            RetryPriority
+	forceHttpVersion         *httpVersion
 	bodyReadCloser           io.ReadCloser
 	dumpOptions              *DumpOptions
 	marshalBody              interface{}
@@ -63,15 +71,56 @@ type Request struct {
 	uploadFiles              []*FileUpload
 	uploadReader             []io.ReadCloser
 	outputFile               string
+	outputFileFromURL        bool
 	output                   io.Writer
+	outputWriters            []io.Writer
+	streamHandler            StreamHandler
+	logger                   Logger
+	resume                   bool
+	resumeOffset             int64
+	downloadSegments         int
+	downloadChecksum         *downloadChecksum
+	hedgeDelay               time.Duration
+	hedgeMaxExtra            int
+	hedgeCtx                 context.Context
+	tokenProvider            TokenProvider
+	tokenAuthState           int
+	labels                   map[string]string
+	awsSigV4                 *awsSigV4Signer
 	trace                    *clientTrace
 	dumpBuffer               *bytes.Buffer
 	responseReturnTime       time.Time
 	afterResponse            []ResponseMiddleware
+	locale                   string
+	bodyLength               int64
+	healthProbe              bool
+	pathEscapeOptions        *PathEscapeOptions
+	uncompressedBody         []byte
+	responseCharset          string
+	dumpFile                 *rotate.Writer
 }
 
 type GetContentFunc func() (io.ReadCloser, error)
 
+// requestHeaderExtraCap is the extra room of the request header map for the
+// headers set by the request and the middlewares (e.g. Content-Type and
+// Authorization) and the Cookie header set when sending.
+const requestHeaderExtraCap = 4
+
+// initHeaders allocates the request header map with the room for the common
+// headers of the client, so that the map is not grown again and again when
+// the headers are merged on the hot path.
+func (r *Request) initHeaders() {
+	if r.Headers != nil {
+		return
+	}
+	n := requestHeaderExtraCap
+	if r.client != nil {
+		n += len(r.client.Headers)
+	}
+	r.Headers = make(http.Header, n)
+}
+
 func (r *Request) getHeader(key string) string {
 	if r.Headers == nil {
 		return ""
@@ -145,7 +194,9 @@ func (r *Request) TraceInfo() TraceInfo {
 	// Capture remote address info when connection is non-nil
 	if ct.gotConnInfo.Conn != nil {
 		ti.RemoteAddr = ct.gotConnInfo.Conn.RemoteAddr()
+		ti.LocalAddr = ct.gotConnInfo.Conn.LocalAddr()
 	}
+	ti.Protocol = ct.protocol
 
 	return ti
 }
@@ -188,15 +239,29 @@ func (r *Request) SetFormData(data map[string]string) *Request {
 }
 
 // SetFormDataAnyType set the form data from a map, which value could be any type,
-// will convert to string automatically.
+// will convert to string automatically, see Client.SetParamTimeFormat for the
+// format of time.Time.
 // It will not been used if request method does not allow payload.
 func (r *Request) SetFormDataAnyType(data map[string]interface{}) *Request {
 	if r.FormData == nil {
 		r.FormData = urlpkg.Values{}
 	}
 	for k, v := range data {
-		r.FormData.Set(k, fmt.Sprint(v))
+		r.FormData.Set(k, r.client.formatParamValue(v))
+	}
+	return r
+}
+
+// SetFormDataAny set a form data with a key-value pair, the value could be
+// any type (e.g. int, bool, float64, time.Time and fmt.Stringer), which is
+// converted to string automatically, see Client.SetParamTimeFormat for the
+// format of time.Time. It will not been used if request method does not
+// allow payload.
+func (r *Request) SetFormDataAny(key string, value interface{}) *Request {
+	if r.FormData == nil {
+		r.FormData = urlpkg.Values{}
 	}
+	r.FormData.Set(key, r.client.formatParamValue(value))
 	return r
 }
 
@@ -211,7 +276,7 @@ func (r *Request) SetCookies(cookies ...*http.Cookie) *Request {
 func (r *Request) SetQueryString(query string) *Request {
 	params, err := urlpkg.ParseQuery(strings.TrimSpace(query))
 	if err != nil {
-		r.client.log.Warnf("failed to parse query string (%s): %v", query, err)
+		r.getLogger().Warnf("failed to parse query string (%s): %v", query, err)
 		return r
 	}
 	if r.QueryParams == nil {
@@ -266,13 +331,13 @@ func (r *Request) SetFiles(files map[string]string) *Request {
 func (r *Request) SetFile(paramName, filePath string) *Request {
 	file, err := os.Open(filePath)
 	if err != nil {
-		r.client.log.Errorf("failed to open %s: %v", filePath, err)
+		r.getLogger().Errorf("failed to open %s: %v", filePath, err)
 		r.appendError(err)
 		return r
 	}
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		r.client.log.Errorf("failed to stat file %s: %v", filePath, err)
+		r.getLogger().Errorf("failed to stat file %s: %v", filePath, err)
 		r.appendError(err)
 		return r
 	}
@@ -324,18 +389,19 @@ func (r *Request) SetFileUpload(uploads ...FileUpload) *Request {
 }
 
 // SetUploadCallback set the UploadCallback which will be invoked at least
-// every 200ms during file upload, usually used to show upload progress.
+// every 200ms during file upload or raw body upload, usually used to show
+// upload progress. Multipart body will be uploaded in chunked encoding.
 func (r *Request) SetUploadCallback(callback UploadCallback) *Request {
 	return r.SetUploadCallbackWithInterval(callback, 200*time.Millisecond)
 }
 
 // SetUploadCallbackWithInterval set the UploadCallback which will be invoked at least
-// every `minInterval` during file upload, usually used to show upload progress.
+// every `minInterval` during file upload or raw body upload, usually used to
+// show upload progress.
 func (r *Request) SetUploadCallbackWithInterval(callback UploadCallback, minInterval time.Duration) *Request {
 	if callback == nil {
 		return r
 	}
-	r.forceChunkedEncoding = true
 	r.uploadCallback = callback
 	r.uploadCallbackInterval = minInterval
 	return r
@@ -373,7 +439,16 @@ func (r *Request) SetResult(result interface{}) *Request {
 // no error occurs and Response.ResultState() returns SuccessState, by default
 // it requires HTTP status `code >= 200 && code <= 299`, you can also use
 // Request.SetResultStateCheckFunc or Client.SetResultStateCheckFunc to customize
-// the result state check logic.
+// the result state check logic. The fields tagged with `reqHeader:"Name"` are
+// set to the values of the response header, so that the result carries them.
+// For example:
+//
+//	type Repo struct {
+//		Name         string    `json:"name"`
+//		ETag         string    `reqHeader:"ETag"`
+//		LastModified time.Time `reqHeader:"Last-Modified"`
+//		RateLimit    int       `reqHeader:"X-RateLimit-Remaining"`
+//	}
 func (r *Request) SetSuccessResult(result interface{}) *Request {
 	if result == nil {
 		return r
@@ -396,6 +471,8 @@ func (r *Request) SetError(err interface{}) *Request {
 // no error occurs and Response.ResultState() returns ErrorState, by default
 // it requires HTTP status `code >= 400`, you can also use Request.SetResultStateCheckFunc
 // or Client.SetResultStateCheckFunc to customize the result state check logic.
+// The fields tagged with `reqHeader:"Name"` are set to the values of the
+// response header, see SetSuccessResult.
 func (r *Request) SetErrorResult(err interface{}) *Request {
 	if err == nil {
 		return r
@@ -437,6 +514,19 @@ func (r *Request) OnAfterResponse(m ResponseMiddleware) *Request {
 	return r
 }
 
+// SetLocale set the locale for the request, which overrides the locales
+// set by Client.SetLocale.
+func (r *Request) SetLocale(tag string) *Request {
+	r.locale = tag
+	return r
+}
+
+// GetLocale returns the locale used by the request, which is empty
+// if no locale is set.
+func (r *Request) GetLocale() string {
+	return r.locale
+}
+
 // SetHeaders set headers from a map for the request.
 func (r *Request) SetHeaders(hdrs map[string]string) *Request {
 	for k, v := range hdrs {
@@ -447,10 +537,8 @@ func (r *Request) SetHeaders(hdrs map[string]string) *Request {
 
 // SetHeader set a header for the request.
 func (r *Request) SetHeader(key, value string) *Request {
-	if r.Headers == nil {
-		r.Headers = make(http.Header)
-	}
-	r.Headers.Set(key, value)
+	r.initHeaders()
+	r.Headers[canonicalHeaderKey(key)] = []string{value}
 	return r
 }
 
@@ -466,9 +554,7 @@ func (r *Request) SetHeadersNonCanonical(hdrs map[string]string) *Request {
 // SetHeaderNonCanonical set a header for the request which key is a
 // non-canonical key (keep case unchanged), only valid for HTTP/1.1.
 func (r *Request) SetHeaderNonCanonical(key, value string) *Request {
-	if r.Headers == nil {
-		r.Headers = make(http.Header)
-	}
+	r.initHeaders()
 	r.Headers[key] = append(r.Headers[key], value)
 	return r
 }
@@ -492,9 +578,7 @@ const (
 //	    "accept-encoding",
 //	)
 func (r *Request) SetHeaderOrder(keys ...string) *Request {
-	if r.Headers == nil {
-		r.Headers = make(http.Header)
-	}
+	r.initHeaders()
 	r.Headers[HeaderOderKey] = append(r.Headers[HeaderOderKey], keys...)
 	return r
 }
@@ -510,24 +594,47 @@ func (r *Request) SetHeaderOrder(keys ...string) *Request {
 //	    ":method",
 //	)
 func (r *Request) SetPseudoHeaderOrder(keys ...string) *Request {
-	if r.Headers == nil {
-		r.Headers = make(http.Header)
-	}
+	r.initHeaders()
 	r.Headers[PseudoHeaderOderKey] = append(r.Headers[PseudoHeaderOderKey], keys...)
 	return r
 }
 
 // SetOutputFile set the file that response Body will be downloaded to.
+// The missing directories are created automatically, and the body is
+// written to a temporary file with ".part" suffix in the same directory
+// which is renamed to the file once the download succeeds, so interrupted
+// downloads never leave truncated files behind.
 func (r *Request) SetOutputFile(file string) *Request {
 	r.isSaveResponse = true
 	r.outputFile = file
 	return r
 }
 
+// EnableResume enables resumable download for the file set by SetOutputFile.
+// If the partial file (with ".part" suffix) of a previous interrupted
+// download exists, the download is resumed from the end of it with Range
+// header, and the partial file is appended instead of truncated.
+// The ETag or Last-Modified of the response is saved alongside the file
+// (with ".resume" suffix) until the download completes, which is sent in
+// If-Range header to make sure the partial file is still valid, the file
+// will be downloaded from scratch if the resource has changed.
+//
+// The partial file is kept if the download fails, and the download is
+// considered complete if the server responds with 416 (Range Not
+// Satisfiable) and the size of the partial file matches.
+func (r *Request) EnableResume() *Request {
+	r.resume = true
+	return r
+}
+
 // SetOutput set the io.Writer that response Body will be downloaded to.
+// The body is streamed into the writer (decompressed and charset-decoded
+// if needed) without being buffered into memory, so Response.Bytes() is
+// empty, which is suitable for piping large responses into hashers or
+// archive extractors.
 func (r *Request) SetOutput(output io.Writer) *Request {
 	if output == nil {
-		r.client.log.Warnf("nil io.Writer is not allowed in SetOutput")
+		r.getLogger().Warnf("nil io.Writer is not allowed in SetOutput")
 		return r
 	}
 	r.output = output
@@ -535,6 +642,23 @@ func (r *Request) SetOutput(output io.Writer) *Request {
 	return r
 }
 
+// AddOutputWriter adds an extra io.Writer which receives a copy of the
+// response body while it is being read, so the body can be saved, hashed
+// and unmarshalled in a single pass, e.g.
+//
+//	h := sha256.New()
+//	client.R().AddOutputWriter(archive).AddOutputWriter(h).SetSuccessResult(&result).Get(url)
+//
+// Note the writers receive the body of every attempt if retry is enabled.
+func (r *Request) AddOutputWriter(w io.Writer) *Request {
+	if w == nil {
+		r.getLogger().Warnf("nil io.Writer is not allowed in AddOutputWriter")
+		return r
+	}
+	r.outputWriters = append(r.outputWriters, w)
+	return r
+}
+
 // SetQueryParams set URL query parameters from a map for the request.
 func (r *Request) SetQueryParams(params map[string]string) *Request {
 	for k, v := range params {
@@ -544,10 +668,11 @@ func (r *Request) SetQueryParams(params map[string]string) *Request {
 }
 
 // SetQueryParamsAnyType set URL query parameters from a map for the request.
-// The value of map is any type, will be convert to string automatically.
+// The value of map is any type, will be convert to string automatically,
+// see Client.SetParamTimeFormat for the format of time.Time.
 func (r *Request) SetQueryParamsAnyType(params map[string]interface{}) *Request {
 	for k, v := range params {
-		r.SetQueryParam(k, fmt.Sprint(v))
+		r.SetQueryParamAny(k, v)
 	}
 	return r
 }
@@ -561,6 +686,22 @@ func (r *Request) SetQueryParam(key, value string) *Request {
 	return r
 }
 
+// SetQueryParamAny set an URL query parameter for the request, the value
+// could be any type (e.g. int, bool, float64, time.Time and fmt.Stringer),
+// which is converted to string automatically, see Client.SetParamTimeFormat
+// for the format of time.Time. For example:
+//
+//	client.R().SetQueryParamAny("page", 2).SetQueryParamAny("since", time.Now())
+func (r *Request) SetQueryParamAny(key string, value interface{}) *Request {
+	return r.SetQueryParam(key, r.client.formatParamValue(value))
+}
+
+// AddQueryParamAny add a URL query parameter for the request, the value
+// could be any type, see Request.SetQueryParamAny.
+func (r *Request) AddQueryParamAny(key string, value interface{}) *Request {
+	return r.AddQueryParam(key, r.client.formatParamValue(value))
+}
+
 // AddQueryParam add a URL query parameter for the request.
 func (r *Request) AddQueryParam(key, value string) *Request {
 	if r.QueryParams == nil {
@@ -598,6 +739,25 @@ func (r *Request) SetPathParam(key, value string) *Request {
 	return r
 }
 
+// SetPathParamValues set a multi-value URL path parameter for the request,
+// the values are escaped individually and joined with "/" as the repeated
+// path segments, e.g. "/files/{path...}" with "a b" and "c.txt" becomes
+// "/files/a%20b/c.txt".
+//
+// The wildcard placeholder "{key...}" also works with SetPathParam, whose
+// value is split by "/" into the segments, so that the nested keys of
+// storage-style APIs keep their slashes unescaped, while the slashes of the
+// value of the normal placeholder "{key}" are escaped. For example:
+//
+//	client.R().SetPathParam("key", "photos/2024/a.jpg").Get("/buckets/{bucket}/objects/{key...}")
+func (r *Request) SetPathParamValues(key string, values ...string) *Request {
+	if r.PathParamValues == nil {
+		r.PathParamValues = make(map[string][]string)
+	}
+	r.PathParamValues[key] = values
+	return r
+}
+
 func (r *Request) appendError(err error) {
 	r.error = multierror.Append(r.error, err)
 }
@@ -612,13 +772,19 @@ func (r *Request) newErrorResponse(err error) *Response {
 
 // Do fires http request, 0 or 1 context is allowed, and returns the *Response which
 // is always not nil, and Response.Err is not nil if error occurs.
-func (r *Request) Do(ctx ...context.Context) *Response {
+func (r *Request) Do(ctx ...context.Context) (resp *Response) {
 	if len(ctx) > 0 && ctx[0] != nil {
 		r.ctx = ctx[0]
 	}
 
 	defer func() {
+		if r.dumpFile != nil {
+			r.closeDumpFile(resp)
+		}
 		r.responseReturnTime = time.Now()
+		if r.trace != nil && r.client.traceInfoHandler != nil {
+			r.client.traceInfoHandler(r.TraceInfo())
+		}
 	}()
 	if r.error != nil {
 		return r.newErrorResponse(r.error)
@@ -626,7 +792,7 @@ func (r *Request) Do(ctx ...context.Context) *Response {
 	if r.retryOption != nil && r.retryOption.MaxRetries != 0 && r.unReplayableBody != nil { // retryable request should not have unreplayable Body
 		return r.newErrorResponse(errRetryableWithUnReplayableBody)
 	}
-	resp, _ := r.do()
+	resp, _ = r.do()
 	return resp
 }
 
@@ -640,10 +806,13 @@ func (r *Request) do() (resp *Response, err error) {
 		}
 	}()
 
+	start := r.client.now()
+	retryBudget := r.client.sharedRetryBudget
+	if retryBudget != nil {
+		retryBudget.deposit(start)
+	}
 	for {
-		if r.Headers == nil {
-			r.Headers = make(http.Header)
-		}
+		r.initHeaders()
 		for _, f := range r.client.udBeforeRequest {
 			if err = f(r.client, r); err != nil {
 				return
@@ -655,23 +824,46 @@ func (r *Request) do() (resp *Response, err error) {
 			}
 		}
 
-		if r.client.wrappedRoundTrip != nil {
+		if timeout := r.retryOption.attemptTimeout(r.client.now().Sub(start)); timeout > 0 {
+			resp, err = r.roundTripWithTimeout(timeout)
+		} else if r.client.wrappedRoundTrip != nil {
 			resp, err = r.client.wrappedRoundTrip.RoundTrip(r)
 		} else {
 			resp, err = r.client.roundTrip(r)
 		}
 
+		// re-authenticate with a refreshed token and resend once on 401.
+		if r.shouldReauthenticate(resp, err) {
+			r.tokenAuthState = tokenAuthRefresh
+			r.discardForResend(resp)
+			continue
+		}
+
+		// resend uncompressed if the compressed body is rejected.
+		if r.shouldResendUncompressed(resp, err) {
+			r.discardForResend(resp)
+			continue
+		}
+
 		// Determine if the error is from a canceled context.
 		// Store it here so it doesn't get lost when processing the AfterResponse middleware.
 		contextCanceled := errors.Is(err, context.Canceled)
 
-		for _, f := range r.afterResponse {
-			if err = f(r.client, resp); err != nil {
+		if len(r.afterResponse) > 0 {
+			// run all the middlewares, so that failures of the following
+			// ones (e.g. cleanup) are not hidden.
+			var errs []*MiddlewareError
+			for _, f := range r.afterResponse {
+				if e := f(r.client, resp); e != nil {
+					errs = append(errs, newMiddlewareError(f, e))
+				}
+			}
+			if err = joinMiddlewareErrors(errs); err != nil {
 				return
 			}
 		}
 
-		if contextCanceled || r.retryOption == nil || (r.RetryAttempt >= r.retryOption.MaxRetries && r.retryOption.MaxRetries >= 0) { // absolutely cannot retry.
+		if contextCanceled || r.retryOption == nil || r.retryOption.exhausted(r.RetryAttempt, r.client.now().Sub(start)) { // absolutely cannot retry.
 			return
 		}
 
@@ -690,27 +882,71 @@ func (r *Request) do() (resp *Response, err error) {
 		}
 
 		// need retry, attempt to retry
+		interval := r.retryOption.GetRetryInterval(resp, r.RetryAttempt+1)
+		now := r.client.now()
+		if r.retryOption.exceedsDeadline(now.Sub(start), interval) {
+			return
+		}
+		if retryBudget != nil && !retryBudget.withdraw(r.retryPriority, now) {
+			return
+		}
 		r.RetryAttempt++
 		if l := len(r.retryOption.RetryHooks); l > 0 {
 			for i := l - 1; i >= 0; i-- { // run retry hooks in reverse order
 				r.retryOption.RetryHooks[i](resp, err)
 			}
 		}
-		time.Sleep(r.retryOption.GetRetryInterval(resp, r.RetryAttempt))
+		time.Sleep(interval)
 
 		// clean up before retry
-		if r.dumpBuffer != nil {
+		if r.dumpBuffer != nil && (r.dumpOptions == nil || !r.dumpOptions.RetryDiff) {
 			r.dumpBuffer.Reset()
 		}
 		if r.trace != nil {
 			r.trace = &clientTrace{}
 		}
-		resp.body = nil
+		resp.resetBody()
 		resp.result = nil
 		resp.error = nil
 	}
 }
 
+// discardForResend discards the response which is resent without counting
+// as a retry (e.g. re-authentication), and resets the trace.
+func (r *Request) discardForResend(resp *Response) {
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	if r.trace != nil {
+		r.trace = &clientTrace{}
+	}
+}
+
+// roundTripWithTimeout sends the request with its own context which times
+// out after timeout, the context is canceled once the response body is
+// closed (or read if auto-read is enabled).
+func (r *Request) roundTripWithTimeout(timeout time.Duration) (resp *Response, err error) {
+	ctx := r.ctx
+	parent := ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	var cancel context.CancelFunc
+	r.ctx, cancel = context.WithTimeout(parent, timeout)
+	defer func() { r.ctx = ctx }()
+	if r.client.wrappedRoundTrip != nil {
+		resp, err = r.client.wrappedRoundTrip.RoundTrip(r)
+	} else {
+		resp, err = r.client.roundTrip(r)
+	}
+	if resp != nil && resp.Response != nil && resp.Body != nil && resp.body == nil {
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+	return
+}
+
 // Send fires http request with specified method and url, returns the
 // *Response which is always not nil, and the error is not nil if error occurs.
 func (r *Request) Send(method, url string) (*Response, error) {
@@ -836,11 +1072,13 @@ func (r *Request) SetBody(body interface{}) *Request {
 	switch b := body.(type) {
 	case io.ReadCloser:
 		r.unReplayableBody = b
+		r.bodyLength = readerLength(b)
 		r.GetBody = func() (io.ReadCloser, error) {
 			return r.unReplayableBody, nil
 		}
 	case io.Reader:
 		r.unReplayableBody = io.NopCloser(b)
+		r.bodyLength = readerLength(b)
 		r.GetBody = func() (io.ReadCloser, error) {
 			return r.unReplayableBody, nil
 		}
@@ -864,6 +1102,29 @@ func (r *Request) SetBody(body interface{}) *Request {
 	return r
 }
 
+// readerLength returns the number of unread bytes of the reader if it can
+// be determined (e.g. bytes.Reader, strings.Reader and os.File), otherwise
+// returns 0 which means the length is unknown.
+func readerLength(reader io.Reader) int64 {
+	switch rd := reader.(type) {
+	case interface{ Len() int }:
+		return int64(rd.Len())
+	case *os.File:
+		fi, err := rd.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0
+		}
+		offset, err := rd.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0
+		}
+		return fi.Size() - offset
+	case interface{ Size() int64 }:
+		return rd.Size()
+	}
+	return 0
+}
+
 // SetBodyBytes set the request Body as []byte.
 func (r *Request) SetBodyBytes(body []byte) *Request {
 	r.Body = body
@@ -902,6 +1163,26 @@ func (r *Request) SetBodyJsonMarshal(v interface{}) *Request {
 	return r.SetBodyJsonBytes(b)
 }
 
+// SetBodyJsonStream set the request Body that encoded from object by
+// json.Encoder, and set Content-Type header as "application/json; charset=utf-8".
+// Unlike SetBodyJsonMarshal, the object is encoded while the body is being
+// sent with chunked encoding instead of being marshaled into memory first,
+// which is useful for very large payloads. The object is encoded again if the
+// request is resent (e.g. retry), so it should not be modified before the
+// request is done. Note the json marshal function set by Client.SetJsonMarshal
+// is not used here.
+func (r *Request) SetBodyJsonStream(v interface{}) *Request {
+	r.SetContentType(header.JsonContentType)
+	r.GetBody = func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(json.NewEncoder(pw).Encode(v))
+		}()
+		return pr, nil
+	}
+	return r
+}
+
 // SetBodyXmlString set the request Body as string and set Content-Type header
 // as "text/xml; charset=utf-8"
 func (r *Request) SetBodyXmlString(body string) *Request {
@@ -966,6 +1247,127 @@ func (r *Request) GetContextData(key any) any {
 	return r.Context().Value(key)
 }
 
+// SetLabel set the label (metadata) of the request, e.g. the logical
+// operation name ("operation" -> "CreateOrder"), so that telemetry can be
+// grouped by labels rather than raw URL. Labels are annotated in the dump,
+// recorded in the audit log, and can be read in hooks and middlewares
+// (e.g. OnError) with GetLabel.
+func (r *Request) SetLabel(key, value string) *Request {
+	if r.labels == nil {
+		r.labels = make(map[string]string)
+	}
+	r.labels[key] = value
+	return r
+}
+
+// SetLabels set labels of the request, see SetLabel.
+func (r *Request) SetLabels(labels map[string]string) *Request {
+	for k, v := range labels {
+		r.SetLabel(k, v)
+	}
+	return r
+}
+
+// GetLabel returns the label value of the key, which set by SetLabel.
+func (r *Request) GetLabel(key string) string {
+	return r.labels[key]
+}
+
+// GetLabels returns a copy of all labels of the request.
+func (r *Request) GetLabels() map[string]string {
+	if len(r.labels) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(r.labels))
+	for k, v := range r.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// OperationName returns the low-cardinality operation name of the request
+// which can be used as the label of metrics and tracing, the "operation"
+// label set by SetLabel takes precedence, otherwise it's derived from the
+// method and the path template before path params are substituted, e.g.
+// "GET /users/{id}" (rather than "GET /users/123"), the path of the BaseURL
+// of the client is joined if the url is relative, e.g. "GET /v1/users/{id}".
+func (r *Request) OperationName() string {
+	if op := r.labels["operation"]; op != "" {
+		return op
+	}
+	return r.Method + " " + r.pathTemplate()
+}
+
+// pathTemplate returns the path template of the request, which is joined
+// with the path of the BaseURL the same way as parseRequestURL.
+func (r *Request) pathTemplate() string {
+	rawURL := r.RawURL
+	if r.client != nil && !strings.Contains(rawURL, "://") {
+		if r.client.scheme != "" {
+			rawURL = r.client.scheme + "://" + rawURL
+		} else if r.client.BaseURL != "" {
+			if !strings.HasPrefix(rawURL, "/") {
+				rawURL = "/" + rawURL
+			}
+			rawURL = r.client.BaseURL + rawURL
+		}
+	}
+	return pathTemplate(rawURL)
+}
+
+// pathTemplate returns the path of the raw url without scheme, host, query
+// and fragment.
+func pathTemplate(rawURL string) string {
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rawURL = rawURL[i+3:]
+		if i = strings.IndexByte(rawURL, '/'); i >= 0 {
+			rawURL = rawURL[i:]
+		} else {
+			rawURL = ""
+		}
+	}
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	if rawURL == "" {
+		return "/"
+	}
+	return rawURL
+}
+
+// labelString returns the labels sorted by key, e.g. "a=1, b=2".
+func (r *Request) labelString() string {
+	keys := make([]string, 0, len(r.labels))
+	for k := range r.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + r.labels[k]
+	}
+	return strings.Join(keys, ", ")
+}
+
+// SetLogger set the logger for the request, which overrides the logger of
+// the client (see Client.SetLogger), e.g. a logger with the contextual
+// fields of the tenant or operation, it's used by the warnings and the
+// debug log (see Client.EnableDebugLog) of the request. Set to nil to
+// disable the log of the request.
+func (r *Request) SetLogger(log Logger) *Request {
+	if log == nil {
+		log = &disableLogger{}
+	}
+	r.logger = log
+	return r
+}
+
+func (r *Request) getLogger() Logger {
+	if r.logger != nil {
+		return r.logger
+	}
+	return r.client.log
+}
+
 // DisableAutoReadResponse disable read response body automatically (enabled by default).
 func (r *Request) DisableAutoReadResponse() *Request {
 	r.disableAutoReadResponse = true
@@ -978,6 +1380,52 @@ func (r *Request) EnableAutoReadResponse() *Request {
 	return r
 }
 
+// EnableForceHTTP1 enable force using HTTP1 for the request, which
+// overrides the http version forced by the client, so that the protocol can
+// be pinned per endpoint (e.g. servers misbehave on HTTP2).
+func (r *Request) EnableForceHTTP1() *Request {
+	return r.setForceHttpVersion(h1)
+}
+
+// EnableForceHTTP2 enable force using HTTP2 for the request (https only),
+// which overrides the http version forced by the client.
+func (r *Request) EnableForceHTTP2() *Request {
+	return r.setForceHttpVersion(h2)
+}
+
+// EnableForceHTTP3 enable force using HTTP3 for the request (https only),
+// which overrides the http version forced by the client, the request fails
+// if HTTP3 is not enabled by the client.
+func (r *Request) EnableForceHTTP3() *Request {
+	return r.setForceHttpVersion(h3)
+}
+
+// DisableForceHttpVersion disable force using specified http version for
+// the request even if it's forced by the client, the http version is
+// negotiated automatically.
+func (r *Request) DisableForceHttpVersion() *Request {
+	return r.setForceHttpVersion("")
+}
+
+func (r *Request) setForceHttpVersion(version httpVersion) *Request {
+	r.forceHttpVersion = &version
+	return r
+}
+
+// SetResponseCharset forces decoding the response body from the charset
+// (e.g. "gbk") to utf-8 for the request, which is useful for the servers
+// that lie about or omit the charset, the charset in Content-Type and body
+// is ignored, and the auto-decode configuration of the client is not
+// changed. The body is not decoded if the charset is utf-8.
+func (r *Request) SetResponseCharset(charset string) *Request {
+	if _, err := lookupCharset(charset); err != nil {
+		r.appendError(err)
+		return r
+	}
+	r.responseCharset = charset
+	return r
+}
+
 // DisableTrace disables trace.
 func (r *Request) DisableTrace() *Request {
 	r.trace = nil
@@ -1018,17 +1466,46 @@ func (r *Request) EnableDumpTo(output io.Writer) *Request {
 	return r.EnableDump()
 }
 
-// EnableDumpToFile enables dump and save to the specified filename.
+// EnableDumpToFile enables dump and save to the specified filename, the file
+// is closed once the request is done, or once the response body is closed
+// if it's not read automatically (e.g. see DisableAutoReadResponse), and is
+// reopened to append the dump if the request is sent again.
 func (r *Request) EnableDumpToFile(filename string) *Request {
-	file, err := os.Create(filename)
+	w, err := rotate.Create(filename)
 	if err != nil {
 		r.appendError(err)
 		return r
 	}
-	r.getDumpOptions().Output = file
+	if r.dumpFile != nil {
+		r.dumpFile.Close()
+	}
+	r.dumpFile = w
+	r.getDumpOptions().Output = w
 	return r.EnableDump()
 }
 
+// closeDumpFile releases the file opened by EnableDumpToFile, which is
+// deferred to the closing of the response body if it's not read yet.
+func (r *Request) closeDumpFile(resp *Response) {
+	if resp.Err == nil && resp.Response != nil && resp.Body != nil && resp.body == nil {
+		resp.Body = &closeFileBody{ReadCloser: resp.Body, file: r.dumpFile}
+		return
+	}
+	r.dumpFile.Release()
+}
+
+// closeFileBody releases the file when the body is closed.
+type closeFileBody struct {
+	io.ReadCloser
+	file *rotate.Writer
+}
+
+func (b *closeFileBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.file.Release()
+	return err
+}
+
 // SetDumpOptions sets DumpOptions at request level.
 func (r *Request) SetDumpOptions(opt *DumpOptions) *Request {
 	if opt == nil {
@@ -1050,6 +1527,22 @@ func (r *Request) EnableDump() *Request {
 	return r.SetContext(context.WithValue(r.Context(), dump.DumperKey, newDumper(r.getDumpOptions())))
 }
 
+// EnableDumpOnError is like EnableDump, but the dump content is kept only
+// if the request fails (an error occurs or the response is in error state,
+// see Client.SetResultStateCheckFunc), and discarded otherwise, so that
+// Response.Dump() can be attached to the error report without keeping the
+// dump of the successful requests.
+func (r *Request) EnableDumpOnError() *Request {
+	return r.EnableDump().OnAfterResponse(discardDumpOnSuccess)
+}
+
+func discardDumpOnSuccess(client *Client, resp *Response) error {
+	if resp.Err == nil && !resp.IsErrorState() && resp.Request.dumpBuffer != nil {
+		resp.Request.dumpBuffer.Reset()
+	}
+	return nil
+}
+
 // EnableDumpWithoutBody enables dump only header for the request and response.
 func (r *Request) EnableDumpWithoutBody() *Request {
 	o := r.getDumpOptions()
@@ -1196,6 +1689,50 @@ func (r *Request) AddRetryCondition(condition RetryConditionFunc) *Request {
 	return r
 }
 
+// SetRetryPolicy configures retry with a compact policy string, which is
+// a space separated list of key=value pairs, e.g.
+//
+//	req.SetRetryPolicy("retries=3 backoff=exp base=100ms max=5s on=429,5xx,conn-reset")
+//
+// Supported keys:
+//   - retries: maximum retry count, negative means retry infinitely.
+//   - backoff: "fixed" (default) or "exp" (capped exponential backoff with jitter).
+//   - base: the fixed interval, or the minimum interval of exp backoff (default 100ms).
+//   - max: the maximum interval of exp backoff.
+//   - on: comma separated retry conditions, each one can be a status code
+//     (e.g. 429), a status class (e.g. 5xx), "error" (any error) or the
+//     name of an ErrorClass (e.g. "timeout", "conn-reset", "dns"), which is
+//     classified by the ErrorClassifier of the client.
+//
+// It overrides the retry count, interval and conditions that have been set
+// before (including client-level ones), retry hooks are kept.
+func (r *Request) SetRetryPolicy(policy string) *Request {
+	ro, err := parseRetryPolicy(policy)
+	if err != nil {
+		r.appendError(err)
+		return r
+	}
+	ro.RetryHooks = r.getRetryOption().RetryHooks
+	ro.Budget = r.getRetryOption().Budget
+	r.retryOption = ro
+	return r
+}
+
+// SetRetryBudget set the RetryBudget for the request, which overrides the
+// client-level one, see Client.SetCommonRetryBudget.
+func (r *Request) SetRetryBudget(budget RetryBudget) *Request {
+	r.getRetryOption().Budget = &budget
+	return r
+}
+
+// SetRetryPriority set the priority of the retries of the request, which
+// determines whether it can retry when the SharedRetryBudget of the client
+// is running out, see Client.SetSharedRetryBudget.
+func (r *Request) SetRetryPriority(priority RetryPriority) *Request {
+	r.retryPriority = priority
+	return r
+}
+
 // SetClient change the client of request dynamically.
 func (r *Request) SetClient(client *Client) *Request {
 	if client != nil {
@@ -2,18 +2,28 @@ package req
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/imroc/req/v3/internal/dump"
 	"github.com/imroc/req/v3/internal/header"
 	"github.com/imroc/req/v3/internal/tests"
 )
@@ -338,6 +348,14 @@ func TestSetBodyMarshal(t *testing.T) {
 			},
 			Assert: assertUsernameJson,
 		},
+		{ // SetBodyJsonStream with struct
+			Set: func(r *Request) {
+				var user User
+				user.Username = username
+				r.SetBodyJsonStream(&user)
+			},
+			Assert: assertUsernameJson,
+		},
 		{ // SetBodyXmlMarshal with struct
 			Set: func(r *Request) {
 				var user User
@@ -479,6 +497,57 @@ func TestSetBody(t *testing.T) {
 	}
 }
 
+func TestReaderBodyContentLength(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		resp, err := c.R().SetBody(strings.NewReader("hello")).Post("/content-length")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "5", resp.String())
+
+		resp, err = c.R().SetBody(bytes.NewReader([]byte("hello world"))).Post("/content-length")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "11", resp.String())
+
+		resp, err = c.R().SetBody(io.NopCloser(strings.NewReader("hello"))).Post("/content-length")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "-1", resp.String())
+
+		resp, err = c.R().SetBody(strings.NewReader("hello")).EnableForceChunkedEncoding().Post("/content-length")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "-1", resp.String())
+	})
+}
+
+func TestAddOutputWriter(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		var raw bytes.Buffer
+		h := sha256.New()
+		var user struct {
+			Name string `json:"name"`
+		}
+		resp, err := c.R().AddOutputWriter(&raw).AddOutputWriter(h).SetSuccessResult(&user).Get("/json")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "roc", user.Name)
+		tests.AssertEqual(t, `{"name": "roc"}`, raw.String())
+		sum := sha256.Sum256(raw.Bytes())
+		tests.AssertEqual(t, hex.EncodeToString(sum[:]), hex.EncodeToString(h.Sum(nil)))
+
+		var out, tee bytes.Buffer
+		resp, err = c.R().SetOutput(&out).AddOutputWriter(&tee).Get("/")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "TestGet: text response", out.String())
+		tests.AssertEqual(t, out.String(), tee.String())
+
+		_, err = c.R().AddOutputWriter(errorWriter{}).Get("/")
+		tests.AssertErrorContains(t, err, "write failed")
+	})
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
 func TestCookie(t *testing.T) {
 	headers := make(http.Header)
 	resp, err := tc().R().SetCookies(
@@ -495,6 +564,47 @@ func TestCookie(t *testing.T) {
 	tests.AssertEqual(t, "cookie1=value1; cookie2=value2", headers.Get("Cookie"))
 }
 
+func TestAddCookies(t *testing.T) {
+	cookies := []*http.Cookie{
+		{Name: "a", Value: "1"},
+		{Name: "b\n", Value: "with space"},
+		{Name: "c", Value: "3;\""},
+	}
+	expected := &http.Request{Header: http.Header{"Cookie": {"x=0"}}}
+	for _, cookie := range cookies {
+		expected.AddCookie(cookie)
+	}
+	req := &http.Request{Header: http.Header{"Cookie": {"x=0"}}}
+	addCookies(req, cookies)
+	tests.AssertEqual(t, expected.Header, req.Header)
+
+	h := http.Header{"Accept": {"a", "b"}, "X-Empty": nil}
+	clone := cloneHeader(h, requestHeaderExtraCap)
+	tests.AssertEqual(t, h, clone)
+	clone.Add("Accept", "c")
+	tests.AssertEqual(t, []string{"a", "b"}, h["Accept"])
+	tests.AssertEqual(t, true, cloneHeader(nil, requestHeaderExtraCap) == nil)
+}
+
+func TestCanonicalHeaderKey(t *testing.T) {
+	for _, key := range []string{"content-type", "Content-Type", "x-custom-header", "USER-AGENT", "x-request-id"} {
+		tests.AssertEqual(t, http.CanonicalHeaderKey(key), canonicalHeaderKey(key))
+	}
+	r := C().R().SetHeader("content-type", "text/plain")
+	tests.AssertEqual(t, []string{"text/plain"}, r.Headers["Content-Type"])
+}
+
+func BenchmarkSetHeader(b *testing.B) {
+	c := C()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.R().SetHeader("content-type", "application/json").
+			SetHeader("accept", "application/json").
+			SetHeader("authorization", "Bearer token").
+			SetHeader("x-request-id", "1")
+	}
+}
+
 func TestSetBasicAuth(t *testing.T) {
 	headers := make(http.Header)
 	resp, err := tc().R().
@@ -638,6 +748,28 @@ func testQueryParam(t *testing.T, c *Client) {
 	tests.AssertEqual(t, "key1=value1&key1=value11&key2=value2&key2=value22&key3=value3&key4=value4&key4=value44&key5=value5&key6=value6&key6=value66", resp.String())
 }
 
+func TestQueryParamAny(t *testing.T) {
+	c := tc()
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	resp, err := c.R().
+		SetQueryParamAny("key1", 1).
+		SetQueryParamAny("key2", true).
+		SetQueryParamAny("key3", 1.5).
+		SetQueryParamAny("key4", ts).
+		AddQueryParamAny("key4", time.Second).
+		Get("/query-parameter")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "key1=1&key2=true&key3=1.5&key4=2024-01-02T03%3A04%3A05Z&key4=1s", resp.String())
+
+	c.SetParamTimeFormat(time.DateOnly).SetCommonQueryParamAny("key1", 2)
+	resp, err = c.R().SetQueryParamsAnyType(map[string]interface{}{"key2": &ts}).Get("/query-parameter")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "key1=2&key2=2024-01-02", resp.String())
+
+	r := c.R().SetFormDataAny("since", ts).SetFormDataAnyType(map[string]interface{}{"n": 3})
+	tests.AssertEqual(t, "n=3&since=2024-01-02", r.FormData.Encode())
+}
+
 func TestPathParam(t *testing.T) {
 	testPathParam(t, tc())
 	testPathParam(t, tc().EnableForceHTTP1())
@@ -652,6 +784,30 @@ func testPathParam(t *testing.T, c *Client) {
 	tests.AssertEqual(t, fmt.Sprintf("%s's profile", username), resp.String())
 }
 
+func TestWildcardPathParam(t *testing.T) {
+	c := C().SetBaseURL("http://example.com").SetCommonPathParam("bucket", "my bucket")
+	cases := []struct {
+		r        *Request
+		template string
+		path     string
+	}{
+		{c.R().SetPathParam("key", "photos/2024/a b.jpg"), "/b/{bucket}/o/{key...}", "/b/my%20bucket/o/photos/2024/a%20b.jpg"},
+		{c.R().SetPathParam("key", "photos/2024/a b.jpg"), "/b/{bucket}/o/{key}", "/b/my%20bucket/o/photos%2F2024%2Fa%20b.jpg"},
+		{c.R().SetPathParamValues("key", "a/b", "c?d", ""), "/o/{key...}/meta", "/o/a%2Fb/c%3Fd//meta"},
+		{c.R().SetPathParamValues("id", "1", "2"), "/items/{id}", "/items/1/2"},
+		{c.R().SetPathParam("key", "a/b").SetPathParamValues("key", "c"), "/o/{key...}", "/o/a/b"},
+	}
+	for _, cs := range cases {
+		cs.r.RawURL = cs.template
+		tests.AssertNoError(t, parseRequestURL(c, cs.r))
+		tests.AssertEqual(t, cs.path, cs.r.URL.EscapedPath())
+	}
+
+	resp, err := tc().R().SetPathParamValues("username", "imroc").Get("/user/{username...}/profile")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "imroc's profile", resp.String())
+}
+
 func TestSuccess(t *testing.T) {
 	testWithAllTransport(t, testSuccess)
 }
@@ -893,6 +1049,18 @@ func TestSetFileBytes(t *testing.T) {
 	tests.AssertEqual(t, "test", resp.String())
 }
 
+func TestFileUploadContentType(t *testing.T) {
+	resp := uploadTextFile(t, func(r *Request) {
+		r.SetFileBytes("file", "data.json", []byte(`{"name":"roc"}`))
+	})
+	tests.AssertEqual(t, "application/json", resp.GetHeader("X-File-Content-Type"))
+
+	resp = uploadTextFile(t, func(r *Request) {
+		r.SetFileBytes("file", "data", []byte("test"))
+	})
+	tests.AssertEqual(t, header.PlainTextContentType, resp.GetHeader("X-File-Content-Type"))
+}
+
 func TestSetFileReader(t *testing.T) {
 	buff := bytes.NewBufferString("test")
 	resp := uploadTextFile(t, func(r *Request) {
@@ -986,6 +1154,353 @@ func TestUploadCallback(t *testing.T) {
 	tests.AssertEqual(t, true, n > 1)
 }
 
+func TestRawBodyUploadCallback(t *testing.T) {
+	body := strings.Repeat("h", 1024*1024)
+	var last UploadInfo
+	resp, err := tc().R().
+		SetBody(strings.NewReader(body)).
+		SetUploadCallbackWithInterval(func(info UploadInfo) {
+			last = info
+		}, 0).
+		Post("/content-length")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, strconv.Itoa(len(body)), resp.String())
+	tests.AssertEqual(t, int64(len(body)), last.FileSize)
+	tests.AssertEqual(t, int64(len(body)), last.UploadedSize)
+}
+
+func TestResumeDownload(t *testing.T) {
+	c := tc().SetOutputDirectory(t.TempDir())
+	file := filepath.Join(c.outputDirectory, "resume.txt")
+	download := func() *Response {
+		resp, err := c.R().SetOutputFile("resume.txt").EnableResume().Get("/resume")
+		tests.AssertNoError(t, err)
+		b, err := os.ReadFile(file)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, resumeContent, string(b))
+		return resp
+	}
+
+	// partial file without validator
+	tests.AssertNoError(t, os.WriteFile(partFilename(file), []byte(resumeContent[:10]), 0644))
+	resp := download()
+	tests.AssertEqual(t, http.StatusPartialContent, resp.StatusCode)
+	tests.AssertEqual(t, "bytes=10-", resp.Request.Headers.Get("Range"))
+	_, err := os.Stat(partFilename(file))
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	// partial file is already complete
+	tests.AssertNoError(t, os.WriteFile(partFilename(file), []byte(resumeContent), 0644))
+	resp = download()
+	tests.AssertEqual(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	// partial file with stale validator
+	tests.AssertNoError(t, os.WriteFile(partFilename(file), []byte("stale"), 0644))
+	tests.AssertNoError(t, os.WriteFile(file+resumeValidatorSuffix, []byte(`"v0"`), 0644))
+	resp = download()
+	tests.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	_, err = os.Stat(file + resumeValidatorSuffix)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+}
+
+func TestAtomicDownload(t *testing.T) {
+	c := tc().SetOutputDirectory(t.TempDir())
+	file := filepath.Join(c.outputDirectory, "sub", "atomic.txt")
+
+	_, err := c.R().SetOutputFile("sub/atomic.txt").Get("/truncated")
+	tests.AssertNotNil(t, err)
+	_, err = os.Stat(file)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+	_, err = os.Stat(partFilename(file))
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	resp, err := c.R().SetOutputFile("sub/atomic.txt").Get("/resume")
+	assertSuccess(t, resp, err)
+	b, err := os.ReadFile(file)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, resumeContent, string(b))
+	_, err = os.Stat(partFilename(file))
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte(resumeContent))
+	expected := hex.EncodeToString(sum[:])
+	c := tc().SetOutputDirectory(t.TempDir())
+	file := filepath.Join(c.outputDirectory, "checksum.txt")
+
+	resp, err := c.R().SetOutputFile("checksum.txt").SetDownloadChecksum("SHA256", expected).Get("/resume")
+	assertSuccess(t, resp, err)
+	tests.AssertNoError(t, os.Remove(file))
+
+	// resumed download hashes the existing partial content
+	tests.AssertNoError(t, os.WriteFile(partFilename(file), []byte(resumeContent[:10]), 0644))
+	resp, err = c.R().SetOutputFile("checksum.txt").EnableResume().SetDownloadChecksum("sha256", expected).Get("/resume")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, http.StatusPartialContent, resp.StatusCode)
+	tests.AssertNoError(t, os.Remove(file))
+
+	// segmented download
+	resp, err = c.R().SetOutputFile("checksum.txt").EnableSegmentedDownload(3).SetDownloadChecksum("sha256", expected).Get("/resume")
+	assertSuccess(t, resp, err)
+	tests.AssertNoError(t, os.Remove(file))
+
+	md5Sum := md5.Sum([]byte("other"))
+	_, err = c.R().SetOutputFile("checksum.txt").SetDownloadChecksum("md5", hex.EncodeToString(md5Sum[:])).Get("/resume")
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("expected ChecksumError, got %v", err)
+	}
+	tests.AssertEqual(t, "md5", checksumErr.Algorithm)
+	_, err = os.Stat(file)
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+	_, err = os.Stat(partFilename(file))
+	tests.AssertEqual(t, true, os.IsNotExist(err))
+
+	buf := new(bytes.Buffer)
+	resp, err = c.R().SetOutput(buf).SetDownloadChecksum("sha256", expected).Get("/resume")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, resumeContent, buf.String())
+
+	_, err = c.R().SetDownloadChecksum("crc32", "00000000").Get("/")
+	tests.AssertErrorContains(t, err, "unsupported checksum algorithm")
+	_, err = c.R().SetDownloadChecksum("sha256", "abc").Get("/")
+	tests.AssertErrorContains(t, err, "invalid sha256 checksum")
+}
+
+func TestSetOutput(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		buf := new(bytes.Buffer)
+		resp, err := c.R().SetOutput(buf).Get("/gzip")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "TestGet: gzip response", buf.String())
+		tests.AssertEqual(t, 0, len(resp.Bytes()))
+
+		buf.Reset()
+		resp, err = c.R().SetOutput(buf).Get("/gbk")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "我是roc", buf.String())
+		tests.AssertEqual(t, 0, len(resp.Bytes()))
+	})
+}
+
+func TestOperationName(t *testing.T) {
+	resp, err := tc().R().SetPathParam("username", "imroc").Get("/user/{username}/profile?a=b")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "imroc's profile", resp.String())
+	tests.AssertEqual(t, "GET /user/{username}/profile", resp.Request.OperationName())
+
+	resp, err = tc().R().SetLabel("operation", "Home").Get(getTestServerURL())
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "Home", resp.Request.OperationName())
+	resp.Request.labels = nil
+	tests.AssertEqual(t, "GET /", resp.Request.OperationName())
+
+	// the path of the base url is joined.
+	resp, _ = tc().SetBaseURL(getTestServerURL()+"/api/v1").R().SetPathParam("id", "1").Get("users/{id}")
+	tests.AssertEqual(t, "GET /api/v1/users/{id}", resp.Request.OperationName())
+}
+
+func TestAwsSigV4(t *testing.T) {
+	// test vectors from the AWS Signature Version 4 test suite, and the
+	// ones with the sub-delims in the path computed by the AWS SDK signer.
+	now := func() time.Time {
+		return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	}
+	for _, c := range []struct {
+		service   string
+		url       string
+		rawURL    string
+		signature string
+	}{
+		{"service", "https://example.amazonaws.com/", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"service", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"service", "https://example.amazonaws.com/example space/", "https://example.amazonaws.com/example%20space/", "446b817944c553435b35e813c261ff4e161fff982d1bacdef1c87f6785dd1662"},
+		{"service", "https://example.amazonaws.com/ሴ", "https://example.amazonaws.com/%E1%88%B4", "697b34846207a3f72246f99d74ae1ee4fe54f44bb06730c58a0d339eb079596d"},
+		{"service", "https://example.amazonaws.com/-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", "https://example.amazonaws.com/-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", "07ef7494c76fa4850883e2b006601f940f8a34d404d0cfa977f52a65bbf5f24f"},
+		{"service", "https://example.amazonaws.com/a+b/x=y", "https://example.amazonaws.com/a%2Bb/x%3Dy", "02c5f4b2d421ce390852d5bd6dea077d0bf5129f8b64856791ec58e49a2e2818"},
+		{"service", "https://example.amazonaws.com/a:b,c;d", "https://example.amazonaws.com/a%3Ab%2Cc%3Bd", "c767797dc09cc573726800a0ccd81ab6be611fc3a004c3db1d218dc60eaeea24"},
+		{"s3", "https://example.amazonaws.com/example space/", "https://example.amazonaws.com/example%20space/", "4cb8bf8499585972f853e84c89b348898c3db771ff522aa635b803aaaf2fb1a2"},
+		{"s3", "https://example.amazonaws.com/a+b/x=y", "https://example.amazonaws.com/a%2Bb/x%3Dy", "97895c6a3436c9977d76c3eb65ecb4dcfa8ed19fa7b32d4cb07aacb5a9d85aeb"},
+		{"s3", "https://example.amazonaws.com/a:b,c;d", "https://example.amazonaws.com/a%3Ab%2Cc%3Bd", "7b33b813af71e1a7921914d94976fffc3e910f2b2b02107f7ac4a0ec91526fd4"},
+	} {
+		signer := newAwsSigV4Signer("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", c.service)
+		signer.now = now
+		signedHeaders := "host;x-amz-date"
+		if c.service == "s3" {
+			signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+		}
+		req, _ := http.NewRequest(http.MethodGet, c.url, nil)
+		signer.sign(req, hashSHA256(nil))
+		tests.AssertEqual(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/"+c.service+"/aws4_request, "+
+			"SignedHeaders="+signedHeaders+", Signature="+c.signature, req.Header.Get("Authorization"))
+		tests.AssertEqual(t, c.rawURL, req.URL.String())
+	}
+
+	resp, err := tc().SetCommonAwsSigV4("ak", "sk", "us-east-1", "s3").R().SetBody("test").Post("/")
+	assertSuccess(t, resp, err)
+	h := resp.Request.RawRequest.Header
+	tests.AssertContains(t, h.Get("Authorization"), "credential=ak/", true)
+	tests.AssertEqual(t, hashSHA256([]byte("test")), h.Get("X-Amz-Content-Sha256"))
+}
+
+func TestSetLabel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	var errLabel string
+	c := tc().EnableAuditLog(&AuditOptions{Output: buf}).OnError(func(client *Client, req *Request, resp *Response, err error) {
+		errLabel = req.GetLabel("operation")
+	})
+	resp, err := c.R().EnableDump().
+		SetLabel("operation", "CreateOrder").
+		SetLabels(map[string]string{"tenant": "t1"}).
+		Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "CreateOrder", resp.Request.GetLabel("operation"))
+	tests.AssertContains(t, resp.Dump(), "* labels: operation=createorder, tenant=t1", true)
+
+	var record AuditRecord
+	tests.AssertNoError(t, json.Unmarshal(buf.Bytes(), &record))
+	tests.AssertEqual(t, map[string]string{"operation": "CreateOrder", "tenant": "t1"}, record.Labels)
+
+	c.R().SetLabel("operation", "GetOrder").Get("http://127.0.0.1:1")
+	tests.AssertEqual(t, "GetOrder", errLabel)
+}
+
+func TestDigestAuth(t *testing.T) {
+	h := func(algo, s string) string {
+		if algo == "SHA-256" {
+			return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+		}
+		return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+	}
+	newServer := func(algo string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			params := make(map[string]string)
+			for _, p := range splitChallengeParams(strings.TrimPrefix(auth, "Digest ")) {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				params[k] = strings.Trim(v, `"`)
+			}
+			ha1 := h(algo, "roc:test:123456")
+			ha2 := h(algo, r.Method+":"+params["uri"])
+			expected := h(algo, strings.Join([]string{ha1, "abc", params["nc"], params["cnonce"], "auth", ha2}, ":"))
+			if params["response"] != expected || params["qop"] != "auth" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", nonce="abc", qop="auth,auth-int", algorithm=%s`, algo))
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("unauthorized"))
+				return
+			}
+			w.Write([]byte("authorized"))
+		}))
+	}
+	for _, algo := range []string{"MD5", "SHA-256"} {
+		server := newServer(algo)
+		resp, err := C().R().SetDigestAuth("roc", "123456").Get(server.URL + "/digest?a=b")
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "authorized", resp.String())
+
+		resp, err = C().SetCommonDigestAuth("roc", "123456").R().Get(server.URL)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "authorized", resp.String())
+
+		resp, err = C().SetCommonDigestAuth("roc", "wrong").R().Get(server.URL)
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+		server.Close()
+	}
+}
+
+func TestHedging(t *testing.T) {
+	var count int32
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+			case <-time.After(2 * time.Second):
+			}
+			w.Write([]byte("slow"))
+			return
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := C().EnableTraceAll().R().
+		EnableDump().
+		EnableHedging(50*time.Millisecond, 2).
+		Get(server.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "fast", resp.String())
+	tests.AssertEqual(t, true, time.Since(start) < time.Second)
+	tests.AssertEqual(t, int32(2), atomic.LoadInt32(&count))
+	dump := resp.Dump()
+	tests.AssertEqual(t, 1, strings.Count(dump, "GET / HTTP/1.1"))
+	tests.AssertContains(t, dump, "fast", true)
+	tests.AssertEqual(t, true, resp.TraceInfo().TotalTime > 0)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow request is not canceled")
+	}
+}
+
+func TestHedgingContext(t *testing.T) {
+	resp, err := tc().R().
+		EnableForceHTTP1().
+		EnableHedging(time.Millisecond, 1).
+		Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.1", resp.Proto)
+
+	var count int32
+	newProxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&count, 1) == 1 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(2 * time.Second):
+				}
+			}
+			w.Write([]byte(name))
+		}))
+	}
+	p1, p2 := newProxy("p1"), newProxy("p2")
+	defer p1.Close()
+	defer p2.Close()
+	c := C().SetProxyPool([]string{p1.URL, p2.URL}, ProxyPoolRoundRobin)
+	resp, err = c.R().
+		EnableForceHTTP1().
+		EnableHedging(50*time.Millisecond, 1).
+		Get("http://example.com")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "p2", resp.String())
+	stats := c.ProxyPoolStats()
+	tests.AssertEqual(t, int64(0), stats[0].Requests)
+	tests.AssertEqual(t, int64(1), stats[1].Requests)
+}
+
+func TestSegmentedDownload(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		var ranges []string
+		var mu sync.Mutex
+		c.SetOutputDirectory(t.TempDir()).OnBeforeRequest(func(client *Client, req *Request) error {
+			mu.Lock()
+			ranges = append(ranges, req.Headers.Get("Range"))
+			mu.Unlock()
+			return nil
+		})
+		resp, err := c.R().SetOutputFile("segmented.txt").EnableSegmentedDownload(4).Get("/resume")
+		assertSuccess(t, resp, err)
+		b, err := os.ReadFile(filepath.Join(c.outputDirectory, "segmented.txt"))
+		tests.AssertNoError(t, err)
+		tests.AssertEqual(t, resumeContent, string(b))
+		tests.AssertEqual(t, 4, len(ranges))
+	})
+}
+
 func TestDownloadCallback(t *testing.T) {
 	n := 0
 	resp, err := tc().R().
@@ -1023,3 +1538,433 @@ func TestRestoreResponseBody(t *testing.T) {
 	tests.AssertNoError(t, err)
 	tests.AssertEqual(t, true, len(body) > 0)
 }
+
+func TestFetch(t *testing.T) {
+	attempt := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			attempt++
+			if attempt < 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set(header.ContentType, header.JsonContentType)
+			w.Write([]byte(`{"name": "roc"}`))
+		case "/bytes":
+			w.Write([]byte("hello"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}
+	}))
+	defer ts.Close()
+
+	var user struct {
+		Name string `json:"name"`
+	}
+	err := FetchJSON(context.Background(), ts.URL+"/flaky", &user)
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "roc", user.Name)
+	tests.AssertEqual(t, 2, attempt)
+
+	b, err := FetchBytes(context.Background(), ts.URL+"/bytes")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, "hello", string(b))
+
+	_, err = FetchBytes(context.Background(), ts.URL+"/missing")
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected StatusError, got %v", err)
+	}
+	tests.AssertEqual(t, http.StatusNotFound, se.StatusCode)
+	tests.AssertEqual(t, "not found", string(se.Body))
+}
+
+func TestRequestForceHttpVersion(t *testing.T) {
+	c := tc()
+	resp, err := c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+
+	resp, err = c.R().EnableForceHTTP1().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.1", resp.Proto)
+
+	c = tc().EnableForceHTTP1()
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/1.1", resp.Proto)
+
+	resp, err = c.R().EnableForceHTTP2().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+
+	resp, err = c.R().DisableForceHttpVersion().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+
+	_, err = c.R().EnableForceHTTP3().Get("/")
+	tests.AssertErrorContains(t, err, "http3 is not enabled")
+}
+
+func TestStreamHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sse":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(": comment\n\nevent: greeting\nid: 1\ndata: hello\ndata: world\n\ndata: bye\r\n\r\n"))
+		case "/ndjson":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Write([]byte("{\"n\":1}\n\n{\"n\":2}\n{\"n\":3}"))
+		case "/mixed":
+			w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
+			w.Write([]byte("--frame\r\nContent-Type: image/jpeg\r\n\r\nframe1\r\n--frame\r\nContent-Type: image/jpeg\r\n\r\nframe2\r\n--frame--\r\n"))
+		case "/custom":
+			w.Header().Set("Content-Type", "application/x-lines")
+			w.Write([]byte("a|b|c"))
+		}
+	}))
+	defer server.Close()
+
+	var msgs []*StreamMessage
+	handler := func(msg *StreamMessage) error {
+		msgs = append(msgs, msg)
+		return nil
+	}
+	c := tc()
+	resp, err := c.R().SetStreamHandler(handler).Get(server.URL + "/sse")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 2, len(msgs))
+	tests.AssertEqual(t, "greeting", msgs[0].Header.Get("Event"))
+	tests.AssertEqual(t, "1", msgs[0].Header.Get("Id"))
+	tests.AssertEqual(t, "hello\nworld", string(msgs[0].Data))
+	tests.AssertEqual(t, "bye", string(msgs[1].Data))
+	tests.AssertEqual(t, "", resp.String())
+
+	msgs = nil
+	resp, err = c.R().SetStreamHandler(func(msg *StreamMessage) error {
+		msgs = append(msgs, msg)
+		if len(msgs) == 2 {
+			return io.EOF
+		}
+		return nil
+	}).Get(server.URL + "/ndjson")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 2, len(msgs))
+	tests.AssertEqual(t, `{"n":2}`, string(msgs[1].Data))
+
+	msgs = nil
+	resp, err = c.R().SetStreamHandler(handler).Get(server.URL + "/mixed")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 2, len(msgs))
+	tests.AssertEqual(t, "image/jpeg", msgs[1].Header.Get("Content-Type"))
+	tests.AssertEqual(t, "frame2", string(msgs[1].Data))
+
+	// no decoder is registered, read the body as usual.
+	msgs = nil
+	resp, err = c.R().SetStreamHandler(handler).Get(server.URL + "/custom")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 0, len(msgs))
+	tests.AssertEqual(t, "a|b|c", resp.String())
+
+	c.RegisterStreamDecoder("Application/X-Lines", func(resp *Response, handler StreamHandler) error {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		for _, s := range strings.Split(string(body), "|") {
+			if err = handler(&StreamMessage{Data: []byte(s)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	_, err = c.R().SetStreamHandler(func(msg *StreamMessage) error {
+		if string(msg.Data) == "b" {
+			return errors.New("unexpected b")
+		}
+		return nil
+	}).Get(server.URL + "/custom")
+	tests.AssertErrorContains(t, err, "unexpected b")
+}
+
+func TestRequestSetLogger(t *testing.T) {
+	clientBuf, requestBuf := new(bytes.Buffer), new(bytes.Buffer)
+	c := tc().EnableDebugLog().SetLogger(NewLogger(clientBuf, "", 0))
+	resp, err := c.R().SetLogger(NewLogger(requestBuf, "", 0)).SetOutput(nil).Get("/unlimited-redirect")
+	tests.AssertNotNil(t, err)
+	tests.AssertNotNil(t, resp)
+	tests.AssertContains(t, requestBuf.String(), "nil io.writer is not allowed in setoutput", true)
+	tests.AssertContains(t, requestBuf.String(), "http/2 get", true)
+	tests.AssertContains(t, requestBuf.String(), "<redirect> get", true)
+	tests.AssertEqual(t, "", clientBuf.String())
+
+	resp, err = c.R().Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, clientBuf.String(), "http/2 get", true)
+
+	clientBuf.Reset()
+	resp, err = c.R().SetLogger(nil).Get("/")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "", clientBuf.String())
+}
+
+func TestResponseHeaderMapping(t *testing.T) {
+	lastModified := time.Date(2024, 3, 6, 14, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Header().Set("X-RateLimit-Remaining", r.URL.Query().Get("remaining"))
+		w.Header().Add("Link", "</page2>")
+		w.Header().Add("Link", "</page3>")
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"bad"}`))
+			return
+		}
+		w.Write([]byte(`{"name":"req"}`))
+	}))
+	defer server.Close()
+
+	type Meta struct {
+		ETag string `reqHeader:"ETag"`
+	}
+	type Repo struct {
+		Meta
+		Name         string    `json:"name"`
+		LastModified time.Time `reqHeader:"Last-Modified"`
+		Remaining    int       `reqHeader:"X-RateLimit-Remaining"`
+		Links        []string  `reqHeader:"Link"`
+		Missing      string    `reqHeader:"X-Missing"`
+	}
+	var repo Repo
+	resp, err := C().R().SetSuccessResult(&repo).Get(server.URL + "/?remaining=42")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, Repo{
+		Meta:         Meta{ETag: `"v1"`},
+		Name:         "req",
+		LastModified: lastModified,
+		Remaining:    42,
+		Links:        []string{"</page2>", "</page3>"},
+	}, repo)
+
+	type ErrorMessage struct {
+		Message string `json:"message"`
+		ETag    string `reqHeader:"ETag"`
+	}
+	var errMsg ErrorMessage
+	resp, err = C().R().SetErrorResult(&errMsg).Get(server.URL + "/error")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, ErrorMessage{Message: "bad", ETag: `"v1"`}, errMsg)
+
+	_, err = C().R().SetSuccessResult(&repo).Get(server.URL + "/?remaining=many")
+	tests.AssertErrorContains(t, err, "failed to map response header X-RateLimit-Remaining to field Remaining")
+}
+
+func TestPathEscapeOptions(t *testing.T) {
+	c := C().SetBaseURL("http://example.com")
+	r := c.R().SetPathParam("key", "a+b:c%d e")
+	r.RawURL = "/o/{key}"
+	tests.AssertNoError(t, parseRequestURL(c, r))
+	tests.AssertEqual(t, "/o/a+b:c%25d%20e", r.URL.EscapedPath())
+
+	c.SetCommonPathEscapeOptions(&PathEscapeOptions{Escape: "+:"})
+	r = c.R().SetPathParam("key", "dir/a+b:c%d e")
+	r.RawURL = "/o/{key...}"
+	tests.AssertNoError(t, parseRequestURL(c, r))
+	tests.AssertEqual(t, "/o/dir/a%2Bb%3Ac%25d%20e", r.URL.EscapedPath())
+	tests.AssertEqual(t, "/o/dir/a+b:c%d e", r.URL.Path)
+
+	// the request-level options override the client-level ones
+	r = c.R().SetPathParam("key", "a%2Bb@c").SetPathEscapeOptions(&PathEscapeOptions{Preserve: "%@"})
+	r.RawURL = "/o/{key}"
+	tests.AssertNoError(t, parseRequestURL(c, r))
+	tests.AssertEqual(t, "/o/a%2Bb@c", r.URL.EscapedPath())
+}
+
+func TestResponseDecodeMemo(t *testing.T) {
+	var calls int
+	c := tc().SetJsonUnmarshal(func(data []byte, v interface{}) error {
+		calls++
+		return json.Unmarshal(data, v)
+	})
+	type user struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	var result user
+	resp, err := c.R().SetSuccessResult(&result).Get("/search?username=imroc&type=json")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 1, calls)
+
+	var u1, u2 user
+	tests.AssertNoError(t, resp.Unmarshal(&u1))
+	tests.AssertNoError(t, resp.UnmarshalJson(&u2))
+	tests.AssertEqual(t, 1, calls)
+	tests.AssertEqual(t, result, u1)
+	tests.AssertEqual(t, result, u2)
+
+	// the non-zero value is decoded as usual
+	u3 := user{Username: "roc"}
+	tests.AssertNoError(t, resp.Into(&u3))
+	tests.AssertEqual(t, 2, calls)
+	tests.AssertEqual(t, result, u3)
+
+	// the decoded values don't alias each other.
+	var m1, m2 map[string]interface{}
+	tests.AssertNoError(t, resp.Unmarshal(&m1))
+	tests.AssertEqual(t, 3, calls)
+	m1["username"] = "roc"
+	tests.AssertNoError(t, resp.Unmarshal(&m2))
+	tests.AssertEqual(t, 3, calls)
+	tests.AssertEqual(t, "imroc", m2["username"])
+	var p1, p2 *user
+	tests.AssertNoError(t, resp.Unmarshal(&p1))
+	p1.Username = "roc"
+	tests.AssertNoError(t, resp.Unmarshal(&p2))
+	tests.AssertEqual(t, 3, calls)
+	tests.AssertEqual(t, "roc", p1.Username)
+	tests.AssertEqual(t, "imroc", p2.Username)
+
+	s1, err := resp.ToString()
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, resp.String(), s1)
+}
+
+func TestSetResponseCharset(t *testing.T) {
+	c := tc().DisableAutoDecode()
+	resp, err := c.R().SetResponseCharset("gbk").Get("/gbk")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "我是roc", resp.String())
+
+	// the charset in Content-Type is ignored
+	resp, err = tc().R().SetResponseCharset("utf-8").Get("/gbk")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, toGbk("我是roc"), resp.Bytes())
+
+	_, err = c.R().SetResponseCharset("no-such-charset").Get("/gbk")
+	tests.AssertErrorContains(t, err, "unsupported charset")
+}
+
+func TestDumpRedactHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	for _, c := range []*Client{C(), tc().EnableForceHTTP2()} {
+		url := ts.URL
+		if c.BaseURL != "" {
+			url = "/"
+		}
+		resp, err := c.R().EnableDump().
+			SetBearerAuthToken("secret-token").
+			SetHeader("X-Api-Key", "secret-key").
+			SetCookies(&http.Cookie{Name: "session", Value: "secret-cookie"}).
+			Get(url)
+		assertSuccess(t, resp, err)
+		dump := resp.Dump()
+		tests.AssertContains(t, dump, "secret-token", false)
+		tests.AssertContains(t, dump, "secret-cookie", false)
+		tests.AssertContains(t, dump, "authorization: redacted", true)
+		tests.AssertContains(t, dump, "cookie: redacted", true)
+		tests.AssertContains(t, dump, "secret-key", true)
+	}
+
+	resp, err := C().R().SetDumpOptions(&DumpOptions{
+		RequestHeader:  true,
+		ResponseHeader: true,
+		RedactHeaders:  []string{"x-api-key"},
+	}).EnableDump().SetHeader("X-Api-Key", "secret-key").SetBearerAuthToken("secret-token").Get(ts.URL)
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, resp.Dump(), "x-api-key: redacted\r\n", true)
+	tests.AssertContains(t, resp.Dump(), "bearer secret-token", true)
+	tests.AssertContains(t, resp.Dump(), "set-cookie: session=secret-session", true)
+}
+
+func TestEnableDumpToFileClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dump.log")
+	r := tc().R().EnableDumpToFile(filename)
+	resp, err := r.Get("/")
+	assertSuccess(t, resp, err)
+	content, err := os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, string(content), "testget: text response", true)
+
+	// the file is closed, and is reopened if the request is sent again.
+	tests.AssertNoError(t, os.Remove(filename))
+	resp, err = r.Get("/")
+	assertSuccess(t, resp, err)
+	content, err = os.ReadFile(filename)
+	tests.AssertNoError(t, err)
+	tests.AssertContains(t, string(content), "testget: text response", true)
+
+	// the file is closed with the response body if it's not read yet.
+	r = tc().R().DisableAutoReadResponse().EnableDumpToFile(filename)
+	resp, err = r.Get("/")
+	tests.AssertNoError(t, err)
+	_, ok := resp.Body.(*closeFileBody)
+	tests.AssertEqual(t, true, ok)
+	tests.AssertNoError(t, resp.Body.Close())
+}
+
+func TestDumpBodyLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(bytes.Repeat([]byte{0xff}, 1300*1024))
+		default:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"name":"` + strings.Repeat("a", 2048) + `"}`))
+		}
+	}))
+	defer ts.Close()
+	for _, c := range []*Client{C().SetBaseURL(ts.URL), tc().EnableForceHTTP2()} {
+		resp, err := c.R().SetDumpOptions(&DumpOptions{
+			RequestBody: true,
+			MaxBodySize: 16,
+		}).EnableDump().SetBody(strings.Repeat("b", 100)).SetContentType("text/plain").Post("/")
+		assertSuccess(t, resp, err)
+		tests.AssertContains(t, resp.Dump(), strings.Repeat("b", 16)+"\r\n[100b text/plain truncated to 16b]", true)
+		tests.AssertContains(t, resp.Dump(), strings.Repeat("b", 17), false)
+
+		resp, err = c.R().SetDumpOptions(&DumpOptions{
+			RequestBody:    true,
+			OmitBinaryBody: true,
+		}).EnableDump().SetBody(bytes.Repeat([]byte{0xff}, 2048)).SetContentType("image/png").Post("/")
+		assertSuccess(t, resp, err)
+		tests.AssertContains(t, resp.Dump(), "[2kb image/png omitted]", true)
+	}
+
+	resp, err := C().R().SetDumpOptions(&DumpOptions{
+		ResponseBody: true,
+		MaxBodySize:  16,
+	}).EnableDump().Get(ts.URL + "/json")
+	assertSuccess(t, resp, err)
+	tests.AssertContains(t, resp.Dump(), `{"name":"aaaaaaa`+"\r\n[application/json truncated to 16b]", true)
+
+	resp, err = C().R().SetDumpOptions(&DumpOptions{
+		ResponseHeader:       true,
+		ResponseBody:         true,
+		OmitBodyContentTypes: []string{"octet-stream"},
+	}).EnableDump().Get(ts.URL + "/binary")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, 1300*1024, len(resp.Bytes()))
+	tests.AssertContains(t, resp.Dump(), "\r\n\r\n[1.3mb application/octet-stream omitted]\r\n", true)
+}
+
+func TestDumpUnknownLengthBody(t *testing.T) {
+	var buf bytes.Buffer
+	d := newDumper(&DumpOptions{Output: &buf, RequestBody: true, OmitBinaryBody: true})
+	tw := &transferWriter{
+		Method:        http.MethodPost,
+		Body:          bytes.NewReader(bytes.Repeat([]byte{0xff}, 2048)),
+		ContentLength: -1,
+		Header:        http.Header{"Content-Type": {"image/png"}},
+	}
+	tests.AssertNoError(t, tw.writeBody(io.Discard, []*dump.Dumper{d}))
+	tests.AssertEqual(t, "[2KB image/png omitted]\r\n", buf.String())
+}
@@ -11,67 +11,73 @@ import (
 // SetURL is a global wrapper methods which delegated
 // to the default client, create a request and SetURL for request.
 func SetURL(url string) *Request {
-	return defaultClient.R().SetURL(url)
+	return DefaultClient().R().SetURL(url)
 }
 
 // SetFormDataFromValues is a global wrapper methods which delegated
 // to the default client, create a request and SetFormDataFromValues for request.
 func SetFormDataFromValues(data url.Values) *Request {
-	return defaultClient.R().SetFormDataFromValues(data)
+	return DefaultClient().R().SetFormDataFromValues(data)
 }
 
 // SetFormData is a global wrapper methods which delegated
 // to the default client, create a request and SetFormData for request.
 func SetFormData(data map[string]string) *Request {
-	return defaultClient.R().SetFormData(data)
+	return DefaultClient().R().SetFormData(data)
 }
 
 // SetFormDataAnyType is a global wrapper methods which delegated
 // to the default client, create a request and SetFormDataAnyType for request.
 func SetFormDataAnyType(data map[string]interface{}) *Request {
-	return defaultClient.R().SetFormDataAnyType(data)
+	return DefaultClient().R().SetFormDataAnyType(data)
+}
+
+// SetFormDataAny is a global wrapper methods which delegated
+// to the default client, create a request and SetFormDataAny for request.
+func SetFormDataAny(key string, value interface{}) *Request {
+	return DefaultClient().R().SetFormDataAny(key, value)
 }
 
 // SetCookies is a global wrapper methods which delegated
 // to the default client, create a request and SetCookies for request.
 func SetCookies(cookies ...*http.Cookie) *Request {
-	return defaultClient.R().SetCookies(cookies...)
+	return DefaultClient().R().SetCookies(cookies...)
 }
 
 // SetQueryString is a global wrapper methods which delegated
 // to the default client, create a request and SetQueryString for request.
 func SetQueryString(query string) *Request {
-	return defaultClient.R().SetQueryString(query)
+	return DefaultClient().R().SetQueryString(query)
 }
 
 // SetFileReader is a global wrapper methods which delegated
 // to the default client, create a request and SetFileReader for request.
 func SetFileReader(paramName, filePath string, reader io.Reader) *Request {
-	return defaultClient.R().SetFileReader(paramName, filePath, reader)
+	return DefaultClient().R().SetFileReader(paramName, filePath, reader)
 }
 
 // SetFileBytes is a global wrapper methods which delegated
 // to the default client, create a request and SetFileBytes for request.
 func SetFileBytes(paramName, filename string, content []byte) *Request {
-	return defaultClient.R().SetFileBytes(paramName, filename, content)
+	return DefaultClient().R().SetFileBytes(paramName, filename, content)
 }
 
 // SetFiles is a global wrapper methods which delegated
 // to the default client, create a request and SetFiles for request.
 func SetFiles(files map[string]string) *Request {
-	return defaultClient.R().SetFiles(files)
+	return DefaultClient().R().SetFiles(files)
 }
 
 // SetFile is a global wrapper methods which delegated
 // to the default client, create a request and SetFile for request.
 func SetFile(paramName, filePath string) *Request {
-	return defaultClient.R().SetFile(paramName, filePath)
+	return DefaultClient().R().SetFile(paramName, filePath)
 }
 
 // SetFileUpload is a global wrapper methods which delegated
 // to the default client, create a request and SetFileUpload for request.
 func SetFileUpload(f ...FileUpload) *Request {
-	return defaultClient.R().SetFileUpload(f...)
+	return DefaultClient().R().SetFileUpload(f...)
 }
 
 // SetResult is a global wrapper methods which delegated
@@ -79,13 +85,13 @@ func SetFileUpload(f ...FileUpload) *Request {
 //
 // Deprecated: Use SetSuccessResult instead.
 func SetResult(result interface{}) *Request {
-	return defaultClient.R().SetSuccessResult(result)
+	return DefaultClient().R().SetSuccessResult(result)
 }
 
 // SetSuccessResult is a global wrapper methods which delegated
 // to the default client, create a request and SetSuccessResult for request.
 func SetSuccessResult(result interface{}) *Request {
-	return defaultClient.R().SetSuccessResult(result)
+	return DefaultClient().R().SetSuccessResult(result)
 }
 
 // SetError is a global wrapper methods which delegated
@@ -93,431 +99,563 @@ func SetSuccessResult(result interface{}) *Request {
 //
 // Deprecated: Use SetErrorResult instead.
 func SetError(error interface{}) *Request {
-	return defaultClient.R().SetErrorResult(error)
+	return DefaultClient().R().SetErrorResult(error)
 }
 
 // SetErrorResult is a global wrapper methods which delegated
 // to the default client, create a request and SetErrorResult for request.
 func SetErrorResult(error interface{}) *Request {
-	return defaultClient.R().SetErrorResult(error)
+	return DefaultClient().R().SetErrorResult(error)
 }
 
 // SetBearerAuthToken is a global wrapper methods which delegated
 // to the default client, create a request and SetBearerAuthToken for request.
 func SetBearerAuthToken(token string) *Request {
-	return defaultClient.R().SetBearerAuthToken(token)
+	return DefaultClient().R().SetBearerAuthToken(token)
 }
 
 // SetBasicAuth is a global wrapper methods which delegated
 // to the default client, create a request and SetBasicAuth for request.
 func SetBasicAuth(username, password string) *Request {
-	return defaultClient.R().SetBasicAuth(username, password)
+	return DefaultClient().R().SetBasicAuth(username, password)
 }
 
 // SetDigestAuth is a global wrapper methods which delegated
 // to the default client, create a request and SetDigestAuth for request.
 func SetDigestAuth(username, password string) *Request {
-	return defaultClient.R().SetDigestAuth(username, password)
+	return DefaultClient().R().SetDigestAuth(username, password)
 }
 
 // SetHeaders is a global wrapper methods which delegated
 // to the default client, create a request and SetHeaders for request.
 func SetHeaders(hdrs map[string]string) *Request {
-	return defaultClient.R().SetHeaders(hdrs)
+	return DefaultClient().R().SetHeaders(hdrs)
 }
 
 // SetHeader is a global wrapper methods which delegated
 // to the default client, create a request and SetHeader for request.
 func SetHeader(key, value string) *Request {
-	return defaultClient.R().SetHeader(key, value)
+	return DefaultClient().R().SetHeader(key, value)
 }
 
 // SetHeaderOrder is a global wrapper methods which delegated
 // to the default client, create a request and SetHeaderOrder for request.
 func SetHeaderOrder(keys ...string) *Request {
-	return defaultClient.R().SetHeaderOrder(keys...)
+	return DefaultClient().R().SetHeaderOrder(keys...)
 }
 
 // SetPseudoHeaderOrder is a global wrapper methods which delegated
 // to the default client, create a request and SetPseudoHeaderOrder for request.
 func SetPseudoHeaderOrder(keys ...string) *Request {
-	return defaultClient.R().SetPseudoHeaderOrder(keys...)
+	return DefaultClient().R().SetPseudoHeaderOrder(keys...)
 }
 
 // SetOutputFile is a global wrapper methods which delegated
 // to the default client, create a request and SetOutputFile for request.
 func SetOutputFile(file string) *Request {
-	return defaultClient.R().SetOutputFile(file)
+	return DefaultClient().R().SetOutputFile(file)
+}
+
+// SetOutputFileFromURL is a global wrapper methods which delegated
+// to the default client, create a request and SetOutputFileFromURL for request.
+func SetOutputFileFromURL() *Request {
+	return DefaultClient().R().SetOutputFileFromURL()
+}
+
+// EnableResume is a global wrapper methods which delegated
+// to the default client, create a request and EnableResume for request.
+func EnableResume() *Request {
+	return DefaultClient().R().EnableResume()
+}
+
+// EnableSegmentedDownload is a global wrapper methods which delegated
+// to the default client, create a request and EnableSegmentedDownload for request.
+func EnableSegmentedDownload(segments int) *Request {
+	return DefaultClient().R().EnableSegmentedDownload(segments)
+}
+
+// SetDownloadChecksum is a global wrapper methods which delegated
+// to the default client, create a request and SetDownloadChecksum for request.
+func SetDownloadChecksum(algo, expectedHex string) *Request {
+	return DefaultClient().R().SetDownloadChecksum(algo, expectedHex)
+}
+
+// SetStreamHandler is a global wrapper methods which delegated
+// to the default client, create a request and SetStreamHandler for request.
+func SetStreamHandler(handler StreamHandler) *Request {
+	return DefaultClient().R().SetStreamHandler(handler)
 }
 
 // SetOutput is a global wrapper methods which delegated
 // to the default client, create a request and SetOutput for request.
 func SetOutput(output io.Writer) *Request {
-	return defaultClient.R().SetOutput(output)
+	return DefaultClient().R().SetOutput(output)
+}
+
+// AddOutputWriter is a global wrapper methods which delegated
+// to the default client, create a request and AddOutputWriter for request.
+func AddOutputWriter(w io.Writer) *Request {
+	return DefaultClient().R().AddOutputWriter(w)
 }
 
 // SetQueryParams is a global wrapper methods which delegated
 // to the default client, create a request and SetQueryParams for request.
 func SetQueryParams(params map[string]string) *Request {
-	return defaultClient.R().SetQueryParams(params)
+	return DefaultClient().R().SetQueryParams(params)
 }
 
 // SetQueryParamsAnyType is a global wrapper methods which delegated
 // to the default client, create a request and SetQueryParamsAnyType for request.
 func SetQueryParamsAnyType(params map[string]interface{}) *Request {
-	return defaultClient.R().SetQueryParamsAnyType(params)
+	return DefaultClient().R().SetQueryParamsAnyType(params)
+}
+
+// SetQueryParamAny is a global wrapper methods which delegated
+// to the default client, create a request and SetQueryParamAny for request.
+func SetQueryParamAny(key string, value interface{}) *Request {
+	return DefaultClient().R().SetQueryParamAny(key, value)
+}
+
+// AddQueryParamAny is a global wrapper methods which delegated
+// to the default client, create a request and AddQueryParamAny for request.
+func AddQueryParamAny(key string, value interface{}) *Request {
+	return DefaultClient().R().AddQueryParamAny(key, value)
 }
 
 // SetQueryParam is a global wrapper methods which delegated
 // to the default client, create a request and SetQueryParam for request.
 func SetQueryParam(key, value string) *Request {
-	return defaultClient.R().SetQueryParam(key, value)
+	return DefaultClient().R().SetQueryParam(key, value)
 }
 
 // AddQueryParam is a global wrapper methods which delegated
 // to the default client, create a request and AddQueryParam for request.
 func AddQueryParam(key, value string) *Request {
-	return defaultClient.R().AddQueryParam(key, value)
+	return DefaultClient().R().AddQueryParam(key, value)
 }
 
 // AddQueryParams is a global wrapper methods which delegated
 // to the default client, create a request and AddQueryParams for request.
 func AddQueryParams(key string, values ...string) *Request {
-	return defaultClient.R().AddQueryParams(key, values...)
+	return DefaultClient().R().AddQueryParams(key, values...)
 }
 
 // SetPathParams is a global wrapper methods which delegated
 // to the default client, create a request and SetPathParams for request.
 func SetPathParams(params map[string]string) *Request {
-	return defaultClient.R().SetPathParams(params)
+	return DefaultClient().R().SetPathParams(params)
 }
 
 // SetPathParam is a global wrapper methods which delegated
 // to the default client, create a request and SetPathParam for request.
 func SetPathParam(key, value string) *Request {
-	return defaultClient.R().SetPathParam(key, value)
+	return DefaultClient().R().SetPathParam(key, value)
+}
+
+// SetPathParamValues is a global wrapper methods which delegated
+// to the default client, create a request and SetPathParamValues for request.
+func SetPathParamValues(key string, values ...string) *Request {
+	return DefaultClient().R().SetPathParamValues(key, values...)
+}
+
+// SetPathEscapeOptions is a global wrapper methods which delegated
+// to the default client, create a request and SetPathEscapeOptions for request.
+func SetPathEscapeOptions(opts *PathEscapeOptions) *Request {
+	return DefaultClient().R().SetPathEscapeOptions(opts)
 }
 
 // MustGet is a global wrapper methods which delegated
 // to the default client, create a request and MustGet for request.
 func MustGet(url string) *Response {
-	return defaultClient.R().MustGet(url)
+	return DefaultClient().R().MustGet(url)
 }
 
 // Get is a global wrapper methods which delegated
 // to the default client, create a request and Get for request.
 func Get(url string) (*Response, error) {
-	return defaultClient.R().Get(url)
+	return DefaultClient().R().Get(url)
 }
 
 // MustPost is a global wrapper methods which delegated
 // to the default client, create a request and Get for request.
 func MustPost(url string) *Response {
-	return defaultClient.R().MustPost(url)
+	return DefaultClient().R().MustPost(url)
 }
 
 // Post is a global wrapper methods which delegated
 // to the default client, create a request and Post for request.
 func Post(url string) (*Response, error) {
-	return defaultClient.R().Post(url)
+	return DefaultClient().R().Post(url)
 }
 
 // MustPut is a global wrapper methods which delegated
 // to the default client, create a request and MustPut for request.
 func MustPut(url string) *Response {
-	return defaultClient.R().MustPut(url)
+	return DefaultClient().R().MustPut(url)
 }
 
 // Put is a global wrapper methods which delegated
 // to the default client, create a request and Put for request.
 func Put(url string) (*Response, error) {
-	return defaultClient.R().Put(url)
+	return DefaultClient().R().Put(url)
 }
 
 // MustPatch is a global wrapper methods which delegated
 // to the default client, create a request and MustPatch for request.
 func MustPatch(url string) *Response {
-	return defaultClient.R().MustPatch(url)
+	return DefaultClient().R().MustPatch(url)
 }
 
 // Patch is a global wrapper methods which delegated
 // to the default client, create a request and Patch for request.
 func Patch(url string) (*Response, error) {
-	return defaultClient.R().Patch(url)
+	return DefaultClient().R().Patch(url)
 }
 
 // MustDelete is a global wrapper methods which delegated
 // to the default client, create a request and MustDelete for request.
 func MustDelete(url string) *Response {
-	return defaultClient.R().MustDelete(url)
+	return DefaultClient().R().MustDelete(url)
 }
 
 // Delete is a global wrapper methods which delegated
 // to the default client, create a request and Delete for request.
 func Delete(url string) (*Response, error) {
-	return defaultClient.R().Delete(url)
+	return DefaultClient().R().Delete(url)
 }
 
 // MustOptions is a global wrapper methods which delegated
 // to the default client, create a request and MustOptions for request.
 func MustOptions(url string) *Response {
-	return defaultClient.R().MustOptions(url)
+	return DefaultClient().R().MustOptions(url)
 }
 
 // Options is a global wrapper methods which delegated
 // to the default client, create a request and Options for request.
 func Options(url string) (*Response, error) {
-	return defaultClient.R().Options(url)
+	return DefaultClient().R().Options(url)
 }
 
 // MustHead is a global wrapper methods which delegated
 // to the default client, create a request and MustHead for request.
 func MustHead(url string) *Response {
-	return defaultClient.R().MustHead(url)
+	return DefaultClient().R().MustHead(url)
 }
 
 // Head is a global wrapper methods which delegated
 // to the default client, create a request and Head for request.
 func Head(url string) (*Response, error) {
-	return defaultClient.R().Head(url)
+	return DefaultClient().R().Head(url)
 }
 
 // SetBody is a global wrapper methods which delegated
 // to the default client, create a request and SetBody for request.
 func SetBody(body interface{}) *Request {
-	return defaultClient.R().SetBody(body)
+	return DefaultClient().R().SetBody(body)
 }
 
 // SetBodyBytes is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyBytes for request.
 func SetBodyBytes(body []byte) *Request {
-	return defaultClient.R().SetBodyBytes(body)
+	return DefaultClient().R().SetBodyBytes(body)
 }
 
 // SetBodyString is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyString for request.
 func SetBodyString(body string) *Request {
-	return defaultClient.R().SetBodyString(body)
+	return DefaultClient().R().SetBodyString(body)
 }
 
 // SetBodyJsonString is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyJsonString for request.
 func SetBodyJsonString(body string) *Request {
-	return defaultClient.R().SetBodyJsonString(body)
+	return DefaultClient().R().SetBodyJsonString(body)
 }
 
 // SetBodyJsonBytes is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyJsonBytes for request.
 func SetBodyJsonBytes(body []byte) *Request {
-	return defaultClient.R().SetBodyJsonBytes(body)
+	return DefaultClient().R().SetBodyJsonBytes(body)
 }
 
 // SetBodyJsonMarshal is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyJsonMarshal for request.
 func SetBodyJsonMarshal(v interface{}) *Request {
-	return defaultClient.R().SetBodyJsonMarshal(v)
+	return DefaultClient().R().SetBodyJsonMarshal(v)
+}
+
+// SetBodyJsonStream is a global wrapper methods which delegated
+// to the default client, create a request and SetBodyJsonStream for request.
+func SetBodyJsonStream(v interface{}) *Request {
+	return DefaultClient().R().SetBodyJsonStream(v)
 }
 
 // SetBodyXmlString is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyXmlString for request.
 func SetBodyXmlString(body string) *Request {
-	return defaultClient.R().SetBodyXmlString(body)
+	return DefaultClient().R().SetBodyXmlString(body)
 }
 
 // SetBodyXmlBytes is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyXmlBytes for request.
 func SetBodyXmlBytes(body []byte) *Request {
-	return defaultClient.R().SetBodyXmlBytes(body)
+	return DefaultClient().R().SetBodyXmlBytes(body)
 }
 
 // SetBodyXmlMarshal is a global wrapper methods which delegated
 // to the default client, create a request and SetBodyXmlMarshal for request.
 func SetBodyXmlMarshal(v interface{}) *Request {
-	return defaultClient.R().SetBodyXmlMarshal(v)
+	return DefaultClient().R().SetBodyXmlMarshal(v)
 }
 
 // SetContentType is a global wrapper methods which delegated
 // to the default client, create a request and SetContentType for request.
 func SetContentType(contentType string) *Request {
-	return defaultClient.R().SetContentType(contentType)
+	return DefaultClient().R().SetContentType(contentType)
 }
 
 // SetContext is a global wrapper methods which delegated
 // to the default client, create a request and SetContext for request.
 func SetContext(ctx context.Context) *Request {
-	return defaultClient.R().SetContext(ctx)
+	return DefaultClient().R().SetContext(ctx)
 }
 
 // DisableTrace is a global wrapper methods which delegated
 // to the default client, create a request and DisableTrace for request.
 func DisableTrace() *Request {
-	return defaultClient.R().DisableTrace()
+	return DefaultClient().R().DisableTrace()
 }
 
 // EnableTrace is a global wrapper methods which delegated
 // to the default client, create a request and EnableTrace for request.
 func EnableTrace() *Request {
-	return defaultClient.R().EnableTrace()
+	return DefaultClient().R().EnableTrace()
 }
 
 // EnableForceChunkedEncoding is a global wrapper methods which delegated
 // to the default client, create a request and EnableForceChunkedEncoding for request.
 func EnableForceChunkedEncoding() *Request {
-	return defaultClient.R().EnableForceChunkedEncoding()
+	return DefaultClient().R().EnableForceChunkedEncoding()
 }
 
 // DisableForceChunkedEncoding is a global wrapper methods which delegated
 // to the default client, create a request and DisableForceChunkedEncoding for request.
 func DisableForceChunkedEncoding() *Request {
-	return defaultClient.R().DisableForceChunkedEncoding()
+	return DefaultClient().R().DisableForceChunkedEncoding()
 }
 
 // EnableForceMultipart is a global wrapper methods which delegated
 // to the default client, create a request and EnableForceMultipart for request.
 func EnableForceMultipart() *Request {
-	return defaultClient.R().EnableForceMultipart()
+	return DefaultClient().R().EnableForceMultipart()
 }
 
 // DisableForceMultipart is a global wrapper methods which delegated
 // to the default client, create a request and DisableForceMultipart for request.
 func DisableForceMultipart() *Request {
-	return defaultClient.R().DisableForceMultipart()
+	return DefaultClient().R().DisableForceMultipart()
 }
 
 // EnableDumpTo is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpTo for request.
 func EnableDumpTo(output io.Writer) *Request {
-	return defaultClient.R().EnableDumpTo(output)
+	return DefaultClient().R().EnableDumpTo(output)
 }
 
 // EnableDumpToFile is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpToFile for request.
 func EnableDumpToFile(filename string) *Request {
-	return defaultClient.R().EnableDumpToFile(filename)
+	return DefaultClient().R().EnableDumpToFile(filename)
 }
 
 // SetDumpOptions is a global wrapper methods which delegated
 // to the default client, create a request and SetDumpOptions for request.
 func SetDumpOptions(opt *DumpOptions) *Request {
-	return defaultClient.R().SetDumpOptions(opt)
+	return DefaultClient().R().SetDumpOptions(opt)
 }
 
 // EnableDump is a global wrapper methods which delegated
 // to the default client, create a request and EnableDump for request.
 func EnableDump() *Request {
-	return defaultClient.R().EnableDump()
+	return DefaultClient().R().EnableDump()
+}
+
+// SetResponseCharset is a global wrapper methods which delegated
+// to the default client, create a request and SetResponseCharset for request.
+func SetResponseCharset(charset string) *Request {
+	return DefaultClient().R().SetResponseCharset(charset)
+}
+
+// EnableDumpOnError is a global wrapper methods which delegated
+// to the default client, create a request and EnableDumpOnError for request.
+func EnableDumpOnError() *Request {
+	return DefaultClient().R().EnableDumpOnError()
 }
 
 // EnableDumpWithoutBody is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpWithoutBody for request.
 func EnableDumpWithoutBody() *Request {
-	return defaultClient.R().EnableDumpWithoutBody()
+	return DefaultClient().R().EnableDumpWithoutBody()
 }
 
 // EnableDumpWithoutHeader is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpWithoutHeader for request.
 func EnableDumpWithoutHeader() *Request {
-	return defaultClient.R().EnableDumpWithoutHeader()
+	return DefaultClient().R().EnableDumpWithoutHeader()
 }
 
 // EnableDumpWithoutResponse is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpWithoutResponse for request.
 func EnableDumpWithoutResponse() *Request {
-	return defaultClient.R().EnableDumpWithoutResponse()
+	return DefaultClient().R().EnableDumpWithoutResponse()
 }
 
 // EnableDumpWithoutRequest is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpWithoutRequest for request.
 func EnableDumpWithoutRequest() *Request {
-	return defaultClient.R().EnableDumpWithoutRequest()
+	return DefaultClient().R().EnableDumpWithoutRequest()
 }
 
 // EnableDumpWithoutRequestBody is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpWithoutRequestBody for request.
 func EnableDumpWithoutRequestBody() *Request {
-	return defaultClient.R().EnableDumpWithoutRequestBody()
+	return DefaultClient().R().EnableDumpWithoutRequestBody()
 }
 
 // EnableDumpWithoutResponseBody is a global wrapper methods which delegated
 // to the default client, create a request and EnableDumpWithoutResponseBody for request.
 func EnableDumpWithoutResponseBody() *Request {
-	return defaultClient.R().EnableDumpWithoutResponseBody()
+	return DefaultClient().R().EnableDumpWithoutResponseBody()
 }
 
 // SetRetryCount is a global wrapper methods which delegated
 // to the default client, create a request and SetRetryCount for request.
 func SetRetryCount(count int) *Request {
-	return defaultClient.R().SetRetryCount(count)
+	return DefaultClient().R().SetRetryCount(count)
 }
 
 // SetRetryInterval is a global wrapper methods which delegated
 // to the default client, create a request and SetRetryInterval for request.
 func SetRetryInterval(getRetryIntervalFunc GetRetryIntervalFunc) *Request {
-	return defaultClient.R().SetRetryInterval(getRetryIntervalFunc)
+	return DefaultClient().R().SetRetryInterval(getRetryIntervalFunc)
 }
 
 // SetRetryFixedInterval is a global wrapper methods which delegated
 // to the default client, create a request and SetRetryFixedInterval for request.
 func SetRetryFixedInterval(interval time.Duration) *Request {
-	return defaultClient.R().SetRetryFixedInterval(interval)
+	return DefaultClient().R().SetRetryFixedInterval(interval)
 }
 
 // SetRetryBackoffInterval is a global wrapper methods which delegated
 // to the default client, create a request and SetRetryBackoffInterval for request.
 func SetRetryBackoffInterval(min, max time.Duration) *Request {
-	return defaultClient.R().SetRetryBackoffInterval(min, max)
+	return DefaultClient().R().SetRetryBackoffInterval(min, max)
 }
 
 // SetRetryHook is a global wrapper methods which delegated
 // to the default client, create a request and SetRetryHook for request.
 func SetRetryHook(hook RetryHookFunc) *Request {
-	return defaultClient.R().SetRetryHook(hook)
+	return DefaultClient().R().SetRetryHook(hook)
 }
 
 // AddRetryHook is a global wrapper methods which delegated
 // to the default client, create a request and AddRetryHook for request.
 func AddRetryHook(hook RetryHookFunc) *Request {
-	return defaultClient.R().AddRetryHook(hook)
+	return DefaultClient().R().AddRetryHook(hook)
 }
 
 // SetRetryCondition is a global wrapper methods which delegated
 // to the default client, create a request and SetRetryCondition for request.
 func SetRetryCondition(condition RetryConditionFunc) *Request {
-	return defaultClient.R().SetRetryCondition(condition)
+	return DefaultClient().R().SetRetryCondition(condition)
 }
 
 // AddRetryCondition is a global wrapper methods which delegated
 // to the default client, create a request and AddRetryCondition for request.
 func AddRetryCondition(condition RetryConditionFunc) *Request {
-	return defaultClient.R().AddRetryCondition(condition)
+	return DefaultClient().R().AddRetryCondition(condition)
+}
+
+// SetRetryPolicy is a global wrapper methods which delegated
+// to the default client, create a request and SetRetryPolicy for request.
+func SetRetryPolicy(policy string) *Request {
+	return DefaultClient().R().SetRetryPolicy(policy)
+}
+
+// SetRetryBudget is a global wrapper methods which delegated
+// to the default client, create a request and SetRetryBudget for request.
+func SetRetryBudget(budget RetryBudget) *Request {
+	return DefaultClient().R().SetRetryBudget(budget)
+}
+
+// SetRetryPriority is a global wrapper methods which delegated
+// to the default client, create a request and SetRetryPriority for request.
+func SetRetryPriority(priority RetryPriority) *Request {
+	return DefaultClient().R().SetRetryPriority(priority)
+}
+
+// EnableHedging is a global wrapper methods which delegated
+// to the default client, create a request and EnableHedging for request.
+func EnableHedging(delay time.Duration, maxExtra int) *Request {
+	return DefaultClient().R().EnableHedging(delay, maxExtra)
+}
+
+// DisableHedging is a global wrapper methods which delegated
+// to the default client, create a request and DisableHedging for request.
+func DisableHedging() *Request {
+	return DefaultClient().R().DisableHedging()
+}
+
+// SetTokenProvider is a global wrapper methods which delegated
+// to the default client, create a request and SetTokenProvider for request.
+func SetTokenProvider(provider TokenProvider) *Request {
+	return DefaultClient().R().SetTokenProvider(provider)
+}
+
+// SetLabel is a global wrapper methods which delegated
+// to the default client, create a request and SetLabel for request.
+func SetLabel(key, value string) *Request {
+	return DefaultClient().R().SetLabel(key, value)
+}
+
+// SetLabels is a global wrapper methods which delegated
+// to the default client, create a request and SetLabels for request.
+func SetLabels(labels map[string]string) *Request {
+	return DefaultClient().R().SetLabels(labels)
+}
+
+// SetAwsSigV4 is a global wrapper methods which delegated
+// to the default client, create a request and SetAwsSigV4 for request.
+func SetAwsSigV4(accessKey, secretKey, region, service string) *Request {
+	return DefaultClient().R().SetAwsSigV4(accessKey, secretKey, region, service)
 }
 
 // SetUploadCallback is a global wrapper methods which delegated
 // to the default client, create a request and SetUploadCallback for request.
 func SetUploadCallback(callback UploadCallback) *Request {
-	return defaultClient.R().SetUploadCallback(callback)
+	return DefaultClient().R().SetUploadCallback(callback)
 }
 
 // SetUploadCallbackWithInterval is a global wrapper methods which delegated
 // to the default client, create a request and SetUploadCallbackWithInterval for request.
 func SetUploadCallbackWithInterval(callback UploadCallback, minInterval time.Duration) *Request {
-	return defaultClient.R().SetUploadCallbackWithInterval(callback, minInterval)
+	return DefaultClient().R().SetUploadCallbackWithInterval(callback, minInterval)
 }
 
 // SetDownloadCallback is a global wrapper methods which delegated
 // to the default client, create a request and SetDownloadCallback for request.
 func SetDownloadCallback(callback DownloadCallback) *Request {
-	return defaultClient.R().SetDownloadCallback(callback)
+	return DefaultClient().R().SetDownloadCallback(callback)
 }
 
 // SetDownloadCallbackWithInterval is a global wrapper methods which delegated
 // to the default client, create a request and SetDownloadCallbackWithInterval for request.
 func SetDownloadCallbackWithInterval(callback DownloadCallback, minInterval time.Duration) *Request {
-	return defaultClient.R().SetDownloadCallbackWithInterval(callback, minInterval)
+	return DefaultClient().R().SetDownloadCallbackWithInterval(callback, minInterval)
 }
 
 // EnableCloseConnection is a global wrapper methods which delegated
 // to the default client, create a request and EnableCloseConnection for request.
 func EnableCloseConnection() *Request {
-	return defaultClient.R().EnableCloseConnection()
+	return DefaultClient().R().EnableCloseConnection()
 }
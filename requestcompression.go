@@ -0,0 +1,217 @@
+package req
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	defaultRequestCompressionMinSize   = 1024
+	defaultRequestCompressionRejectTTL = time.Hour
+	defaultRequestCompressionEncoding  = "gzip"
+)
+
+// RequestCompressionOptions is the options of EnableRequestCompression.
+type RequestCompressionOptions struct {
+	// Encoding is the content encoding of the request body, "gzip",
+	// "deflate", "br" or "zstd", default is "gzip".
+	Encoding string
+	// MinSize is the minimum size of the body to be compressed, default is
+	// 1024 bytes.
+	MinSize int
+	// RejectedStatusCodes is the status codes which means the compressed
+	// body is rejected by the server, default is 415. The response with
+	// the Accept-Encoding header (RFC 7694) is always the rejection.
+	RejectedStatusCodes []int
+	// RejectTTL is how long the compression is disabled for the host which
+	// rejects the compressed body, default is 1h.
+	RejectTTL time.Duration
+}
+
+// requestCompression compresses the request bodies, and remembers the
+// hosts which reject the compressed bodies.
+type requestCompression struct {
+	encoding  string
+	minSize   int
+	rejectTTL time.Duration
+	rejected  map[int]bool
+
+	mu    sync.Mutex
+	hosts map[string]time.Time
+}
+
+func newRequestCompression(opts ...*RequestCompressionOptions) (*requestCompression, error) {
+	var o RequestCompressionOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	rc := &requestCompression{
+		encoding:  strings.ToLower(o.Encoding),
+		minSize:   o.MinSize,
+		rejectTTL: o.RejectTTL,
+		rejected:  make(map[int]bool),
+		hosts:     make(map[string]time.Time),
+	}
+	switch rc.encoding {
+	case "":
+		rc.encoding = defaultRequestCompressionEncoding
+	case "gzip", "deflate", "br", "zstd":
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", o.Encoding)
+	}
+	if rc.minSize <= 0 {
+		rc.minSize = defaultRequestCompressionMinSize
+	}
+	if rc.rejectTTL <= 0 {
+		rc.rejectTTL = defaultRequestCompressionRejectTTL
+	}
+	codes := o.RejectedStatusCodes
+	if len(codes) == 0 {
+		codes = []int{http.StatusUnsupportedMediaType}
+	}
+	for _, code := range codes {
+		rc.rejected[code] = true
+	}
+	return rc, nil
+}
+
+func (rc *requestCompression) compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch rc.encoding {
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		w = gzip.NewWriter(&buf)
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// accepts reports whether the host accepts the compressed body, which is
+// true unless it rejected one within the RejectTTL.
+func (rc *requestCompression) accepts(host string, now time.Time) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	until, ok := rc.hosts[host]
+	if !ok {
+		return true
+	}
+	if now.Before(until) {
+		return false
+	}
+	delete(rc.hosts, host)
+	return true
+}
+
+func (rc *requestCompression) reject(host string, now time.Time) {
+	rc.mu.Lock()
+	rc.hosts[host] = now.Add(rc.rejectTTL)
+	rc.mu.Unlock()
+}
+
+// handleRequestCompression compresses the request body if it's enabled, the
+// body compressed by the previous attempt is restored first.
+func handleRequestCompression(c *Client, r *Request) error {
+	if r.uncompressedBody != nil {
+		r.SetBodyBytes(r.uncompressedBody)
+		r.uncompressedBody = nil
+		r.Headers.Del("Content-Encoding")
+	}
+	rc := c.requestCompression
+	if rc == nil || len(r.Body) < rc.minSize || r.getHeader("Content-Encoding") != "" ||
+		!rc.accepts(r.URL.Host, c.now()) {
+		return nil
+	}
+	compressed, err := rc.compress(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed to compress request body: %w", err)
+	}
+	r.uncompressedBody = r.Body
+	r.SetBodyBytes(compressed)
+	r.Headers.Set("Content-Encoding", rc.encoding)
+	return nil
+}
+
+// shouldResendUncompressed reports whether the compressed body is rejected
+// and the request should be resent uncompressed, the host is remembered so
+// that the following requests are not compressed. The rejection is the
+// RejectedStatusCodes, or the response with the Accept-Encoding header (RFC
+// 7694). The non-idempotent requests are not resent.
+func (r *Request) shouldResendUncompressed(resp *Response, err error) bool {
+	rc := r.client.requestCompression
+	if err != nil || resp.Response == nil || rc == nil || r.uncompressedBody == nil {
+		return false
+	}
+	if !rc.rejected[resp.StatusCode] && resp.Header.Get("Accept-Encoding") == "" {
+		return false
+	}
+	rc.reject(r.URL.Host, r.client.now())
+	return isIdempotent(r.RawRequest)
+}
+
+// isIdempotent reports whether the request is idempotent (RFC 9110), or has
+// the Idempotency-Key header.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return headerHas(req.Header, "Idempotency-Key") || headerHas(req.Header, "X-Idempotency-Key")
+}
+
+// EnableRequestCompression enables compressing the request bodies (only the
+// in-memory bodies, e.g. set by SetBody with bytes, string or the marshaled
+// struct, not smaller than MinSize) with the Content-Encoding, which saves
+// the bandwidth of large uploads. It's safe to be enabled globally: if the
+// server rejects the compressed body (415, or the response with the
+// Accept-Encoding header by default), the compression is disabled for the
+// host for a while, and the idempotent request is resent uncompressed (the
+// non-idempotent one, e.g. POST, returns the rejection as is unless it has
+// the Idempotency-Key header). The body with Content-Encoding set
+// explicitly is not compressed.
+// For example:
+//
+//	client.EnableRequestCompression(&req.RequestCompressionOptions{
+//		Encoding: "zstd",
+//		MinSize:  4096,
+//	})
+func (c *Client) EnableRequestCompression(opts ...*RequestCompressionOptions) *Client {
+	rc, err := newRequestCompression(opts...)
+	if err != nil {
+		c.log.Errorf("failed to enable request compression: %v", err)
+		return c
+	}
+	c.requestCompression = rc
+	return c
+}
+
+// DisableRequestCompression disables the request compression enabled by
+// EnableRequestCompression.
+func (c *Client) DisableRequestCompression() *Client {
+	c.requestCompression = nil
+	return c
+}
@@ -0,0 +1,226 @@
+package req
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	urlpkg "net/url"
+	"os"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// LogFormat is the format of the lines written by NewRequestLogMiddleware.
+type LogFormat int
+
+const (
+	// LogFormatCommon is the Common Log Format extended with the latency,
+	// retries, trace summary and error, e.g.
+	//
+	//	example.com - - [02/Jan/2024:15:04:05 +0800] "GET https://example.com/users?id=REDACTED HTTP/2.0" 200 1024 12.3ms retries=1 trace="dns=1ms connect=2ms tls=3ms ttfb=5ms reused=false"
+	LogFormatCommon LogFormat = iota
+	// LogFormatJSON is the JSON lines of RequestLogEntry.
+	LogFormatJSON
+)
+
+// RequestLogEntry is the entry of the request log written by
+// NewRequestLogMiddleware, and the data of the custom template.
+type RequestLogEntry struct {
+	Time          time.Time     `json:"time"`
+	Host          string        `json:"host"`
+	Method        string        `json:"method"`
+	URL           string        `json:"url"`
+	Proto         string        `json:"proto,omitempty"`
+	Status        int           `json:"status,omitempty"`
+	BytesSent     int64         `json:"bytes_sent"`
+	BytesReceived int64         `json:"bytes_received"`
+	Latency       time.Duration `json:"latency"`
+	Retries       int           `json:"retries"`
+	Error         string        `json:"error,omitempty"`
+	// Trace is the trace information, nil if trace is not enabled (see
+	// Client.EnableTraceAll and Request.EnableTrace).
+	Trace *TraceInfo `json:"trace,omitempty"`
+}
+
+// TraceSummary returns the one line summary of the trace information, e.g.
+// "dns=1ms connect=2ms tls=3ms ttfb=5ms reused=false", empty if trace is
+// not enabled.
+func (e *RequestLogEntry) TraceSummary() string {
+	if e.Trace == nil {
+		return ""
+	}
+	t := e.Trace
+	return fmt.Sprintf("dns=%v connect=%v tls=%v ttfb=%v reused=%t", t.DNSLookupTime, t.TCPConnectTime, t.TLSHandshakeTime, t.FirstResponseTime, t.IsConnReused)
+}
+
+// RequestLogOptions controls the behavior of NewRequestLogMiddleware.
+type RequestLogOptions struct {
+	// Output is the writer which the lines are written to, default is
+	// os.Stdout.
+	Output io.Writer
+	// Format is the format of the lines, default is LogFormatCommon, it's
+	// ignored if Template is set.
+	Format LogFormat
+	// Template is the custom template of the lines, which is executed with
+	// the *RequestLogEntry, e.g.
+	//
+	//	template.Must(template.New("log").Parse(`{{.Method}} {{.URL}} {{.Status}} {{.Latency}}`))
+	//
+	// The newline is appended if the output of the template doesn't end
+	// with it.
+	Template *template.Template
+	// RedactURL returns the url written in the lines, the default one
+	// removes the password and the values of query parameters.
+	RedactURL func(u *urlpkg.URL) string
+	// Skip returns true if the response should not be logged, e.g. only
+	// logs the failed requests.
+	Skip func(resp *Response) bool
+}
+
+type requestLogger struct {
+	mu  sync.Mutex
+	opt RequestLogOptions
+}
+
+// NewRequestLogMiddleware creates a ResponseMiddleware which writes one line
+// for each request attempt with the outcome (status, latency, bytes, retries
+// and trace summary), it's the access log of the requests, which is much
+// lighter than the dump. For example:
+//
+//	client.OnAfterResponse(req.NewRequestLogMiddleware(&req.RequestLogOptions{
+//		Output: logFile,
+//		Format: req.LogFormatJSON,
+//	}))
+func NewRequestLogMiddleware(opts ...*RequestLogOptions) ResponseMiddleware {
+	l := &requestLogger{}
+	if len(opts) > 0 && opts[0] != nil {
+		l.opt = *opts[0]
+	}
+	if l.opt.Output == nil {
+		l.opt.Output = os.Stdout
+	}
+	if l.opt.RedactURL == nil {
+		l.opt.RedactURL = redactURL
+	}
+	return l.log
+}
+
+func (l *requestLogger) log(client *Client, resp *Response) error {
+	if l.opt.Skip != nil && l.opt.Skip(resp) {
+		return nil
+	}
+	line, err := l.format(l.newEntry(resp))
+	if err != nil {
+		client.log.Errorf("failed to format request log: %v", err)
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err = l.opt.Output.Write(line); err != nil {
+		client.log.Errorf("failed to write request log: %v", err)
+	}
+	return nil
+}
+
+func (l *requestLogger) newEntry(resp *Response) *RequestLogEntry {
+	r := resp.Request
+	e := &RequestLogEntry{
+		Time:      r.StartTime,
+		Method:    r.Method,
+		BytesSent: requestBytesSent(r),
+		Retries:   r.RetryAttempt,
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	} else {
+		e.Latency = time.Since(r.StartTime)
+	}
+	if r.URL != nil {
+		e.Host = r.URL.Host
+		e.URL = l.opt.RedactURL(r.URL)
+	}
+	if resp.Response != nil {
+		e.Proto = resp.Proto
+		e.Status = resp.StatusCode
+		e.BytesReceived = responseBytesReceived(resp)
+	}
+	if resp.Err != nil {
+		e.Error = resp.Err.Error()
+	}
+	if r.trace != nil {
+		trace := r.TraceInfo()
+		e.Trace = &trace
+	}
+	return e
+}
+
+func (l *requestLogger) format(e *RequestLogEntry) ([]byte, error) {
+	if l.opt.Template != nil {
+		var buf bytes.Buffer
+		if err := l.opt.Template.Execute(&buf, e); err != nil {
+			return nil, err
+		}
+		if b := buf.Bytes(); len(b) == 0 || b[len(b)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	}
+	if l.opt.Format == LogFormatJSON {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+	return formatCommonLog(e), nil
+}
+
+// formatCommonLog formats the entry in the Common Log Format, the missing
+// fields are written as "-".
+func formatCommonLog(e *RequestLogEntry) []byte {
+	b := make([]byte, 0, 256)
+	b = append(b, orDash(e.Host)...)
+	b = append(b, " - - ["...)
+	b = e.Time.AppendFormat(b, "02/Jan/2006:15:04:05 -0700")
+	b = append(b, `] "`...)
+	b = append(b, e.Method...)
+	b = append(b, ' ')
+	b = append(b, e.URL...)
+	b = append(b, ' ')
+	b = append(b, orDash(e.Proto)...)
+	b = append(b, `" `...)
+	if e.Status > 0 {
+		b = strconv.AppendInt(b, int64(e.Status), 10)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, ' ')
+	if e.BytesReceived > 0 {
+		b = strconv.AppendInt(b, e.BytesReceived, 10)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, ' ')
+	b = append(b, e.Latency.String()...)
+	b = append(b, " retries="...)
+	b = strconv.AppendInt(b, int64(e.Retries), 10)
+	if summary := e.TraceSummary(); summary != "" {
+		b = append(b, " trace="...)
+		b = strconv.AppendQuote(b, summary)
+	}
+	if e.Error != "" {
+		b = append(b, " error="...)
+		b = strconv.AppendQuote(b, e.Error)
+	}
+	return append(b, '\n')
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,413 @@
+package req
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver resolves the host to the IP addresses, *net.Resolver implements
+// it, and NewDNSResolver, NewDoTResolver and NewDoHResolver create the
+// resolvers which send the DNS queries to the specified servers.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// ResolverFunc is a Resolver implemented by the function.
+type ResolverFunc func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+// LookupIPAddr implements Resolver.
+func (f ResolverFunc) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return f(ctx, host)
+}
+
+// withDefaultPort appends the port to the addr if it has no port.
+func withDefaultPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, port)
+	}
+	return addr
+}
+
+// roundRobinDialer dials the servers in turn, so that the retried queries
+// are sent to the next server.
+type roundRobinDialer struct {
+	servers []string
+	next    uint32
+	dial    func(ctx context.Context, network, server string) (net.Conn, error)
+}
+
+func (d *roundRobinDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	i := atomic.AddUint32(&d.next, 1) - 1
+	return d.dial(ctx, network, d.servers[int(i)%len(d.servers)])
+}
+
+func newServersResolver(servers []string, defaultPort string, dial func(ctx context.Context, network, server string) (net.Conn, error)) *net.Resolver {
+	d := &roundRobinDialer{dial: dial}
+	for _, server := range servers {
+		d.servers = append(d.servers, withDefaultPort(server, defaultPort))
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial:     d.DialContext,
+	}
+}
+
+// NewDNSResolver creates the resolver which sends the DNS queries to the
+// servers (port 53 is used if not specified) in turn instead of the ones
+// of the system, e.g. NewDNSResolver("8.8.8.8", "1.1.1.1:53").
+func NewDNSResolver(servers ...string) Resolver {
+	if len(servers) == 0 {
+		return net.DefaultResolver
+	}
+	var d net.Dialer
+	return newServersResolver(servers, "53", d.DialContext)
+}
+
+// NewDoTResolver creates the resolver which sends the DNS queries to the
+// servers (port 853 is used if not specified) in turn over TLS (DNS over
+// TLS, RFC 7858), e.g. NewDoTResolver("1.1.1.1", "dns.google"). The
+// certificate of the server is verified with the host of the server.
+func NewDoTResolver(servers ...string) Resolver {
+	if len(servers) == 0 {
+		return net.DefaultResolver
+	}
+	var d net.Dialer
+	return newServersResolver(servers, "853", func(ctx context.Context, _, server string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(server)
+		conn, err := d.DialContext(ctx, "tcp", server)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	})
+}
+
+// maxDoHResponseSize is the max size of the DNS message.
+const maxDoHResponseSize = 65535
+
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
+
+// dohConn sends the DNS queries written by the resolver in the TCP format
+// (prefixed with the 2-byte length) with the HTTPS POST requests, and
+// returns the responses in the same format.
+type dohConn struct {
+	ctx      context.Context
+	url      string
+	client   *http.Client
+	deadline time.Time
+	wbuf     bytes.Buffer
+	rbuf     bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.wbuf.Write(b)
+	for c.wbuf.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.wbuf.Bytes()))
+		if c.wbuf.Len() < 2+n {
+			break
+		}
+		c.wbuf.Next(2)
+		if err := c.query(c.wbuf.Next(n)); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// detachContext returns the context which is canceled with ctx and has the
+// same deadline, but carries none of its values, so that the DoH exchange
+// doesn't fire the httptrace hooks of the request being resolved.
+func detachContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.Background())
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		detached, cancelDeadline = context.WithDeadline(detached, deadline)
+		cancelParent := cancel
+		cancel = func() {
+			cancelDeadline()
+			cancelParent()
+		}
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (c *dohConn) query(msg []byte) error {
+	ctx, cancel := detachContext(c.ctx)
+	defer cancel()
+	if !c.deadline.IsZero() {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, c.deadline)
+		defer cancelDeadline()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %q of DoH server %s", resp.Status, c.url)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDoHResponseSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxDoHResponseSize {
+		return errors.New("DoH response is too large")
+	}
+	c.rbuf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(body))))
+	c.rbuf.Write(body)
+	return nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.rbuf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.rbuf.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr("") }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+// NewDoHResolver creates the resolver which sends the DNS queries to the
+// servers in turn with HTTPS (DNS over HTTPS, RFC 8484), e.g.
+// NewDoHResolver("https://1.1.1.1/dns-query", "https://dns.google/dns-query").
+// The host of the server is resolved with the system resolver, use the IP
+// address in the url to avoid it.
+func NewDoHResolver(urls ...string) Resolver {
+	if len(urls) == 0 {
+		return net.DefaultResolver
+	}
+	client := &http.Client{}
+	d := &roundRobinDialer{
+		servers: urls,
+		dial: func(ctx context.Context, _, url string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, url: url, client: client}, nil
+		},
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial:     d.DialContext,
+	}
+}
+
+// ErrDNSTimeout is returned if the DNS lookup is not completed within the
+// timeout set by SetDNSLookupTimeout.
+var ErrDNSTimeout = errors.New("req: dns lookup timeout")
+
+// dnsTimeoutError is ErrDNSTimeout with the host, it's a timeout net.Error
+// too, so that it's retried like the other timeouts.
+type dnsTimeoutError struct {
+	host    string
+	timeout time.Duration
+}
+
+func (e *dnsTimeoutError) Error() string {
+	return fmt.Sprintf("%s: lookup %s exceeded %s", ErrDNSTimeout, e.host, e.timeout)
+}
+
+func (e *dnsTimeoutError) Is(target error) bool { return target == ErrDNSTimeout }
+func (e *dnsTimeoutError) Timeout() bool        { return true }
+func (e *dnsTimeoutError) Temporary() bool      { return true }
+
+// lookupIPAddr resolves the host with the resolver and the DNS cache, the
+// DNS lookup is reported to the httptrace.ClientTrace of the ctx.
+func (t *Transport) lookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	resolver := t.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	lookupCtx := ctx
+	if t.dnsTimeout > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, t.dnsTimeout)
+		defer cancel()
+	}
+	var ips []net.IPAddr
+	var err error
+	if t.dnsCache != nil {
+		ips, err = t.dnsCache.lookup(lookupCtx, host, resolver)
+	} else {
+		ips, err = resolver.LookupIPAddr(lookupCtx, host)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(lookupCtx.Err(), context.DeadlineExceeded) {
+		err = &dnsTimeoutError{host: host, timeout: t.dnsTimeout}
+	}
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: ips, Err: err})
+	}
+	return ips, err
+}
+
+// dialResolved resolves the host of the addr with the resolver and the DNS
+// cache, and dials the resolved addresses with Happy Eyeballs.
+func (t *Transport) dialResolved(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		if t.dnsTimeout > 0 && t.DialTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, t.DialTimeout)
+			defer cancel()
+		}
+		return t.netDialer().DialContext(ctx, network, addr)
+	}
+	ips, err := t.lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	if t.dnsTimeout > 0 && t.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.DialTimeout)
+		defer cancel()
+	}
+	primaries, fallbacks := t.partitionAddrs(network, ips, port)
+	if len(primaries) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+	return t.dialParallel(ctx, network, primaries, fallbacks)
+}
+
+// SetResolver set the resolver, see Client.SetResolver.
+func (t *Transport) SetResolver(resolver Resolver) *Transport {
+	t.resolver = resolver
+	return t
+}
+
+// SetResolver set the resolver which resolves the hosts of the requests
+// (or the host of the proxy if the request is sent via the proxy) instead
+// of the system resolver, so that the lookups can bypass the broken or
+// censored local resolvers, the DNS lookup time is still reported in the
+// TraceInfo. It takes no effect if the custom dial function is set (e.g.
+// SetDial, SetSSHTunnel) as the host is resolved by it, and on HTTP3. It
+// works with EnableDnsCache. For example:
+//
+//	client.SetResolver(req.NewDoHResolver("https://1.1.1.1/dns-query"))
+//	client.SetResolver(req.NewDoTResolver("8.8.8.8", "dns.google"))
+//	client.SetResolver(req.NewDNSResolver("223.5.5.5"))
+func (c *Client) SetResolver(resolver Resolver) *Client {
+	c.Transport.SetResolver(resolver)
+	return c
+}
+
+// SetDNSLookupTimeout set the DNS lookup timeout, see
+// Client.SetDNSLookupTimeout.
+func (t *Transport) SetDNSLookupTimeout(timeout time.Duration) *Transport {
+	t.dnsTimeout = timeout
+	return t
+}
+
+// SetDNSLookupTimeout set the maximum amount of time to wait for the DNS
+// lookup of the host, the lookup fails with ErrDNSTimeout (which can be
+// checked with errors.Is) if it's exceeded. Once it's set, the dial timeout
+// (SetDialTimeout) covers the connecting only instead of both the lookup
+// and the connecting, so that a hanging resolver can not eat the whole
+// connect budget or the request timeout. It works with SetResolver and
+// EnableDnsCache, but takes no effect if the custom dial function is set
+// and on HTTP3. Zero (default) means no separate timeout. For example:
+//
+//	client.SetDNSLookupTimeout(2 * time.Second).SetDialTimeout(3 * time.Second)
+func (c *Client) SetDNSLookupTimeout(timeout time.Duration) *Client {
+	c.Transport.SetDNSLookupTimeout(timeout)
+	return c
+}
+
+// mapHost returns the address which the addr is mapped to by the host
+// mapping, the addr is returned as is if it's not mapped.
+func (t *Transport) mapHost(addr string) string {
+	if len(t.hostMapping) == 0 {
+		return addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	host = strings.ToLower(host)
+	target, ok := t.hostMapping[net.JoinHostPort(host, port)]
+	if !ok {
+		if target, ok = t.hostMapping[host]; !ok {
+			return addr
+		}
+	}
+	if _, _, err = net.SplitHostPort(target); err != nil {
+		return net.JoinHostPort(target, port)
+	}
+	return target
+}
+
+// SetHostMapping set the host mapping, see Client.SetHostMapping.
+func (t *Transport) SetHostMapping(mapping map[string]string) *Transport {
+	hostMapping := make(map[string]string, len(mapping))
+	for from, to := range mapping {
+		hostMapping[strings.ToLower(from)] = to
+	}
+	t.hostMapping = hostMapping
+	return t
+}
+
+// SetHostMapping set the static mapping from the "host:port" (or "host" for
+// all ports) to the IP address (or "ip:port" to change the port too), like
+// the --resolve option of curl, the connections to the mapped hosts are
+// dialed to the mapped addresses without the DNS lookup, while the SNI and
+// Host header are kept intact, e.g. test against the staging servers behind
+// the production hostnames. It takes effect on the custom dial function set
+// by SetDial too, but not on SetDialTLS and HTTP3. The previous mapping is
+// replaced. For example:
+//
+//	client.SetHostMapping(map[string]string{
+//		"api.example.com:443": "10.0.0.12",
+//		"cdn.example.com":     "10.0.0.13:8443",
+//	})
+func (c *Client) SetHostMapping(mapping map[string]string) *Client {
+	for from, to := range mapping {
+		toHost := to
+		if host, _, err := net.SplitHostPort(to); err == nil {
+			toHost = host
+		}
+		if net.ParseIP(toHost) == nil {
+			c.log.Errorf("failed to set host mapping: %q of %q is not an ip address", to, from)
+			return c
+		}
+	}
+	c.Transport.SetHostMapping(mapping)
+	return c
+}
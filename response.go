@@ -5,7 +5,9 @@ import (
 	"github.com/imroc/req/v3/internal/util"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,6 +25,155 @@ type Response struct {
 	receivedAt time.Time
 	error      interface{}
 	result     interface{}
+	connReuse  *connReuseTracker
+	// bodyTruncated is true if the body is truncated by the auto-read
+	// policy.
+	bodyTruncated bool
+	// decoded memoizes the decoding results of the body per target type.
+	decoded map[decodeKey]reflect.Value
+}
+
+// decodeKey is the key of the memoized decoding result of the body.
+type decodeKey struct {
+	typ reflect.Type
+	xml bool
+}
+
+// decodedMu guards the decoded results of all responses, the results are
+// never modified once stored, so they're copied out of the lock.
+var decodedMu sync.Mutex
+
+// resetBody drops the read body and the decoding results, e.g. before the
+// response is replaced by the one of the resent request.
+func (r *Response) resetBody() {
+	r.body = nil
+	decodedMu.Lock()
+	r.decoded = nil
+	decodedMu.Unlock()
+}
+
+// decode unmarshals the buffered body into v. The result is memoized per
+// the type of v if v points to a zero value, and a deep copy of the result
+// is set to v if the body is decoded into the same type again, so that the
+// body is decoded once when it's inspected by both the middlewares and the
+// caller, and the decoded values never alias each other. The non-zero values
+// and the types that can't be deep copied (e.g. with unexported fields) are
+// decoded on each call.
+func (r *Response) decode(v interface{}, xml bool) error {
+	body, err := r.ToBytes()
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	memoize := rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().IsZero()
+	var key decodeKey
+	if memoize {
+		key = decodeKey{typ: rv.Elem().Type(), xml: xml}
+		decodedMu.Lock()
+		result, ok := r.decoded[key]
+		decodedMu.Unlock()
+		if ok {
+			deepCopy(rv.Elem(), result)
+			return nil
+		}
+	}
+	c := r.Request.client
+	body = c.stripBOM(body)
+	if xml {
+		err = c.xmlUnmarshal(body, v)
+	} else {
+		err = c.jsonUnmarshal(body, v)
+	}
+	if err != nil || !memoize {
+		return err
+	}
+	result := reflect.New(key.typ).Elem()
+	if !deepCopy(result, rv.Elem()) {
+		return nil
+	}
+	decodedMu.Lock()
+	if r.decoded == nil {
+		r.decoded = make(map[decodeKey]reflect.Value)
+	}
+	r.decoded[key] = result
+	decodedMu.Unlock()
+	return nil
+}
+
+// deepCopy copies src to dst which is settable, the maps, slices, pointers
+// and interfaces are copied recursively. It returns false if src can't be
+// deep copied, e.g. it has unexported fields, channels or functions.
+func deepCopy(dst, src reflect.Value) bool {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return true
+		}
+		p := reflect.New(src.Type().Elem())
+		if !deepCopy(p.Elem(), src.Elem()) {
+			return false
+		}
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			return true
+		}
+		e := reflect.New(src.Elem().Type()).Elem()
+		if !deepCopy(e, src.Elem()) {
+			return false
+		}
+		dst.Set(e)
+	case reflect.Slice:
+		if src.IsNil() {
+			return true
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if !deepCopy(s.Index(i), src.Index(i)) {
+				return false
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			if !deepCopy(dst.Index(i), src.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return true
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			v := reflect.New(src.Type().Elem()).Elem()
+			if !deepCopy(k, iter.Key()) || !deepCopy(v, iter.Value()) {
+				return false
+			}
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		if src.Type() == timeType { // immutable value
+			dst.Set(src)
+			return true
+		}
+		for i := 0; i < src.NumField(); i++ {
+			if !src.Type().Field(i).IsExported() {
+				return false
+			}
+			if !deepCopy(dst.Field(i), src.Field(i)) {
+				return false
+			}
+		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return src.IsNil()
+	default:
+		dst.Set(src)
+	}
+	return true
 }
 
 // IsSuccess method returns true if no error occurs and HTTP status `code >= 200 and <= 299`
@@ -126,6 +277,15 @@ func (r *Response) TraceInfo() TraceInfo {
 	return r.Request.TraceInfo()
 }
 
+// ASCIIHost returns the host (with port if any) that the request is sent
+// to, unicode hostname is converted to punycode, e.g. "xn--bcher-kva.example".
+func (r *Response) ASCIIHost() string {
+	if r.Request == nil || r.Request.URL == nil {
+		return ""
+	}
+	return r.Request.URL.Host
+}
+
 // TotalTime returns the total time of the request, from request we sent to response we received.
 func (r *Response) TotalTime() time.Duration {
 	if r.Request.trace != nil {
@@ -154,11 +314,7 @@ func (r *Response) UnmarshalJson(v interface{}) error {
 	if r.Err != nil {
 		return r.Err
 	}
-	b, err := r.ToBytes()
-	if err != nil {
-		return err
-	}
-	return r.Request.client.jsonUnmarshal(b, v)
+	return r.decode(v, false)
 }
 
 // UnmarshalXml unmarshalls XML response body into the specified object.
@@ -166,11 +322,7 @@ func (r *Response) UnmarshalXml(v interface{}) error {
 	if r.Err != nil {
 		return r.Err
 	}
-	b, err := r.ToBytes()
-	if err != nil {
-		return err
-	}
-	return r.Request.client.xmlUnmarshal(b, v)
+	return r.decode(v, true)
 }
 
 // Unmarshal unmarshalls response body into the specified object according
@@ -215,8 +367,10 @@ func (r *Response) String() string {
 
 // ToString returns the response body as string, read body if not have been read.
 func (r *Response) ToString() (string, error) {
-	b, err := r.ToBytes()
-	return string(b), err
+	if _, err := r.ToBytes(); err != nil {
+		return "", err
+	}
+	return r.String(), nil
 }
 
 // ToBytes returns the response body as []byte, read body if not have been read.
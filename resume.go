@@ -0,0 +1,103 @@
+package req
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resumeValidatorSuffix is the suffix of the file which saves the validator
+// (ETag or Last-Modified) of a partial download, it's removed once the
+// download completes. The partial content is saved in the ".part" file.
+const resumeValidatorSuffix = ".resume"
+
+func handleResume(c *Client, r *Request) error {
+	if !r.resume || r.outputFile == "" {
+		return nil
+	}
+	r.resumeOffset = 0
+	r.Headers.Del("Range")
+	r.Headers.Del("If-Range")
+	file := c.outputFilePath(r)
+	info, err := os.Stat(partFilename(file))
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil
+	}
+	r.resumeOffset = info.Size()
+	r.SetHeader("Range", fmt.Sprintf("bytes=%d-", r.resumeOffset))
+	if validator, err := os.ReadFile(file + resumeValidatorSuffix); err == nil && len(validator) > 0 {
+		r.SetHeader("If-Range", string(validator))
+	}
+	return nil
+}
+
+// parseContentRangeStart returns the first byte position of the
+// Content-Range header, e.g. "bytes 100-199/200" -> 100.
+func parseContentRangeStart(contentRange string) (int64, bool) {
+	s, found := strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return 0, false
+	}
+	s, _, found = strings.Cut(s, "-")
+	if !found {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(s, 10, 64)
+	return start, err == nil
+}
+
+// parseContentRangeSize returns the complete length of the Content-Range
+// header, e.g. "bytes */200" -> 200.
+func parseContentRangeSize(contentRange string) (int64, bool) {
+	_, s, found := strings.Cut(contentRange, "/")
+	if !found {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	return size, err == nil
+}
+
+// openResumeOutput opens the ".part" file of the resumable download, the
+// file is appended if the server returns the expected partial content,
+// otherwise it's truncated. A nil file is returned if nothing needs to be
+// written, e.g. the response is not successful (the partial file is kept),
+// or the partial file is already complete (renamed to the output file).
+func openResumeOutput(resp *Response, file string) (*os.File, error) {
+	r := resp.Request
+	part := partFilename(file)
+	if r.resumeOffset > 0 {
+		switch resp.StatusCode {
+		case http.StatusRequestedRangeNotSatisfiable:
+			if size, ok := parseContentRangeSize(resp.Header.Get("Content-Range")); ok && size == r.resumeOffset {
+				f, err := os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					return nil, err
+				}
+				err = r.downloadChecksum.verifyFile(part)
+				return nil, finishPartFile(f, file, err, !isChecksumError(err))
+			}
+			return nil, fmt.Errorf("failed to resume download: %s", resp.Status)
+		case http.StatusPartialContent:
+			start, ok := parseContentRangeStart(resp.Header.Get("Content-Range"))
+			if !ok || start != r.resumeOffset {
+				return nil, fmt.Errorf("failed to resume download: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+			}
+			return os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0)
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") { // If-Range requires a strong validator
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator != "" {
+		if err := os.WriteFile(file+resumeValidatorSuffix, []byte(validator), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return os.Create(part)
+}
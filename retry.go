@@ -1,8 +1,13 @@
 package req
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -43,6 +48,179 @@ type retryOption struct {
 	GetRetryInterval GetRetryIntervalFunc
 	RetryConditions  []RetryConditionFunc
 	RetryHooks       []RetryHookFunc
+	Budget           *RetryBudget
+}
+
+// RetryBudget limits the attempts of a request across retries, so that
+// retries never exceed an SLA.
+type RetryBudget struct {
+	// MaxAttempts is the max number of attempts including the first one,
+	// 0 means no limit.
+	MaxAttempts int
+	// Deadline is the wall-clock time limit across all attempts (including
+	// the retry intervals), the in-flight attempt is canceled once it's
+	// exceeded, 0 means no limit.
+	Deadline time.Duration
+	// AttemptTimeout is the timeout of each attempt, each attempt gets its
+	// own context which is derived from the request context, 0 means no
+	// per-attempt timeout.
+	AttemptTimeout time.Duration
+}
+
+// RetryPriority is the priority of the retries of a request, which
+// determines how the SharedRetryBudget is consumed.
+type RetryPriority int
+
+const (
+	// RetryPriorityNormal retries while the shared budget is not exhausted,
+	// it's the default priority.
+	RetryPriorityNormal RetryPriority = iota
+	// RetryPriorityLow retries only while less than half of the shared
+	// budget is used, so that low priority requests (e.g. background jobs)
+	// stop retrying first during an outage.
+	RetryPriorityLow
+	// RetryPriorityHigh always retries even if the shared budget is
+	// exhausted, the retries are still counted in the budget.
+	RetryPriorityHigh
+)
+
+// SharedRetryBudget limits the retries of all requests fired from the
+// client to a ratio of the requests in a sliding window, so that a
+// widespread outage doesn't trigger a retry storm.
+type SharedRetryBudget struct {
+	// Ratio is the max ratio of retries to requests in the window, e.g.
+	// 0.1 means retries may add at most 10% extra load, default is 0.1.
+	Ratio float64
+	// MinRetriesPerSecond is the number of retries per second which are
+	// always allowed, so that requests at a low rate can still retry,
+	// default is 10, negative means none.
+	MinRetriesPerSecond int
+	// Window is the sliding window in which requests and retries are
+	// counted, which is rounded up to seconds, default is 10s.
+	Window time.Duration
+}
+
+type retryBudgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// sharedRetryBudget counts the requests and retries per second in the
+// sliding window.
+type sharedRetryBudget struct {
+	ratio      float64
+	minRetries int
+	mu         sync.Mutex
+	buckets    []retryBudgetBucket
+}
+
+func newSharedRetryBudget(budget SharedRetryBudget) *sharedRetryBudget {
+	if budget.Ratio <= 0 {
+		budget.Ratio = 0.1
+	}
+	if budget.MinRetriesPerSecond == 0 {
+		budget.MinRetriesPerSecond = 10
+	} else if budget.MinRetriesPerSecond < 0 {
+		budget.MinRetriesPerSecond = 0
+	}
+	if budget.Window <= 0 {
+		budget.Window = 10 * time.Second
+	}
+	seconds := int((budget.Window + time.Second - 1) / time.Second)
+	return &sharedRetryBudget{
+		ratio:      budget.Ratio,
+		minRetries: budget.MinRetriesPerSecond * seconds,
+		buckets:    make([]retryBudgetBucket, seconds),
+	}
+}
+
+// bucket returns the bucket of the current second, the caller must hold
+// the lock.
+func (b *sharedRetryBudget) bucket(now time.Time) *retryBudgetBucket {
+	second := now.Unix()
+	bucket := &b.buckets[int(second%int64(len(b.buckets)))]
+	if bucket.second != second {
+		*bucket = retryBudgetBucket{second: second}
+	}
+	return bucket
+}
+
+// deposit records a request.
+func (b *sharedRetryBudget) deposit(now time.Time) {
+	b.mu.Lock()
+	b.bucket(now).requests++
+	b.mu.Unlock()
+}
+
+// withdraw records a retry and reports whether it's allowed by the budget.
+func (b *sharedRetryBudget) withdraw(priority RetryPriority, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket := b.bucket(now)
+	var requests, retries int
+	oldest := now.Unix() - int64(len(b.buckets))
+	for _, bk := range b.buckets {
+		if bk.second > oldest {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+	budget := b.ratio*float64(requests) + float64(b.minRetries)
+	switch priority {
+	case RetryPriorityLow:
+		budget /= 2
+	case RetryPriorityHigh:
+		budget = math.Inf(1)
+	}
+	if float64(retries+1) > budget {
+		return false
+	}
+	bucket.retries++
+	return true
+}
+
+// exhausted reports whether no more retry is allowed after the attempt,
+// elapsed is the time since the first attempt started.
+func (ro *retryOption) exhausted(attempt int, elapsed time.Duration) bool {
+	if b := ro.Budget; b != nil {
+		if b.MaxAttempts > 0 && attempt+1 >= b.MaxAttempts {
+			return true
+		}
+		if b.Deadline > 0 && elapsed >= b.Deadline {
+			return true
+		}
+		if ro.MaxRetries == 0 && (b.MaxAttempts > 0 || b.Deadline > 0) {
+			return false // retry count is not set, limited by budget only
+		}
+	}
+	return attempt >= ro.MaxRetries && ro.MaxRetries >= 0
+}
+
+// exceedsDeadline reports whether the next attempt would start after the
+// deadline of the budget if sleeping for the retry interval.
+func (ro *retryOption) exceedsDeadline(elapsed, interval time.Duration) bool {
+	b := ro.Budget
+	return b != nil && b.Deadline > 0 && elapsed+interval >= b.Deadline
+}
+
+// attemptTimeout returns the timeout of the next attempt, which is limited
+// by the remaining time before the deadline of the budget.
+func (ro *retryOption) attemptTimeout(elapsed time.Duration) time.Duration {
+	if ro == nil || ro.Budget == nil {
+		return 0
+	}
+	timeout := ro.Budget.AttemptTimeout
+	if ro.Budget.Deadline > 0 {
+		remaining := ro.Budget.Deadline - elapsed
+		if remaining <= 0 {
+			remaining = time.Nanosecond
+		}
+		if timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return timeout
 }
 
 func (ro *retryOption) Clone() *retryOption {
@@ -52,8 +230,105 @@ func (ro *retryOption) Clone() *retryOption {
 	o := &retryOption{
 		MaxRetries:       ro.MaxRetries,
 		GetRetryInterval: ro.GetRetryInterval,
+		Budget:           ro.Budget,
 	}
 	o.RetryConditions = append(o.RetryConditions, ro.RetryConditions...)
 	o.RetryHooks = append(o.RetryHooks, ro.RetryHooks...)
 	return o
 }
+
+// parseRetryPolicy parses the compact policy string of SetRetryPolicy
+// and SetCommonRetryPolicy.
+func parseRetryPolicy(policy string) (*retryOption, error) {
+	ro := newDefaultRetryOption()
+	backoff := "fixed"
+	base := 100 * time.Millisecond
+	max := time.Duration(0)
+	for _, field := range strings.Fields(policy) {
+		key, value, found := strings.Cut(field, "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("invalid retry policy field %q", field)
+		}
+		var err error
+		switch strings.ToLower(key) {
+		case "retries":
+			ro.MaxRetries, err = strconv.Atoi(value)
+		case "backoff":
+			backoff = strings.ToLower(value)
+			if backoff != "fixed" && backoff != "exp" {
+				err = errors.New("must be fixed or exp")
+			}
+		case "base":
+			base, err = time.ParseDuration(value)
+		case "max":
+			max, err = time.ParseDuration(value)
+		case "on":
+			var condition RetryConditionFunc
+			condition, err = parseRetryOn(value)
+			if err == nil {
+				ro.RetryConditions = []RetryConditionFunc{condition}
+			}
+		default:
+			err = errors.New("unknown key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy field %q: %v", field, err)
+		}
+	}
+	if backoff == "exp" {
+		if max < base {
+			max = base * 32
+		}
+		ro.GetRetryInterval = backoffInterval(base, max)
+	} else {
+		interval := base
+		ro.GetRetryInterval = func(resp *Response, attempt int) time.Duration {
+			return interval
+		}
+	}
+	return ro, nil
+}
+
+func parseRetryOn(value string) (RetryConditionFunc, error) {
+	var (
+		codes      = make(map[int]bool)
+		classes    = make(map[int]bool)
+		errClasses = make(map[ErrorClass]bool)
+		onError    bool
+	)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "error" {
+			onError = true
+			continue
+		}
+		if class, ok := parseErrorClass(v); ok {
+			errClasses[class] = true
+			continue
+		}
+		if len(v) == 3 && v[1:] == "xx" && v[0] >= '1' && v[0] <= '5' {
+			classes[int(v[0]-'0')] = true
+			continue
+		}
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("unknown retry condition %q", v)
+		}
+		codes[code] = true
+	}
+	return func(resp *Response, err error) bool {
+		if err != nil {
+			if onError {
+				return true
+			}
+			if resp != nil && resp.Request != nil && resp.Request.client != nil {
+				return errClasses[resp.Request.client.ClassifyError(err)]
+			}
+			return errClasses[defaultClassifyError(err)]
+		}
+		if resp == nil || resp.Response == nil {
+			return false
+		}
+		return codes[resp.StatusCode] || classes[resp.StatusCode/100]
+	}, nil
+}
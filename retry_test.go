@@ -2,13 +2,24 @@ package req
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"io"
 	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/imroc/req/v3/http2"
 	"github.com/imroc/req/v3/internal/tests"
+	xhttp2 "golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
 )
 
 func TestRetryBackOff(t *testing.T) {
@@ -196,3 +207,240 @@ func TestRetryTurnedOffWhenRetryCountEqZero(t *testing.T) {
 	tests.AssertIsNil(t, resp.Response)
 	tests.AssertEqual(t, 0, resp.Request.RetryAttempt)
 }
+
+func TestRetryPolicy(t *testing.T) {
+	testRetry(t, func(r *Request) {
+		r.SetRetryPolicy("retries=3 backoff=exp base=1ms max=10ms on=429,5xx,conn-reset")
+	})
+
+	attempt := 0
+	resp, err := tc().SetCommonRetryPolicy("retries=2 base=1ms on=5xx").R().
+		SetRetryHook(func(resp *Response, err error) {
+			attempt++
+		}).Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 0, resp.Request.RetryAttempt)
+	tests.AssertEqual(t, 0, attempt)
+
+	_, err = tc().R().SetRetryPolicy("retries=3 on=6xx").Get("/")
+	tests.AssertErrorContains(t, err, "unknown retry condition")
+	_, err = tc().R().SetRetryPolicy("retries=x").Get("/")
+	tests.AssertErrorContains(t, err, "invalid retry policy field")
+}
+
+func TestRetryBudget(t *testing.T) {
+	// limited by max attempts without retry count
+	resp, err := tc().SetCommonRetryBudget(RetryBudget{MaxAttempts: 3}).R().
+		SetRetryFixedInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return resp.StatusCode == http.StatusTooManyRequests
+		}).Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, resp.Request.RetryAttempt)
+
+	// each attempt times out, limited by the deadline
+	start := time.Now()
+	resp, err = tc().R().
+		SetRetryCount(100).
+		SetRetryFixedInterval(10 * time.Millisecond).
+		SetRetryBudget(RetryBudget{
+			Deadline:       300 * time.Millisecond,
+			AttemptTimeout: 50 * time.Millisecond,
+		}).Get("/slow-body")
+	tests.AssertErrorContains(t, err, "deadline exceeded")
+	tests.AssertEqual(t, true, time.Since(start) < time.Second)
+	tests.AssertEqual(t, true, resp.Request.RetryAttempt > 1)
+
+	// the body can be read after the attempt succeeds
+	resp, err = tc().R().
+		SetRetryBudget(RetryBudget{AttemptTimeout: time.Second}).
+		SetOutput(io.Discard).
+		Get("/slow-body")
+	assertSuccess(t, resp, err)
+	resp, err = tc().R().
+		SetRetryBudget(RetryBudget{AttemptTimeout: time.Second}).
+		Get("/slow-body")
+	assertSuccess(t, resp, err)
+	tests.AssertEqual(t, "slow body", resp.String())
+}
+
+func TestSharedRetryBudget(t *testing.T) {
+	c := tc().SetCommonRetryCount(3).SetCommonRetryFixedInterval(time.Millisecond).
+		SetCommonRetryCondition(func(resp *Response, err error) bool {
+			return resp.StatusCode == http.StatusTooManyRequests
+		}).
+		SetSharedRetryBudget(SharedRetryBudget{Ratio: 0.5, MinRetriesPerSecond: -1})
+	// 1 request allows 0.5 retry
+	resp, err := c.R().Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 0, resp.Request.RetryAttempt)
+
+	// 4 requests allow 2 retries
+	for i := 0; i < 2; i++ {
+		_, err = c.R().Get("/")
+		tests.AssertNoError(t, err)
+	}
+	resp, err = c.R().Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 2, resp.Request.RetryAttempt)
+
+	// low priority retries use half of the budget
+	for i := 0; i < 4; i++ {
+		_, err = c.R().Get("/")
+		tests.AssertNoError(t, err)
+	}
+	resp, err = c.R().SetRetryPriority(RetryPriorityLow).Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 0, resp.Request.RetryAttempt)
+
+	// high priority retries are always allowed
+	resp, err = c.R().SetRetryPriority(RetryPriorityHigh).Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 3, resp.Request.RetryAttempt)
+
+	resp, err = c.DisableSharedRetryBudget().R().Get("/too-many")
+	tests.AssertNoError(t, err)
+	tests.AssertEqual(t, 3, resp.Request.RetryAttempt)
+}
+
+func TestRetryDumpDiff(t *testing.T) {
+	testWithAllTransport(t, func(t *testing.T, c *Client) {
+		r := c.R()
+		resp, err := r.SetDumpOptions(&DumpOptions{RequestHeader: true, RetryDiff: true}).
+			EnableDump().
+			SetHeader("X-Attempt", "0").
+			SetRetryCount(2).
+			SetRetryFixedInterval(time.Millisecond).
+			SetRetryCondition(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode == http.StatusTooManyRequests
+			}).
+			SetRetryHook(func(resp *Response, err error) {
+				r.SetHeader("X-Attempt", strconv.Itoa(r.RetryAttempt))
+			}).
+			Get("/too-many")
+		tests.AssertNoError(t, err)
+		dump := resp.Dump()
+		tests.AssertContains(t, dump, "x-attempt: 0", true)
+		tests.AssertContains(t, dump, "----- retry attempt 1 (request headers diff from previous attempt) -----", true)
+		tests.AssertContains(t, dump, "+ x-attempt: 1", true)
+		tests.AssertContains(t, dump, "- x-attempt: 0", true)
+		tests.AssertContains(t, dump, "----- retry attempt 2", true)
+		tests.AssertContains(t, dump, "+ x-attempt: 2", true)
+		tests.AssertEqual(t, 1, strings.Count(strings.ToLower(dump), "user-agent"))
+	})
+}
+
+type proxyRejectedError struct{}
+
+func (proxyRejectedError) Error() string { return "proxy rejected" }
+
+func TestErrorClassifier(t *testing.T) {
+	tests.AssertEqual(t, ErrorClassCanceled, defaultClassifyError(context.Canceled))
+	tests.AssertEqual(t, ErrorClassTimeout, defaultClassifyError(context.DeadlineExceeded))
+	tests.AssertEqual(t, ErrorClassDNS, defaultClassifyError(&net.DNSError{Err: "no such host"}))
+	tests.AssertEqual(t, ErrorClassUnknown, defaultClassifyError(proxyRejectedError{}))
+
+	attempt := 0
+	c := tc().
+		SetDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, proxyRejectedError{}
+		}).
+		SetErrorClassifier(func(err error) ErrorClass {
+			if errors.As(err, &proxyRejectedError{}) {
+				return ErrorClassConnRefused
+			}
+			return ErrorClassUnknown
+		}).
+		SetCommonRetryPolicy("retries=2 base=1ms on=conn-refused").
+		AddCommonRetryHook(func(resp *Response, err error) {
+			attempt++
+		})
+	resp, err := c.R().Get("/")
+	tests.AssertNotNil(t, err)
+	tests.AssertEqual(t, 2, attempt)
+	tests.AssertEqual(t, ErrorClassConnRefused, resp.ErrorClass())
+	tests.AssertEqual(t, "conn-refused", resp.ErrorClass().String())
+}
+
+// newRawH2Server starts an HTTP/2 server which resets the stream with the
+// error code returned by reset for the nth request, or responds "ok" if
+// it returns ErrCodeNo.
+func newRawH2Server(t *testing.T, reset func(n int32) xhttp2.ErrCode) net.Listener {
+	s := httptest.NewTLSServer(nil) // borrow the certificate
+	cert := s.TLS.Certificates[0]
+	s.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+	})
+	tests.AssertNoError(t, err)
+	var n int32
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		if _, err := io.ReadFull(conn, make([]byte, len(xhttp2.ClientPreface))); err != nil {
+			return
+		}
+		fr := xhttp2.NewFramer(conn, conn)
+		fr.WriteSettings()
+		for {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch f := f.(type) {
+			case *xhttp2.SettingsFrame:
+				if !f.IsAck() {
+					fr.WriteSettingsAck()
+				}
+			case *xhttp2.HeadersFrame:
+				if code := reset(atomic.AddInt32(&n, 1)); code != xhttp2.ErrCodeNo {
+					fr.WriteRSTStream(f.StreamID, code)
+					continue
+				}
+				var buf bytes.Buffer
+				hpack.NewEncoder(&buf).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+				fr.WriteHeaders(xhttp2.HeadersFrameParam{StreamID: f.StreamID, BlockFragment: buf.Bytes(), EndHeaders: true})
+				fr.WriteData(f.StreamID, true, []byte("ok"))
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln
+}
+
+func TestHTTP2StreamError(t *testing.T) {
+	// REFUSED_STREAM is retried automatically, including on the cached conn
+	ln := newRawH2Server(t, func(n int32) xhttp2.ErrCode {
+		if n == 2 {
+			return xhttp2.ErrCodeRefusedStream
+		}
+		return xhttp2.ErrCodeNo
+	})
+	defer ln.Close()
+	c := C().EnableInsecureSkipVerify()
+	url := "https://" + ln.Addr().String()
+	for i := 0; i < 2; i++ {
+		resp, err := c.R().Get(url)
+		assertSuccess(t, resp, err)
+		tests.AssertEqual(t, "ok", resp.String())
+		tests.AssertEqual(t, "HTTP/2.0", resp.Proto)
+	}
+
+	// other stream errors are surfaced as http2.StreamError
+	ln2 := newRawH2Server(t, func(n int32) xhttp2.ErrCode {
+		return xhttp2.ErrCodeInternal
+	})
+	defer ln2.Close()
+	_, err := c.R().Get("https://" + ln2.Addr().String())
+	var se http2.StreamError
+	tests.AssertEqual(t, true, errors.As(err, &se))
+	tests.AssertEqual(t, http2.ErrCodeInternal, se.Code)
+}
@@ -0,0 +1,217 @@
+package req
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	urlpkg "net/url"
+	"strings"
+)
+
+// hopHeaders are the hop-by-hop headers which are removed when proxying.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// ReverseProxyOptions is the options of Client.ReverseProxy.
+type ReverseProxyOptions struct {
+	// StripPrefix is removed from the path of the incoming request before
+	// it's appended to the BaseURL of the client.
+	StripPrefix string
+	// Rewrite rewrites the path (after StripPrefix is removed) of the
+	// incoming request, e.g. "/users" to "/api/v2/users".
+	Rewrite func(path string) string
+	// PreserveHost sends the Host header of the incoming request to the
+	// upstream instead of the host of the BaseURL.
+	PreserveHost bool
+	// ModifyRequest is called before the request is sent to the upstream,
+	// which can be used to modify the request, e.g. set the auth.
+	ModifyRequest func(r *Request, in *http.Request)
+	// ErrorHandler is called when the request to the upstream fails, the
+	// default responds with 502 Bad Gateway, or 504 Gateway Timeout if the
+	// request is timeout.
+	ErrorHandler func(w http.ResponseWriter, in *http.Request, err error)
+}
+
+type reverseProxy struct {
+	client *Client
+	opts   ReverseProxyOptions
+}
+
+func defaultProxyErrorHandler(w http.ResponseWriter, in *http.Request, err error) {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+func (p *reverseProxy) upstreamURL(in *http.Request) string {
+	path := strings.TrimPrefix(in.URL.Path, p.opts.StripPrefix)
+	if p.opts.Rewrite != nil {
+		path = p.opts.Rewrite(path)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := p.client.BaseURL + (&urlpkg.URL{Path: path}).EscapedPath()
+	if in.URL.RawQuery != "" {
+		u += "?" + in.URL.RawQuery
+	}
+	return u
+}
+
+func (p *reverseProxy) ServeHTTP(w http.ResponseWriter, in *http.Request) {
+	if p.client.BaseURL == "" {
+		p.opts.ErrorHandler(w, in, errors.New("req: BaseURL of the client is not set for the reverse proxy"))
+		return
+	}
+	// the body is forwarded as is, so it must not be decoded to utf-8
+	ctx := context.WithValue(in.Context(), disableAutoDecodeKey{}, true)
+	r := p.client.R().SetContext(ctx).DisableAutoReadResponse()
+	r.Headers = in.Header.Clone()
+	removeHopHeaders(r.Headers)
+	if clientIP, _, err := net.SplitHostPort(in.RemoteAddr); err == nil {
+		if prior := r.Headers.Values("X-Forwarded-For"); len(prior) > 0 {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		r.Headers.Set("X-Forwarded-For", clientIP)
+	}
+	r.Headers.Set("X-Forwarded-Host", in.Host)
+	if in.TLS != nil {
+		r.Headers.Set("X-Forwarded-Proto", "https")
+	} else {
+		r.Headers.Set("X-Forwarded-Proto", "http")
+	}
+	if p.opts.PreserveHost {
+		r.Headers.Set("Host", in.Host)
+	}
+	if in.Body != nil && in.Body != http.NoBody && in.ContentLength != 0 {
+		r.SetBody(in.Body)
+		if in.ContentLength > 0 {
+			r.bodyLength = in.ContentLength
+		}
+		if r.retryOption != nil {
+			// the streamed body is not replayable
+			r.retryOption.MaxRetries = 0
+		}
+	}
+	if p.opts.ModifyRequest != nil {
+		p.opts.ModifyRequest(r, in)
+	}
+
+	resp, err := r.Send(in.Method, p.upstreamURL(in))
+	if resp.Response == nil {
+		p.opts.ErrorHandler(w, in, err)
+		return
+	}
+	header := w.Header()
+	for k, vv := range resp.Header {
+		header[k] = append([]string(nil), vv...)
+	}
+	removeHopHeaders(header)
+	if r.responseCharset != "" {
+		// the body is decoded to utf-8 with the forced charset
+		header.Del("Content-Length")
+		if ct := header.Get("Content-Type"); ct != "" {
+			if mediaType, params, err := mime.ParseMediaType(ct); err == nil {
+				params["charset"] = "utf-8"
+				header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+			}
+		}
+	}
+	if resp.body != nil {
+		// the body is read (e.g. by the error result), which may be
+		// transformed, so write it as is.
+		header.Del("Content-Length")
+		w.WriteHeader(resp.StatusCode)
+		w.Write(resp.body)
+		return
+	}
+	defer resp.Body.Close()
+	w.WriteHeader(resp.StatusCode)
+	copyResponseBody(w, resp.Body, resp.ContentLength == -1)
+}
+
+// copyResponseBody copies the body to the w, which is flushed after each
+// write if the body is streaming, e.g. server-sent events.
+func copyResponseBody(w http.ResponseWriter, body io.Reader, flush bool) {
+	flusher, _ := w.(http.Flusher)
+	if !flush || flusher == nil {
+		buf := getCopyBuf()
+		io.CopyBuffer(w, body, buf)
+		putCopyBuf(buf)
+		return
+	}
+	flusher.Flush()
+	buf := getCopyBuf()
+	defer putCopyBuf(buf)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ReverseProxy returns the http.Handler which proxies the incoming requests
+// to the BaseURL of the client with the path appended, so that the gateways
+// can reuse the retry, auth, tracing and other settings of the client. The
+// request and response bodies are streamed both ways, the hop-by-hop headers
+// are removed, and the X-Forwarded-For, X-Forwarded-Host and
+// X-Forwarded-Proto headers are set. The response body is not decoded to
+// utf-8 unless the charset is forced by Request.SetResponseCharset in
+// ModifyRequest. The requests with body are not
+// retried, as the streamed body is not replayable. The protocol upgrade
+// (e.g. WebSocket) is not supported. For example:
+//
+//	client := req.C().SetBaseURL("http://users.internal:8080").
+//		SetCommonBearerAuthToken(token).
+//		SetCommonRetryCount(2)
+//	http.Handle("/users/", client.ReverseProxy(&req.ReverseProxyOptions{
+//		StripPrefix: "/users",
+//		Rewrite: func(path string) string {
+//			return "/api/v2/users" + path
+//		},
+//	}))
+func (c *Client) ReverseProxy(opts ...*ReverseProxyOptions) http.Handler {
+	p := &reverseProxy{client: c}
+	if len(opts) > 0 && opts[0] != nil {
+		p.opts = *opts[0]
+	}
+	if p.opts.ErrorHandler == nil {
+		p.opts.ErrorHandler = defaultProxyErrorHandler
+	}
+	return p
+}
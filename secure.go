@@ -0,0 +1,98 @@
+package req
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInsecureConfig is returned in SecureModeStrict if the request is sent
+// with an insecure configuration.
+var ErrInsecureConfig = errors.New("req: insecure configuration")
+
+// SecureMode controls how insecure configurations are reported, see
+// SetSecureMode.
+type SecureMode int
+
+const (
+	// SecureModeOff disables the security checks (default).
+	SecureModeOff SecureMode = iota
+	// SecureModeWarn logs a warning for each insecure configuration.
+	SecureModeWarn
+	// SecureModeStrict fails the request with ErrInsecureConfig.
+	SecureModeStrict
+)
+
+// sensitiveRedirectHeaders are the headers which are not forwarded to
+// other domains on redirect by default.
+var sensitiveRedirectHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// SetSecureMode set the SecureMode for requests fired from the client, which
+// helps to enforce security policy on shared clients. The following are
+// considered insecure:
+//   - Sending https requests with InsecureSkipVerify enabled.
+//   - Sending credentials (Authorization header or userinfo of the url)
+//     over plain HTTP.
+//   - Forwarding sensitive headers (e.g. Authorization and Cookie) to other
+//     domains on redirect, e.g. with AlwaysCopyHeaderRedirectPolicy.
+//
+// SecureModeWarn logs a warning and SecureModeStrict fails the request
+// with ErrInsecureConfig.
+func (c *Client) SetSecureMode(mode SecureMode) *Client {
+	c.secureMode = mode
+	return c
+}
+
+func (c *Client) reportInsecure(format string, v ...interface{}) error {
+	switch c.secureMode {
+	case SecureModeWarn:
+		c.log.Warnf("insecure configuration: "+format, v...)
+	case SecureModeStrict:
+		return fmt.Errorf("%w: %s", ErrInsecureConfig, fmt.Sprintf(format, v...))
+	}
+	return nil
+}
+
+func checkSecureMode(c *Client, r *Request) error {
+	if c.secureMode == SecureModeOff || r.URL == nil {
+		return nil
+	}
+	switch r.URL.Scheme {
+	case "https":
+		if c.TLSClientConfig != nil && c.TLSClientConfig.InsecureSkipVerify {
+			return c.reportInsecure("InsecureSkipVerify is enabled for %s", r.URL.Host)
+		}
+	case "http":
+		if r.URL.User != nil || r.Headers.Get("Authorization") != "" {
+			return c.reportInsecure("credentials are sent over plain HTTP to %s", r.URL.Host)
+		}
+	}
+	return nil
+}
+
+func (c *Client) checkSecureRedirect(req *http.Request, via []*http.Request) error {
+	if c.secureMode == SecureModeOff || len(via) == 0 {
+		return nil
+	}
+	initial := via[0].URL.Hostname()
+	if host := req.URL.Hostname(); isDomainOrSubdomain(host, initial) {
+		return nil
+	}
+	for _, h := range sensitiveRedirectHeaders {
+		if req.Header.Get(h) != "" {
+			return c.reportInsecure("%s header is forwarded from %s to %s on redirect", h, initial, req.URL.Hostname())
+		}
+	}
+	return nil
+}
+
+// isDomainOrSubdomain reports whether sub is a subdomain (or exact match) of
+// the parent domain.
+func isDomainOrSubdomain(sub, parent string) bool {
+	sub, parent = strings.ToLower(sub), strings.ToLower(parent)
+	if sub == parent {
+		return true
+	}
+	return strings.HasSuffix(sub, "."+parent)
+}
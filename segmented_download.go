@@ -0,0 +1,140 @@
+package req
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3/internal/dump"
+)
+
+// defaultSegmentRetries is the retry count of each segment if retry is not
+// enabled for the request.
+const defaultSegmentRetries = 2
+
+// EnableSegmentedDownload enables parallel segmented download for the file
+// set by SetOutputFile. If the server supports Range (responds with
+// "Accept-Ranges: bytes" and a known Content-Length), the file is split
+// into the given number of segments which are downloaded concurrently into
+// a sparse file, each segment is retried independently (retry count of the
+// request is used if enabled, otherwise 2). It falls back to the normal
+// download if the server doesn't support Range.
+func (r *Request) EnableSegmentedDownload(segments int) *Request {
+	r.downloadSegments = segments
+	return r
+}
+
+func shouldDownloadSegments(resp *Response) bool {
+	r := resp.Request
+	return r.downloadSegments > 1 &&
+		r.resumeOffset == 0 &&
+		resp.body == nil &&
+		resp.StatusCode == http.StatusOK &&
+		resp.Header.Get("Accept-Ranges") == "bytes" &&
+		resp.ContentLength >= int64(r.downloadSegments)
+}
+
+// downloadSegments downloads the segments concurrently into the ".part"
+// file which is renamed to the output file on success, the first segment
+// reuses the body of the response.
+func downloadSegments(c *Client, resp *Response, body io.ReadCloser, filename string) error {
+	r := resp.Request
+	file, err := os.Create(partFilename(filename))
+	if err != nil {
+		return err
+	}
+
+	size := resp.ContentLength
+	if err = file.Truncate(size); err != nil {
+		return finishPartFile(file, filename, err, false)
+	}
+	n := int64(r.downloadSegments)
+	segmentSize := (size + n - 1) / n
+	if c.DebugLog {
+		c.log.Debugf("download %d bytes with %d segments of %d bytes", size, n, segmentSize)
+	}
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := int64(1); i < n; i++ {
+		start := i * segmentSize
+		if start >= size {
+			break
+		}
+		end := min(start+segmentSize, size) - 1
+		wg.Add(1)
+		go func(i, start, end int64) {
+			defer wg.Done()
+			errs[i] = downloadSegment(c, r, file, start, end)
+		}(i, start, end)
+	}
+
+	firstSize := min(segmentSize, size)
+	written, err := io.Copy(io.NewOffsetWriter(file, 0), io.LimitReader(body, firstSize))
+	body.Close()
+	if err != nil || written != firstSize {
+		errs[0] = downloadSegment(c, r, file, 0, firstSize-1)
+	}
+	wg.Wait()
+	resp.setReceivedAt()
+	err = errors.Join(errs...)
+	if err == nil {
+		err = r.downloadChecksum.verifyFile(file.Name())
+	}
+	return finishPartFile(file, filename, err, false)
+}
+
+func downloadSegment(c *Client, r *Request, file *os.File, start, end int64) (err error) {
+	// the request-level dumper is not shared with segments which are
+	// downloaded concurrently.
+	ctx := context.WithValue(r.Context(), dump.DumperKey, nil)
+	retries := defaultSegmentRetries
+	getRetryInterval := defaultGetRetryInterval
+	if ro := r.retryOption; ro != nil && ro.MaxRetries > 0 {
+		retries = ro.MaxRetries
+		getRetryInterval = ro.GetRetryInterval
+	}
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(getRetryInterval(&Response{Request: r}, attempt))
+		}
+		seg := c.R().SetContext(ctx).DisableAutoReadResponse()
+		seg.Headers = r.Headers.Clone()
+		seg.Headers.Del("If-Range")
+		seg.SetHeader("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		var resp *Response
+		if resp, err = seg.Get(r.URL.String()); err == nil {
+			err = writeSegment(resp, file, start, end)
+		}
+		if err == nil {
+			return nil
+		}
+		if c.DebugLog {
+			c.log.Debugf("download segment %d-%d failed (attempt %d): %v", start, end, attempt, err)
+		}
+	}
+	return
+}
+
+func writeSegment(resp *Response, file *os.File, start, end int64) error {
+	defer resp.Body.Close()
+	if cr := resp.Header.Get("Content-Range"); resp.StatusCode != http.StatusPartialContent || !rangeStartsAt(cr, start) {
+		return fmt.Errorf("failed to download segment %d-%d: unexpected response %s %q", start, end, resp.Status, cr)
+	}
+	size := end - start + 1
+	written, err := io.Copy(io.NewOffsetWriter(file, start), io.LimitReader(resp.Body, size))
+	if err == nil && written != size {
+		err = fmt.Errorf("failed to download segment %d-%d: %w", start, end, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+func rangeStartsAt(contentRange string, start int64) bool {
+	s, ok := parseContentRangeStart(contentRange)
+	return ok && s == start
+}
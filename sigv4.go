@@ -0,0 +1,215 @@
+package req
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	awsSigV4Algorithm       = "AWS4-HMAC-SHA256"
+	awsSigV4TimeFormat      = "20060102T150405Z"
+	awsSigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// awsSigV4Signer signs requests with AWS Signature Version 4.
+type awsSigV4Signer struct {
+	accessKey string
+	secretKey string
+	region    string
+	service   string
+	now       func() time.Time
+}
+
+func newAwsSigV4Signer(accessKey, secretKey, region, service string) *awsSigV4Signer {
+	return &awsSigV4Signer{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+		service:   service,
+		now:       time.Now,
+	}
+}
+
+// SetCommonAwsSigV4 signs requests fired from the client with AWS Signature
+// Version 4, which is done just before the request is sent, after all
+// middlewares have run, so the final url, headers and body are signed. The
+// payload is signed if the body is replayable, otherwise "UNSIGNED-PAYLOAD"
+// is used. For temporary credentials, set the session token with the
+// "X-Amz-Security-Token" header which is signed as well. For example:
+//
+//	client.SetCommonAwsSigV4(accessKey, secretKey, "us-east-1", "s3")
+func (c *Client) SetCommonAwsSigV4(accessKey, secretKey, region, service string) *Client {
+	c.awsSigV4 = newAwsSigV4Signer(accessKey, secretKey, region, service)
+	return c
+}
+
+// SetAwsSigV4 signs the request with AWS Signature Version 4, which
+// overrides the one set by Client.SetCommonAwsSigV4.
+func (r *Request) SetAwsSigV4(accessKey, secretKey, region, service string) *Request {
+	r.awsSigV4 = newAwsSigV4Signer(accessKey, secretKey, region, service)
+	return r
+}
+
+func (r *Request) getAwsSigV4Signer() *awsSigV4Signer {
+	if r.awsSigV4 != nil {
+		return r.awsSigV4
+	}
+	return r.client.awsSigV4
+}
+
+// sign sets the X-Amz-Date and Authorization headers of req with the hex
+// encoded SHA256 of the payload (or "UNSIGNED-PAYLOAD").
+func (s *awsSigV4Signer) sign(req *http.Request, payloadHash string) {
+	t := s.now().UTC()
+	amzDate := t.Format(awsSigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// send the path encoded the same way as the signed one, which is not
+	// the case for some characters encoded by url.URL (e.g. '+' and '=').
+	if req.URL.Path != "" {
+		u := *req.URL
+		u.RawPath = awsURIEncodePath(u.Path)
+		req.URL = &u
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": strings.TrimSpace(host)}
+	for k, vv := range req.Header {
+		k = strings.ToLower(k)
+		if k == "content-type" || k == "content-md5" || strings.HasPrefix(k, "x-amz-") {
+			values := make([]string, len(vv))
+			for i, v := range vv {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			headers[k] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	date := t.Format("20060102")
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		awsSigV4Algorithm,
+		amzDate,
+		scope,
+		hashSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", awsSigV4Algorithm+
+		" Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// canonicalURI returns the uri-encoded path, each segment is encoded once
+// for s3 and twice for the other services.
+func (s *awsSigV4Signer) canonicalURI(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	path := awsURIEncodePath(u.Path)
+	if s.service == "s3" {
+		return path
+	}
+	return awsURIEncodePath(path)
+}
+
+// awsURIEncodePath encodes each segment of the path with awsURIEncode.
+func awsURIEncodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = awsURIEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsURIEncode(k)+"="+awsURIEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode encodes every byte except the unreserved characters
+// (A-Z, a-z, 0-9, '-', '.', '_' and '~').
+func awsURIEncode(s string) string {
+	const hexChars = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexChars[c>>4])
+		b.WriteByte(hexChars[c&15])
+	}
+	return b.String()
+}
+
+// awsPayloadHash returns the payload hash of the request to be signed.
+func (r *Request) awsPayloadHash() string {
+	if r.Body != nil || r.GetBody == nil {
+		return hashSHA256(r.Body)
+	}
+	return awsSigV4UnsignedPayload
+}
+
+func hashSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
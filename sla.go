@@ -0,0 +1,298 @@
+package req
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSLACircuitOpen is returned when the request is rejected because the
+// SLA of the host is breached and the circuit is open, see Client.SetSLA.
+var ErrSLACircuitOpen = errors.New("req: circuit is open as the SLA of the host is breached")
+
+const (
+	defaultSLAWindowSize = 100
+	defaultSLAMinSamples = 20
+)
+
+// SLAFailure is the latency recorded for the failed requests (e.g. the
+// connection is refused), which always exceeds the p99 latency target.
+const SLAFailure = time.Duration(math.MaxInt64)
+
+// SLAState is the circuit state of a host with SLA, see Client.SetSLA.
+type SLAState int
+
+const (
+	// SLAStateClosed means the SLA is met, and requests are sent normally.
+	SLAStateClosed SLAState = iota
+	// SLAStateOpen means the SLA is breached, requests are rejected with
+	// ErrSLACircuitOpen if SLAOptions.OpenDuration is set.
+	SLAStateOpen
+	// SLAStateHalfOpen means the OpenDuration has elapsed, and a single
+	// probe request is allowed to check whether the host recovers.
+	SLAStateHalfOpen
+)
+
+// String returns the name of the state.
+func (s SLAState) String() string {
+	switch s {
+	case SLAStateClosed:
+		return "closed"
+	case SLAStateOpen:
+		return "open"
+	case SLAStateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// SLAEvent is passed to SLAOptions.OnStateChange when the circuit state of
+// a host changes.
+type SLAEvent struct {
+	// Host is the host passed to Client.SetSLA.
+	Host string
+	// Target is the p99 latency target of the host.
+	Target time.Duration
+	// P99 is the rolling p99 latency (or the latency of the probe request
+	// in half-open state) which causes the transition, it's SLAFailure if
+	// the transition is caused by the failed requests.
+	P99  time.Duration
+	From SLAState
+	To   SLAState
+}
+
+// SLAOptions is the options of Client.SetSLA.
+type SLAOptions struct {
+	// WindowSize is the number of the most recent latencies used to
+	// calculate the p99 latency, default is 100.
+	WindowSize int
+	// MinSamples is the minimum number of latencies before the SLA is
+	// checked, default is 20.
+	MinSamples int
+	// OpenDuration is how long requests to the host are rejected with
+	// ErrSLACircuitOpen after the SLA is breached, then a single probe
+	// request is allowed, the circuit is closed if its latency meets the
+	// target, otherwise it's opened again. If it's zero, requests are never
+	// rejected, and the circuit is closed once the rolling p99 latency
+	// meets the target again.
+	OpenDuration time.Duration
+	// OnStateChange is called when the circuit state of the host changes.
+	OnStateChange func(e SLAEvent)
+}
+
+// slaTracker tracks the rolling latency of a host.
+type slaTracker struct {
+	host   string
+	target time.Duration
+	opts   SLAOptions
+
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	state    SLAState
+	openedAt time.Time
+	probing  bool
+}
+
+func newSLATracker(host string, target time.Duration, opts SLAOptions) *slaTracker {
+	if opts.WindowSize <= 0 {
+		opts.WindowSize = defaultSLAWindowSize
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = defaultSLAMinSamples
+	}
+	if opts.MinSamples > opts.WindowSize {
+		opts.MinSamples = opts.WindowSize
+	}
+	return &slaTracker{
+		host:    host,
+		target:  target,
+		opts:    opts,
+		samples: make([]time.Duration, 0, opts.WindowSize),
+	}
+}
+
+func (t *slaTracker) transit(to SLAState, p99 time.Duration, events []SLAEvent) []SLAEvent {
+	if t.state == to {
+		return events
+	}
+	e := SLAEvent{Host: t.host, Target: t.target, P99: p99, From: t.state, To: to}
+	t.state = to
+	return append(events, e)
+}
+
+func (t *slaTracker) notify(events []SLAEvent) {
+	if t.opts.OnStateChange == nil {
+		return
+	}
+	for _, e := range events {
+		t.opts.OnStateChange(e)
+	}
+}
+
+// allow reports whether the request can be sent to the host, it returns
+// ErrSLACircuitOpen if the circuit is open.
+func (t *slaTracker) allow(now time.Time) error {
+	if t.opts.OpenDuration <= 0 {
+		return nil
+	}
+	var events []SLAEvent
+	t.mu.Lock()
+	err := func() error {
+		switch t.state {
+		case SLAStateOpen:
+			if now.Sub(t.openedAt) < t.opts.OpenDuration {
+				return ErrSLACircuitOpen
+			}
+			events = t.transit(SLAStateHalfOpen, 0, events)
+		case SLAStateHalfOpen:
+			if t.probing {
+				return ErrSLACircuitOpen
+			}
+		default:
+			return nil
+		}
+		t.probing = true
+		return nil
+	}()
+	t.mu.Unlock()
+	t.notify(events)
+	return err
+}
+
+// release releases the probe allowed by allow if the request is not sent,
+// so that another request can probe the host.
+func (t *slaTracker) release() {
+	t.mu.Lock()
+	t.probing = false
+	t.mu.Unlock()
+}
+
+// record records the latency of a request to the host, the failed request
+// is recorded as SLAFailure rather than its latency.
+func (t *slaTracker) record(latency time.Duration, failed bool, now time.Time) {
+	if failed {
+		latency = SLAFailure
+	}
+	var events []SLAEvent
+	t.mu.Lock()
+	if t.state == SLAStateHalfOpen && t.probing {
+		t.probing = false
+		if latency <= t.target {
+			t.samples = t.samples[:0]
+			t.next = 0
+			events = t.transit(SLAStateClosed, latency, events)
+		} else {
+			t.openedAt = now
+			events = t.transit(SLAStateOpen, latency, events)
+		}
+		t.mu.Unlock()
+		t.notify(events)
+		return
+	}
+	if len(t.samples) < t.opts.WindowSize {
+		t.samples = append(t.samples, latency)
+	} else {
+		t.samples[t.next] = latency
+		t.next = (t.next + 1) % t.opts.WindowSize
+	}
+	if len(t.samples) >= t.opts.MinSamples {
+		p99 := t.p99()
+		switch {
+		case t.state == SLAStateClosed && p99 > t.target:
+			t.openedAt = now
+			events = t.transit(SLAStateOpen, p99, events)
+		case t.state == SLAStateOpen && t.opts.OpenDuration <= 0 && p99 <= t.target:
+			events = t.transit(SLAStateClosed, p99, events)
+		}
+	}
+	t.mu.Unlock()
+	t.notify(events)
+}
+
+// setHealthy opens the circuit when the host becomes unhealthy, and closes
+// it when the host recovers, which is reported by the health check.
+func (t *slaTracker) setHealthy(healthy bool, now time.Time) {
+	var events []SLAEvent
+	t.mu.Lock()
+	if healthy {
+		if t.state != SLAStateClosed {
+			t.samples = t.samples[:0]
+			t.next = 0
+			t.probing = false
+			events = t.transit(SLAStateClosed, 0, events)
+		}
+	} else if t.state != SLAStateOpen {
+		t.openedAt = now
+		t.probing = false
+		events = t.transit(SLAStateOpen, 0, events)
+	}
+	t.mu.Unlock()
+	t.notify(events)
+}
+
+// p99 returns the p99 latency of the samples (nearest-rank method).
+func (t *slaTracker) p99() time.Duration {
+	sorted := append([]time.Duration(nil), t.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (len(sorted)*99 + 99) / 100
+	return sorted[rank-1]
+}
+
+// SetSLA set the p99 latency target of the host (port is ignored), the
+// latency (until the response header is received) of requests to the host
+// fired from the client is tracked in a rolling window, and the circuit of
+// the host is opened when the rolling p99 latency exceeds the target, which
+// calls SLAOptions.OnStateChange and rejects requests with ErrSLACircuitOpen
+// for SLAOptions.OpenDuration if it's set. For example:
+//
+//	client.SetSLA("api.example.com", 300*time.Millisecond, &req.SLAOptions{
+//		OpenDuration: 10 * time.Second,
+//		OnStateChange: func(e req.SLAEvent) {
+//			log.Printf("%s: %s -> %s (p99 %s)", e.Host, e.From, e.To, e.P99)
+//		},
+//	})
+func (c *Client) SetSLA(host string, p99Target time.Duration, opts ...*SLAOptions) *Client {
+	if p99Target <= 0 {
+		c.log.Warnf("ignore SetSLA for %s with non-positive p99 target %v", host, p99Target)
+		return c
+	}
+	var o SLAOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	host = strings.ToLower(removeHostPort(host))
+	slas := make(map[string]*slaTracker, len(c.slas)+1)
+	for k, v := range c.slas {
+		slas[k] = v
+	}
+	slas[host] = newSLATracker(host, p99Target, o)
+	c.slas = slas
+	return c
+}
+
+// RemoveSLA removes the SLA of the host set by SetSLA.
+func (c *Client) RemoveSLA(host string) *Client {
+	host = strings.ToLower(removeHostPort(host))
+	if _, ok := c.slas[host]; !ok {
+		return c
+	}
+	slas := make(map[string]*slaTracker, len(c.slas))
+	for k, v := range c.slas {
+		if k != host {
+			slas[k] = v
+		}
+	}
+	c.slas = slas
+	return c
+}
+
+func (c *Client) getSLATracker(host string) *slaTracker {
+	if len(c.slas) == 0 {
+		return nil
+	}
+	return c.slas[strings.ToLower(removeHostPort(host))]
+}
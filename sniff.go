@@ -0,0 +1,93 @@
+package req
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/imroc/req/v3/internal/util"
+)
+
+// ErrUnexpectedContentType is matched (with errors.Is) by the
+// *ContentTypeMismatchError returned when the content type sniffing is
+// enabled and the response body doesn't match the declared Content-Type.
+var ErrUnexpectedContentType = errors.New("req: unexpected content type")
+
+// ContentTypeMismatchError is returned if the response body doesn't match
+// the declared Content-Type, e.g. an HTML page of the captive portal or
+// proxy is returned with the "application/json" Content-Type. It's only
+// returned if EnableContentTypeSniffing is called.
+type ContentTypeMismatchError struct {
+	// Declared is the Content-Type of the response.
+	Declared string
+	// Detected is the sniffed type of the body, "json", "xml" or "html".
+	Detected string
+}
+
+func (e *ContentTypeMismatchError) Error() string {
+	return fmt.Sprintf("req: unexpected content type: declared %q, but the body looks like %s", e.Declared, e.Detected)
+}
+
+// Is makes errors.Is(err, ErrUnexpectedContentType) true.
+func (e *ContentTypeMismatchError) Is(target error) bool {
+	return target == ErrUnexpectedContentType
+}
+
+// sniffContentType returns the type of the body, "json", "xml", "html" or
+// "" if it's unknown.
+func sniffContentType(body []byte) string {
+	body = bytes.TrimPrefix(body, utf8BOM)
+	body = bytes.TrimLeft(body, " \t\r\n")
+	if len(body) == 0 {
+		return ""
+	}
+	switch body[0] {
+	case '{', '[':
+		return "json"
+	case '<':
+		if strings.HasPrefix(http.DetectContentType(body), "text/html") {
+			return "html"
+		}
+		return "xml"
+	}
+	return ""
+}
+
+func checkContentType(r *Response) error {
+	declared := r.GetContentType()
+	if r.body == nil || declared == "" || strings.Contains(declared, "html") {
+		return nil
+	}
+	detected := sniffContentType(r.body)
+	if detected == "" {
+		return nil
+	}
+	if util.IsJSONType(declared) && detected != "json" ||
+		util.IsXMLType(declared) && detected != "xml" {
+		return &ContentTypeMismatchError{
+			Declared: declared,
+			Detected: detected,
+		}
+	}
+	return nil
+}
+
+// EnableContentTypeSniffing enables the sniffing of the response body,
+// the response fails with *ContentTypeMismatchError (which matches
+// ErrUnexpectedContentType) if the JSON or XML Content-Type is declared but
+// the body looks like something else, e.g. an HTML page of the captive
+// portal or proxy, so that it's caught early rather than failed with
+// obscure unmarshal errors. Only the automatically read body is sniffed.
+func (c *Client) EnableContentTypeSniffing() *Client {
+	c.contentTypeSniffing = true
+	return c
+}
+
+// DisableContentTypeSniffing disables the sniffing of the response body
+// (disabled by default).
+func (c *Client) DisableContentTypeSniffing() *Client {
+	c.contentTypeSniffing = false
+	return c
+}
@@ -0,0 +1,120 @@
+package req
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnel dials connections through the SSH jump host, the SSH connection
+// is established on the first dial, shared by all the tunneled connections,
+// and re-established once it's broken.
+type sshTunnel struct {
+	addr   string
+	config *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+func (t *sshTunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.addr, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(c, chans, reqs)
+	t.client = client
+	go func() {
+		client.Wait()
+		t.mu.Lock()
+		if t.client == client {
+			t.client = nil
+		}
+		t.mu.Unlock()
+	}()
+	return client, nil
+}
+
+func (t *sshTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh jump host %s: %w", t.addr, err)
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s via ssh jump host %s: %w", addr, t.addr, err)
+	}
+	return conn, nil
+}
+
+func sshAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(host, "22")
+	}
+	return host
+}
+
+// SetSSHTunnel set the client to dial connections through the SSH jump
+// host (port 22 is used if not specified), so that the servers in private
+// networks can be reached, the host key of the jump host is verified with
+// ~/.ssh/known_hosts, use SetSSHTunnelConfig for the custom verification.
+// The SSH connection is established on the first request and shared by all
+// requests, it's re-established once broken. HTTP3 is not supported as
+// it's over UDP. For example:
+//
+//	key, _ := os.ReadFile("/home/roc/.ssh/id_ed25519")
+//	signer, _ := ssh.ParsePrivateKey(key)
+//	client.SetSSHTunnel("roc", "bastion.example.com", ssh.PublicKeys(signer))
+func (c *Client) SetSSHTunnel(user, host string, auth ...ssh.AuthMethod) *Client {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		c.log.Errorf("failed to locate known_hosts file: %v", err)
+		return c
+	}
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		c.log.Errorf("failed to load known_hosts file: %v", err)
+		return c
+	}
+	return c.SetSSHTunnelConfig(host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+}
+
+// SetSSHTunnelConfig is like SetSSHTunnel, but with the ssh.ClientConfig
+// which specifies the user, auth methods and host key verification.
+func (c *Client) SetSSHTunnelConfig(host string, config *ssh.ClientConfig) *Client {
+	if config == nil {
+		c.log.Warnf("ignore nil ssh config in SetSSHTunnelConfig")
+		return c
+	}
+	t := &sshTunnel{
+		addr:   sshAddr(host),
+		config: config,
+	}
+	return c.SetDial(t.DialContext)
+}
@@ -0,0 +1,183 @@
+package req
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HostConnStats is the connection counts of a host in TransportStats.
+type HostConnStats struct {
+	// Open is the number of the open connections, including the idle ones.
+	Open int
+	// Idle is the number of the idle HTTP1 connections in the pool.
+	Idle int
+}
+
+// TransportStats is the metrics of the connections of the client, see
+// Client.EnableTransportStats.
+type TransportStats struct {
+	// Hosts is the connection counts keyed by the dialed address
+	// ("host:port", which is the address of the proxy if the requests are
+	// sent via the proxy).
+	Hosts map[string]HostConnStats
+	// Dials is the number of the dials, including the failed ones.
+	Dials int64
+	// DialFailures is the number of the failed dials.
+	DialFailures int64
+	// NewConns is the number of the requests sent on the new connections.
+	NewConns int64
+	// ReusedConns is the number of the requests sent on the reused
+	// connections.
+	ReusedConns int64
+	// BytesRead is the number of bytes read from the connections.
+	BytesRead int64
+	// BytesWritten is the number of bytes written to the connections.
+	BytesWritten int64
+}
+
+type transportStats struct {
+	dials        int64
+	dialFailures int64
+	newConns     int64
+	reusedConns  int64
+	bytesRead    int64
+	bytesWritten int64
+
+	mu   sync.Mutex
+	open map[string]int
+	// callbackStop stops the goroutine of OnTransportStats, guarded by mu.
+	callbackStop chan struct{}
+}
+
+func newTransportStats() *transportStats {
+	return &transportStats{open: make(map[string]int)}
+}
+
+// clone returns the empty stats for the cloned transport.
+func (s *transportStats) clone() *transportStats {
+	if s == nil {
+		return nil
+	}
+	return newTransportStats()
+}
+
+func (s *transportStats) opened(addr string) {
+	atomic.AddInt64(&s.dials, 1)
+	s.mu.Lock()
+	s.open[addr]++
+	s.mu.Unlock()
+}
+
+func (s *transportStats) closed(addr string) {
+	s.mu.Lock()
+	if s.open[addr] <= 1 {
+		delete(s.open, addr)
+	} else {
+		s.open[addr]--
+	}
+	s.mu.Unlock()
+}
+
+func (t *Transport) countDialFailure() {
+	if s := t.stats; s != nil {
+		atomic.AddInt64(&s.dials, 1)
+		atomic.AddInt64(&s.dialFailures, 1)
+	}
+}
+
+// EnableTransportStats enables collecting the metrics of the connections,
+// see Client.EnableTransportStats.
+func (t *Transport) EnableTransportStats() *Transport {
+	if t.stats == nil {
+		t.stats = newTransportStats()
+	}
+	return t
+}
+
+// TransportStats returns the metrics of the connections, the zero value is
+// returned if EnableTransportStats is not called.
+func (t *Transport) TransportStats() TransportStats {
+	s := t.stats
+	if s == nil {
+		return TransportStats{}
+	}
+	stats := TransportStats{
+		Hosts:        make(map[string]HostConnStats),
+		Dials:        atomic.LoadInt64(&s.dials),
+		DialFailures: atomic.LoadInt64(&s.dialFailures),
+		NewConns:     atomic.LoadInt64(&s.newConns),
+		ReusedConns:  atomic.LoadInt64(&s.reusedConns),
+		BytesRead:    atomic.LoadInt64(&s.bytesRead),
+		BytesWritten: atomic.LoadInt64(&s.bytesWritten),
+	}
+	s.mu.Lock()
+	for addr, n := range s.open {
+		stats.Hosts[addr] = HostConnStats{Open: n}
+	}
+	s.mu.Unlock()
+	t.idleMu.Lock()
+	for key, conns := range t.idleConn {
+		h := stats.Hosts[key.addr]
+		h.Idle += len(conns)
+		stats.Hosts[key.addr] = h
+	}
+	t.idleMu.Unlock()
+	return stats
+}
+
+// EnableTransportStats enables collecting the metrics of the connections
+// of the client, which is much lighter than the dump and trace, e.g. the
+// open and idle connections per host, dials, reused connections and the
+// bytes read and written, use TransportStats to get them, or
+// OnTransportStats to report them periodically, which helps to diagnose
+// the connection pool exhaustion. The connections established before it's
+// enabled are not counted, and HTTP3 connections are not included as
+// they're over UDP.
+func (c *Client) EnableTransportStats() *Client {
+	c.Transport.EnableTransportStats()
+	return c
+}
+
+// OnTransportStats enables the transport stats (see EnableTransportStats),
+// and calls the fn with the stats every interval in the background, the
+// previous fn is stopped, and the nil fn stops reporting. For example:
+//
+//	client.OnTransportStats(time.Minute, func(stats req.TransportStats) {
+//		for host, h := range stats.Hosts {
+//			log.Printf("%s: %d open, %d idle", host, h.Open, h.Idle)
+//		}
+//	})
+func (c *Client) OnTransportStats(interval time.Duration, fn func(stats TransportStats)) *Client {
+	t := c.Transport
+	t.EnableTransportStats()
+	s := t.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.callbackStop != nil {
+		close(s.callbackStop)
+		s.callbackStop = nil
+	}
+	if fn == nil {
+		return c
+	}
+	if interval <= 0 {
+		c.log.Warnf("ignore OnTransportStats with non-positive interval %v", interval)
+		return c
+	}
+	stop := make(chan struct{})
+	s.callbackStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn(t.TransportStats())
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return c
+}
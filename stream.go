@@ -0,0 +1,206 @@
+package req
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// StreamMessage is a message decoded from the streaming response body.
+type StreamMessage struct {
+	// Header is the MIME header of the part for multipart streams, or the
+	// fields of the server-sent event ("Event", "Id" and "Retry").
+	Header http.Header
+	// Data is the content of the message.
+	Data []byte
+}
+
+// StreamHandler handles the message decoded from the streaming response
+// body, return io.EOF to stop reading the stream without error, or other
+// errors to abort the stream with the error.
+type StreamHandler func(msg *StreamMessage) error
+
+// StreamDecoder decodes the streaming response body (resp.Body) and calls
+// the handler with each message until the body ends or the handler returns
+// an error.
+type StreamDecoder func(resp *Response, handler StreamHandler) error
+
+var defaultStreamDecoders = map[string]StreamDecoder{
+	"text/event-stream":         decodeEventStream,
+	"application/x-ndjson":      decodeNDJSON,
+	"application/jsonl":         decodeNDJSON,
+	"multipart/x-mixed-replace": decodeMultipartStream,
+}
+
+func readStreamLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return bytes.TrimRight(line, "\r\n"), err
+}
+
+// decodeEventStream decodes the server-sent events, see
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+func decodeEventStream(resp *Response, handler StreamHandler) error {
+	br := bufio.NewReader(resp.Body)
+	header := make(http.Header)
+	var data [][]byte
+	first := true
+	for {
+		line, err := readStreamLine(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if first {
+			line = bytes.TrimPrefix(line, utf8BOM)
+			first = false
+		}
+		if len(line) == 0 { // dispatch the event
+			if len(data) > 0 {
+				msg := &StreamMessage{Header: header, Data: bytes.Join(data, []byte("\n"))}
+				if err = handler(msg); err != nil {
+					return err
+				}
+			}
+			header = make(http.Header)
+			data = nil
+			continue
+		}
+		if line[0] == ':' { // comment
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "data":
+			data = append(data, value)
+		case "event", "id", "retry":
+			header.Set(string(field), string(value))
+		}
+	}
+}
+
+// decodeNDJSON decodes the newline delimited JSON, each non-empty line is
+// a message.
+func decodeNDJSON(resp *Response, handler StreamHandler) error {
+	br := bufio.NewReader(resp.Body)
+	for {
+		line, err := readStreamLine(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err = handler(&StreamMessage{Header: make(http.Header), Data: line}); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeMultipartStream decodes the multipart stream, e.g. the MJPEG
+// stream of multipart/x-mixed-replace, each part is a message.
+func decodeMultipartStream(resp *Response, handler StreamHandler) error {
+	_, params, err := mime.ParseMediaType(resp.GetContentType())
+	if err != nil {
+		return err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return errors.New("no boundary in the multipart Content-Type")
+	}
+	mr := multipart.NewReader(resp.Body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		if err = handler(&StreamMessage{Header: http.Header(part.Header), Data: data}); err != nil {
+			return err
+		}
+	}
+}
+
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+func (c *Client) getStreamDecoder(contentType string) StreamDecoder {
+	mt := mediaType(contentType)
+	if decoder, ok := c.streamDecoders[mt]; ok {
+		return decoder
+	}
+	return defaultStreamDecoders[mt]
+}
+
+// isStreamResponse returns true if the response body is consumed by the
+// stream decoder rather than read automatically.
+func (c *Client) isStreamResponse(resp *Response) bool {
+	return resp.Request.streamHandler != nil && c.getStreamDecoder(resp.GetContentType()) != nil
+}
+
+func handleStream(c *Client, r *Response) (err error) {
+	if r.Response == nil || !c.isStreamResponse(r) {
+		return nil
+	}
+	defer r.Body.Close()
+	err = c.getStreamDecoder(r.GetContentType())(r, r.Request.streamHandler)
+	r.setReceivedAt()
+	r.body = []byte{} // the body is consumed by the stream handler
+	if err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// RegisterStreamDecoder registers the decoder of the streaming response
+// body with the content type (e.g. "application/x-protobuf-stream"), which
+// takes over the body consumption of the requests with the stream handler
+// (see Request.SetStreamHandler). The built-in decoders of
+// "text/event-stream" (server-sent events), "application/x-ndjson",
+// "application/jsonl" and "multipart/x-mixed-replace" can be overridden,
+// and the nil decoder disables the stream decoding of the content type.
+func (c *Client) RegisterStreamDecoder(contentType string, decoder StreamDecoder) *Client {
+	decoders := make(map[string]StreamDecoder, len(c.streamDecoders)+1)
+	for k, v := range c.streamDecoders {
+		decoders[k] = v
+	}
+	decoders[mediaType(contentType)] = decoder
+	c.streamDecoders = decoders
+	return c
+}
+
+// SetStreamHandler set the handler of the streaming response body, if the
+// Content-Type of the response has a registered stream decoder (see
+// Client.RegisterStreamDecoder), the body is not read automatically, it's
+// decoded as the stream and the handler is called with each message
+// before the request returns. For example:
+//
+//	client.R().SetStreamHandler(func(msg *req.StreamMessage) error {
+//		fmt.Println(msg.Header.Get("Event"), string(msg.Data))
+//		return nil
+//	}).Get("https://api.example.com/events")
+func (r *Request) SetStreamHandler(handler StreamHandler) *Request {
+	r.streamHandler = handler
+	return r
+}
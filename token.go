@@ -0,0 +1,126 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3/internal/header"
+)
+
+// TokenProvider provides the bearer token which is injected into the
+// Authorization header of requests, see Client.SetCommonTokenProvider.
+type TokenProvider interface {
+	// Token returns the access token, refresh is true if the previous
+	// token is rejected by the server (401 Unauthorized), and a new token
+	// should be fetched instead of the cached one.
+	Token(ctx context.Context, refresh bool) (string, error)
+}
+
+// TokenProviderFunc is a function which implements TokenProvider.
+type TokenProviderFunc func(ctx context.Context, refresh bool) (string, error)
+
+// Token implements TokenProvider.
+func (f TokenProviderFunc) Token(ctx context.Context, refresh bool) (string, error) {
+	return f(ctx, refresh)
+}
+
+// tokenRefreshSkew is how long before expiry the cached token is refreshed.
+const tokenRefreshSkew = 10 * time.Second
+
+type cachedTokenProvider struct {
+	mu     sync.Mutex
+	fetch  func(ctx context.Context) (token string, expiry time.Time, err error)
+	token  string
+	expiry time.Time
+}
+
+// NewCachedTokenProvider creates a TokenProvider which caches the token
+// returned by fetch, and transparently refreshes it before it expires (a
+// zero expiry means never expire) or when it's rejected by the server.
+// For example, with golang.org/x/oauth2:
+//
+//	ts := conf.TokenSource(ctx, tok)
+//	client.SetCommonTokenProvider(req.NewCachedTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+//		t, err := ts.Token()
+//		if err != nil {
+//			return "", time.Time{}, err
+//		}
+//		return t.AccessToken, t.Expiry, nil
+//	}))
+func NewCachedTokenProvider(fetch func(ctx context.Context) (token string, expiry time.Time, err error)) TokenProvider {
+	return &cachedTokenProvider{fetch: fetch}
+}
+
+func (p *cachedTokenProvider) Token(ctx context.Context, refresh bool) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !refresh && p.token != "" && (p.expiry.IsZero() || p.expiry.Sub(nowFromContext(ctx)) > tokenRefreshSkew) {
+		return p.token, nil
+	}
+	token, expiry, err := p.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.token, p.expiry = token, expiry
+	return token, nil
+}
+
+const (
+	tokenAuthInitial = iota
+	tokenAuthRefresh
+	tokenAuthRefreshed
+)
+
+// SetCommonTokenProvider set the TokenProvider for requests fired from the
+// client, the token is injected into the Authorization header as a bearer
+// token for each attempt, and the request is re-authenticated with a
+// refreshed token and resent once if the server responds with 401.
+func (c *Client) SetCommonTokenProvider(provider TokenProvider) *Client {
+	c.tokenProvider = provider
+	return c
+}
+
+// SetTokenProvider set the TokenProvider for the request, which overrides
+// the one set by Client.SetCommonTokenProvider.
+func (r *Request) SetTokenProvider(provider TokenProvider) *Request {
+	r.tokenProvider = provider
+	return r
+}
+
+func (r *Request) getTokenProvider() TokenProvider {
+	if r.tokenProvider != nil {
+		return r.tokenProvider
+	}
+	return r.client.tokenProvider
+}
+
+func handleTokenAuth(c *Client, r *Request) error {
+	provider := r.getTokenProvider()
+	if provider == nil {
+		return nil
+	}
+	refresh := r.tokenAuthState == tokenAuthRefresh
+	if refresh {
+		r.tokenAuthState = tokenAuthRefreshed
+	}
+	ctx := r.Context()
+	if c.nowFunc != nil {
+		ctx = context.WithValue(ctx, nowFuncKey{}, c.nowFunc)
+	}
+	token, err := provider.Token(ctx, refresh)
+	if err != nil {
+		return err
+	}
+	r.Headers.Set(header.Authorization, "Bearer "+token)
+	return nil
+}
+
+// shouldReauthenticate reports whether the request should be resent with
+// a refreshed token.
+func (r *Request) shouldReauthenticate(resp *Response, err error) bool {
+	return err == nil && resp.Response != nil && resp.StatusCode == http.StatusUnauthorized &&
+		r.tokenAuthState == tokenAuthInitial && r.unReplayableBody == nil &&
+		r.getTokenProvider() != nil
+}
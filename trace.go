@@ -3,6 +3,7 @@ package req
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http/httptrace"
@@ -102,6 +103,91 @@ type TraceInfo struct {
 
 	// RemoteAddr returns the remote network address.
 	RemoteAddr net.Addr
+
+	// LocalAddr returns the local network address.
+	LocalAddr net.Addr
+
+	// Protocol is the protocol negotiated with the server, e.g. "HTTP/1.1",
+	// "HTTP/2.0" or "HTTP/3.0", empty if no response is received.
+	Protocol string
+}
+
+// traceAddr is the net.Addr unmarshalled from the JSON of TraceInfo.
+type traceAddr string
+
+func (a traceAddr) Network() string { return "tcp" }
+func (a traceAddr) String() string  { return string(a) }
+
+// traceInfoJSON is the JSON form of TraceInfo, the durations are in
+// nanoseconds and the addresses are strings.
+type traceInfoJSON struct {
+	DNSLookupTime     time.Duration `json:"dns_lookup_time"`
+	ConnectTime       time.Duration `json:"connect_time"`
+	TCPConnectTime    time.Duration `json:"tcp_connect_time"`
+	TLSHandshakeTime  time.Duration `json:"tls_handshake_time"`
+	FirstResponseTime time.Duration `json:"first_response_time"`
+	ResponseTime      time.Duration `json:"response_time"`
+	TotalTime         time.Duration `json:"total_time"`
+	IsConnReused      bool          `json:"is_conn_reused"`
+	IsConnWasIdle     bool          `json:"is_conn_was_idle"`
+	ConnIdleTime      time.Duration `json:"conn_idle_time"`
+	RemoteAddr        string        `json:"remote_addr,omitempty"`
+	LocalAddr         string        `json:"local_addr,omitempty"`
+	Protocol          string        `json:"protocol,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, the durations are in nanoseconds
+// and the addresses are strings, so that it can be shipped to the logs or
+// metrics pipelines and aggregated.
+func (t TraceInfo) MarshalJSON() ([]byte, error) {
+	j := traceInfoJSON{
+		DNSLookupTime:     t.DNSLookupTime,
+		ConnectTime:       t.ConnectTime,
+		TCPConnectTime:    t.TCPConnectTime,
+		TLSHandshakeTime:  t.TLSHandshakeTime,
+		FirstResponseTime: t.FirstResponseTime,
+		ResponseTime:      t.ResponseTime,
+		TotalTime:         t.TotalTime,
+		IsConnReused:      t.IsConnReused,
+		IsConnWasIdle:     t.IsConnWasIdle,
+		ConnIdleTime:      t.ConnIdleTime,
+		Protocol:          t.Protocol,
+	}
+	if t.RemoteAddr != nil {
+		j.RemoteAddr = t.RemoteAddr.String()
+	}
+	if t.LocalAddr != nil {
+		j.LocalAddr = t.LocalAddr.String()
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *TraceInfo) UnmarshalJSON(data []byte) error {
+	var j traceInfoJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*t = TraceInfo{
+		DNSLookupTime:     j.DNSLookupTime,
+		ConnectTime:       j.ConnectTime,
+		TCPConnectTime:    j.TCPConnectTime,
+		TLSHandshakeTime:  j.TLSHandshakeTime,
+		FirstResponseTime: j.FirstResponseTime,
+		ResponseTime:      j.ResponseTime,
+		TotalTime:         j.TotalTime,
+		IsConnReused:      j.IsConnReused,
+		IsConnWasIdle:     j.IsConnWasIdle,
+		ConnIdleTime:      j.ConnIdleTime,
+		Protocol:          j.Protocol,
+	}
+	if j.RemoteAddr != "" {
+		t.RemoteAddr = traceAddr(j.RemoteAddr)
+	}
+	if j.LocalAddr != "" {
+		t.LocalAddr = traceAddr(j.LocalAddr)
+	}
+	return nil
 }
 
 type clientTrace struct {
@@ -115,6 +201,7 @@ type clientTrace struct {
 	gotFirstResponseByte time.Time
 	endTime              time.Time
 	gotConnInfo          httptrace.GotConnInfo
+	protocol             string
 }
 
 func (t *clientTrace) createContext(ctx context.Context) context.Context {
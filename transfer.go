@@ -308,9 +308,12 @@ func (t *transferWriter) writeBody(w io.Writer, dumps []*dump.Dumper) (err error
 	}()
 
 	rw := w // raw writer
+	var filters []*dump.BodyFilter
 	for _, dump := range dumps {
 		if dump.RequestBody() {
-			w = dump.WrapRequestBodyWriter(w)
+			filter := dump.NewBodyFilter(t.Header, t.ContentLength)
+			filters = append(filters, filter)
+			w = dump.WrapRequestBodyWriter(w, filter)
 		}
 	}
 
@@ -327,7 +330,7 @@ func (t *transferWriter) writeBody(w io.Writer, dumps []*dump.Dumper) (err error
 			cw := internal.NewChunkedWriter(rw)
 			for _, dump := range dumps {
 				if dump.RequestBody() {
-					cw = dump.WrapRequestBodyWriteCloser(cw)
+					cw = dump.WrapRequestBodyWriteCloser(cw, dump.NewBodyFilter(t.Header, t.ContentLength))
 				}
 			}
 			_, err = t.doBodyCopy(cw, body)
@@ -340,6 +343,16 @@ func (t *transferWriter) writeBody(w io.Writer, dumps []*dump.Dumper) (err error
 				dst = bufioFlushWriter{dst}
 			}
 			ncopy, err = t.doBodyCopy(dst, body)
+			if err == nil {
+				// the size of the omitted body is known once it's copied.
+				i := 0
+				for _, dump := range dumps {
+					if dump.RequestBody() {
+						dump.DumpRequestBody(filters[i].Finish())
+						i++
+					}
+				}
+			}
 		} else {
 			ncopy, err = t.doBodyCopy(w, io.LimitReader(body, t.ContentLength))
 			if err != nil {
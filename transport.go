@@ -29,6 +29,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/imroc/req/v3/http2"
@@ -47,6 +48,7 @@ import (
 	"github.com/imroc/req/v3/pkg/altsvc"
 	reqtls "github.com/imroc/req/v3/pkg/tls"
 	htmlcharset "golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/ianaindex"
 
 	"golang.org/x/net/http/httpguts"
@@ -104,7 +106,15 @@ const defaultMaxIdleConnsPerHost = 2
 // request is treated as idempotent but the header is not sent on the
 // wire.
 type Transport struct {
+	// Headers is the common headers of the requests fired from the clients
+	// using the Transport, which have lower priority than Client.Headers.
+	//
+	// Deprecated: Use Client.Headers instead.
 	Headers http.Header
+	// Cookies is the common cookies of the requests fired from the clients
+	// using the Transport, which are sent after Client.Cookies.
+	//
+	// Deprecated: Use Client.Cookies instead.
 	Cookies []*http.Cookie
 
 	idleMu       sync.Mutex
@@ -122,10 +132,44 @@ type Transport struct {
 	altSvcJar        altsvc.Jar
 	pendingAltSvcs   map[string]*pendingAltSvc
 	pendingAltSvcsMu sync.Mutex
+	altSvc           bool
 
 	// Force using specific http version
 	forceHttpVersion httpVersion
 
+	// proxyUser is the credentials for proxies without userinfo in url
+	proxyUser *url.Userinfo
+
+	hostRules []*HostRule
+
+	// onConnOpen and onConnClose are the hooks of the connection lifecycle.
+	onConnOpen  func(info ConnectionInfo)
+	onConnClose func(info ConnectionInfo)
+
+	// dnsCache memoizes the lookups of hosts in dial if it's not nil.
+	dnsCache *dnsCache
+	// resolver resolves the hosts in dial if it's not nil.
+	resolver Resolver
+	// dnsTimeout limits the DNS lookups in dial separately from the
+	// DialTimeout if it's positive.
+	dnsTimeout time.Duration
+	// hostMapping maps the "host:port" or "host" to the address to dial.
+	hostMapping map[string]string
+	// fallbackDelay and ipPreference control the Happy Eyeballs dialing.
+	fallbackDelay time.Duration
+	ipPreference  IPPreference
+	// stats collects the metrics of the connections if it's not nil.
+	stats *transportStats
+	// fingerprintNoise randomizes the fingerprint if it's not nil.
+	fingerprintNoise *fingerprintNoise
+	// replay serves the requests with the recorded responses if it's not nil.
+	replay *replayTransport
+	// mock serves the requests instead of the network if it's not nil.
+	mock http.RoundTripper
+	// dumpMu guards the start, stop and flush of the Dump, and the dump
+	// file of the clients using the Transport.
+	dumpMu sync.Mutex
+
 	transport.Options
 
 	t2 *h2internal.Transport // non-nil if http2 wired up
@@ -264,6 +308,14 @@ func (t *Transport) SetMaxIdleConns(max int) *Transport {
 	return t
 }
 
+// SetMaxIdleConnsPerHost set the MaxIdleConnsPerHost, which controls the
+// maximum idle (keep-alive) connections to keep per-host. If zero,
+// defaultMaxIdleConnsPerHost (2) is used, and negative disables it.
+func (t *Transport) SetMaxIdleConnsPerHost(max int) *Transport {
+	t.MaxIdleConnsPerHost = max
+	return t
+}
+
 // SetMaxConnsPerHost set the MaxConnsPerHost, optionally limits the
 // total number of connections per host, including connections in the
 // dialing, active, and idle states. On limit violation, dials will block.
@@ -302,6 +354,24 @@ func (t *Transport) SetResponseHeaderTimeout(timeout time.Duration) *Transport {
 	return t
 }
 
+// SetDialTimeout set the DialTimeout, which specifies the maximum amount
+// of time to wait for a dial to complete.
+//
+// Zero means no timeout.
+func (t *Transport) SetDialTimeout(timeout time.Duration) *Transport {
+	t.DialTimeout = timeout
+	return t
+}
+
+// SetResponseBodyReadIdleTimeout set the ResponseBodyReadIdleTimeout, if non-zero,
+// specifies the maximum amount of time to wait for the next chunk of data while
+// reading the response body, ErrResponseBodyReadTimeout will be returned when
+// reading the body if the timeout is exceeded.
+func (t *Transport) SetResponseBodyReadIdleTimeout(timeout time.Duration) *Transport {
+	t.ResponseBodyReadIdleTimeout = timeout
+	return t
+}
+
 // SetExpectContinueTimeout set the ExpectContinueTimeout, if non-zero, specifies
 // the amount of time to wait for a server's first response headers after fully
 // writing the request headers if the request has an "Expect: 100-continue" header.
@@ -383,6 +453,29 @@ func (t *Transport) SetHTTP2StrictMaxConcurrentStreams(strict bool) *Transport {
 	return t
 }
 
+// SetHTTP2MaxConcurrentStreams set the max number of concurrent streams
+// opened on a http2 connection, which caps the server's
+// SETTINGS_MAX_CONCURRENT_STREAMS. If zero, only the server's limit is
+// respected.
+func (t *Transport) SetHTTP2MaxConcurrentStreams(max uint32) *Transport {
+	t.t2.MaxConcurrentStreams = max
+	return t
+}
+
+// SetHTTP2InitialWindowSize set the http2 SETTINGS_INITIAL_WINDOW_SIZE
+// to send in the initial settings frame, which is how many bytes the
+// server can send on a stream before waiting for a WINDOW_UPDATE, and
+// how many bytes are buffered per stream. If zero, 4MB is used. Use
+// SetHTTP2ConnectionFlow to set the connection-level window. The size
+// greater than 2^31-1 is invalid and ignored.
+func (t *Transport) SetHTTP2InitialWindowSize(size uint32) *Transport {
+	if size > h2internal.MaxInitialWindowSize {
+		return t
+	}
+	t.t2.InitialWindowSize = size
+	return t
+}
+
 // SetHTTP2ReadIdleTimeout set the http2 ReadIdleTimeout,
 // which is the timeout after which a health check using ping
 // frame will be carried out if no frame is received on the connection.
@@ -458,8 +551,9 @@ func (t *Transport) SetDebug(debugf func(format string, v ...interface{})) *Tran
 // is aborted with the provided error.
 //
 // The proxy type is determined by the URL scheme. "http",
-// "https", and "socks5" are supported. If the scheme is empty,
-// "http" is assumed.
+// "https", "socks5" and "socks5h" are supported. If the scheme is empty,
+// "http" is assumed. The host name of the target is resolved by the
+// socks5 proxy for both "socks5" and "socks5h", just like net/http.
 //
 // If Proxy is nil or returns a nil *URL, no proxy is used.
 func (t *Transport) SetProxy(proxy func(*http.Request) (*url.URL, error)) *Transport {
@@ -467,6 +561,16 @@ func (t *Transport) SetProxy(proxy func(*http.Request) (*url.URL, error)) *Trans
 	return t
 }
 
+// SetProxyBasicAuth set the username and password for proxies, which is
+// used if the proxy url has no userinfo. The credentials are sent in the
+// Proxy-Authorization header with the Basic scheme (or as the socks5
+// username and password), and the CONNECT request is re-issued with the
+// Digest scheme if the proxy responds with a 407 Digest challenge.
+func (t *Transport) SetProxyBasicAuth(username, password string) *Transport {
+	t.proxyUser = url.UserPassword(username, password)
+	return t
+}
+
 // SetDial set the custom DialContext function, only valid for HTTP1 and HTTP2, which specifies the
 // dial function for creating unencrypted TCP connections.
 // If it is nil, then the transport dials using package net.
@@ -557,11 +661,60 @@ func (t *Transport) DisableForceHttpVersion() *Transport {
 }
 
 func (t *Transport) DisableHTTP3() {
-	t.altSvcJar = nil
-	t.pendingAltSvcs = nil
+	if !t.altSvc {
+		t.altSvcJar = nil
+		t.pendingAltSvcs = nil
+	}
 	t.t3 = nil
 }
 
+type forceHttpVersionKey struct{}
+
+// withForceHttpVersion returns a copy of ctx which forces the http version
+// of the request, which overrides the one of the transport.
+func withForceHttpVersion(ctx context.Context, version httpVersion) context.Context {
+	return context.WithValue(ctx, forceHttpVersionKey{}, version)
+}
+
+// getForceHttpVersion returns the forced http version of the request with
+// the ctx.
+func (t *Transport) getForceHttpVersion(ctx context.Context) httpVersion {
+	if v, ok := ctx.Value(forceHttpVersionKey{}).(httpVersion); ok {
+		return v
+	}
+	return t.forceHttpVersion
+}
+
+// EnableAltSvc enables the Alt-Svc (RFC 7838) support, the alternative
+// services advertised by the Alt-Svc header are cached per origin until
+// they expire (or "clear" is received), and subsequent requests are routed
+// to them. The h2 alternative services on the same host (e.g. h2=":8443")
+// are supported, as well as h3 if HTTP3 is enabled. If the alternative
+// service fails, it's removed from the cache and the request falls back to
+// the origin if the body can be rewound. It's enabled automatically for
+// h3 by EnableHTTP3.
+func (t *Transport) EnableAltSvc() *Transport {
+	t.altSvc = true
+	if t.altSvcJar == nil {
+		t.altSvcJar = altsvc.NewAltSvcJar()
+	}
+	if t.pendingAltSvcs == nil {
+		t.pendingAltSvcs = make(map[string]*pendingAltSvc)
+	}
+	return t
+}
+
+// DisableAltSvc disables the Alt-Svc support (disabled by default unless
+// HTTP3 is enabled).
+func (t *Transport) DisableAltSvc() *Transport {
+	t.altSvc = false
+	if t.t3 == nil {
+		t.altSvcJar = nil
+		t.pendingAltSvcs = nil
+	}
+	return t
+}
+
 func (t *Transport) EnableHTTP3() {
 	if t.t3 != nil {
 		return
@@ -597,7 +750,8 @@ func (t *Transport) EnableHTTP3() {
 		t.pendingAltSvcs = make(map[string]*pendingAltSvc)
 	}
 	t3 := &http3.RoundTripper{
-		Options: &t.Options,
+		Options:          &t.Options,
+		TLSConfigForHost: t.hostRuleTLSConfig,
 	}
 	t.t3 = t3
 }
@@ -609,19 +763,24 @@ const wrapResponseBodyKey wrapResponseBodyKeyType = iota
 type wrapResponseBodyFunc func(rc io.ReadCloser) io.ReadCloser
 
 func (t *Transport) handleResponseBody(res *http.Response, req *http.Request) {
+	if t.ResponseBodyReadIdleTimeout > 0 && res.Body != nil && res.Body != NoBody {
+		res.Body = &idleTimeoutReadCloser{ReadCloser: res.Body, timeout: t.ResponseBodyReadIdleTimeout}
+	}
 	if wrap, ok := req.Context().Value(wrapResponseBodyKey).(wrapResponseBodyFunc); ok {
 		t.wrapResponseBody(res, wrap)
 	}
-	t.autoDecodeResponseBody(res)
+	t.autoDecodeResponseBody(res, req)
 	dump.WrapResponseBodyIfNeeded(res, req, t.Dump)
 }
 
-var allowedProtocols = map[string]bool{
-	"h3": true,
-}
-
 func (t *Transport) handleAltSvc(req *http.Request, value string) {
 	addr := netutil.AuthorityKey(req.URL)
+	if strings.TrimSpace(value) == "clear" {
+		if as := t.altSvcJar.GetAltSvc(addr); as != nil {
+			t.altSvcJar.SetAltSvc(addr, &altsvc.AltSvc{Protocol: as.Protocol})
+		}
+		return
+	}
 	as := t.altSvcJar.GetAltSvc(addr)
 	if as != nil {
 		return
@@ -642,8 +801,20 @@ func (t *Transport) handleAltSvc(req *http.Request, value string) {
 	}
 	var entries []*altsvc.AltSvc
 	for _, a := range ass {
-		if allowedProtocols[a.Protocol] {
-			entries = append(entries, a)
+		switch a.Protocol {
+		case "h3":
+			if t.t3 != nil {
+				entries = append(entries, a)
+			}
+		case "h2":
+			// the certificate of the alternative service is verified
+			// against its own host, so only the ones on the same host
+			// are allowed.
+			if len(entries) == 0 && t.t2 != nil && req.URL.Scheme == "https" &&
+				(a.Host == "" || a.Host == req.URL.Hostname()) && t.forceHttpVersion != h1 {
+				t.altSvcJar.SetAltSvc(addr, a)
+				return
+			}
 		}
 	}
 	if len(entries) > 0 {
@@ -689,8 +860,48 @@ func (t *Transport) wrapResponseBody(res *http.Response, wrap wrapResponseBodyFu
 	}
 }
 
-func (t *Transport) autoDecodeResponseBody(res *http.Response) {
-	if t.disableAutoDecode || res.Header.Get("Accept-Encoding") != "" {
+type responseCharsetKey struct{}
+
+// disableAutoDecodeKey is the context key which disables the auto-decode of
+// the response body of the request, e.g. the requests of the reverse proxy
+// whose body is forwarded as is.
+type disableAutoDecodeKey struct{}
+
+// withResponseCharset returns a copy of ctx which forces the charset to
+// decode the response body of the request.
+func withResponseCharset(ctx context.Context, charset string) context.Context {
+	return context.WithValue(ctx, responseCharsetKey{}, charset)
+}
+
+// lookupCharset returns the encoding of the charset, nil if it's utf-8
+// which needs no decoding, and an error if it's not supported.
+func lookupCharset(charset string) (encoding.Encoding, error) {
+	charset = strings.ToLower(charset)
+	if strings.Contains(charset, "utf-8") || strings.Contains(charset, "utf8") { // do not decode utf-8
+		return nil, nil
+	}
+	enc, _ := htmlcharset.Lookup(charset)
+	if enc == nil {
+		var err error
+		enc, err = ianaindex.MIME.Encoding(charset)
+		if err != nil || enc == nil {
+			return nil, fmt.Errorf("unsupported charset %q", charset)
+		}
+	}
+	return enc, nil
+}
+
+func (t *Transport) autoDecodeResponseBody(res *http.Response, req *http.Request) {
+	if charset, ok := req.Context().Value(responseCharsetKey{}).(string); ok {
+		if enc, _ := lookupCharset(charset); enc != nil && res.Body != nil && res.Body != NoBody {
+			if t.Debugf != nil {
+				t.Debugf("charset %s is forced, decode to utf-8", charset)
+			}
+			res.Body = &decodeReaderCloser{res.Body, enc.NewDecoder().Reader(res.Body)}
+		}
+		return
+	}
+	if t.disableAutoDecode || res.Header.Get("Accept-Encoding") != "" || req.Context().Value(disableAutoDecodeKey{}) != nil {
 		return
 	}
 	contentType := res.Header.Get("Content-Type")
@@ -709,19 +920,15 @@ func (t *Transport) autoDecodeResponseBody(res *http.Response) {
 			t.Debugf("failed to parse content type %q: %v", contentType, err)
 		}
 	} else if charset, ok := params["charset"]; ok {
-		charset = strings.ToLower(charset)
-		if strings.Contains(charset, "utf-8") || strings.Contains(charset, "utf8") { // do not decode utf-8
+		enc, err := lookupCharset(charset)
+		if err != nil {
+			if t.Debugf != nil {
+				t.Debugf("ignore charset %s which is detected in Content-Type but not supported", charset)
+			}
 			return
 		}
-		enc, _ := htmlcharset.Lookup(charset)
 		if enc == nil {
-			enc, err = ianaindex.MIME.Encoding(charset)
-			if err != nil || enc == nil {
-				if t.Debugf != nil {
-					t.Debugf("ignore charset %s which is detected in Content-Type but not supported", charset)
-				}
-				return
-			}
+			return
 		}
 		if t.Debugf != nil {
 			t.Debugf("charset %s detected in Content-Type, auto-decode to utf-8", charset)
@@ -763,7 +970,23 @@ func (t *Transport) Clone() *Transport {
 		disableAutoDecode:     t.disableAutoDecode,
 		autoDecodeContentType: t.autoDecodeContentType,
 		forceHttpVersion:      t.forceHttpVersion,
+		proxyUser:             t.proxyUser,
 		httpRoundTripWrappers: t.httpRoundTripWrappers,
+		onConnOpen:            t.onConnOpen,
+		onConnClose:           t.onConnClose,
+		dnsCache:              t.dnsCache,
+		resolver:              t.resolver,
+		dnsTimeout:            t.dnsTimeout,
+		hostMapping:           t.hostMapping,
+		fallbackDelay:         t.fallbackDelay,
+		ipPreference:          t.ipPreference,
+		stats:                 t.stats.clone(),
+		fingerprintNoise:      t.fingerprintNoise.clone(),
+		replay:                t.replay,
+		mock:                  t.mock,
+	}
+	for _, rule := range t.hostRules {
+		tt.hostRules = append(tt.hostRules, rule.clone())
 	}
 	if len(tt.httpRoundTripWrappers) > 0 { // clone transport middleware
 		fn := func(req *http.Request) (*http.Response, error) {
@@ -779,6 +1002,8 @@ func (t *Transport) Clone() *Transport {
 			Options:                    &tt.Options,
 			MaxHeaderListSize:          t.t2.MaxHeaderListSize,
 			StrictMaxConcurrentStreams: t.t2.StrictMaxConcurrentStreams,
+			MaxConcurrentStreams:       t.t2.MaxConcurrentStreams,
+			InitialWindowSize:          t.t2.InitialWindowSize,
 			ReadIdleTimeout:            t.t2.ReadIdleTimeout,
 			PingTimeout:                t.t2.PingTimeout,
 			WriteByteTimeout:           t.t2.WriteByteTimeout,
@@ -791,11 +1016,20 @@ func (t *Transport) Clone() *Transport {
 	if t.t3 != nil {
 		tt.EnableHTTP3()
 	}
+	if t.altSvc {
+		tt.EnableAltSvc()
+	}
 	return tt
 }
 
 // EnableDump enables the dump for all requests with specified dump options.
 func (t *Transport) EnableDump(opt *DumpOptions) {
+	t.dumpMu.Lock()
+	t.enableDump(opt)
+	t.dumpMu.Unlock()
+}
+
+func (t *Transport) enableDump(opt *DumpOptions) {
 	dump := newDumper(opt)
 	t.Dump = dump
 	go dump.Start()
@@ -803,12 +1037,24 @@ func (t *Transport) EnableDump(opt *DumpOptions) {
 
 // DisableDump disables the dump.
 func (t *Transport) DisableDump() {
+	t.dumpMu.Lock()
+	t.disableDump()
+	t.dumpMu.Unlock()
+}
+
+func (t *Transport) disableDump() {
 	if t.Dump != nil {
 		t.Dump.Stop()
 		t.Dump = nil
 	}
 }
 
+func (t *Transport) flushDump() {
+	if t.Dump != nil {
+		t.Dump.Flush()
+	}
+}
+
 func (t *Transport) hasCustomTLSDialer() bool {
 	return t.DialTLSContext != nil
 }
@@ -844,11 +1090,16 @@ func (tr *transportRequest) setError(err error) {
 func (t *Transport) roundTripAltSvc(req *http.Request, as *altsvc.AltSvc) (resp *http.Response, err error) {
 	r := req.Clone(req.Context())
 	r.URL = altsvcutil.ConvertURL(as, req.URL)
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
 	switch as.Protocol {
 	case "h3":
 		resp, err = t.t3.RoundTrip(r)
 	case "h2":
-		resp, err = t.t2.RoundTrip(r)
+		// negotiated on the connection dialed as usual, so that the proxy
+		// and the HostRule are honored.
+		resp, err = t.roundTripConn(r, "")
 	default:
 		// impossible!
 		panic(fmt.Sprintf("unknown protocol %q", as.Protocol))
@@ -856,54 +1107,120 @@ func (t *Transport) roundTripAltSvc(req *http.Request, as *altsvc.AltSvc) (resp
 	return
 }
 
-func (t *Transport) checkAltSvc(req *http.Request) (resp *http.Response, err error) {
+// checkAltSvc sends the request to the alternative service of the origin
+// if any. If the alternative service fails, it's removed from the cache,
+// and the request with the rewound body is returned to be sent to the
+// origin.
+func (t *Transport) checkAltSvc(req *http.Request) (*http.Request, *http.Response, error) {
 	if t.altSvcJar == nil {
-		return
+		return req, nil, nil
 	}
 	addr := netutil.AuthorityKey(req.URL)
+	t.pendingAltSvcsMu.Lock()
 	pas, ok := t.pendingAltSvcs[addr]
+	t.pendingAltSvcsMu.Unlock()
 	if ok && pas.Transport != nil {
 		pas.Mu.Lock()
+		defer pas.Mu.Unlock()
 		if pas.Transport != nil {
 			pas.LastTime = time.Now()
-			r := req.Clone(req.Context())
-			r.URL = altsvcutil.ConvertURL(pas.Entries[pas.CurrentIndex], req.URL)
-			resp, err = pas.Transport.RoundTrip(r)
+			r := setupRewindBody(req)
+			rr := r.Clone(r.Context())
+			rr.URL = altsvcutil.ConvertURL(pas.Entries[pas.CurrentIndex], req.URL)
+			resp, err := pas.Transport.RoundTrip(rr)
 			if err != nil {
 				pas.Transport = nil
 				if pas.CurrentIndex+1 < len(pas.Entries) {
 					pas.CurrentIndex++
 					go t.handlePendingAltSvc(req.URL, pas)
 				}
-			} else {
-				t.altSvcJar.SetAltSvc(addr, pas.Entries[pas.CurrentIndex])
-				delete(t.pendingAltSvcs, addr)
+				return t.fallbackAltSvc(r, err)
 			}
+			t.altSvcJar.SetAltSvc(addr, pas.Entries[pas.CurrentIndex])
+			t.pendingAltSvcsMu.Lock()
+			delete(t.pendingAltSvcs, addr)
+			t.pendingAltSvcsMu.Unlock()
+			return req, resp, nil
 		}
-		pas.Mu.Unlock()
-		return
+		return req, nil, nil
 	}
 	if as := t.altSvcJar.GetAltSvc(addr); as != nil {
-		return t.roundTripAltSvc(req, as)
+		r := setupRewindBody(req)
+		resp, err := t.roundTripAltSvc(r, as)
+		if err != nil {
+			// mark the alternative service as expired
+			t.altSvcJar.SetAltSvc(addr, &altsvc.AltSvc{Protocol: as.Protocol})
+			return t.fallbackAltSvc(r, err)
+		}
+		return req, resp, nil
 	}
-	return
+	return req, nil, nil
+}
+
+// fallbackAltSvc returns the request with the rewound body to be sent to
+// the origin after the alternative service failed with err.
+func (t *Transport) fallbackAltSvc(req *http.Request, err error) (*http.Request, *http.Response, error) {
+	if req.Context().Err() != nil {
+		return nil, nil, err
+	}
+	if debugf := common.Debugf(req.Context(), t.Debugf); debugf != nil {
+		debugf("alternative service of %s failed, fall back to the origin: %s", req.URL.Host, err.Error())
+	}
+	req, rerr := rewindBody(req)
+	if rerr != nil {
+		return nil, nil, err
+	}
+	return req, nil, nil
 }
 
 // roundTrip implements a http.RoundTripper over HTTP.
 func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error) {
+	if t.mock != nil {
+		return t.roundTripMock(req)
+	}
+	if t.replay != nil {
+		return t.replay.RoundTrip(req)
+	}
+	req = t.withConnRequestCounter(req)
+	if t.fingerprintNoise != nil {
+		req = t.fingerprintNoise.apply(req)
+	}
 	ctx := req.Context()
-	trace := httptrace.ContextClientTrace(ctx)
 
 	if req.URL == nil {
 		closeBody(req)
 		return nil, errors.New("http: nil Request.URL")
 	}
 
-	resp, err = t.checkAltSvc(req)
+	if len(t.hostRules) > 0 {
+		var cancel context.CancelFunc
+		req, cancel = t.applyHostRule(req)
+		if cancel != nil {
+			defer func() {
+				if err != nil || resp == nil || resp.Body == nil {
+					cancel()
+					return
+				}
+				resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			}()
+		}
+	}
+
+	forceHttpVersion := t.getForceHttpVersion(ctx)
+	if forceHttpVersion == t.forceHttpVersion {
+		req, resp, err = t.checkAltSvc(req)
+	}
 	if err != nil || resp != nil {
 		return
 	}
+	return t.roundTripConn(req, forceHttpVersion)
+}
 
+// roundTripConn sends the request over the connection of the transport,
+// which is dialed through the proxy and with the HostRule of the host.
+func (t *Transport) roundTripConn(req *http.Request, forceHttpVersion httpVersion) (resp *http.Response, err error) {
+	ctx := req.Context()
+	trace := httptrace.ContextClientTrace(ctx)
 	scheme := req.URL.Scheme
 	isHTTP := scheme == "http" || scheme == "https"
 
@@ -930,9 +1247,13 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 		req.Header = make(http.Header)
 	}
 
-	if t.forceHttpVersion != "" {
-		switch t.forceHttpVersion {
+	if forceHttpVersion != "" {
+		switch forceHttpVersion {
 		case h3:
+			if t.t3 == nil {
+				closeBody(req)
+				return nil, errors.New("req: http3 is not enabled")
+			}
 			return t.t3.RoundTrip(req)
 		case h2:
 			return t.t2.RoundTrip(req)
@@ -943,7 +1264,7 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 	cancelKey := cancelKey{origReq}
 	req = setupRewindBody(req)
 
-	if scheme == "https" && t.forceHttpVersion != h1 {
+	if scheme == "https" && forceHttpVersion != h1 {
 		resp, err := t.t2.RoundTripOnlyCachedConn(req)
 		if err != h2internal.ErrNoCachedConn {
 			return resp, err
@@ -1005,7 +1326,7 @@ func (t *Transport) roundTrip(req *http.Request) (resp *http.Response, err error
 		}
 
 		var resp *http.Response
-		if t.forceHttpVersion != h1 && pconn.alt != nil {
+		if forceHttpVersion != h1 && pconn.alt != nil {
 			// HTTP/2 path.
 			t.setReqCanceler(cancelKey, nil) // not cancelable with CancelRequest
 			resp, err = pconn.alt.RoundTrip(req)
@@ -1207,13 +1528,155 @@ func resetProxyConfig() {
 func (t *Transport) connectMethodForRequest(treq *transportRequest) (cm connectMethod, err error) {
 	cm.targetScheme = treq.URL.Scheme
 	cm.targetAddr = canonicalAddr(treq.URL)
-	if t.Proxy != nil {
+	if rule := t.matchHostRule(treq.URL.Host); rule != nil && rule.proxy != nil {
+		cm.proxyURL, err = rule.proxy(treq.Request)
+	} else if t.Proxy != nil {
 		cm.proxyURL, err = t.Proxy(treq.Request)
 	}
-	cm.onlyH1 = t.forceHttpVersion == h1 || requestRequiresHTTP1(treq.Request)
+	if cm.proxyURL != nil && cm.proxyURL.User == nil && t.proxyUser != nil {
+		u := *cm.proxyURL
+		u.User = t.proxyUser
+		cm.proxyURL = &u
+	}
+	cm.onlyH1 = t.getForceHttpVersion(treq.Context()) == h1 || requestRequiresHTTP1(treq.Request)
 	return cm, err
 }
 
+// proxyConnect sends the CONNECT request to the proxy, which is re-issued
+// once with the Digest credentials on the same connection if the proxy
+// responds with a 407 Digest challenge. The exchange is dumped if the
+// request header or response header dump is enabled.
+func (t *Transport) proxyConnect(ctx context.Context, conn net.Conn, cm connectMethod) error {
+	var hdr http.Header
+	if t.GetProxyConnectHeader != nil {
+		var err error
+		hdr, err = t.GetProxyConnectHeader(ctx, cm.proxyURL, cm.targetAddr)
+		if err != nil {
+			return err
+		}
+	} else {
+		hdr = t.ProxyConnectHeader
+	}
+	if hdr == nil {
+		hdr = make(http.Header)
+	}
+	if pa := cm.proxyAuth(); pa != "" {
+		hdr = hdr.Clone()
+		hdr.Set("Proxy-Authorization", pa)
+	}
+
+	// If there's no done channel (no deadline or cancellation
+	// from the caller possible), at least set some (long)
+	// timeout here. This will make sure we don't block forever
+	// and leak a goroutine if the connection stops replying
+	// after the TCP connect.
+	connectCtx := ctx
+	if ctx.Done() == nil {
+		newCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+		defer cancel()
+		connectCtx = newCtx
+	}
+
+	// Okay to use the buffered reader here, because TLS server will not
+	// speak until spoken to, and the proxy will not speak until the
+	// CONNECT request is re-issued.
+	br := bufio.NewReader(conn)
+	dumps := dump.GetDumpers(ctx, t.Dump)
+	for attempt := 0; ; attempt++ {
+		connectReq := &http.Request{
+			Method: "CONNECT",
+			URL:    &url.URL{Opaque: cm.targetAddr},
+			Host:   cm.targetAddr,
+			Header: hdr,
+		}
+		resp, err := writeProxyConnect(connectCtx, conn, br, connectReq, dumps)
+		if err != nil {
+			return err
+		}
+
+		if t.OnProxyConnectResponse != nil {
+			err = t.OnProxyConnectResponse(ctx, cm.proxyURL, connectReq, resp)
+			if err != nil {
+				return err
+			}
+		}
+
+		if resp.StatusCode == http.StatusProxyAuthRequired && attempt == 0 && !resp.Close && cm.proxyURL.User != nil {
+			chal := resp.Header.Get("Proxy-Authenticate")
+			if len(chal) > 7 && strings.EqualFold(chal[:7], "digest ") {
+				password, _ := cm.proxyURL.User.Password()
+				auth, err := digestAuthorization(chal, cm.targetAddr, "CONNECT", cm.proxyURL.User.Username(), password)
+				if err != nil {
+					return err
+				}
+				// drain the body so that the connection can be reused
+				if _, err = io.Copy(io.Discard, resp.Body); err != nil {
+					return err
+				}
+				resp.Body.Close()
+				hdr = hdr.Clone()
+				hdr.Set("Proxy-Authorization", auth)
+				continue
+			}
+		}
+
+		if resp.StatusCode != 200 {
+			_, text, ok := util.CutString(resp.Status, " ")
+			if !ok {
+				return errors.New("unknown status code")
+			}
+			return errors.New(text)
+		}
+		return nil
+	}
+}
+
+// writeProxyConnect writes the CONNECT request and reads the response.
+func writeProxyConnect(ctx context.Context, conn net.Conn, br *bufio.Reader, connectReq *http.Request, dumps []*dump.Dumper) (resp *http.Response, err error) {
+	didReadResponse := make(chan struct{}) // closed after CONNECT write+read is done or fails
+	go func() {
+		defer close(didReadResponse)
+		var w io.Writer = conn
+		for _, d := range dumps {
+			if d.RequestHeader() {
+				w = d.WrapRequestHeaderWriter(w)
+			}
+		}
+		err = connectReq.Write(w)
+		if err != nil {
+			return
+		}
+		resp, err = http.ReadResponse(br, connectReq)
+		if err != nil {
+			return
+		}
+		for _, d := range dumps {
+			if d.ResponseHeader() {
+				d.DumpResponseHeader(dumpResponseHeader(resp))
+			}
+		}
+	}()
+	select {
+	case <-ctx.Done():
+		conn.Close()
+		<-didReadResponse
+		return nil, ctx.Err()
+	case <-didReadResponse:
+		// resp or err now set
+	}
+	return
+}
+
+// dumpResponseHeader returns the status line and header of the response
+// in wire format.
+func dumpResponseHeader(resp *http.Response) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(&b)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
 // proxyAuth returns the Proxy-Authorization header to set
 // on requests, if applicable.
 func (cm *connectMethod) proxyAuth() string {
@@ -1539,14 +2002,39 @@ func (t *Transport) replaceReqCanceler(key cancelKey, fn func(error)) bool {
 var zeroDialer net.Dialer
 
 func (t *Transport) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	addr = t.mapHost(addr)
+	network = t.ipNetwork(network)
+	resolve := t.dnsCache != nil || t.resolver != nil || t.dnsTimeout > 0 || t.ipPreference == IPPreferenceIPv4 || t.ipPreference == IPPreferenceIPv6
+	if t.DialTimeout > 0 && (t.dnsTimeout <= 0 || t.DialContext != nil) {
+		// with the DNS lookup timeout, the DialTimeout is applied after the
+		// lookup in dialResolved.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.DialTimeout)
+		defer cancel()
+	}
 	if t.DialContext != nil {
 		c, err := t.DialContext(ctx, network, addr)
 		if c == nil && err == nil {
 			err = errors.New("net/http: Transport.DialContext hook returned (nil, nil)")
 		}
-		return c, err
+		if err != nil {
+			t.countDialFailure()
+			return nil, err
+		}
+		return t.trackConn(c, network, addr), nil
 	}
-	return zeroDialer.DialContext(ctx, network, addr)
+	var c net.Conn
+	var err error
+	if resolve {
+		c, err = t.dialResolved(ctx, network, addr)
+	} else {
+		c, err = t.netDialer().DialContext(ctx, network, addr)
+	}
+	if err != nil {
+		t.countDialFailure()
+		return nil, err
+	}
+	return t.trackConn(c, network, addr), nil
 }
 
 // A wantConn records state about a wanted connection
@@ -1698,6 +2186,11 @@ func (t *Transport) customDialTLS(ctx context.Context, network, addr string) (co
 	if conn == nil && err == nil {
 		err = errors.New("net/http: Transport.DialTLS or DialTLSContext returned (nil, nil)")
 	}
+	if err == nil {
+		conn = t.trackConn(conn, network, addr)
+	} else {
+		t.countDialFailure()
+	}
 	return
 }
 
@@ -1900,6 +2393,9 @@ func (pc *persistConn) addTLS(ctx context.Context, name string, trace *httptrace
 	if cfg.ServerName == "" {
 		cfg.ServerName = name
 	}
+	if rule := pc.t.matchHostRule(name); rule != nil {
+		rule.tlsConfig(cfg)
+	}
 	if pc.cacheKey.onlyH1 {
 		cfg.NextProtos = nil
 	}
@@ -1940,7 +2436,7 @@ func (pc *persistConn) addTLS(ctx context.Context, name string, trace *httptrace
 	}
 	pc.tlsState = &cs
 	pc.conn = tlsConn
-	if !forProxy && pc.t.forceHttpVersion == h2 && cs.NegotiatedProtocol != h2internal.NextProtoTLS {
+	if !forProxy && pc.t.getForceHttpVersion(ctx) == h2 && cs.NegotiatedProtocol != h2internal.NextProtoTLS {
 		return newHttp2NotSupportedError(cs.NegotiatedProtocol)
 	}
 	return nil
@@ -2032,7 +2528,7 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 				trace.TLSHandshakeDone(cs, nil)
 			}
 			pconn.tlsState = &cs
-			if cm.proxyURL == nil && pconn.t.forceHttpVersion == h2 && cs.NegotiatedProtocol != h2internal.NextProtoTLS {
+			if cm.proxyURL == nil && pconn.t.getForceHttpVersion(ctx) == h2 && cs.NegotiatedProtocol != h2internal.NextProtoTLS {
 				return nil, newHttp2NotSupportedError(cs.NegotiatedProtocol)
 			}
 		}
@@ -2060,15 +2556,15 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		}
 	}
 
-	if t.Debugf != nil && cm.proxyURL != nil {
-		t.Debugf("connect %s via proxy %s", cm.targetAddr, cm.proxyURL.String())
+	if debugf := common.Debugf(ctx, t.Debugf); debugf != nil && cm.proxyURL != nil {
+		debugf("connect %s via proxy %s", cm.targetAddr, cm.proxyURL.Redacted())
 	}
 
 	// Proxy setup.
 	switch {
 	case cm.proxyURL == nil:
 		// Do nothing. Not using a proxy.
-	case cm.proxyURL.Scheme == "socks5":
+	case cm.proxyURL.Scheme == "socks5" || cm.proxyURL.Scheme == "socks5h":
 		conn := pconn.conn
 		d := socks.NewDialer("tcp", conn.RemoteAddr().String())
 		if u := cm.proxyURL.User; u != nil {
@@ -2094,90 +2590,10 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 			}
 		}
 	case cm.targetScheme == "https":
-		conn := pconn.conn
-		var hdr http.Header
-		if t.GetProxyConnectHeader != nil {
-			var err error
-			hdr, err = t.GetProxyConnectHeader(ctx, cm.proxyURL, cm.targetAddr)
-			if err != nil {
-				conn.Close()
-				return nil, err
-			}
-		} else {
-			hdr = t.ProxyConnectHeader
-		}
-		if hdr == nil {
-			hdr = make(http.Header)
-		}
-		if pa := cm.proxyAuth(); pa != "" {
-			hdr = hdr.Clone()
-			hdr.Set("Proxy-Authorization", pa)
-		}
-		connectReq := &http.Request{
-			Method: "CONNECT",
-			URL:    &url.URL{Opaque: cm.targetAddr},
-			Host:   cm.targetAddr,
-			Header: hdr,
-		}
-
-		// If there's no done channel (no deadline or cancellation
-		// from the caller possible), at least set some (long)
-		// timeout here. This will make sure we don't block forever
-		// and leak a goroutine if the connection stops replying
-		// after the TCP connect.
-		connectCtx := ctx
-		if ctx.Done() == nil {
-			newCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
-			defer cancel()
-			connectCtx = newCtx
-		}
-
-		didReadResponse := make(chan struct{}) // closed after CONNECT write+read is done or fails
-		var (
-			resp *http.Response
-			err  error // write or read error
-		)
-		// Write the CONNECT request & read the response.
-		go func() {
-			defer close(didReadResponse)
-			err = connectReq.Write(conn)
-			if err != nil {
-				return
-			}
-			// Okay to use and discard buffered reader here, because
-			// TLS server will not speak until spoken to.
-			br := bufio.NewReader(conn)
-			resp, err = http.ReadResponse(br, connectReq)
-		}()
-		select {
-		case <-connectCtx.Done():
-			conn.Close()
-			<-didReadResponse
-			return nil, connectCtx.Err()
-		case <-didReadResponse:
-			// resp or err now set
-		}
-		if err != nil {
-			conn.Close()
+		if err := t.proxyConnect(ctx, pconn.conn, cm); err != nil {
+			pconn.conn.Close()
 			return nil, err
 		}
-
-		if t.OnProxyConnectResponse != nil {
-			err = t.OnProxyConnectResponse(ctx, cm.proxyURL, connectReq, resp)
-			if err != nil {
-				conn.Close()
-				return nil, err
-			}
-		}
-
-		if resp.StatusCode != 200 {
-			_, text, ok := util.CutString(resp.Status, " ")
-			conn.Close()
-			if !ok {
-				return nil, errors.New("unknown status code")
-			}
-			return nil, errors.New(text)
-		}
 	}
 
 	if cm.proxyURL != nil && cm.targetScheme == "https" {
@@ -2193,7 +2609,7 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 		}
 	}
 
-	if s := pconn.tlsState; t.forceHttpVersion != h1 && s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
+	if s := pconn.tlsState; !cm.onlyH1 && s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
 		if s.NegotiatedProtocol == h2internal.NextProtoTLS {
 			if used, err := t.t2.AddConn(pconn.conn, cm.targetAddr); err != nil {
 				go pconn.conn.Close()
@@ -2284,7 +2700,7 @@ func (cm *connectMethod) key() connectMethodKey {
 	}
 }
 
-// scheme returns the first hop scheme: http, https, or socks5
+// scheme returns the first hop scheme: http, https, socks5 or socks5h
 func (cm *connectMethod) scheme() string {
 	if cm.proxyURL != nil {
 		return cm.proxyURL.Scheme
@@ -3295,6 +3711,43 @@ func (e *timeoutError) Is(err error) bool { return err == context.DeadlineExceed
 
 var errTimeout error = &timeoutError{"net/http: timeout awaiting response headers"}
 
+// ErrResponseBodyReadTimeout is returned when reading the response body if no
+// data is received within the ResponseBodyReadIdleTimeout.
+var ErrResponseBodyReadTimeout error = &timeoutError{"req: timeout awaiting response body data"}
+
+// idleTimeoutReadCloser closes the underlying body if no data is received
+// within the timeout, which unblocks the pending Read.
+type idleTimeoutReadCloser struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+func (r *idleTimeoutReadCloser) Read(p []byte) (n int, err error) {
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.timeout, func() {
+			r.timedOut.Store(true)
+			r.ReadCloser.Close()
+		})
+	} else {
+		r.timer.Reset(r.timeout)
+	}
+	n, err = r.ReadCloser.Read(p)
+	r.timer.Stop()
+	if err != nil && err != io.EOF && r.timedOut.Load() {
+		err = ErrResponseBodyReadTimeout
+	}
+	return
+}
+
+func (r *idleTimeoutReadCloser) Close() error {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	return r.ReadCloser.Close()
+}
+
 var errRequestCanceledConn = errors.New("net/http: request canceled while waiting for connection") // TODO: unify?
 
 func nop() {}
@@ -3312,8 +3765,8 @@ var (
 )
 
 func (pc *persistConn) roundTrip(req *transportRequest) (resp *http.Response, err error) {
-	if pc.t.Debugf != nil {
-		pc.t.Debugf("HTTP/1.1 %s %s", req.Method, req.URL.String())
+	if debugf := common.Debugf(req.Context(), pc.t.Debugf); debugf != nil {
+		debugf("HTTP/1.1 %s %s", req.Method, req.URL.Redacted())
 	}
 	testHookEnterRoundTrip()
 	if !pc.t.replaceReqCanceler(req.cancelKey, pc.cancelRequest) {
@@ -3503,9 +3956,10 @@ func (pc *persistConn) closeLocked(err error) {
 }
 
 var portMap = map[string]string{
-	"http":   "80",
-	"https":  "443",
-	"socks5": "1080",
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
 }
 
 func idnaASCIIFromURL(url *url.URL) string {
@@ -0,0 +1,50 @@
+// Package zaplog adapts the zap logger to the req.StructuredLogger.
+//
+//	client := req.C().SetLogger(zaplog.New(zapLogger))
+//
+// Note *zap.SugaredLogger implements req.StructuredLogger as well, it can be
+// passed to Client.SetLogger directly, New additionally skips the caller of
+// the adapter so that the caller annotation points to req.
+package zaplog
+
+import (
+	"github.com/imroc/req/v3"
+	"go.uber.org/zap"
+)
+
+// New creates a req.StructuredLogger which logs with l, the global zap
+// logger is used if l is nil.
+func New(l *zap.Logger) req.StructuredLogger {
+	if l == nil {
+		l = zap.L()
+	}
+	return &logger{s: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+type logger struct {
+	s *zap.SugaredLogger
+}
+
+func (l *logger) Errorf(format string, v ...interface{}) {
+	l.s.Errorf(format, v...)
+}
+
+func (l *logger) Warnf(format string, v ...interface{}) {
+	l.s.Warnf(format, v...)
+}
+
+func (l *logger) Debugf(format string, v ...interface{}) {
+	l.s.Debugf(format, v...)
+}
+
+func (l *logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.s.Errorw(msg, keysAndValues...)
+}
+
+func (l *logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.s.Warnw(msg, keysAndValues...)
+}
+
+func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.s.Debugw(msg, keysAndValues...)
+}
@@ -0,0 +1,42 @@
+package zaplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imroc/req/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	client := req.C().SetLogger(New(zap.New(core))).EnableDebugLog()
+	if _, err := client.R().Get(server.URL + "/tea"); err != nil {
+		t.Fatal(err)
+	}
+	entries := logs.FilterMessage("request completed").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 request log, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["method"] != http.MethodGet {
+		t.Errorf("unexpected method %v", fields["method"])
+	}
+	if fields["url"] != server.URL+"/tea" {
+		t.Errorf("unexpected url %v", fields["url"])
+	}
+	if fields["status"] != int64(http.StatusTeapot) {
+		t.Errorf("unexpected status %v", fields["status"])
+	}
+	if _, ok := fields["duration"]; !ok {
+		t.Error("missing duration")
+	}
+}